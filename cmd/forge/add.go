@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// generator describes one kind of boilerplate forge add can write
+type generator struct {
+	dir      string // default directory
+	suffix   string // appended to the type name
+	register string // how to register it in main.go
+}
+
+var generators = map[string]generator{
+	"command":    {"commands", "Command", "bot.RegisterCommand(%s.New%s())"},
+	"module":     {"modules", "Module", "bot.RegisterModule(%s.New%s())"},
+	"middleware": {"middleware", "Middleware", "bot.AddMiddleware(%s.New%s())"},
+}
+
+// generatedData fills the templates under templates/add
+type generatedData struct {
+	Package string
+	Type    string
+	Name    string // module and middleware name
+	Command string // command name
+}
+
+// runAdd generates a command, module or middleware and its test
+func runAdd(args []string) error {
+	flags := newFlagSet("add")
+	dir := flags.String("dir", "", "Directory to write to (default: commands, modules or middleware)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("expected a kind and a name")
+	}
+
+	kind, name := flags.Arg(0), flags.Arg(1)
+	gen, ok := generators[kind]
+	if !ok {
+		return fmt.Errorf("unknown kind %q; expected command, module or middleware", kind)
+	}
+	if *dir == "" {
+		*dir = gen.dir
+	}
+
+	data, err := generate(*dir, kind, name)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("📝 Register it in main.go:")
+	log.Printf("   "+gen.register, data.Package, data.Type)
+	return nil
+}
+
+// generate writes the source and test files for one kind of boilerplate
+func generate(dir, kind, name string) (*generatedData, error) {
+	gen := generators[kind]
+	if pascalCase(name) == "" {
+		return nil, fmt.Errorf("invalid name %q", name)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &generatedData{
+		Package: packageName(abs),
+		Type:    pascalCase(name),
+		Name:    pascalCase(name),
+		Command: kebabCase(name),
+	}
+	if !strings.HasSuffix(data.Type, gen.suffix) {
+		data.Type += gen.suffix
+	}
+
+	stem := snakeCase(name)
+	files := []struct{ template, path string }{
+		{"add/" + kind + ".go.tmpl", filepath.Join(dir, stem+".go")},
+		{"add/" + kind + "_test.go.tmpl", filepath.Join(dir, stem+"_test.go")},
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file.path); err == nil {
+			return nil, fmt.Errorf("%s already exists", file.path)
+		}
+	}
+	for _, file := range files {
+		content, err := render(file.template, data)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeNew(file.path, content); err != nil {
+			return nil, err
+		}
+		log.Printf("✅ Created %s", file.path)
+	}
+	return data, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"discord-bot-forge/web"
+)
+
+// defaultURL is where a bot's web interface listens by default
+const defaultURL = "http://localhost:8080"
+
+// apiTimeout bounds requests to a running bot
+const apiTimeout = 10 * time.Second
+
+// botURL returns the web interface to query: FORGE_URL or the default
+func botURL() string {
+	if url := os.Getenv("FORGE_URL"); url != "" {
+		return url
+	}
+	return defaultURL
+}
+
+// getJSON fetches an endpoint of a running bot's web API into v
+func getJSON(baseURL, path string, v interface{}) error {
+	client := &http.Client{Timeout: apiTimeout}
+	url := strings.TrimSuffix(baseURL, "/") + path
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("error reaching bot at %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding %s: %w", url, err)
+	}
+	return nil
+}
+
+// runStatus shows a running bot's status from its web API
+func runStatus(args []string) error {
+	flags := newFlagSet("status")
+	url := flags.String("url", botURL(), "Bot web interface (or FORGE_URL)")
+	asJSON := flags.Bool("json", false, "Print the status as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var status web.BotStatus
+	if err := getJSON(*url, "/api/status", &status); err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(status)
+	}
+
+	table := newTable()
+	fmt.Fprintf(table, "Version\t%s\n", status.Version)
+	fmt.Fprintf(table, "Uptime\t%s\n", status.Uptime)
+	fmt.Fprintf(table, "Commands\t%d\n", status.Commands)
+	fmt.Fprintf(table, "Modules\t%d\n", status.Modules)
+	fmt.Fprintf(table, "Middleware\t%d\n", status.Middleware)
+	fmt.Fprintf(table, "Messages seen\t%v\n", status.Stats["messages"])
+	fmt.Fprintf(table, "Commands run\t%v\n", status.Stats["commands_executed"])
+	fmt.Fprintf(table, "Intents\t%s\n", joinOrDash(status.Intents))
+	fmt.Fprintf(table, "Message queue\t%d pending, %d sent, %d retried, %d failed\n",
+		status.Queue.Pending, status.Queue.Sent, status.Queue.Retries, status.Queue.Failed)
+
+	fmt.Fprintf(table, "\nSHARD\tSTATE\tGUILDS\tLATENCY\tRECONNECTS\tSINCE\n")
+	for _, shard := range status.Shards {
+		fmt.Fprintf(table, "%d/%d\t%s\t%d\t%v\t%d\t%s\n", shard.ID, status.ShardCount, shard.State, shard.Guilds,
+			shard.Latency.Round(time.Millisecond), shard.Reconnects, shard.Since.Format(time.RFC3339))
+	}
+	return table.Flush()
+}
+
+// runLogs prints a running bot's recent log lines from its web API
+func runLogs(args []string) error {
+	flags := newFlagSet("logs")
+	url := flags.String("url", botURL(), "Bot web interface (or FORGE_URL)")
+	limit := flags.Int("n", 50, "Number of most recent entries to show; 0 shows all")
+	asJSON := flags.Bool("json", false, "Print the entries as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var entries []json.RawMessage
+	if err := getJSON(*url, "/api/logs", &entries); err != nil {
+		return err
+	}
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[len(entries)-*limit:]
+	}
+	if *asJSON {
+		return printJSON(entries)
+	}
+
+	for _, entry := range entries {
+		fmt.Println(formatLogEntry(entry))
+	}
+	return nil
+}
+
+// formatLogEntry prints a plain log line as is and a structured entry as
+// its time, level, subsystem and message followed by its fields
+func formatLogEntry(entry json.RawMessage) string {
+	var line string
+	if err := json.Unmarshal(entry, &line); err == nil {
+		return line
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(entry, &fields); err != nil {
+		return string(entry)
+	}
+
+	var parts []string
+	for _, key := range []string{"time", "level", "subsystem", "msg", "message"} {
+		if value, ok := fields[key]; ok {
+			text := fmt.Sprint(value)
+			if key == "subsystem" {
+				text += ":"
+			}
+			parts = append(parts, text)
+			delete(fields, key)
+		}
+	}
+
+	// Entries from the bot keep their fields in a nested object
+	if nested, ok := fields["fields"].(map[string]interface{}); ok {
+		delete(fields, "fields")
+		for key, value := range nested {
+			fields[key] = value
+		}
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, ok := fields[key].(string)
+		if !ok {
+			data, _ := json.Marshal(fields[key])
+			value = string(data)
+		}
+		parts = append(parts, key+"="+value)
+	}
+	return strings.Join(parts, " ")
+}
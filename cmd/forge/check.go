@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"discord-bot-forge/core"
+)
+
+// privilegedIntentFlags maps privileged intents to the application flags
+// that show they are enabled, for bots in more or fewer than 100 servers
+var privilegedIntentFlags = map[string]int{
+	"guild_presences": 1<<12 | 1<<13,
+	"guild_members":   1<<14 | 1<<15,
+	"message_content": 1<<18 | 1<<19,
+}
+
+// runCheck verifies the bot token with Discord and that the privileged
+// intents the bot identifies with are enabled for its application
+func runCheck(args []string) error {
+	flags := newFlagSet("check")
+	dir := flags.String("dir", ".", "Bot project directory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := enterProject(*dir); err != nil {
+		return err
+	}
+
+	config, err := core.LoadConfig(flags.Args())
+	if err != nil {
+		return err
+	}
+	session, err := newSession(config)
+	if err != nil {
+		return err
+	}
+
+	user, err := session.User("@me")
+	if err != nil {
+		if isUnauthorized(err) {
+			log.Println("❌ Discord rejected the bot token; reset it in the Discord Developer Portal")
+			return errReported
+		}
+		return fmt.Errorf("error verifying token: %w", err)
+	}
+	log.Printf("✅ Token is valid for %s (%s)", user.Username, user.ID)
+
+	app, err := session.Application("@me")
+	if err != nil {
+		return fmt.Errorf("error fetching application: %w", err)
+	}
+
+	definition, err := inspectBot(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	used := make(map[string]bool)
+	for _, name := range definition.Intents {
+		used[name] = true
+		enabled, privileged := privilegedIntentFlags[name]
+		switch {
+		case !privileged:
+		case app.Flags&enabled != 0:
+			log.Printf("✅ Privileged intent %s is enabled", name)
+		default:
+			log.Printf("❌ Privileged intent %s is used but not enabled in the Discord Developer Portal", name)
+			failed = true
+		}
+	}
+	for _, name := range definition.RequiredIntents {
+		if !used[name] {
+			log.Printf("⚠️ Intent %s is required by the bot but not in the configured intents", name)
+		}
+	}
+	log.Printf("📡 Gateway intents: %s", joinOrDash(definition.Intents))
+
+	if failed {
+		return errReported
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// runList describes the commands, modules and middleware a bot registers,
+// without connecting to Discord
+func runList(args []string) error {
+	flags := newFlagSet("list")
+	dir := flags.String("dir", ".", "Bot project directory")
+	asJSON := flags.Bool("json", false, "Print the bot definition as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := enterProject(*dir); err != nil {
+		return err
+	}
+
+	definition, err := inspectBot(flags.Args())
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(definition)
+	}
+
+	table := newTable()
+	fmt.Fprintf(table, "COMMAND\tCATEGORY\tSLASH\tCOOLDOWN\tPERMISSIONS\tDESCRIPTION\n")
+	for _, cmd := range definition.Commands {
+		slash := "-"
+		if cmd.Slash {
+			slash = "yes"
+		}
+		fmt.Fprintf(table, "%s%s\t%s\t%s\t%ds\t%s\t%s\n", definition.Prefix, cmd.Name, cmd.Category, slash, cmd.Cooldown,
+			joinOrDash(cmd.Permissions), cmd.Description)
+	}
+
+	fmt.Fprintf(table, "\nMODULE\tVERSION\tDEPENDS ON\n")
+	for _, module := range definition.Modules {
+		depends := module.Dependencies
+		for _, optional := range module.OptionalDependencies {
+			depends = append(depends, optional+" (optional)")
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\n", module.Name, module.Version, joinOrDash(depends))
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	middleware := "-"
+	if len(definition.Middleware) > 0 {
+		middleware = strings.Join(definition.Middleware, " → ")
+	}
+	fmt.Printf("\nMiddleware: %s\n", middleware)
+	fmt.Printf("Intents:    %s\n", joinOrDash(definition.Intents))
+	return nil
+}
@@ -0,0 +1,108 @@
+// Command forge scaffolds and runs DiscordBotForge bots.
+//
+//	forge new <bot>                              create a bot project
+//	forge add command|module|middleware <name>   generate boilerplate with a test
+//	forge run [-- bot flags]                     run the bot, rebuilding on changes
+//	forge validate | list | check                inspect a bot without running it
+//	forge replay <recording>                     replay recorded events into a bot
+//	forge slash diff|push                        sync slash commands with Discord
+//	forge status | logs                          query a running bot's web API
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// subcommand is one forge verb
+type subcommand struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) error
+}
+
+// errReported fails a command whose problems were already printed
+var errReported = errors.New("failed")
+
+// subcommands is filled in init because their usage refers back to it
+var subcommands []subcommand
+
+func init() {
+	subcommands = []subcommand{
+		{"new", "new [flags] <bot>", "Create a new bot project", runNew},
+		{"add", "add [flags] command|module|middleware <name>", "Generate a command, module or middleware with a test", runAdd},
+		{"run", "run [flags] [-- bot flags]", "Build and run the bot, restarting it when sources change", runRun},
+		{"validate", "validate [flags] [-- bot flags]", "Check the bot's configuration", runValidate},
+		{"list", "list [flags] [-- bot flags]", "List registered commands, modules and middleware without connecting", runList},
+		{"replay", "replay [flags] <recording> [-- bot flags]", "Replay a recording into the bot and print the calls it makes", runReplay},
+		{"slash", "slash [flags] diff|push [bot flags]", "Compare or register slash commands with Discord", runSlash},
+		{"check", "check [flags] [-- bot flags]", "Verify the bot token and privileged intents", runCheck},
+		{"status", "status [flags]", "Show a running bot's status from its web API", runStatus},
+		{"logs", "logs [flags]", "Show a running bot's recent logs from its web API", runLogs},
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage()
+		return
+	}
+
+	for _, sub := range subcommands {
+		if sub.name != name {
+			continue
+		}
+		if err := sub.run(os.Args[2:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			if errors.Is(err, errReported) {
+				os.Exit(1)
+			}
+			log.Printf("❌ %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	log.Printf("❌ Unknown command %q", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "🔥 forge - DiscordBotForge project tool")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Usage:")
+	for _, sub := range subcommands {
+		fmt.Fprintf(os.Stderr, "  forge %-48s %s\n", sub.usage, sub.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'forge <command> -h' for a command's flags.")
+}
+
+// newFlagSet creates a flag set whose usage names the subcommand
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("forge "+name, flag.ContinueOnError)
+	fs.Usage = func() {
+		for _, sub := range subcommands {
+			if sub.name == name {
+				fmt.Fprintf(fs.Output(), "Usage: forge %s\n\n%s.\n\n", sub.usage, sub.summary)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
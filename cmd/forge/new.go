@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// projectData fills the templates of a new project
+type projectData struct {
+	Name          string
+	Module        string
+	Framework     string
+	FrameworkPath string
+}
+
+// projectFiles maps templates under templates/new to project files
+var projectFiles = []struct {
+	template string
+	path     string
+}{
+	{"new/go.mod.tmpl", "go.mod"},
+	{"new/main.go.tmpl", "main.go"},
+	{"new/config.yaml.tmpl", "config.yaml"},
+	{"new/env.example.tmpl", "env.example"},
+	{"new/gitignore.tmpl", ".gitignore"},
+	{"new/Dockerfile.tmpl", "Dockerfile"},
+	{"new/README.md.tmpl", "README.md"},
+}
+
+// runNew scaffolds a bot project in a new directory
+func runNew(args []string) error {
+	flags := newFlagSet("new")
+	module := flags.String("module", "", "Go module path (default: the directory name)")
+	framework := flags.String("forge", "", "DiscordBotForge checkout to use via a replace directive (default: detected, or FORGE_PATH)")
+	noTidy := flags.Bool("no-tidy", false, "Skip running 'go mod tidy' in the new project")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected exactly one project name")
+	}
+
+	dir := flags.Arg(0)
+	if err := checkEmptyDir(dir); err != nil {
+		return err
+	}
+
+	data := projectData{
+		Name:      filepath.Base(dir),
+		Module:    *module,
+		Framework: frameworkModule,
+	}
+	if data.Module == "" {
+		data.Module = kebabCase(data.Name)
+	}
+
+	data.FrameworkPath = *framework
+	if data.FrameworkPath == "" {
+		data.FrameworkPath = findFramework()
+	}
+	if data.FrameworkPath != "" {
+		abs, err := filepath.Abs(data.FrameworkPath)
+		if err != nil {
+			return err
+		}
+		if module, err := readModulePath(filepath.Join(abs, "go.mod")); err != nil || module != frameworkModule {
+			return fmt.Errorf("%s is not a DiscordBotForge checkout", abs)
+		}
+		data.FrameworkPath = abs
+	}
+
+	for _, file := range projectFiles {
+		content, err := render(file.template, data)
+		if err != nil {
+			return err
+		}
+		if err := writeNew(filepath.Join(dir, file.path), content); err != nil {
+			return err
+		}
+	}
+
+	// The example command is generated like any other
+	if _, err := generate(filepath.Join(dir, "commands"), "command", "hello"); err != nil {
+		return err
+	}
+
+	log.Printf("✅ Created %s in %s", data.Name, dir)
+	if data.FrameworkPath == "" {
+		log.Printf("⚠️ No DiscordBotForge checkout found; add a replace directive for %s to go.mod, or pass -forge", frameworkModule)
+	} else {
+		log.Printf("🔗 Using DiscordBotForge from %s", data.FrameworkPath)
+		if !*noTidy {
+			tidy := exec.Command("go", "mod", "tidy")
+			tidy.Dir = dir
+			tidy.Stdout = os.Stdout
+			tidy.Stderr = os.Stderr
+			if err := tidy.Run(); err != nil {
+				log.Printf("⚠️ 'go mod tidy' failed: %v", err)
+			}
+		}
+	}
+
+	log.Println("📝 Next steps:")
+	log.Printf("   cd %s", dir)
+	log.Println("   cp env.example .env   # then add your bot token")
+	log.Println("   forge run")
+	return nil
+}
+
+// checkEmptyDir fails unless dir is missing or empty
+func checkEmptyDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", dir, err)
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("%s already exists and is not empty", dir)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"discord-bot-forge/core"
+	"github.com/bwmarrin/discordgo"
+	"github.com/joho/godotenv"
+)
+
+// toolToken stands in for a missing token while forge inspects or replays
+// into a bot, which never connects
+const toolToken = "forge-tool"
+
+// toolFile is the file toolCommand adds to a bot's main package
+const toolFile = "zz_forge_tool.go"
+
+// enterProject switches to a bot's main package directory and loads its .env
+// like the bot's main does, so relative config paths resolve the same way
+func enterProject(dir string) error {
+	if _, _, err := findGoMod(dir); err != nil {
+		return fmt.Errorf("%s is not inside a Go module: %w", dir, err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error loading .env: %w", err)
+	}
+	return nil
+}
+
+// inspectSource makes the bot write its definition from bot.Run instead
+// of connecting
+const inspectSource = `package main
+
+import (
+	"context"
+
+	"discord-bot-forge/core"
+)
+
+func init() {
+	core.SetToolRunner(func(ctx context.Context, bot *core.Bot) error {
+		return bot.Inspect(%s)
+	})
+}
+`
+
+// inspectBot runs the bot in the current directory with a tool runner that
+// calls Bot.Inspect and returns the definition it writes
+func inspectBot(args []string) (*core.Definition, error) {
+	tmp, err := os.MkdirTemp("", "forge-inspect")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "definition.json")
+
+	cmd, cleanup, err := toolCommand(fmt.Sprintf(inspectSource, strconv.Quote(path)), args)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	output, runErr := cmd.CombinedOutput()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if runErr == nil {
+			runErr = errors.New("it exited without calling bot.Run")
+		}
+		return nil, fmt.Errorf("error inspecting bot: %v\n%s", runErr, strings.TrimSpace(string(output)))
+	}
+
+	var definition core.Definition
+	if err := json.Unmarshal(data, &definition); err != nil {
+		return nil, fmt.Errorf("error reading bot definition: %w", err)
+	}
+	return &definition, nil
+}
+
+// toolCommand prepares a go run of the bot in the current directory with
+// source added to its main package through a build overlay, so a tool can
+// install a core.ToolRunner without changing the project. cleanup removes
+// the generated files once the command has finished.
+func toolCommand(source string, args []string) (cmd *exec.Cmd, cleanup func(), err error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, nil, err
+	}
+	tmp, err := os.MkdirTemp("", "forge-tool")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup = func() { os.RemoveAll(tmp) }
+
+	generated := filepath.Join(tmp, toolFile)
+	overlay, err := json.Marshal(map[string]map[string]string{
+		"Replace": {filepath.Join(dir, toolFile): generated},
+	})
+	if err == nil {
+		err = os.WriteFile(generated, []byte(source), 0644)
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(tmp, "overlay.json"), overlay, 0644)
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("error generating tool runner: %w", err)
+	}
+
+	cmd = exec.Command("go", append([]string{"run", "-overlay", filepath.Join(tmp, "overlay.json"), "."}, args...)...)
+	cmd.Env = os.Environ()
+	if os.Getenv("DISCORD_BOT_TOKEN") == "" && os.Getenv("DISCORD_BOT_TOKEN_FILE") == "" {
+		cmd.Env = append(cmd.Env, "DISCORD_BOT_TOKEN="+toolToken)
+	}
+	return cmd, cleanup, nil
+}
+
+// newSession creates a REST-only Discord session for a configuration
+func newSession(config *core.Config) (*discordgo.Session, error) {
+	session, err := discordgo.New("Bot " + config.Token)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Discord session: %w", err)
+	}
+	return session, nil
+}
+
+// isUnauthorized reports whether Discord rejected the token
+func isUnauthorized(err error) bool {
+	var restErr *discordgo.RESTError
+	return errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == 401
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(v)
+}
+
+// newTable aligns tab-separated columns on stdout; call Flush when done
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+}
+
+// joinOrDash joins values for a table cell
+func joinOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ", ")
+}
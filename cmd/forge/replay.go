@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// replaySource installs forgetest's replay tool in the bot being debugged
+const replaySource = `package main
+
+import (
+	"os"
+
+	"discord-bot-forge/core"
+	"discord-bot-forge/forgetest"
+)
+
+func init() {
+	core.SetToolRunner(forgetest.ReplayTool(%s, %s, os.Stdout))
+}
+`
+
+// runReplay feeds a recording made by the Recorder module into the bot in
+// the project directory and prints the REST calls the bot made, without
+// connecting to Discord
+func runReplay(args []string) error {
+	flags := newFlagSet("replay")
+	dir := flags.String("dir", ".", "Bot project directory")
+	speed := flags.Float64("speed", 0, "Replay speed: 1 is real time, 0 as fast as possible")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return errors.New("replay needs a recording file")
+	}
+	if *speed < 0 {
+		return errors.New("-speed cannot be negative")
+	}
+
+	// Resolve the recording before switching to the project directory
+	recording, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(recording); err != nil {
+		return fmt.Errorf("error opening recording: %w", err)
+	}
+	if err := enterProject(*dir); err != nil {
+		return err
+	}
+
+	source := fmt.Sprintf(replaySource, strconv.Quote(recording), strconv.FormatFloat(*speed, 'g', -1, 64))
+	botArgs := flags.Args()[1:]
+	if len(botArgs) > 0 && botArgs[0] == "--" {
+		botArgs = botArgs[1:]
+	}
+	cmd, cleanup, err := toolCommand(source, botArgs)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error replaying %s: %w", flags.Arg(0), err)
+	}
+	return nil
+}
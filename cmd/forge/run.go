@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultWatchInterval is how often sources are checked for changes
+	defaultWatchInterval = 500 * time.Millisecond
+
+	// stopTimeout is how long the bot gets to shut down gracefully before
+	// it is killed; it covers the default drain and module timeouts
+	stopTimeout = 20 * time.Second
+)
+
+// skippedDirs are never watched
+var skippedDirs = map[string]bool{
+	"vendor": true, "node_modules": true, "bin": true, "data": true, "recordings": true, "testdata": true,
+}
+
+// runRun builds and runs the bot in a directory, rebuilding and restarting
+// it whenever its Go sources change
+func runRun(args []string) error {
+	flags := newFlagSet("run")
+	dir := flags.String("dir", ".", "Bot project directory")
+	interval := flags.Duration("interval", defaultWatchInterval, "How often to check sources for changes")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	root, err := filepath.Abs(*dir)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		return fmt.Errorf("%s is not a Go module: %w", root, err)
+	}
+
+	binary := filepath.Join(os.TempDir(), fmt.Sprintf("forge-run-%d", os.Getpid()))
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+	defer os.Remove(binary)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	loop := &devLoop{
+		dir:      root,
+		binary:   binary,
+		args:     flags.Args(),
+		interval: *interval,
+	}
+	loop.run(ctx)
+	return nil
+}
+
+// devLoop keeps one bot process running from the latest successful build
+type devLoop struct {
+	dir      string
+	binary   string
+	args     []string
+	interval time.Duration
+	process  *botProcess
+}
+
+// botProcess is one run of the bot binary
+type botProcess struct {
+	cmd      *exec.Cmd
+	done     chan struct{}
+	stopping atomic.Bool
+}
+
+// fileStamp identifies one version of a watched file
+type fileStamp struct {
+	modified time.Time
+	size     int64
+}
+
+func (l *devLoop) run(ctx context.Context) {
+	log.Printf("👀 Watching %s for changes", l.dir)
+
+	sources := l.snapshot()
+	if l.build() {
+		l.start()
+	}
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("🛑 Stopping...")
+			l.stop()
+			return
+		case <-ticker.C:
+		}
+
+		current := l.snapshot()
+		if maps.Equal(current, sources) {
+			continue
+		}
+
+		// Editors often write several files at once; wait for them to settle
+		for {
+			time.Sleep(l.interval)
+			settled := l.snapshot()
+			if maps.Equal(settled, current) {
+				break
+			}
+			current = settled
+		}
+		sources = current
+
+		log.Println("🔄 Change detected, rebuilding...")
+		if !l.build() {
+			if l.running() {
+				log.Println("⚠️ Build failed; the previous build keeps running")
+			}
+			continue
+		}
+		l.stop()
+		l.start()
+	}
+}
+
+// snapshot stamps every file whose change needs a rebuild or restart. Config
+// files are left out because the bot reloads them itself.
+func (l *devLoop) snapshot() map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	filepath.WalkDir(l.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			if path != l.dir && (strings.HasPrefix(name, ".") || skippedDirs[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		watched := (strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")) ||
+			name == "go.mod" || name == "go.sum" || name == ".env"
+		if !watched {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			stamps[path] = fileStamp{modified: info.ModTime(), size: info.Size()}
+		}
+		return nil
+	})
+	return stamps
+}
+
+// build compiles the bot, reporting whether it succeeded
+func (l *devLoop) build() bool {
+	started := time.Now()
+
+	cmd := exec.Command("go", "build", "-o", l.binary, ".")
+	cmd.Dir = l.dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Printf("❌ Build failed: %v", err)
+		return false
+	}
+
+	log.Printf("🔨 Built in %v", time.Since(started).Round(time.Millisecond))
+	return true
+}
+
+// start runs the latest build
+func (l *devLoop) start() {
+	cmd := exec.Command(l.binary, l.args...)
+	cmd.Dir = l.dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		log.Printf("❌ Error starting bot: %v", err)
+		return
+	}
+
+	process := &botProcess{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		err := cmd.Wait()
+		if !process.stopping.Load() {
+			if err != nil {
+				log.Printf("💥 Bot exited: %v; waiting for changes", err)
+			} else {
+				log.Println("⏹️ Bot exited; waiting for changes")
+			}
+		}
+		close(process.done)
+	}()
+
+	l.process = process
+	log.Printf("🚀 Started bot (pid %d)", cmd.Process.Pid)
+}
+
+// running reports whether a bot process is still alive
+func (l *devLoop) running() bool {
+	if l.process == nil {
+		return false
+	}
+	select {
+	case <-l.process.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop asks the running bot to shut down gracefully, killing it if it takes
+// longer than stopTimeout
+func (l *devLoop) stop() {
+	process := l.process
+	l.process = nil
+	if process == nil {
+		return
+	}
+	process.stopping.Store(true)
+
+	select {
+	case <-process.done:
+		return
+	default:
+	}
+
+	// Windows cannot deliver an interrupt to another process
+	if err := process.cmd.Process.Signal(os.Interrupt); err != nil {
+		process.cmd.Process.Kill()
+	}
+
+	select {
+	case <-process.done:
+	case <-time.After(stopTimeout):
+		log.Printf("⚠️ Bot did not stop within %v, killing it", stopTimeout)
+		process.cmd.Process.Kill()
+		<-process.done
+	}
+}
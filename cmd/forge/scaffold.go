@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// frameworkModule is the module path of DiscordBotForge itself
+const frameworkModule = "discord-bot-forge"
+
+//go:embed templates
+var templates embed.FS
+
+// render executes an embedded template. Go output is gofmt'd.
+func render(name string, data interface{}) ([]byte, error) {
+	source, err := templates.ReadFile("templates/" + name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template %s: %w", name, err)
+	}
+
+	tmpl, err := template.New(name).Parse(string(source))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("error executing template %s: %w", name, err)
+	}
+
+	if strings.HasSuffix(name, ".go.tmpl") {
+		formatted, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("error formatting %s: %w", name, err)
+		}
+		return formatted, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// writeNew writes a file that must not exist yet, creating its directory
+func writeNew(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%s already exists", path)
+		}
+		return fmt.Errorf("error creating %s: %w", path, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// findGoMod walks up from dir to the nearest go.mod and returns its
+// directory and module path
+func findGoMod(dir string) (root, module string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for {
+		if module, err := readModulePath(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, module, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", fs.ErrNotExist
+		}
+		dir = parent
+	}
+}
+
+// readModulePath returns the module declared in a go.mod file
+func readModulePath(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s has no module directive", path)
+}
+
+// findFramework locates a DiscordBotForge checkout for a replace directive:
+// the FORGE_PATH environment variable, the current directory or one of its
+// parents, or the checkout forge itself was built into
+func findFramework() string {
+	if path := os.Getenv("FORGE_PATH"); path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			return abs
+		}
+		return path
+	}
+
+	var starts []string
+	if wd, err := os.Getwd(); err == nil {
+		starts = append(starts, wd)
+	}
+	if exe, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		starts = append(starts, filepath.Dir(exe))
+	}
+
+	for _, start := range starts {
+		if root, module, err := findGoMod(start); err == nil && module == frameworkModule {
+			return root
+		}
+	}
+	return ""
+}
+
+// words splits an identifier like "roll-dice", "roll_dice" or "RollDice"
+// into lower-case words
+func words(name string) []string {
+	var result []string
+	var current []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			if len(current) > 0 {
+				result = append(result, string(current))
+				current = nil
+			}
+			continue
+		case unicode.IsUpper(r) && len(current) > 0 &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))):
+			result = append(result, string(current))
+			current = nil
+		}
+		current = append(current, unicode.ToLower(r))
+	}
+	if len(current) > 0 {
+		result = append(result, string(current))
+	}
+	return result
+}
+
+// pascalCase turns a name into an exported Go identifier
+func pascalCase(name string) string {
+	var b strings.Builder
+	for _, word := range words(name) {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
+
+// snakeCase turns a name into a file name stem
+func snakeCase(name string) string {
+	return strings.Join(words(name), "_")
+}
+
+// kebabCase turns a name into a command name
+func kebabCase(name string) string {
+	return strings.Join(words(name), "-")
+}
+
+// packageName derives a Go package name from a directory
+func packageName(dir string) string {
+	name := strings.Join(words(filepath.Base(dir)), "")
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		return "pkg" + name
+	}
+	return name
+}
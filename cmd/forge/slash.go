@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"discord-bot-forge/core"
+	"github.com/bwmarrin/discordgo"
+)
+
+// runSlash compares or synchronizes the bot's slash commands with Discord
+func runSlash(args []string) error {
+	flags := newFlagSet("slash")
+	dir := flags.String("dir", ".", "Bot project directory")
+	guild := flags.String("guild", "", "Guild to register commands in (default: global)")
+	asJSON := flags.Bool("json", false, "Print the differences as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || (flags.Arg(0) != "diff" && flags.Arg(0) != "push") {
+		flags.Usage()
+		return errors.New("expected diff or push")
+	}
+	push := flags.Arg(0) == "push"
+
+	if err := enterProject(*dir); err != nil {
+		return err
+	}
+	botArgs := flags.Args()[1:]
+
+	config, err := core.LoadConfig(botArgs)
+	if err != nil {
+		return err
+	}
+	definition, err := inspectBot(botArgs)
+	if err != nil {
+		return err
+	}
+
+	session, err := newSession(config)
+	if err != nil {
+		return err
+	}
+	app, err := session.Application("@me")
+	if err != nil {
+		if isUnauthorized(err) {
+			return errors.New("Discord rejected the bot token")
+		}
+		return fmt.Errorf("error fetching application: %w", err)
+	}
+
+	registered, err := session.ApplicationCommands(app.ID, *guild)
+	if err != nil {
+		return fmt.Errorf("error fetching registered commands: %w", err)
+	}
+
+	desired := definition.ApplicationCommands
+	if desired == nil {
+		desired = make([]*discordgo.ApplicationCommand, 0)
+	}
+	changes := core.DiffApplicationCommands(registered, desired)
+
+	if *asJSON {
+		if err := printJSON(changes); err != nil {
+			return err
+		}
+	} else {
+		printChanges(changes, *guild)
+	}
+	if !push || len(changes) == 0 {
+		return nil
+	}
+
+	created, err := session.ApplicationCommandBulkOverwrite(app.ID, *guild, desired)
+	if err != nil {
+		return fmt.Errorf("error registering commands: %w", err)
+	}
+	log.Printf("✅ Registered %d slash commands %s", len(created), scopeName(*guild))
+	return nil
+}
+
+// printChanges lists slash command differences, one per line
+func printChanges(changes []core.ApplicationCommandChange, guild string) {
+	if len(changes) == 0 {
+		if guild == "" {
+			log.Println("✅ Global slash commands are up to date")
+		} else {
+			log.Printf("✅ Slash commands in guild %s are up to date", guild)
+		}
+		return
+	}
+
+	for _, change := range changes {
+		switch change.Action {
+		case "create":
+			fmt.Printf("+ /%s\n", change.Name)
+		case "delete":
+			fmt.Printf("- /%s\n", change.Name)
+		default:
+			fmt.Printf("~ /%s (%s)\n", change.Name, joinOrDash(change.Fields))
+		}
+	}
+}
+
+// scopeName describes where commands are registered
+func scopeName(guild string) string {
+	if guild == "" {
+		return "globally"
+	}
+	return "in guild " + guild
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"discord-bot-forge/core"
+)
+
+// runValidate loads a project's configuration the way the bot would and
+// reports every problem with it
+func runValidate(args []string) error {
+	flags := newFlagSet("validate")
+	dir := flags.String("dir", ".", "Bot project directory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := enterProject(*dir); err != nil {
+		return err
+	}
+
+	config, err := core.LoadConfig(flags.Args())
+	if err != nil {
+		log.Println("❌ Configuration is invalid:")
+		for _, problem := range configProblems(err) {
+			log.Printf("   - %s", problem)
+		}
+		return errReported
+	}
+
+	source := config.Path()
+	if source == "" {
+		source = "defaults and environment"
+	}
+	log.Printf("✅ Configuration is valid (%s)", source)
+
+	table := newTable()
+	fmt.Fprintf(table, "Prefix\t%s\n", config.Prefix)
+	fmt.Fprintf(table, "Intents\t%s\n", joinOrDash(config.Intents))
+	if config.Web.Enabled {
+		fmt.Fprintf(table, "Web\t%s\n", config.Web.Addr())
+	} else {
+		fmt.Fprintf(table, "Web\tdisabled\n")
+	}
+	data := config.DataDir
+	if data == "" {
+		data = "in memory"
+	}
+	fmt.Fprintf(table, "Data\t%s\n", data)
+	fmt.Fprintf(table, "Logging\t%s, %s\n", config.Logging.Level, config.Logging.Format)
+	modules := make([]string, 0, len(config.Modules))
+	for name := range config.Modules {
+		modules = append(modules, name)
+	}
+	sort.Strings(modules)
+	fmt.Fprintf(table, "Module settings\t%s\n", joinOrDash(modules))
+	return table.Flush()
+}
+
+// configProblems splits a configuration error into its individual problems
+func configProblems(err error) []string {
+	for unwrapped := err; unwrapped != nil; unwrapped = errors.Unwrap(unwrapped) {
+		if joined, ok := unwrapped.(interface{ Unwrap() []error }); ok {
+			var problems []string
+			for _, problem := range joined.Unwrap() {
+				problems = append(problems, problem.Error())
+			}
+			return problems
+		}
+	}
+	return strings.Split(err.Error(), "\n")
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"discord-bot-forge/core"
+	"discord-bot-forge/modules"
+	"github.com/bwmarrin/discordgo"
+)
+
+// auditSearchLimit is how many entries one search shows
+const auditSearchLimit = 10
+
+// auditTypeNames maps the words accepted by the audit command to entry types
+var auditTypeNames = map[string][]modules.AuditEventType{
+	"edit":     {modules.AuditMessageEdit},
+	"delete":   {modules.AuditMessageDelete, modules.AuditMessageBulkDelete},
+	"bulk":     {modules.AuditMessageBulkDelete},
+	"join":     {modules.AuditMemberJoin},
+	"leave":    {modules.AuditMemberLeave},
+	"nickname": {modules.AuditMemberNickname},
+	"roles":    {modules.AuditMemberRoles},
+	"member":   {modules.AuditMemberJoin, modules.AuditMemberLeave, modules.AuditMemberNickname, modules.AuditMemberRoles},
+	"channel":  {modules.AuditChannelCreate, modules.AuditChannelUpdate, modules.AuditChannelDelete},
+}
+
+var (
+	userMentionPattern    = regexp.MustCompile(`^<@!?(\d+)>$`)
+	channelMentionPattern = regexp.MustCompile(`^<#(\d+)>$`)
+)
+
+// AuditCommand searches the audit log kept by the Audit module
+type AuditCommand struct {
+	bot *core.Bot
+}
+
+func NewAuditCommand(bot *core.Bot) *AuditCommand {
+	return &AuditCommand{bot: bot}
+}
+
+func (c *AuditCommand) Name() string {
+	return "audit"
+}
+
+func (c *AuditCommand) Description() string {
+	return "Search the server's audit log"
+}
+
+func (c *AuditCommand) Usage() string {
+	return "audit [edit|delete|bulk|join|leave|nickname|roles|member|channel] [@user] [#channel] [text]"
+}
+
+func (c *AuditCommand) Execute(s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	return c.ExecuteContext(context.Background(), s, m, args)
+}
+
+func (c *AuditCommand) ExecuteContext(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	if m.GuildID == "" {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, "❌ The audit log can only be searched inside a server.")
+		return err
+	}
+	if allowed, err := c.canSearch(s, m); err != nil {
+		return err
+	} else if !allowed {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, "❌ You need the Manage Messages permission to search the audit log.")
+		return err
+	}
+
+	audit, ok := core.Service[*modules.AuditModule](c.bot)
+	if !ok {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, "❌ The Audit module is not running.")
+		return err
+	}
+
+	query := modules.AuditQuery{Limit: auditSearchLimit}
+	var text []string
+	for _, arg := range args {
+		if types, known := auditTypeNames[strings.ToLower(arg)]; known {
+			query.Types = append(query.Types, types...)
+		} else if match := userMentionPattern.FindStringSubmatch(arg); match != nil {
+			query.UserID = match[1]
+		} else if match := channelMentionPattern.FindStringSubmatch(arg); match != nil {
+			query.ChannelID = match[1]
+		} else {
+			text = append(text, arg)
+		}
+	}
+	query.Text = strings.Join(text, " ")
+
+	entries, err := audit.Search(m.GuildID, query)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, "🔍 No audit log entries found.")
+		return err
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		line := fmt.Sprintf("<t:%d:f> `%s` %s", entry.Time.Unix(), entry.Type, entry.Summary)
+		detail := entry.Before + entry.After
+		if entry.Before != "" && entry.After != "" {
+			detail = entry.Before + " → " + entry.After
+		}
+		if detail != "" {
+			if runes := []rune(detail); len(runes) > 80 {
+				detail = string(runes[:80]) + "…"
+			}
+			line += "\n> " + strings.ReplaceAll(detail, "\n", " ")
+		}
+		lines = append(lines, line)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "🔍 Audit Log",
+		Description: strings.Join(lines, "\n"),
+		Color:       0xff6b35,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Newest %d matching entries", len(entries)),
+		},
+	}
+	_, err = c.bot.Messages.Send(ctx, m.ChannelID, &discordgo.MessageSend{
+		Embeds:          []*discordgo.MessageEmbed{embed},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err
+}
+
+// canSearch reports whether the author may read the audit log
+func (c *AuditCommand) canSearch(s *discordgo.Session, m *discordgo.MessageCreate) (bool, error) {
+	if m.Author.ID == c.bot.Config().OwnerID {
+		return true, nil
+	}
+	return core.HasPermissions(s, m.Author.ID, m.ChannelID, c.Permissions())
+}
+
+func (c *AuditCommand) Permissions() []string {
+	return []string{"MANAGE_MESSAGES"}
+}
+
+func (c *AuditCommand) Cooldown() int {
+	return 3
+}
+
+func (c *AuditCommand) Category() string {
+	return "Admin"
+}
@@ -0,0 +1,48 @@
+package commands_test
+
+import (
+	"net/http"
+	"testing"
+
+	"discord-bot-forge/commands"
+	"discord-bot-forge/forgetest"
+)
+
+func TestPing(t *testing.T) {
+	h := forgetest.New(t)
+	h.Bot.RegisterCommand(&commands.PingCommand{})
+
+	h.Send("!ping")
+	h.AssertReplyContains("Pong! Latency:")
+	if messages := h.Discord.Messages(); len(messages) != 1 {
+		t.Fatalf("ping sent %d messages, want 1 edited in place", len(messages))
+	}
+}
+
+func TestHelpListsCommands(t *testing.T) {
+	h := forgetest.New(t)
+	h.Bot.RegisterCommand(&commands.PingCommand{})
+	h.Bot.RegisterCommand(commands.NewHelpCommand(h.Bot))
+
+	h.Send("!help")
+	embed := h.AssertEmbed("🔥 DiscordBotForge Commands")
+	if len(embed.Fields) == 0 {
+		t.Fatal("help embed has no command categories")
+	}
+
+	h.Send("!help nope")
+	h.AssertReply("❌ Command not found.")
+}
+
+func TestHelpRetriesFailedReplies(t *testing.T) {
+	h := forgetest.New(t)
+	h.Bot.RegisterCommand(commands.NewHelpCommand(h.Bot))
+
+	h.Discord.FailNext(2, http.StatusServiceUnavailable)
+	h.Send("!help")
+	h.AssertEmbed("🔥 DiscordBotForge Commands")
+
+	if stats := h.Bot.Messages.Stats(); stats.Retries != 2 || stats.Failed != 0 {
+		t.Errorf("queue stats = %+v, want 2 retries and no failures", stats)
+	}
+}
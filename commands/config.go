@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"discord-bot-forge/core"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ConfigCommand reads and changes per-guild settings
+type ConfigCommand struct {
+	bot *core.Bot
+}
+
+func NewConfigCommand(bot *core.Bot) *ConfigCommand {
+	return &ConfigCommand{bot: bot}
+}
+
+func (c *ConfigCommand) Name() string {
+	return "config"
+}
+
+func (c *ConfigCommand) Description() string {
+	return "View or change server settings"
+}
+
+func (c *ConfigCommand) Usage() string {
+	return "config [get <key> | set <key> <value> | reset <key>]"
+}
+
+func (c *ConfigCommand) Execute(s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	return c.ExecuteContext(context.Background(), s, m, args)
+}
+
+func (c *ConfigCommand) ExecuteContext(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	if m.GuildID == "" {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, "❌ Settings can only be managed inside a server.")
+		return err
+	}
+
+	settings := c.bot.GuildSettings(m.GuildID)
+
+	if len(args) == 0 || args[0] == "list" {
+		return c.list(ctx, m, settings)
+	}
+
+	action := strings.ToLower(args[0])
+	if len(args) < 2 {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("Usage: `%s%s`", c.bot.Prefix(m.GuildID), c.Usage()))
+		return err
+	}
+	key := strings.ToLower(args[1])
+
+	setting, exists := c.bot.LookupSetting(key)
+	if !exists {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("❌ Unknown setting `%s`. Use `%sconfig` to list settings.", key, c.bot.Prefix(m.GuildID)))
+		return err
+	}
+
+	switch action {
+	case "get":
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("⚙️ `%s` = `%s`", key, setting.Format(settings.Get(key))))
+		return err
+	case "set", "reset":
+		if allowed, err := c.canManage(s, m); err != nil {
+			return err
+		} else if !allowed {
+			_, err := c.bot.Messages.SendText(ctx, m.ChannelID, "❌ You need the Manage Server permission to change settings.")
+			return err
+		}
+	default:
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("Usage: `%s%s`", c.bot.Prefix(m.GuildID), c.Usage()))
+		return err
+	}
+
+	if action == "reset" {
+		if err := settings.Reset(key); err != nil {
+			return err
+		}
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("✅ `%s` reset to `%s`", key, setting.Format(settings.Get(key))))
+		return err
+	}
+
+	if len(args) < 3 {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("Usage: `%sconfig set %s <value>`", c.bot.Prefix(m.GuildID), key))
+		return err
+	}
+
+	if err := settings.Set(key, strings.Join(args[2:], " ")); err != nil {
+		_, sendErr := c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("❌ %v", err))
+		return sendErr
+	}
+
+	_, err := c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("✅ `%s` set to `%s`", key, setting.Format(settings.Get(key))))
+	return err
+}
+
+// list shows every setting with its effective value
+func (c *ConfigCommand) list(ctx context.Context, m *discordgo.MessageCreate, settings *core.GuildSettings) error {
+	var fields []*discordgo.MessageEmbedField
+	for _, setting := range c.bot.SettingDefinitions() {
+		value := setting.Format(settings.Get(setting.Key))
+		if value == "" {
+			value = "not set"
+		}
+		if !settings.IsSet(setting.Key) {
+			value += " (default)"
+		}
+
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   fmt.Sprintf("%s (%s)", setting.Key, setting.Type),
+			Value:  fmt.Sprintf("%s\n`%s`", setting.Description, value),
+			Inline: false,
+		})
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "⚙️ Server Settings",
+		Description: fmt.Sprintf("Use `%sconfig set <key> <value>` to change a setting", c.bot.Prefix(m.GuildID)),
+		Color:       0xff6b35,
+		Fields:      fields,
+	}
+	_, err := c.bot.Messages.Send(ctx, m.ChannelID, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}})
+	return err
+}
+
+// canManage reports whether the author may change settings
+func (c *ConfigCommand) canManage(s *discordgo.Session, m *discordgo.MessageCreate) (bool, error) {
+	if m.Author.ID == c.bot.Config().OwnerID {
+		return true, nil
+	}
+	return core.HasPermissions(s, m.Author.ID, m.ChannelID, c.Permissions())
+}
+
+func (c *ConfigCommand) Permissions() []string {
+	return []string{"MANAGE_GUILD"}
+}
+
+func (c *ConfigCommand) Cooldown() int {
+	return 0
+}
+
+func (c *ConfigCommand) Category() string {
+	return "Admin"
+}
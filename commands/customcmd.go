@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"discord-bot-forge/core"
+	"github.com/bwmarrin/discordgo"
+)
+
+// CustomCmdCommand manages the guild's custom commands
+type CustomCmdCommand struct {
+	bot *core.Bot
+}
+
+func NewCustomCmdCommand(bot *core.Bot) *CustomCmdCommand {
+	return &CustomCmdCommand{bot: bot}
+}
+
+func (c *CustomCmdCommand) Name() string {
+	return "customcmd"
+}
+
+func (c *CustomCmdCommand) Description() string {
+	return "Create and manage custom commands for this server"
+}
+
+func (c *CustomCmdCommand) Usage() string {
+	return "customcmd [list | show <name> | add <name> <template> | edit <name> <template> | remove <name>]"
+}
+
+func (c *CustomCmdCommand) Execute(s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	return c.ExecuteContext(context.Background(), s, m, args)
+}
+
+func (c *CustomCmdCommand) ExecuteContext(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	if m.GuildID == "" {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, "❌ Custom commands can only be managed inside a server.")
+		return err
+	}
+
+	if len(args) == 0 || strings.ToLower(args[0]) == "list" {
+		return c.list(ctx, m)
+	}
+
+	action := strings.ToLower(args[0])
+	if len(args) < 2 {
+		return c.usage(ctx, m)
+	}
+	name := strings.ToLower(args[1])
+
+	if action == "show" {
+		cmd, err := c.bot.CustomCommands.Get(m.GuildID, name)
+		if err != nil {
+			_, err = c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("❌ %v", err))
+			return err
+		}
+		_, err = c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("📝 `%s%s`\n```\n%s\n```", c.bot.Prefix(m.GuildID), cmd.Name, cmd.Template))
+		return err
+	}
+
+	switch action {
+	case "add", "edit", "remove":
+	default:
+		return c.usage(ctx, m)
+	}
+
+	if allowed, err := c.canManage(s, m); err != nil {
+		return err
+	} else if !allowed {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, "❌ You need the Manage Server permission to change custom commands.")
+		return err
+	}
+
+	var err error
+	switch action {
+	case "add", "edit":
+		// Use the raw message so quotes and spacing in the template survive
+		content := strings.TrimPrefix(m.Content, c.bot.Prefix(m.GuildID))
+		template := stripCodeBlock(afterFields(content, 3))
+		if template == "" {
+			return c.usage(ctx, m)
+		}
+		cmd := core.CustomCommand{
+			Name:        name,
+			Description: "Custom command",
+			Template:    template,
+			CreatedBy:   m.Author.ID,
+		}
+		if action == "add" {
+			err = c.bot.CustomCommands.Create(m.GuildID, cmd)
+		} else {
+			if existing, getErr := c.bot.CustomCommands.Get(m.GuildID, name); getErr == nil {
+				cmd.Description, cmd.Usage = existing.Description, existing.Usage
+				cmd.Category, cmd.Cooldown = existing.Category, existing.Cooldown
+			}
+			err = c.bot.CustomCommands.Update(m.GuildID, cmd)
+		}
+	case "remove":
+		err = c.bot.CustomCommands.Delete(m.GuildID, name)
+	}
+
+	if err != nil {
+		_, sendErr := c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("❌ %v", err))
+		return sendErr
+	}
+
+	verb := map[string]string{"add": "created", "edit": "updated", "remove": "removed"}[action]
+	_, err = c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("✅ Custom command `%s%s` %s", c.bot.Prefix(m.GuildID), name, verb))
+	return err
+}
+
+// list shows the guild's custom commands
+func (c *CustomCmdCommand) list(ctx context.Context, m *discordgo.MessageCreate) error {
+	commands, err := c.bot.CustomCommands.List(m.GuildID)
+	if err != nil {
+		return err
+	}
+
+	prefix := c.bot.Prefix(m.GuildID)
+	if len(commands) == 0 {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("No custom commands yet. Create one with `%scustomcmd add <name> <template>`", prefix))
+		return err
+	}
+
+	names := make([]string, len(commands))
+	for i, cmd := range commands {
+		names[i] = "`" + prefix + cmd.Name + "`"
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "📝 Custom Commands",
+		Description: strings.Join(names, ", "),
+		Color:       0xff6b35,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Use %scustomcmd show <name> to see a command's template", prefix),
+		},
+	}
+	_, err = c.bot.Messages.Send(ctx, m.ChannelID, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}})
+	return err
+}
+
+func (c *CustomCmdCommand) usage(ctx context.Context, m *discordgo.MessageCreate) error {
+	_, err := c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("Usage: `%s%s`", c.bot.Prefix(m.GuildID), c.Usage()))
+	return err
+}
+
+// canManage reports whether the author may change custom commands
+func (c *CustomCmdCommand) canManage(s *discordgo.Session, m *discordgo.MessageCreate) (bool, error) {
+	if m.Author.ID == c.bot.Config().OwnerID {
+		return true, nil
+	}
+	return core.HasPermissions(s, m.Author.ID, m.ChannelID, []string{"MANAGE_GUILD"})
+}
+
+func (c *CustomCmdCommand) Permissions() []string {
+	return []string{}
+}
+
+func (c *CustomCmdCommand) Cooldown() int {
+	return 0
+}
+
+func (c *CustomCmdCommand) Category() string {
+	return "Admin"
+}
+
+// afterFields returns s without its first n whitespace-separated fields
+func afterFields(s string, n int) string {
+	for i := 0; i < n; i++ {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end < 0 {
+			return ""
+		}
+		s = s[end:]
+	}
+	return strings.TrimSpace(s)
+}
+
+// stripCodeBlock removes a surrounding ``` code block
+func stripCodeBlock(s string) string {
+	if !strings.HasPrefix(s, "```") || !strings.HasSuffix(s, "```") || len(s) < 6 {
+		return s
+	}
+	return strings.TrimSpace(s[3 : len(s)-3])
+}
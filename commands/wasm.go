@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"discord-bot-forge/core"
+	"github.com/bwmarrin/discordgo"
+)
+
+// WasmCommand loads and unloads sandboxed WASM commands at runtime
+type WasmCommand struct {
+	bot *core.Bot
+}
+
+func NewWasmCommand(bot *core.Bot) *WasmCommand {
+	return &WasmCommand{bot: bot}
+}
+
+func (c *WasmCommand) Name() string {
+	return "wasm"
+}
+
+func (c *WasmCommand) Description() string {
+	return "Manage sandboxed WASM commands (owner only)"
+}
+
+func (c *WasmCommand) Usage() string {
+	return "wasm [list | load <name> | unload <name> | reload]"
+}
+
+func (c *WasmCommand) Execute(s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	return c.ExecuteContext(context.Background(), s, m, args)
+}
+
+func (c *WasmCommand) ExecuteContext(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	if m.Author.ID != c.bot.Config().OwnerID {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, "❌ Only the bot owner can manage WASM commands.")
+		return err
+	}
+
+	host, ok := core.Service[*core.WasmHost](c.bot)
+	if !ok {
+		_, err := c.bot.Messages.SendText(ctx, m.ChannelID, "❌ WASM commands are not enabled. Set `wasm.dir` in the configuration.")
+		return err
+	}
+
+	action := "list"
+	if len(args) > 0 {
+		action = strings.ToLower(args[0])
+	}
+
+	var err error
+	switch {
+	case action == "list":
+		loaded := host.Loaded()
+		if len(loaded) == 0 {
+			_, err = c.bot.Messages.SendText(ctx, m.ChannelID, "🧩 No WASM commands loaded.")
+		} else {
+			_, err = c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("🧩 Loaded WASM commands: `%s`", strings.Join(loaded, "`, `")))
+		}
+	case action == "load" && len(args) > 1:
+		err = c.report(ctx, m, host.Load(args[1]), fmt.Sprintf("✅ Loaded `%s`", args[1]))
+	case action == "unload" && len(args) > 1:
+		err = c.report(ctx, m, host.Unload(args[1]), fmt.Sprintf("✅ Unloaded `%s`", args[1]))
+	case action == "reload":
+		err = c.report(ctx, m, host.Reload(), fmt.Sprintf("✅ Reloaded %d WASM commands", len(host.Loaded())))
+	default:
+		_, err = c.bot.Messages.SendText(ctx, m.ChannelID, fmt.Sprintf("Usage: `%s%s`", c.bot.Prefix(m.GuildID), c.Usage()))
+	}
+	return err
+}
+
+// report tells the user whether a management action succeeded
+func (c *WasmCommand) report(ctx context.Context, m *discordgo.MessageCreate, actionErr error, success string) error {
+	message := success
+	if actionErr != nil {
+		message = fmt.Sprintf("❌ %v", actionErr)
+	}
+	_, err := c.bot.Messages.SendText(ctx, m.ChannelID, message)
+	return err
+}
+
+func (c *WasmCommand) Permissions() []string {
+	return []string{}
+}
+
+func (c *WasmCommand) Cooldown() int {
+	return 0
+}
+
+func (c *WasmCommand) Category() string {
+	return "Admin"
+}
@@ -1,225 +1,605 @@
-package core
-
-import (
-	"fmt"
-	"log"
-	"os"
-	"os/signal"
-	"strings"
-	"syscall"
-
-	"github.com/bwmarrin/discordgo"
-)
-
-// Bot represents the main DiscordBotForge bot instance
-type Bot struct {
-	Session    *discordgo.Session
-	Commands   map[string]Command
-	Modules    []Module
-	Config     *Config
-	Middleware []Middleware
-	Version    string
-}
-
-// Config holds bot configuration
-type Config struct {
-	Token     string
-	Prefix    string
-	OwnerID   string
-	DebugMode bool
-	Version   string
-}
-
-// Command interface defines the structure for bot commands
-type Command interface {
-	Name() string
-	Description() string
-	Usage() string
-	Execute(s *discordgo.Session, m *discordgo.MessageCreate, args []string) error
-	Permissions() []string
-	Cooldown() int // seconds
-	Category() string
-}
-
-// Module interface defines the structure for bot modules
-type Module interface {
-	Name() string
-	Initialize(bot *Bot) error
-	Shutdown() error
-	Version() string
-}
-
-// Middleware interface for request processing
-type Middleware interface {
-	Process(s *discordgo.Session, m *discordgo.MessageCreate, next func()) error
-	Name() string
-}
-
-// NewBot creates a new DiscordBotForge bot instance
-func NewBot(config *Config) (*Bot, error) {
-	session, err := discordgo.New("Bot " + config.Token)
-	if err != nil {
-		return nil, fmt.Errorf("error creating Discord session: %w", err)
-	}
-
-	bot := &Bot{
-		Session:    session,
-		Commands:   make(map[string]Command),
-		Modules:    make([]Module, 0),
-		Config:     config,
-		Middleware: make([]Middleware, 0),
-		Version:    config.Version,
-	}
-
-	return bot, nil
-}
-
-// Start initializes and starts the DiscordBotForge bot
-func (b *Bot) Start() error {
-	log.Printf("🔥 DiscordBotForge v%s starting up...", b.Version)
-	
-	// Add message handler
-	b.Session.AddHandler(b.messageHandler)
-
-	// Open connection
-	err := b.Session.Open()
-	if err != nil {
-		return fmt.Errorf("error opening connection: %w", err)
-	}
-
-	// Initialize all modules
-	for _, module := range b.Modules {
-		if err := module.Initialize(b); err != nil {
-			log.Printf("Error initializing module %s: %v", module.Name(), err)
-		} else {
-			log.Printf("✅ Module '%s' v%s initialized", module.Name(), module.Version())
-		}
-	}
-
-	log.Printf("🚀 DiscordBotForge is now running! Press CTRL+C to exit.")
-	
-	// Wait for interrupt signal
-	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
-	<-sc
-
-	return b.Shutdown()
-}
-
-// Shutdown gracefully shuts down the DiscordBotForge bot
-func (b *Bot) Shutdown() error {
-	log.Println("🛑 Shutting down DiscordBotForge...")
-
-	// Shutdown all modules
-	for _, module := range b.Modules {
-		if err := module.Shutdown(); err != nil {
-			log.Printf("Error shutting down module %s: %v", module.Name(), err)
-		} else {
-			log.Printf("✅ Module '%s' shutdown complete", module.Name())
-		}
-	}
-
-	// Close Discord session
-	return b.Session.Close()
-}
-
-// RegisterCommand adds a command to the bot
-func (b *Bot) RegisterCommand(cmd Command) {
-	b.Commands[cmd.Name()] = cmd
-	log.Printf("⚡ Registered command: %s", cmd.Name())
-}
-
-// RegisterModule adds a module to the bot
-func (b *Bot) RegisterModule(module Module) {
-	b.Modules = append(b.Modules, module)
-	log.Printf("🔧 Registered module: %s", module.Name())
-}
-
-// AddMiddleware adds middleware to the bot
-func (b *Bot) AddMiddleware(middleware Middleware) {
-	b.Middleware = append(b.Middleware, middleware)
-	log.Printf("🛡️ Added middleware: %s", middleware.Name())
-}
-
-// messageHandler processes incoming messages
-func (b *Bot) messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
-	// Ignore bot messages
-	if m.Author.Bot {
-		return
-	}
-
-	// Check if message starts with prefix
-	if len(m.Content) < len(b.Config.Prefix) || m.Content[:len(b.Config.Prefix)] != b.Config.Prefix {
-		return
-	}
-
-	// Parse command and arguments
-	args := parseArgs(m.Content[len(b.Config.Prefix):])
-	if len(args) == 0 {
-		return
-	}
-
-	commandName := args[0]
-	commandArgs := args[1:]
-
-	// Find command
-	cmd, exists := b.Commands[commandName]
-	if !exists {
-		return
-	}
-
-	// Process middleware
-	for _, middleware := range b.Middleware {
-		if err := middleware.Process(s, m, func() {
-			// Execute command
-			if err := cmd.Execute(s, m, commandArgs); err != nil {
-				log.Printf("Error executing command %s: %v", commandName, err)
-				s.ChannelMessageSend(m.ChannelID, "❌ An error occurred while executing the command.")
-			}
-		}); err != nil {
-			log.Printf("Middleware error: %v", err)
-			return
-		}
-	}
-}
-
-// parseArgs splits a string into arguments, respecting quotes
-func parseArgs(input string) []string {
-	var args []string
-	var current strings.Builder
-	inQuotes := false
-	
-	for i, r := range input {
-		if r == '"' {
-			inQuotes = !inQuotes
-		} else if r == ' ' && !inQuotes {
-			if current.Len() > 0 {
-				args = append(args, current.String())
-				current.Reset()
-			}
-		} else {
-			current.WriteRune(r)
-		}
-	}
-	
-	if current.Len() > 0 {
-		args = append(args, current.String())
-	}
-	
-	return args
-}
-
-// GetCommandCategories returns a map of commands grouped by category
-func (b *Bot) GetCommandCategories() map[string][]Command {
-	categories := make(map[string][]Command)
-	
-	for _, cmd := range b.Commands {
-		category := cmd.Category()
-		if category == "" {
-			category = "General"
-		}
-		categories[category] = append(categories[category], cmd)
-	}
-	
-	return categories
-}
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Bot represents the main DiscordBotForge bot instance
+type Bot struct {
+	// Session is the first shard's session. It works for REST calls on any
+	// guild; event handlers should be added with AddHandler so they run on
+	// every shard.
+	Session    *discordgo.Session
+	Commands   map[string]Command
+	Modules    []Module
+	Middleware []Middleware
+	Version    string
+	Storage    Storage
+	Events     *EventBus
+
+	// CustomCommands holds the commands server admins define per guild
+	CustomCommands *CustomCommands
+
+	// Scheduler runs cron, interval and one-shot jobs
+	Scheduler *Scheduler
+
+	// Messages queues outgoing messages per channel with rate limiting and
+	// retries
+	Messages *MessageQueue
+
+	config     atomic.Pointer[Config]
+	logs       *logManager
+	log        *slog.Logger
+	settings   *settingsRegistry
+	modules    *moduleRegistry
+	shards     *shardManager
+	commands   *commandTracker
+	commandsMu sync.RWMutex
+	reloadMu   sync.Mutex
+	done       chan struct{}
+
+	openMu    sync.Mutex
+	opened    atomic.Bool
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	hooksMu       sync.Mutex
+	shutdownHooks []namedShutdownHook
+	shutdownOnce  sync.Once
+	shutdownErr   error
+}
+
+// Command interface defines the structure for bot commands
+type Command interface {
+	Name() string
+	Description() string
+	Usage() string
+	Execute(s *discordgo.Session, m *discordgo.MessageCreate, args []string) error
+	Permissions() []string
+	Cooldown() int // seconds
+	Category() string
+}
+
+// ContextCommand is implemented by commands that can stop early when their
+// context is cancelled, such as during shutdown. The bot calls
+// ExecuteContext instead of Execute for them.
+type ContextCommand interface {
+	ExecuteContext(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) error
+}
+
+// Module interface defines the structure for bot modules
+type Module interface {
+	Name() string
+	Initialize(bot *Bot) error
+	Shutdown() error
+	Version() string
+}
+
+// Middleware interface for request processing
+type Middleware interface {
+	Process(s *discordgo.Session, m *discordgo.MessageCreate, next func()) error
+	Name() string
+}
+
+// Config returns the current configuration. A reload publishes a new
+// Config rather than changing the current one, so the result must be
+// treated as read-only.
+func (b *Bot) Config() *Config {
+	return b.config.Load()
+}
+
+// NewBot creates a new DiscordBotForge bot instance
+func NewBot(config *Config) (*Bot, error) {
+	session, err := discordgo.New("Bot " + config.Token)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Discord session: %w", err)
+	}
+
+	if _, err := ParseIntents(config.Intents); err != nil {
+		return nil, err
+	}
+
+	// Log to stderr; the level follows logging.level and debug mode
+	logs := newLogManager(config.Logging, config.DebugMode, os.Stderr)
+
+	storage := NewMemoryStorage()
+	if config.DataDir != "" {
+		storage, err = NewFileStorage(config.DataDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bot := &Bot{
+		Session:    session,
+		Commands:   make(map[string]Command),
+		Modules:    make([]Module, 0),
+		Middleware: make([]Middleware, 0),
+		Version:    config.Version,
+		Storage:    storage,
+		Events:     NewEventBus(),
+		logs:       logs,
+		log:        logs.logger("bot"),
+		settings:   newSettingsRegistry(storage, logs.logger("settings")),
+		modules:    newModuleRegistry(),
+		shards:     newShardManager(session),
+		commands:   newCommandTracker(logs.logger("commands")),
+		done:       make(chan struct{}),
+		ready:      make(chan struct{}),
+	}
+
+	bot.config.Store(config)
+	bot.Events.log = logs.logger("events")
+	bot.CustomCommands = newCustomCommands(bot)
+	bot.Scheduler = newScheduler(bot)
+	bot.Messages = newMessageQueue(bot)
+
+	for _, setting := range builtinSettings() {
+		if err := bot.settings.define(setting); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, plugin := range config.Plugins {
+		bot.RegisterModule(NewPlugin(plugin))
+	}
+	if config.Wasm.Dir != "" {
+		bot.RegisterModule(NewWasmHost(config.Wasm))
+	}
+
+	return bot, nil
+}
+
+// Start opens the bot and blocks until SIGINT or SIGTERM, reloading the
+// configuration on SIGHUP, then shuts it down. Applications that manage
+// their own lifecycle should use Run or Open and Close instead.
+func (b *Bot) Start() error {
+	ctx, stop := b.SignalContext(context.Background())
+	defer stop()
+
+	return b.Run(ctx)
+}
+
+// Run opens the bot and keeps it running until ctx is cancelled or the bot
+// is shut down elsewhere, then closes it. With a ToolRunner installed it
+// runs the tool instead of connecting.
+func (b *Bot) Run(ctx context.Context) error {
+	if toolRunner != nil {
+		return b.runTool(ctx)
+	}
+
+	if err := b.Open(); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-b.done:
+	}
+	return b.Close()
+}
+
+// Open connects every shard and starts the registered modules. It returns
+// once the gateway connections are open; Ready reports when Discord has
+// finished sending the initial state. A bot can be opened only once.
+func (b *Bot) Open() error {
+	b.openMu.Lock()
+	defer b.openMu.Unlock()
+
+	select {
+	case <-b.done:
+		return errors.New("bot has been shut down")
+	default:
+	}
+	if b.opened.Load() {
+		return errors.New("bot is already open")
+	}
+	if toolRunner != nil {
+		return ErrToolRunning
+	}
+
+	b.log.Info("starting DiscordBotForge", "version", b.Version, "debug", b.Config().DebugMode)
+	
+	// Resolve module initialization order before connecting
+	order, err := b.resolveModuleOrder()
+	if err != nil {
+		return err
+	}
+
+	// Identify with the intents registered commands and modules need
+	if err := b.resolveIntents(); err != nil {
+		return err
+	}
+
+	// Add message and slash command handlers
+	removeMessages := b.AddHandler(b.messageHandler)
+	removeInteractions := b.AddHandler(b.interactionHandler)
+	removeHandlers := func() {
+		removeMessages()
+		removeInteractions()
+	}
+
+	// Connect every shard this process runs
+	concurrency, err := b.configureShards()
+	if err != nil {
+		removeHandlers()
+		return err
+	}
+	if err := b.openShards(concurrency); err != nil {
+		removeHandlers()
+		return err
+	}
+
+	b.startModules(order)
+
+	// Reload the configuration when its file changes
+	if path := b.Config().Path(); path != "" {
+		go b.watchConfig(path, b.done)
+		b.Logger("config").Info("watching config file for changes", "path", path)
+	}
+
+	b.opened.Store(true)
+	b.checkReady()
+
+	b.log.Info("DiscordBotForge is running")
+	return nil
+}
+
+// Close gracefully shuts the bot down; see Shutdown
+func (b *Bot) Close() error {
+	return b.Shutdown()
+}
+
+// Ready returns a channel that is closed once the bot is open and every
+// shard has received its first READY from Discord
+func (b *Bot) Ready() <-chan struct{} {
+	return b.ready
+}
+
+// Done returns a channel that is closed when the bot starts shutting down
+func (b *Bot) Done() <-chan struct{} {
+	return b.done
+}
+
+// checkReady closes the ready channel once Open has finished and every
+// shard has identified
+func (b *Bot) checkReady() {
+	if !b.opened.Load() {
+		return
+	}
+
+	b.shards.mu.Lock()
+	shards := append([]*Shard(nil), b.shards.shards...)
+	b.shards.mu.Unlock()
+
+	for _, shard := range shards {
+		shard.mu.Lock()
+		identified := shard.identifies > 0
+		shard.mu.Unlock()
+		if !identified {
+			return
+		}
+	}
+	b.readyOnce.Do(func() { close(b.ready) })
+}
+
+// Shutdown gracefully shuts down the DiscordBotForge bot. It stops accepting
+// commands, waits for running ones up to the drain timeout and cancels the
+// rest, stops modules in reverse initialization order, runs shutdown hooks,
+// delivers queued messages and finally closes the gateway. Calling it again
+// returns the first result.
+func (b *Bot) Shutdown() error {
+	b.shutdownOnce.Do(func() {
+		b.log.Info("shutting down DiscordBotForge")
+		close(b.done)
+
+		report := &ShutdownReport{Started: time.Now()}
+
+		// Let in-flight commands finish before anything they use goes away
+		report.Drained, report.Cancelled = b.commands.drain(b.Config().Shutdown.DrainTimeout.Duration())
+
+		b.shutdownModules(report)
+
+		// Cancel jobs that don't belong to a module
+		report.step("scheduler", func() error {
+			b.Scheduler.Stop()
+			return nil
+		})
+
+		b.runShutdownHooks(report)
+
+		// Deliver replies still waiting in the message queue
+		report.step("message queue", func() error {
+			return withTimeout(b.Config().Shutdown.ModuleTimeout.Duration(), b.Messages.Close)
+		})
+
+		// Close every shard's Discord session
+		report.step("gateway", b.closeShards)
+
+		report.Duration = time.Since(report.Started)
+		report.log(b.log)
+		b.shutdownErr = report.Errors()
+	})
+	return b.shutdownErr
+}
+
+// RegisterCommand adds a command to the bot
+func (b *Bot) RegisterCommand(cmd Command) {
+	b.commandsMu.Lock()
+	b.Commands[cmd.Name()] = cmd
+	b.commandsMu.Unlock()
+
+	b.defineProvidedSettings(cmd)
+	b.log.Info("registered command", LogKeyCommand, cmd.Name())
+}
+
+// UnregisterCommand removes a command from the bot
+func (b *Bot) UnregisterCommand(name string) {
+	b.commandsMu.Lock()
+	defer b.commandsMu.Unlock()
+
+	delete(b.Commands, name)
+}
+
+// Command returns a registered command by name
+func (b *Bot) Command(name string) (Command, bool) {
+	b.commandsMu.RLock()
+	defer b.commandsMu.RUnlock()
+
+	cmd, exists := b.Commands[name]
+	return cmd, exists
+}
+
+// CommandList returns the registered commands sorted by name. Use it rather
+// than ranging over Commands, which WASM and plugin commands change while
+// the bot runs.
+func (b *Bot) CommandList() []Command {
+	b.commandsMu.RLock()
+	commands := make([]Command, 0, len(b.Commands))
+	for _, cmd := range b.Commands {
+		commands = append(commands, cmd)
+	}
+	b.commandsMu.RUnlock()
+
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name() < commands[j].Name() })
+	return commands
+}
+
+// RegisterModule adds a module to the bot
+func (b *Bot) RegisterModule(module Module) {
+	if _, exists := b.ModuleStatus(module.Name()); exists {
+		b.log.Warn("module is already registered", "module", module.Name())
+		return
+	}
+
+	b.Modules = append(b.Modules, module)
+	b.modules.add(module)
+	b.defineProvidedSettings(module)
+	b.log.Info("registered module", "module", module.Name())
+}
+
+// DefineSetting registers a per-guild setting in the bot's settings schema
+func (b *Bot) DefineSetting(setting Setting) error {
+	return b.settings.define(setting)
+}
+
+// SettingDefinitions returns every registered setting sorted by key
+func (b *Bot) SettingDefinitions() []Setting {
+	return b.settings.all()
+}
+
+// LookupSetting returns the definition of a registered setting
+func (b *Bot) LookupSetting(key string) (Setting, bool) {
+	return b.settings.lookup(key)
+}
+
+// GuildSettings returns the cached settings for a guild, loading them from
+// storage on first access. An empty guildID yields read-only defaults.
+func (b *Bot) GuildSettings(guildID string) *GuildSettings {
+	return b.settings.guild(guildID)
+}
+
+// Prefix returns the command prefix in effect for a guild
+func (b *Bot) Prefix(guildID string) string {
+	return b.GuildSettings(guildID).Prefix(b.Config().Prefix)
+}
+
+// defineProvidedSettings registers settings declared by a command or module
+func (b *Bot) defineProvidedSettings(v interface{}) {
+	provider, ok := v.(SettingsProvider)
+	if !ok {
+		return
+	}
+
+	for _, setting := range provider.Settings() {
+		if err := b.settings.define(setting); err != nil {
+			b.log.Error("error defining setting", "setting", setting.Key, "error", err)
+		}
+	}
+}
+
+// AddMiddleware adds middleware to the bot
+func (b *Bot) AddMiddleware(middleware Middleware) {
+	if aware, ok := middleware.(queueAware); ok {
+		aware.useQueue(b.Messages)
+	}
+	if aware, ok := middleware.(loggerAware); ok {
+		aware.useLogger(b.Logger("middleware"))
+	}
+	b.Middleware = append(b.Middleware, middleware)
+	b.log.Info("added middleware", "middleware", middleware.Name())
+}
+
+// messageHandler processes incoming messages. In debug mode every dispatch
+// decision is logged.
+func (b *Bot) messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
+	logger := b.Logger("commands").With(MessageLogAttrs(m.Message)...)
+
+	// Ignore bot messages
+	if m.Author.Bot {
+		logger.Debug("ignoring message from a bot")
+		return
+	}
+
+	// Check if message starts with the guild's prefix
+	guildSettings := b.GuildSettings(m.GuildID)
+	prefix := guildSettings.Prefix(b.Config().Prefix)
+	if !strings.HasPrefix(m.Content, prefix) {
+		logger.Debug("ignoring message without prefix", "prefix", prefix)
+		return
+	}
+
+	// Parse command and arguments
+	args := parseArgs(m.Content[len(prefix):])
+	if len(args) == 0 {
+		logger.Debug("ignoring message with only the prefix")
+		return
+	}
+
+	commandName := args[0]
+	commandArgs := args[1:]
+	logger = logger.With(LogKeyCommand, commandName, LogKeyCorrelationID, newCorrelationID(m.ID))
+
+	// Find command, falling back to the guild's custom commands
+	cmd, exists := b.Command(commandName)
+	if !exists && m.GuildID != "" {
+		if custom, err := b.CustomCommands.Get(m.GuildID, commandName); err == nil {
+			cmd, exists = &customCommandAdapter{store: b.CustomCommands, def: custom}, true
+			logger.Debug("found custom command")
+		}
+	}
+	if !exists {
+		logger.Debug("ignoring unknown command")
+		return
+	}
+	if guildSettings.CommandDisabled(commandName) {
+		logger.Debug("ignoring command disabled in this guild")
+		return
+	}
+
+	// Refuse new commands once shutdown has started
+	ctx, done, ok := b.commands.begin()
+	if !ok {
+		logger.Debug("ignoring command during shutdown")
+		return
+	}
+	defer done()
+
+	// Run the middleware chain; the command executes once every middleware
+	// has called next
+	var run func(i int)
+	run = func(i int) {
+		if i < len(b.Middleware) {
+			middleware := b.Middleware[i]
+			called := false
+			err := middleware.Process(s, m, func() {
+				called = true
+				run(i + 1)
+			})
+			if err != nil {
+				logger.Error("middleware error", "middleware", middleware.Name(), "error", err)
+			} else if !called {
+				logger.Debug("middleware stopped the command", "middleware", middleware.Name())
+			}
+			return
+		}
+		b.executeCommand(WithLogger(ctx, logger), s, m, cmd, commandArgs)
+	}
+	run(0)
+}
+
+// executeCommand runs a command and publishes the outcome on the event bus.
+// ctx carries the invocation's logger.
+func (b *Bot) executeCommand(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, cmd Command, args []string) {
+	logger := LoggerFromContext(ctx)
+	logger.Debug("executing command", "args", len(args))
+
+	started := time.Now()
+	err := safeCall(func() error {
+		if contextCmd, ok := cmd.(ContextCommand); ok {
+			return contextCmd.ExecuteContext(ctx, s, m, args)
+		}
+		return cmd.Execute(s, m, args)
+	})
+	elapsed := time.Since(started)
+
+	if err != nil {
+		logger.Error("error executing command", "duration", elapsed, "error", err)
+		b.Messages.EnqueueText(m.ChannelID, "❌ An error occurred while executing the command.")
+		b.Events.Publish(CommandFailedEvent{
+			Command:   cmd.Name(),
+			Args:      args,
+			GuildID:   m.GuildID,
+			ChannelID: m.ChannelID,
+			UserID:    m.Author.ID,
+			Duration:  elapsed,
+			Err:       err,
+		})
+		return
+	}
+
+	logger.Debug("command finished", "duration", elapsed)
+	b.Events.Publish(CommandExecutedEvent{
+		Command:   cmd.Name(),
+		Args:      args,
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		UserID:    m.Author.ID,
+		Duration:  elapsed,
+	})
+}
+
+// parseArgs splits a string into arguments, respecting quotes
+func parseArgs(input string) []string {
+	var args []string
+	var current strings.Builder
+	inQuotes := false
+	
+	for _, r := range input {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ' ' && !inQuotes {
+			if current.Len() > 0 {
+				args = append(args, current.String())
+				current.Reset()
+			}
+		} else {
+			current.WriteRune(r)
+		}
+	}
+	
+	if current.Len() > 0 {
+		args = append(args, current.String())
+	}
+	
+	return args
+}
+
+// GetCommandCategories returns a map of commands grouped by category
+func (b *Bot) GetCommandCategories() map[string][]Command {
+	b.commandsMu.RLock()
+	defer b.commandsMu.RUnlock()
+
+	categories := make(map[string][]Command)
+	
+	for _, cmd := range b.Commands {
+		category := cmd.Category()
+		if category == "" {
+			category = "General"
+		}
+		categories[category] = append(categories[category], cmd)
+	}
+	
+	return categories
+}
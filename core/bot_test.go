@@ -0,0 +1,128 @@
+package core_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"discord-bot-forge/core"
+	"discord-bot-forge/forgetest"
+)
+
+// noopModule is a module that does nothing
+type noopModule struct{ name string }
+
+func (m noopModule) Name() string               { return m.name }
+func (m noopModule) Version() string            { return "1.0.0" }
+func (m noopModule) Initialize(*core.Bot) error { return nil }
+func (m noopModule) Shutdown() error            { return nil }
+
+// runBot runs bot in the background and waits until it is ready. The
+// returned channel receives Run's result.
+func runBot(t *testing.T, ctx context.Context, bot *core.Bot) <-chan error {
+	t.Helper()
+
+	result := make(chan error, 1)
+	go func() { result <- bot.Run(ctx) }()
+
+	select {
+	case <-bot.Ready():
+	case err := <-result:
+		t.Fatalf("Run returned before the bot was ready: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("bot was not ready")
+	}
+	return result
+}
+
+func TestRunReturnsWhenContextIsCancelled(t *testing.T) {
+	server := forgetest.NewServer(t)
+	bot := server.NewBot()
+	bot.RegisterModule(noopModule{name: "noop"})
+
+	select {
+	case <-bot.Ready():
+		t.Fatal("Ready was closed before the bot was opened")
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := runBot(t, ctx, bot)
+
+	if status, _ := bot.ModuleStatus("noop"); status.State != core.ModuleRunning {
+		t.Errorf("module is %s while the bot runs, want running", status.State)
+	}
+	if err := bot.Open(); err == nil {
+		t.Error("opening a running bot succeeded")
+	}
+
+	cancel()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Run = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+
+	select {
+	case <-bot.Done():
+	default:
+		t.Error("Done is not closed after Run returned")
+	}
+	if status, _ := bot.ModuleStatus("noop"); status.State != core.ModuleStopped {
+		t.Errorf("module is %s after Run returned, want stopped", status.State)
+	}
+	if state := bot.ConnectionState(); state != core.ShardDisconnected {
+		t.Errorf("connection is %s after Run returned, want disconnected", state)
+	}
+	if err := bot.Open(); err == nil {
+		t.Error("reopening a closed bot succeeded")
+	}
+}
+
+func TestRunReturnsWhenBotIsClosed(t *testing.T) {
+	server := forgetest.NewServer(t)
+	bot := server.NewBot()
+
+	result := runBot(t, context.Background(), bot)
+
+	if err := bot.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Run = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return after Close")
+	}
+}
+
+func TestTwoBotsRunInOneProcess(t *testing.T) {
+	server := forgetest.NewServer(t)
+	first, second := server.NewBot(), server.NewBot()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstResult := runBot(t, ctx, first)
+	secondResult := runBot(t, ctx, second)
+
+	if got := server.Identifies(); got != 2 {
+		t.Errorf("identifies = %d, want one per bot", got)
+	}
+
+	cancel()
+	for _, result := range []<-chan error{firstResult, secondResult} {
+		select {
+		case err := <-result:
+			if err != nil {
+				t.Errorf("Run = %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Run did not return after the context was cancelled")
+		}
+	}
+}
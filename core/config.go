@@ -0,0 +1,683 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds bot configuration.
+//
+// LoadConfig assembles it from these sources, later ones overriding earlier
+// ones: built-in defaults, the config file, environment variables and
+// command-line flags.
+type Config struct {
+	Token     string `json:"token"`
+	TokenFile string `json:"token_file"` // read Token from this file, e.g. a Docker secret
+	Prefix    string `json:"prefix"`
+	OwnerID   string `json:"owner_id"`
+	DebugMode bool   `json:"debug"`
+	Version   string `json:"version"`
+	DataDir   string `json:"data_dir"` // directory for persistent storage; in-memory when empty
+
+	// Cooldown is the default delay between commands from one user
+	Cooldown Duration `json:"cooldown"`
+
+	// Intents lists gateway intents by name, e.g. "guild_messages"
+	Intents []string `json:"intents"`
+
+	Web      WebConfig      `json:"web"`
+	Logging  LoggingConfig  `json:"logging"`
+	Sharding ShardingConfig `json:"sharding"`
+	Shutdown ShutdownConfig `json:"shutdown"`
+
+	// Modules holds free-form settings keyed by module name
+	Modules map[string]map[string]interface{} `json:"modules"`
+
+	// Plugins lists out-of-process plugin executables to run
+	Plugins []PluginConfig `json:"plugins"`
+
+	// Wasm configures sandboxed WebAssembly commands
+	Wasm WasmConfig `json:"wasm"`
+
+	source *configSource
+}
+
+// configSource remembers how a Config was loaded so it can be reloaded
+type configSource struct {
+	base *Config
+	args []string
+	path string
+}
+
+// Duration is a time.Duration that decodes from strings like "1.5s" or
+// from a number of seconds
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Duration returns the value as a time.Duration
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// String formats the duration like time.Duration
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// WebConfig configures the web interface
+type WebConfig struct {
+	Enabled bool   `json:"enabled"`
+	Bind    string `json:"bind"`
+	Port    int    `json:"port"`
+
+	// Token must be sent as a bearer token to create or delete custom
+	// commands through the API; those routes are disabled when it is empty
+	Token string `json:"token"`
+}
+
+// Addr returns the listen address for the web interface
+func (w WebConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", w.Bind, w.Port)
+}
+
+// LoggingConfig configures framework logging
+type LoggingConfig struct {
+	Level  string `json:"level"`
+	Format string `json:"format"`
+	File   string `json:"file"`
+
+	// Levels overrides Level for subsystems such as "gateway" or "web"
+	Levels map[string]string `json:"levels"`
+}
+
+// ShardingConfig splits the gateway connection into shards
+type ShardingConfig struct {
+	// Count is the total number of shards across every process; 0 uses
+	// Discord's recommendation
+	Count int `json:"count"`
+
+	// IDs selects the shards run by this process, e.g. "0-3" or "4,5,6";
+	// every shard when empty
+	IDs string `json:"ids"`
+}
+
+// ShutdownConfig bounds how long a graceful shutdown may take
+type ShutdownConfig struct {
+	// DrainTimeout is how long to wait for running commands before their
+	// contexts are cancelled
+	DrainTimeout Duration `json:"drain_timeout"`
+
+	// ModuleTimeout is how long each module or shutdown hook gets to stop
+	ModuleTimeout Duration `json:"module_timeout"`
+}
+
+// ShardIDs returns the shard IDs this process runs out of count shards
+func (s ShardingConfig) ShardIDs(count int) ([]int, error) {
+	if s.IDs == "" {
+		ids := make([]int, count)
+		for i := range ids {
+			ids[i] = i
+		}
+		return ids, nil
+	}
+
+	seen := make(map[int]bool)
+	var ids []int
+	for _, part := range splitList(s.IDs) {
+		low, high := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			low, high = part[:i], part[i+1:]
+		}
+		first, err := strconv.Atoi(low)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shard ID %q", part)
+		}
+		last, err := strconv.Atoi(high)
+		if err != nil || last < first {
+			return nil, fmt.Errorf("invalid shard range %q", part)
+		}
+		for id := first; id <= last; id++ {
+			if id < 0 || id >= count {
+				return nil, fmt.Errorf("shard %d is out of range for %d shards", id, count)
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// DefaultConfig returns the configuration used when nothing else is set
+func DefaultConfig() *Config {
+	return &Config{
+		Prefix:   "!",
+		Version:  "1.0.0",
+		Cooldown: Duration(2 * time.Second),
+		Web: WebConfig{
+			Enabled: true,
+			Port:    8080,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "text",
+			File:   "discord-bot-forge.log",
+		},
+		Shutdown: ShutdownConfig{
+			DrainTimeout:  Duration(10 * time.Second),
+			ModuleTimeout: Duration(5 * time.Second),
+		},
+		Wasm: WasmConfig{
+			MemoryLimitMB: 64,
+			Timeout:       Duration(2 * time.Second),
+		},
+		Modules: make(map[string]map[string]interface{}),
+	}
+}
+
+// LoadConfig builds a configuration from the defaults, the config file,
+// environment variables and args (usually os.Args[1:])
+func LoadConfig(args []string) (*Config, error) {
+	config := DefaultConfig()
+	if err := LoadConfigInto(config, args); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadConfigInto overlays the config file, environment variables and args
+// onto an existing configuration and validates the result. It lets callers
+// start from their own defaults.
+//
+// The config file is taken from the -config flag or the CONFIG_FILE
+// environment variable; YAML, TOML and JSON are detected by extension.
+func LoadConfigInto(config *Config, args []string) error {
+	base := config.clone()
+
+	flags, err := parseConfigFlags(args)
+	if err != nil {
+		return err
+	}
+
+	path := flags.configPath
+	if path == "" {
+		path, _ = lookupEnv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := loadConfigFile(config, path); err != nil {
+			return err
+		}
+	}
+
+	if err := applyConfigEnv(config); err != nil {
+		return err
+	}
+	flags.apply(config)
+
+	if config.Token == "" && config.TokenFile != "" {
+		token, err := readSecretFile(config.TokenFile)
+		if err != nil {
+			return err
+		}
+		config.Token = token
+	}
+
+	config.source = &configSource{base: base, args: args, path: path}
+
+	return config.Validate()
+}
+
+// Path returns the config file the configuration was loaded from, if any
+func (c *Config) Path() string {
+	if c.source == nil {
+		return ""
+	}
+	return c.source.path
+}
+
+// Reload loads the configuration again from the same defaults, file,
+// environment and flags it was originally loaded from. The receiver is
+// left untouched.
+func (c *Config) Reload() (*Config, error) {
+	if c.source == nil {
+		return nil, errors.New("configuration was not created by LoadConfig")
+	}
+
+	next := c.source.base.clone()
+	if err := LoadConfigInto(next, c.source.args); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// clone returns a copy of the configuration that shares no mutable state
+func (c *Config) clone() *Config {
+	clone := *c
+	clone.Intents = append([]string(nil), c.Intents...)
+	clone.Plugins = append([]PluginConfig(nil), c.Plugins...)
+	clone.Modules = make(map[string]map[string]interface{}, len(c.Modules))
+	for name, settings := range c.Modules {
+		copied := make(map[string]interface{}, len(settings))
+		for key, value := range settings {
+			copied[key] = value
+		}
+		clone.Modules[name] = copied
+	}
+	clone.Logging.Levels = make(map[string]string, len(c.Logging.Levels))
+	for subsystem, level := range c.Logging.Levels {
+		clone.Logging.Levels[subsystem] = level
+	}
+	return &clone
+}
+
+// loadConfigFile decodes a YAML, TOML or JSON file onto config
+func loadConfigFile(config *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	// Every format is decoded into a generic map first and then applied
+	// through the JSON tags, so Config only needs one set of field names
+	var raw map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	default:
+		return fmt.Errorf("unsupported config file format %q (use .yaml, .toml or .json)", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// applyConfigEnv overlays environment variables onto config. Every variable
+// can also be given as NAME_FILE pointing at a file holding the value.
+func applyConfigEnv(config *Config) error {
+	var errs []error
+
+	setString := func(name string, target *string) {
+		if value, ok, err := lookupEnvOrFile(name); err != nil {
+			errs = append(errs, err)
+		} else if ok {
+			*target = value
+		}
+	}
+	setBool := func(name string, target *bool) {
+		if value, ok, err := lookupEnvOrFile(name); err != nil {
+			errs = append(errs, err)
+		} else if ok {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be true or false", name))
+				return
+			}
+			*target = b
+		}
+	}
+
+	setString("DISCORD_BOT_TOKEN", &config.Token)
+	setString("BOT_PREFIX", &config.Prefix)
+	setString("BOT_OWNER_ID", &config.OwnerID)
+	setBool("DEBUG", &config.DebugMode)
+	setString("DATA_DIR", &config.DataDir)
+	if value, ok, err := lookupEnvOrFile("BOT_COOLDOWN"); err != nil {
+		errs = append(errs, err)
+	} else if ok {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			errs = append(errs, errors.New("BOT_COOLDOWN must be a duration such as 2s"))
+		} else {
+			config.Cooldown = Duration(parsed)
+		}
+	}
+	setBool("WEB_ENABLED", &config.Web.Enabled)
+	setString("WEB_BIND", &config.Web.Bind)
+	setString("WEB_TOKEN", &config.Web.Token)
+	setString("LOG_LEVEL", &config.Logging.Level)
+	setString("LOG_FORMAT", &config.Logging.Format)
+	setString("LOG_FILE", &config.Logging.File)
+	if value, ok, err := lookupEnvOrFile("LOG_LEVELS"); err != nil {
+		errs = append(errs, err)
+	} else if ok {
+		levels, err := parseLogLevels(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("LOG_LEVELS: %w", err))
+		} else {
+			config.Logging.Levels = levels
+		}
+	}
+
+	if value, ok, err := lookupEnvOrFile("WEB_PORT"); err != nil {
+		errs = append(errs, err)
+	} else if ok {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, errors.New("WEB_PORT must be a number"))
+		} else {
+			config.Web.Port = port
+		}
+	}
+
+	if value, ok, err := lookupEnvOrFile("SHARD_COUNT"); err != nil {
+		errs = append(errs, err)
+	} else if ok {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, errors.New("SHARD_COUNT must be a number"))
+		} else {
+			config.Sharding.Count = count
+		}
+	}
+	setString("SHARD_IDS", &config.Sharding.IDs)
+
+	if value, ok, err := lookupEnvOrFile("BOT_INTENTS"); err != nil {
+		errs = append(errs, err)
+	} else if ok {
+		config.Intents = splitList(value)
+	}
+
+	return errors.Join(errs...)
+}
+
+// lookupEnv returns a non-empty environment variable
+func lookupEnv(name string) (string, bool) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// lookupEnvOrFile returns NAME, or the contents of the file named by NAME_FILE
+func lookupEnvOrFile(name string) (string, bool, error) {
+	if value, ok := lookupEnv(name); ok {
+		return value, true, nil
+	}
+	if path, ok := lookupEnv(name + "_FILE"); ok {
+		value, err := readSecretFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s_FILE: %w", name, err)
+		}
+		return value, true, nil
+	}
+	return "", false, nil
+}
+
+// readSecretFile reads a secret such as /run/secrets/discord_token
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading secret file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// configFlags holds command-line overrides; only flags that were passed
+// are applied
+type configFlags struct {
+	configPath string
+	set        map[string]bool
+
+	token, prefix, owner, dataDir, webBind, logLevel, intents, shardIDs string
+	debug, webEnabled                                                   bool
+	webPort, shardCount                                                 int
+	cooldown                                                            time.Duration
+}
+
+func parseConfigFlags(args []string) (*configFlags, error) {
+	f := &configFlags{set: make(map[string]bool)}
+
+	fs := flag.NewFlagSet("discord-bot-forge", flag.ContinueOnError)
+	fs.StringVar(&f.configPath, "config", "", "path to a YAML, TOML or JSON config file")
+	fs.StringVar(&f.token, "token", "", "Discord bot token")
+	fs.StringVar(&f.prefix, "prefix", "", "default command prefix")
+	fs.StringVar(&f.owner, "owner", "", "Discord user ID of the bot owner")
+	fs.BoolVar(&f.debug, "debug", false, "enable debug mode")
+	fs.StringVar(&f.dataDir, "data-dir", "", "directory for persistent storage")
+	fs.DurationVar(&f.cooldown, "cooldown", 0, "default delay between commands from one user")
+	fs.StringVar(&f.intents, "intents", "", "comma separated gateway intents")
+	fs.BoolVar(&f.webEnabled, "web", true, "enable the web interface")
+	fs.StringVar(&f.webBind, "web-bind", "", "web interface bind address")
+	fs.IntVar(&f.webPort, "web-port", 0, "web interface port")
+	fs.StringVar(&f.logLevel, "log-level", "", "log level (debug, info, warn, error)")
+	fs.IntVar(&f.shardCount, "shard-count", 0, "total number of shards (0 for Discord's recommendation)")
+	fs.StringVar(&f.shardIDs, "shard-ids", "", "shard IDs run by this process, e.g. 0-3")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		f.set[fl.Name] = true
+	})
+
+	return f, nil
+}
+
+func (f *configFlags) apply(config *Config) {
+	if f.set["token"] {
+		config.Token = f.token
+	}
+	if f.set["prefix"] {
+		config.Prefix = f.prefix
+	}
+	if f.set["owner"] {
+		config.OwnerID = f.owner
+	}
+	if f.set["debug"] {
+		config.DebugMode = f.debug
+	}
+	if f.set["data-dir"] {
+		config.DataDir = f.dataDir
+	}
+	if f.set["cooldown"] {
+		config.Cooldown = Duration(f.cooldown)
+	}
+	if f.set["intents"] {
+		config.Intents = splitList(f.intents)
+	}
+	if f.set["web"] {
+		config.Web.Enabled = f.webEnabled
+	}
+	if f.set["web-bind"] {
+		config.Web.Bind = f.webBind
+	}
+	if f.set["web-port"] {
+		config.Web.Port = f.webPort
+	}
+	if f.set["log-level"] {
+		config.Logging.Level = f.logLevel
+	}
+	if f.set["shard-count"] {
+		config.Sharding.Count = f.shardCount
+	}
+	if f.set["shard-ids"] {
+		config.Sharding.IDs = f.shardIDs
+	}
+}
+
+var snowflakePattern = regexp.MustCompile(`^\d{15,21}$`)
+
+// Validate checks that the configuration is complete and consistent
+func (c *Config) Validate() error {
+	var errs []error
+
+	switch {
+	case c.Token == "":
+		errs = append(errs, errors.New("token is required (set DISCORD_BOT_TOKEN, token or token_file)"))
+	case strings.HasPrefix(c.Token, "Bot "):
+		errs = append(errs, errors.New(`token must not include the "Bot " prefix`))
+	case strings.ContainsAny(c.Token, " \t\r\n"):
+		errs = append(errs, errors.New("token must not contain whitespace"))
+	}
+
+	if c.Prefix == "" || len(c.Prefix) > 5 || strings.ContainsAny(c.Prefix, " \t\n") {
+		errs = append(errs, errors.New("prefix must be 1-5 characters without spaces"))
+	}
+
+	if c.Cooldown < 0 {
+		errs = append(errs, errors.New("cooldown cannot be negative"))
+	}
+
+	// Owner-only commands such as !config, !wasm and !audit are unusable
+	// without an owner, so a bot without one is a configuration mistake
+	switch {
+	case c.OwnerID == "":
+		errs = append(errs, errors.New("owner_id is required (set BOT_OWNER_ID, owner_id or -owner)"))
+	case !snowflakePattern.MatchString(c.OwnerID):
+		errs = append(errs, errors.New("owner_id must be a Discord user ID"))
+	}
+
+	if _, err := ParseIntents(c.Intents); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Web.Enabled && (c.Web.Port < 1 || c.Web.Port > 65535) {
+		errs = append(errs, fmt.Errorf("web.port %d is out of range", c.Web.Port))
+	}
+
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("logging.level %q must be debug, info, warn or error", c.Logging.Level))
+	}
+	for _, subsystem := range sortedLogSubsystems(c.Logging.Levels) {
+		if _, err := ParseLogLevel(c.Logging.Levels[subsystem]); err != nil {
+			errs = append(errs, fmt.Errorf("logging.levels.%s %w", subsystem, err))
+		}
+	}
+
+	switch strings.ToLower(c.Logging.Format) {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("logging.format %q must be text or json", c.Logging.Format))
+	}
+
+	switch {
+	case c.Sharding.Count < 0:
+		errs = append(errs, errors.New("sharding.count cannot be negative"))
+	case c.Sharding.IDs != "" && c.Sharding.Count == 0:
+		errs = append(errs, errors.New("sharding.ids requires sharding.count so every process agrees on the total"))
+	case c.Sharding.IDs != "":
+		if _, err := c.Sharding.ShardIDs(c.Sharding.Count); err != nil {
+			errs = append(errs, fmt.Errorf("sharding.ids: %w", err))
+		}
+	}
+
+	if c.Shutdown.DrainTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown.drain_timeout must be positive"))
+	}
+	if c.Shutdown.ModuleTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown.module_timeout must be positive"))
+	}
+
+	if c.Wasm.Dir != "" {
+		if c.Wasm.MemoryLimitMB < 1 || c.Wasm.MemoryLimitMB > 4096 {
+			errs = append(errs, fmt.Errorf("wasm.memory_limit_mb %d must be between 1 and 4096", c.Wasm.MemoryLimitMB))
+		}
+		if c.Wasm.Timeout <= 0 {
+			errs = append(errs, errors.New("wasm.timeout must be positive"))
+		}
+	}
+
+	pluginNames := make(map[string]bool)
+	for i, plugin := range c.Plugins {
+		switch {
+		case plugin.Name == "" || plugin.Path == "":
+			errs = append(errs, fmt.Errorf("plugins[%d] needs a name and a path", i))
+		case pluginNames[plugin.Name]:
+			errs = append(errs, fmt.Errorf("plugin %s is configured more than once", plugin.Name))
+		}
+		pluginNames[plugin.Name] = true
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+// ModuleSettings returns the raw settings configured for a module
+func (c *Config) ModuleSettings(name string) map[string]interface{} {
+	return c.Modules[name]
+}
+
+// DecodeModuleSettings decodes a module's settings into v, typically a
+// pointer to a struct with json tags. Missing settings leave v untouched.
+func (c *Config) DecodeModuleSettings(name string, v interface{}) error {
+	settings, exists := c.Modules[name]
+	if !exists {
+		return nil
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("error encoding settings for module %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("error decoding settings for module %s: %w", name, err)
+	}
+	return nil
+}
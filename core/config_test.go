@@ -0,0 +1,225 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testOwnerID = "500000000000000005"
+
+// configEnv lists every variable applyConfigEnv reads, so tests start from
+// a clean environment
+var configEnv = []string{
+	"CONFIG_FILE", "DISCORD_BOT_TOKEN", "BOT_PREFIX", "BOT_OWNER_ID", "DEBUG", "DATA_DIR",
+	"BOT_COOLDOWN", "WEB_ENABLED", "WEB_BIND", "WEB_TOKEN", "WEB_PORT", "LOG_LEVEL",
+	"LOG_FORMAT", "LOG_FILE", "LOG_LEVELS", "SHARD_COUNT", "SHARD_IDS", "BOT_INTENTS",
+}
+
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+
+	for _, name := range configEnv {
+		t.Setenv(name, "")
+		t.Setenv(name+"_FILE", "")
+	}
+}
+
+func writeConfigFile(t *testing.T, name, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	file := "token: file-token\nowner_id: \"" + testOwnerID + "\"\nprefix: \"?\"\ncooldown: 5s\n"
+
+	tests := []struct {
+		name       string
+		file       bool
+		env        map[string]string
+		args       []string
+		wantToken  string
+		wantPrefix string
+		wantCool   time.Duration
+	}{
+		{
+			name:       "defaults",
+			env:        map[string]string{"DISCORD_BOT_TOKEN": "env-token", "BOT_OWNER_ID": testOwnerID},
+			wantToken:  "env-token",
+			wantPrefix: "!",
+			wantCool:   2 * time.Second,
+		},
+		{
+			name:       "file over defaults",
+			file:       true,
+			wantToken:  "file-token",
+			wantPrefix: "?",
+			wantCool:   5 * time.Second,
+		},
+		{
+			name:       "env over file",
+			file:       true,
+			env:        map[string]string{"BOT_PREFIX": "$", "BOT_COOLDOWN": "1s"},
+			wantToken:  "file-token",
+			wantPrefix: "$",
+			wantCool:   time.Second,
+		},
+		{
+			name:       "flags over env",
+			file:       true,
+			env:        map[string]string{"BOT_PREFIX": "$", "DISCORD_BOT_TOKEN": "env-token"},
+			args:       []string{"-prefix", "%", "-cooldown", "3s"},
+			wantToken:  "env-token",
+			wantPrefix: "%",
+			wantCool:   3 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			args := tt.args
+			if tt.file {
+				args = append([]string{"-config", writeConfigFile(t, "bot.yaml", file)}, args...)
+			}
+
+			config, err := LoadConfig(args)
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if config.Token != tt.wantToken || config.Prefix != tt.wantPrefix || config.Cooldown.Duration() != tt.wantCool {
+				t.Errorf("token %q, prefix %q, cooldown %v; want %q, %q, %v",
+					config.Token, config.Prefix, config.Cooldown, tt.wantToken, tt.wantPrefix, tt.wantCool)
+			}
+			// Fields no source sets keep their defaults
+			if config.Web.Port != 8080 || config.Shutdown.ModuleTimeout.Duration() != 5*time.Second {
+				t.Errorf("defaults were lost: web port %d, module timeout %v", config.Web.Port, config.Shutdown.ModuleTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadConfigReadsSecretFiles(t *testing.T) {
+	clearConfigEnv(t)
+	dir := t.TempDir()
+
+	tokenFile := filepath.Join(dir, "discord_token")
+	if err := os.WriteFile(tokenFile, []byte("secret-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DISCORD_BOT_TOKEN_FILE", tokenFile)
+	t.Setenv("BOT_OWNER_ID", testOwnerID)
+
+	config, err := LoadConfig(nil)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if config.Token != "secret-token" {
+		t.Errorf("token = %q, want the trimmed file contents", config.Token)
+	}
+
+	// The plain variable wins over its _FILE variant
+	t.Setenv("DISCORD_BOT_TOKEN", "env-token")
+	if config, err := LoadConfig(nil); err != nil || config.Token != "env-token" {
+		t.Errorf("with both set: token %v, error %v; want env-token", config, err)
+	}
+
+	t.Setenv("DISCORD_BOT_TOKEN", "")
+	t.Setenv("DISCORD_BOT_TOKEN_FILE", filepath.Join(dir, "missing"))
+	if _, err := LoadConfig(nil); err == nil || !strings.Contains(err.Error(), "DISCORD_BOT_TOKEN_FILE") {
+		t.Errorf("missing secret file: error %v, want one naming DISCORD_BOT_TOKEN_FILE", err)
+	}
+}
+
+func TestLoadConfigFileFormats(t *testing.T) {
+	files := map[string]string{
+		"bot.yaml": "token: file-token\nowner_id: \"" + testOwnerID + "\"\nprefix: \"?\"\ncooldown: 5s\n" +
+			"web:\n  port: 9090\nmodules:\n  stats:\n    interval: 10\n",
+		"bot.toml": "token = \"file-token\"\nowner_id = \"" + testOwnerID + "\"\nprefix = \"?\"\ncooldown = \"5s\"\n" +
+			"[web]\nport = 9090\n[modules.stats]\ninterval = 10\n",
+		"bot.json": `{"token": "file-token", "owner_id": "` + testOwnerID + `", "prefix": "?", "cooldown": "5s",` +
+			` "web": {"port": 9090}, "modules": {"stats": {"interval": 10}}}`,
+	}
+
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			clearConfigEnv(t)
+
+			config, err := LoadConfig([]string{"-config", writeConfigFile(t, name, data)})
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if config.Token != "file-token" || config.OwnerID != testOwnerID || config.Prefix != "?" ||
+				config.Cooldown.Duration() != 5*time.Second || config.Web.Port != 9090 {
+				t.Errorf("decoded %+v", config)
+			}
+
+			var stats struct {
+				Interval int `json:"interval"`
+			}
+			if err := config.DecodeModuleSettings("stats", &stats); err != nil || stats.Interval != 10 {
+				t.Errorf("module settings: %+v, %v", stats, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRejectsUnknownFields(t *testing.T) {
+	files := map[string]string{
+		"bot.yaml": "token: file-token\nowner_id: \"" + testOwnerID + "\"\nprefx: \"?\"\n",
+		"bot.toml": "token = \"file-token\"\nowner_id = \"" + testOwnerID + "\"\n[web]\nprot = 9090\n",
+		"bot.json": `{"token": "file-token", "owner_id": "` + testOwnerID + `", "debugmode": true}`,
+	}
+
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			clearConfigEnv(t)
+
+			_, err := LoadConfig([]string{"-config", writeConfigFile(t, name, data)})
+			if err == nil || !strings.Contains(err.Error(), "unknown field") {
+				t.Errorf("LoadConfig = %v, want an unknown field error", err)
+			}
+		})
+	}
+
+	clearConfigEnv(t)
+	if _, err := LoadConfig([]string{"-config", writeConfigFile(t, "bot.ini", "token=x")}); err == nil {
+		t.Error("an unsupported extension was accepted")
+	}
+}
+
+func TestValidateRequiresOwner(t *testing.T) {
+	tests := []struct {
+		owner   string
+		wantErr string
+	}{
+		{"", "owner_id is required"},
+		{"someone", "owner_id must be a Discord user ID"},
+		{testOwnerID, ""},
+	}
+
+	for _, tt := range tests {
+		config := DefaultConfig()
+		config.Token = "test"
+		config.OwnerID = tt.owner
+
+		err := config.Validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("owner %q: unexpected error %v", tt.owner, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("owner %q: error %v, want %q", tt.owner, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Connection tracking limits
+const (
+	connectionHistorySize   = 100 // entries kept across all shards
+	reconnectStormThreshold = 5   // disconnects within the window that trigger a warning
+	reconnectStormWindow    = time.Minute
+)
+
+// ConnectionEventType is the kind of a connection history entry
+type ConnectionEventType string
+
+const (
+	ConnectionConnected    ConnectionEventType = "connected"
+	ConnectionDisconnected ConnectionEventType = "disconnected"
+	ConnectionReady        ConnectionEventType = "ready"
+	ConnectionResumed      ConnectionEventType = "resumed"
+)
+
+// ConnectionEvent is one entry in the bot's connection history
+type ConnectionEvent struct {
+	Shard int                 `json:"shard"`
+	Type  ConnectionEventType `json:"type"`
+	Time  time.Time           `json:"time"`
+}
+
+// ConnectionState summarizes the bot's gateway connection: ready when every
+// shard is ready, otherwise the state of the least connected shard
+func (b *Bot) ConnectionState() ShardState {
+	rank := map[ShardState]int{
+		ShardIdle:         0,
+		ShardDisconnected: 1,
+		ShardConnecting:   2,
+		ShardConnected:    3,
+		ShardReady:        4,
+	}
+
+	state := ShardReady
+	for _, shard := range b.Shards() {
+		if rank[shard.State] < rank[state] {
+			state = shard.State
+		}
+	}
+	return state
+}
+
+// ConnectionHistory returns recent connects, disconnects, resumes and new
+// sessions across every shard, oldest first
+func (b *Bot) ConnectionHistory() []ConnectionEvent {
+	b.shards.mu.Lock()
+	defer b.shards.mu.Unlock()
+
+	return append([]ConnectionEvent(nil), b.shards.history...)
+}
+
+// trackShard follows a shard's connection through gateway events, records
+// it in the connection history and publishes it on the event bus
+func (b *Bot) trackShard(shard *Shard) {
+	shard.Session.AddHandler(func(_ *discordgo.Session, _ *discordgo.Connect) {
+		b.shardConnected(shard)
+	})
+	shard.Session.AddHandler(func(_ *discordgo.Session, _ *discordgo.Disconnect) {
+		b.shardDisconnected(shard)
+	})
+	shard.Session.AddHandler(func(_ *discordgo.Session, r *discordgo.Ready) {
+		b.shardReady(shard, r)
+	})
+	shard.Session.AddHandler(func(_ *discordgo.Session, _ *discordgo.Resumed) {
+		b.shardResumed(shard)
+	})
+}
+
+// shardDisconnected records a lost connection and warns when a shard keeps
+// reconnecting
+func (b *Bot) shardDisconnected(shard *Shard) {
+	now := time.Now()
+	shard.mu.Lock()
+	shard.state = ShardDisconnected
+	shard.since = now
+	shard.lastDisconnect = now
+	shard.connected = false
+
+	// Keep the disconnects inside the storm window
+	recent := shard.recentDrops[:0]
+	for _, drop := range shard.recentDrops {
+		if now.Sub(drop) < reconnectStormWindow {
+			recent = append(recent, drop)
+		}
+	}
+	shard.recentDrops = append(recent, now)
+	storm := len(shard.recentDrops) >= reconnectStormThreshold && now.Sub(shard.stormWarned) >= reconnectStormWindow
+	if storm {
+		shard.stormWarned = now
+	}
+	drops := len(shard.recentDrops)
+	shard.mu.Unlock()
+
+	if storm {
+		b.Logger("gateway").Warn("shard is reconnecting repeatedly", "shard", shard.ID, "disconnects", drops, "window", reconnectStormWindow)
+	}
+	b.recordConnection(shard.ID, ConnectionDisconnected)
+	b.Events.Publish(ShardDisconnectedEvent{Shard: shard.ID})
+}
+
+// shardReady records a new gateway session. Every session after the first
+// means events were missed while the shard was offline.
+func (b *Bot) shardReady(shard *Shard, r *discordgo.Ready) {
+	b.shardConnected(shard)
+
+	shard.mu.Lock()
+	reconnect := shard.identifies > 0
+	shard.identifies++
+	shard.state = ShardReady
+	shard.since = time.Now()
+	shard.mu.Unlock()
+
+	if reconnect {
+		b.Logger("gateway").Warn("shard started a new session; events sent while it was offline were missed", "shard", shard.ID)
+	}
+	b.recordConnection(shard.ID, ConnectionReady)
+	b.Events.Publish(ShardReadyEvent{
+		Shard:     shard.ID,
+		SessionID: r.SessionID,
+		Guilds:    len(r.Guilds),
+		Reconnect: reconnect,
+	})
+	b.checkReady()
+}
+
+// shardResumed records a resumed session and how long the shard was offline
+func (b *Bot) shardResumed(shard *Shard) {
+	b.shardConnected(shard)
+
+	shard.mu.Lock()
+	shard.resumes++
+	shard.state = ShardReady
+	shard.since = time.Now()
+	var downtime time.Duration
+	if !shard.lastDisconnect.IsZero() {
+		downtime = shard.since.Sub(shard.lastDisconnect)
+	}
+	shard.mu.Unlock()
+
+	b.Logger("gateway").Info("shard resumed", "shard", shard.ID, "downtime", downtime.Round(time.Millisecond))
+	b.recordConnection(shard.ID, ConnectionResumed)
+	b.Events.Publish(ShardResumedEvent{Shard: shard.ID, Downtime: downtime})
+}
+
+// shardConnected records that a shard's websocket opened. discordgo runs
+// handlers concurrently, so Ready or Resumed may be handled before Connect;
+// whichever comes first records the connection.
+func (b *Bot) shardConnected(shard *Shard) {
+	shard.mu.Lock()
+	if shard.connected {
+		shard.mu.Unlock()
+		return
+	}
+	shard.connected = true
+	shard.connects++
+	if shard.state != ShardReady {
+		shard.state = ShardConnected
+		shard.since = time.Now()
+	}
+	shard.mu.Unlock()
+
+	b.recordConnection(shard.ID, ConnectionConnected)
+	b.Events.Publish(ShardConnectedEvent{Shard: shard.ID})
+}
+
+// recordConnection adds an entry to the connection history
+func (b *Bot) recordConnection(shard int, eventType ConnectionEventType) {
+	m := b.shards
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.history = append(m.history, ConnectionEvent{Shard: shard, Type: eventType, Time: time.Now()})
+	if len(m.history) > connectionHistorySize {
+		m.history = m.history[len(m.history)-connectionHistorySize:]
+	}
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func historyTypes(bot *Bot) string {
+	var types []string
+	for _, event := range bot.ConnectionHistory() {
+		types = append(types, string(event.Type))
+	}
+	return strings.Join(types, ",")
+}
+
+func TestShardConnectionTracking(t *testing.T) {
+	bot := newShardedBot(t, ShardingConfig{}, "")
+	shard := bot.shards.shards[0]
+
+	var readies []ShardReadyEvent
+	On(bot.Events, func(event ShardReadyEvent) { readies = append(readies, event) })
+	var resumes []ShardResumedEvent
+	On(bot.Events, func(event ShardResumedEvent) { resumes = append(resumes, event) })
+
+	if state := bot.ConnectionState(); state != ShardIdle {
+		t.Fatalf("state before connecting = %s, want idle", state)
+	}
+
+	// discordgo runs handlers concurrently, so Ready may beat Connect
+	bot.shardReady(shard, &discordgo.Ready{SessionID: "first"})
+	bot.shardConnected(shard)
+	if status := bot.Shards()[0]; status.State != ShardReady || status.Sessions != 1 || status.Reconnects != 0 {
+		t.Fatalf("after the first session: %+v", status)
+	}
+
+	bot.shardDisconnected(shard)
+	if state := bot.ConnectionState(); state != ShardDisconnected {
+		t.Errorf("state after a disconnect = %s, want disconnected", state)
+	}
+	if bot.Shards()[0].LastDisconnect == nil {
+		t.Error("the disconnect time was not recorded")
+	}
+
+	bot.shardConnected(shard)
+	bot.shardResumed(shard)
+
+	bot.shardDisconnected(shard)
+	bot.shardConnected(shard)
+	bot.shardReady(shard, &discordgo.Ready{SessionID: "second"})
+
+	status := bot.Shards()[0]
+	if status.State != ShardReady || status.Sessions != 2 || status.Resumes != 1 || status.Reconnects != 2 {
+		t.Errorf("after a resume and a new session: %+v", status)
+	}
+
+	want := "connected,ready,disconnected,connected,resumed,disconnected,connected,ready"
+	if got := historyTypes(bot); got != want {
+		t.Errorf("history = %s, want %s", got, want)
+	}
+
+	if len(readies) != 2 || readies[0].Reconnect || !readies[1].Reconnect || readies[1].SessionID != "second" {
+		t.Errorf("ready events = %+v, want the second marked as a reconnect", readies)
+	}
+	if len(resumes) != 1 || resumes[0].Downtime < 0 {
+		t.Errorf("resume events = %+v", resumes)
+	}
+}
+
+func TestConnectionStateFollowsLeastConnectedShard(t *testing.T) {
+	bot := newShardedBot(t, ShardingConfig{Count: 2}, "")
+	if _, err := bot.configureShards(); err != nil {
+		t.Fatal(err)
+	}
+	first, second := bot.shards.shards[0], bot.shards.shards[1]
+
+	bot.shardReady(first, &discordgo.Ready{})
+	bot.shardConnected(second)
+	if state := bot.ConnectionState(); state != ShardConnected {
+		t.Errorf("state with one shard still connecting = %s, want connected", state)
+	}
+
+	bot.shardReady(second, &discordgo.Ready{})
+	if state := bot.ConnectionState(); state != ShardReady {
+		t.Errorf("state with every shard ready = %s, want ready", state)
+	}
+}
+
+func TestConnectionHistoryIsBounded(t *testing.T) {
+	bot := newShardedBot(t, ShardingConfig{}, "")
+	shard := bot.shards.shards[0]
+
+	for i := 0; i < connectionHistorySize; i++ {
+		bot.shardConnected(shard)
+		bot.shardDisconnected(shard)
+	}
+
+	history := bot.ConnectionHistory()
+	if len(history) != connectionHistorySize {
+		t.Fatalf("history has %d entries, want %d", len(history), connectionHistorySize)
+	}
+	if last := history[len(history)-1]; last.Type != ConnectionDisconnected {
+		t.Errorf("last entry = %s, want the newest disconnect", last.Type)
+	}
+
+	// That many drops in a row is a reconnect storm
+	shard.mu.Lock()
+	warned := !shard.stormWarned.IsZero()
+	shard.mu.Unlock()
+	if !warned {
+		t.Error("repeated disconnects did not trigger the reconnect warning")
+	}
+}
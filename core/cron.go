@@ -0,0 +1,251 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a recurring job runs next
+type Schedule interface {
+	// Next returns the first run time after the given time, or the zero
+	// time if the job should not run again
+	Next(after time.Time) time.Time
+	String() string
+}
+
+// cronMacros are the supported @-shorthands for common cron expressions
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField describes the range and names of one cron field
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDom    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cronSchedule is a parsed five-field cron expression evaluated in local time
+type cronSchedule struct {
+	spec                         string
+	minute, hour, dom, month     uint64
+	dow                          uint64
+	domRestricted, dowRestricted bool
+}
+
+// ParseCron parses a standard five-field cron expression
+// ("minute hour day-of-month month day-of-week"). Fields accept *, lists,
+// ranges, steps and month or weekday names; @hourly, @daily, @weekly,
+// @monthly, @yearly and "@every <duration>" are also accepted.
+//
+// As in standard cron, when both day-of-month and day-of-week are
+// restricted a day matching either field qualifies. Only a bare * (or ?)
+// leaves a day field unrestricted; a step such as */2 restricts it, so
+// "0 0 */2 * 1" runs on odd days of the month and on Mondays.
+func ParseCron(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("invalid cron expression %q: interval must be positive", spec)
+		}
+		return Every(interval), nil
+	}
+
+	expr := spec
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", spec, len(fields))
+	}
+
+	schedule := &cronSchedule{spec: spec}
+	var err error
+	targets := []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&schedule.minute, cronMinute},
+		{&schedule.hour, cronHour},
+		{&schedule.dom, cronDom},
+		{&schedule.month, cronMonth},
+		{&schedule.dow, cronDow},
+	}
+	for i, target := range targets {
+		if *target.bits, err = parseCronField(fields[i], target.field); err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+	}
+
+	// Sunday may be written as 0 or 7
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow = schedule.dow&^(1<<7) | 1
+	}
+	// Only a bare wildcard is unrestricted; */n still selects days
+	schedule.domRestricted = fields[2] != "*" && fields[2] != "?"
+	schedule.dowRestricted = fields[4] != "*" && fields[4] != "?"
+
+	return schedule, nil
+}
+
+// parseCronField converts one field into a bit set of allowed values
+func parseCronField(expr string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field: %q", field.name, part)
+			}
+			rangeExpr, step = part[:i], n
+		}
+
+		low, high := field.min, field.max
+		switch {
+		case rangeExpr == "*" || rangeExpr == "?":
+		case strings.Contains(rangeExpr, "-"):
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+			var err error
+			if low, err = cronValue(bounds[0], field); err != nil {
+				return 0, err
+			}
+			if high, err = cronValue(bounds[1], field); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range in %s field: %q", field.name, rangeExpr)
+			}
+		default:
+			value, err := cronValue(rangeExpr, field)
+			if err != nil {
+				return 0, err
+			}
+			// "5/15" means every 15 starting at 5
+			low = value
+			if step == 1 {
+				high = value
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// cronValue parses a single number or name within a field's range
+func cronValue(s string, field cronField) (int, error) {
+	if value, ok := field.names[strings.ToLower(s)]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(s)
+	if err != nil || value < field.min || value > field.max {
+		return 0, fmt.Errorf("invalid value in %s field: %q (allowed %d-%d)", field.name, s, field.min, field.max)
+	}
+	return value, nil
+}
+
+// Next returns the first matching minute after the given time
+func (c *cronSchedule) Next(after time.Time) time.Time {
+	t := after.Local().Truncate(time.Minute).Add(time.Minute)
+	loc := t.Location()
+
+	// Any valid expression matches within a few years (Feb 29 needs up to 8)
+	limit := t.AddDate(10, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if !next.After(t) {
+				next = t.Truncate(time.Hour).Add(time.Hour)
+			}
+			t = next
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches applies cron's rule that when both day fields are restricted a
+// day matching either one qualifies. A field is restricted unless it is a
+// bare * or ?.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func (c *cronSchedule) String() string {
+	return c.spec
+}
+
+// intervalSchedule runs at a fixed interval
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+// Every returns a schedule that runs at a fixed interval, measured from the
+// previous run
+func Every(interval time.Duration) Schedule {
+	return intervalSchedule{interval: interval}
+}
+
+func (s intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+func (s intervalSchedule) String() string {
+	return "every " + s.interval.String()
+}
+
+// onceSchedule fires a single time
+type onceSchedule struct {
+	at time.Time
+}
+
+func (s onceSchedule) Next(after time.Time) time.Time {
+	return s.at
+}
+
+func (s onceSchedule) String() string {
+	return "once at " + s.at.Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronDayFieldsUseOrWhenBothRestricted(t *testing.T) {
+	tests := []struct {
+		spec string
+		days []int // days of March 2026 the schedule runs on, up to the 10th
+	}{
+		// Day of week only: every Monday
+		{"0 0 * * 1", []int{2, 9}},
+		// Day of month only
+		{"0 0 */2 * *", []int{1, 3, 5, 7, 9}},
+		// A step restricts the field, so either field matching qualifies
+		{"0 0 */2 * 1", []int{1, 2, 3, 5, 7, 9}},
+		{"0 0 5 * */3", []int{1, 4, 5, 7, 8}}, // Sundays, Wednesdays and Saturdays
+		// ? is a bare wildcard like *
+		{"0 0 ? * 1", []int{2, 9}},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.spec, err)
+		}
+
+		var days []int
+		next := time.Date(2026, time.February, 28, 12, 0, 0, 0, time.Local)
+		for {
+			next = schedule.Next(next)
+			if next.Month() != time.March || next.Day() > 10 {
+				break
+			}
+			days = append(days, next.Day())
+		}
+
+		if len(days) != len(tt.days) {
+			t.Errorf("%q runs on %v, want %v", tt.spec, days, tt.days)
+			continue
+		}
+		for i := range days {
+			if days[i] != tt.days[i] {
+				t.Errorf("%q runs on %v, want %v", tt.spec, days, tt.days)
+				break
+			}
+		}
+	}
+}
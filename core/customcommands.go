@@ -0,0 +1,653 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom command storage namespaces. Commands are keyed "<guild>:<name>";
+// template values get a namespace per guild, customDataNamespace_<guild>.
+const (
+	customCommandsNamespace = "custom_commands"
+	customDataNamespace     = "custom_command_data"
+)
+
+// Limits that keep user-defined templates cheap to evaluate
+const (
+	customMaxCommands    = 100  // per guild
+	customMaxTemplate    = 2000 // bytes of template source
+	customMaxOutput      = 2000 // bytes of rendered output, Discord's limit
+	customMaxCalls       = 50   // storage and random calls per execution
+	customMaxRangeDepth  = 2
+	customMaxListLength  = 100 // elements in Args and split results
+	customMaxValueLength = 2000
+	customMaxKeys        = 500   // stored values per guild
+	customMaxSteps       = 10000 // function calls and output writes per execution
+	customMaxRenderTime  = 250 * time.Millisecond
+)
+
+var customNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// Custom command errors
+var (
+	ErrCustomCommandExists   = errors.New("custom command already exists")
+	ErrCustomCommandNotFound = errors.New("custom command not found")
+	errCustomOutputLimit     = errors.New("output is longer than 2000 characters")
+	errCustomTooSlow         = errors.New("the template took too long to run")
+	errCustomNoFormatting    = errors.New("printf, print and println are not available, write the text directly")
+)
+
+// CustomCommand is a guild-specific command defined by server admins. Its
+// response is a Go text/template, e.g.
+//
+//	🎲 {{.Author.Mention}} rolled {{randInt 1 6}}
+//	Visits: {{incr "visits"}}
+//
+// Available data: .Args, .Author (.ID, .Username, .Mention), .Guild.ID,
+// .Channel (.ID, .Mention) and .Prefix. Available functions:
+//
+//	arg n (the nth argument, from 1), join list sep, split s sep,
+//	upper, lower, trim, contains s sub, default fallback value,
+//	add, sub, mul, div, atoi,
+//	randInt min max, choice a b ..., get key, set key value, incr key, del key
+//
+// Storage functions use values private to the guild, at most 500 of them.
+// Templates cannot define
+// or call other templates and may only range over .Args or split results.
+// printf, print and println are not available, values are limited to 2000
+// characters, and an execution stops after 10000 function calls and writes
+// or 250ms.
+type CustomCommand struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Usage       string    `json:"usage"`
+	Category    string    `json:"category"`
+	Cooldown    int       `json:"cooldown"`
+	Template    string    `json:"template"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CustomCommands stores and evaluates custom commands for every guild
+type CustomCommands struct {
+	bot *Bot
+
+	// mu guards templates and is held across the read-check-write of
+	// Create, Update and Delete so concurrent changes cannot both pass
+	// their checks
+	mu        sync.Mutex
+	templates map[string]*template.Template // parsed templates by storage key
+}
+
+func newCustomCommands(bot *Bot) *CustomCommands {
+	return &CustomCommands{
+		bot:       bot,
+		templates: make(map[string]*template.Template),
+	}
+}
+
+func customKey(guildID, name string) string {
+	return guildID + ":" + name
+}
+
+// List returns a guild's custom commands sorted by name
+func (cc *CustomCommands) List(guildID string) ([]CustomCommand, error) {
+	keys, err := cc.bot.Storage.Keys(customCommandsNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := customKey(guildID, "")
+	commands := []CustomCommand{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		var cmd CustomCommand
+		if err := cc.bot.Storage.Load(customCommandsNamespace, key, &cmd); err != nil {
+			return nil, err
+		}
+		commands = append(commands, cmd)
+	}
+
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands, nil
+}
+
+// Get returns a guild's custom command by name
+func (cc *CustomCommands) Get(guildID, name string) (CustomCommand, error) {
+	var cmd CustomCommand
+	err := cc.bot.Storage.Load(customCommandsNamespace, customKey(guildID, name), &cmd)
+	if errors.Is(err, ErrNotFound) {
+		return cmd, fmt.Errorf("%w: %s", ErrCustomCommandNotFound, name)
+	}
+	return cmd, err
+}
+
+// Create adds a custom command to a guild
+func (cc *CustomCommands) Create(guildID string, cmd CustomCommand) error {
+	if err := cc.validate(guildID, &cmd); err != nil {
+		return err
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	existing, err := cc.List(guildID)
+	if err != nil {
+		return err
+	}
+	if len(existing) >= customMaxCommands {
+		return fmt.Errorf("a server can have at most %d custom commands", customMaxCommands)
+	}
+	for _, other := range existing {
+		if other.Name == cmd.Name {
+			return fmt.Errorf("%w: %s", ErrCustomCommandExists, cmd.Name)
+		}
+	}
+
+	cmd.CreatedAt = time.Now()
+	cmd.UpdatedAt = cmd.CreatedAt
+	return cc.save(guildID, cmd)
+}
+
+// Update replaces the definition of an existing custom command
+func (cc *CustomCommands) Update(guildID string, cmd CustomCommand) error {
+	if err := cc.validate(guildID, &cmd); err != nil {
+		return err
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	existing, err := cc.Get(guildID, cmd.Name)
+	if err != nil {
+		return err
+	}
+
+	cmd.CreatedBy = existing.CreatedBy
+	cmd.CreatedAt = existing.CreatedAt
+	cmd.UpdatedAt = time.Now()
+	return cc.save(guildID, cmd)
+}
+
+// Delete removes a custom command from a guild
+func (cc *CustomCommands) Delete(guildID, name string) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if _, err := cc.Get(guildID, name); err != nil {
+		return err
+	}
+
+	key := customKey(guildID, name)
+	delete(cc.templates, key)
+	return cc.bot.Storage.Delete(customCommandsNamespace, key)
+}
+
+// Render evaluates a custom command for the message that invoked it
+func (cc *CustomCommands) Render(cmd CustomCommand, m *discordgo.MessageCreate, args []string) (string, error) {
+	tmpl, err := cc.template(m.GuildID, cmd)
+	if err != nil {
+		return "", err
+	}
+
+	if len(args) > customMaxListLength {
+		args = args[:customMaxListLength]
+	}
+
+	data := customCommandData{
+		Args:    args,
+		Author:  customAuthor{ID: m.Author.ID, Username: m.Author.Username, Mention: m.Author.Mention()},
+		Guild:   customGuild{ID: m.GuildID},
+		Channel: customChannel{ID: m.ChannelID, Mention: "<#" + m.ChannelID + ">"},
+		Prefix:  cc.bot.Prefix(m.GuildID),
+	}
+
+	run := &customRun{
+		store:    cc,
+		guildID:  m.GuildID,
+		args:     args,
+		calls:    customMaxCalls,
+		steps:    customMaxSteps,
+		deadline: time.Now().Add(customMaxRenderTime),
+	}
+	out := &limitedBuilder{max: customMaxOutput, run: run}
+	if err := tmpl.Funcs(run.funcs()).Execute(out, data); err != nil {
+		for _, limit := range []error{errCustomOutputLimit, errCustomTooSlow} {
+			if errors.Is(err, limit) {
+				return "", limit
+			}
+		}
+		return "", cleanTemplateError(err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// validate normalizes cmd and checks that it can be saved
+func (cc *CustomCommands) validate(guildID string, cmd *CustomCommand) error {
+	if guildID == "" {
+		return errors.New("custom commands can only be created in a server")
+	}
+
+	cmd.Name = strings.ToLower(strings.TrimSpace(cmd.Name))
+	if !customNamePattern.MatchString(cmd.Name) {
+		return errors.New("command names must be 1-32 lowercase letters, digits, - or _")
+	}
+	if _, exists := cc.bot.Command(cmd.Name); exists {
+		return fmt.Errorf("%s is a built-in command", cmd.Name)
+	}
+	if cmd.Cooldown < 0 {
+		return errors.New("cooldown cannot be negative")
+	}
+	if cmd.Category == "" {
+		cmd.Category = "Custom"
+	}
+
+	_, err := parseCustomTemplate(cmd.Name, cmd.Template)
+	return err
+}
+
+// save stores cmd and drops its cached template. Callers must hold cc.mu.
+func (cc *CustomCommands) save(guildID string, cmd CustomCommand) error {
+	key := customKey(guildID, cmd.Name)
+	delete(cc.templates, key)
+	return cc.bot.Storage.Save(customCommandsNamespace, key, cmd)
+}
+
+// template returns a copy of the parsed template that is safe to bind
+// per-execution functions to
+func (cc *CustomCommands) template(guildID string, cmd CustomCommand) (*template.Template, error) {
+	key := customKey(guildID, cmd.Name)
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	tmpl, exists := cc.templates[key]
+	if !exists {
+		parsed, err := parseCustomTemplate(cmd.Name, cmd.Template)
+		if err != nil {
+			return nil, err
+		}
+		cc.templates[key] = parsed
+		tmpl = parsed
+	}
+	return tmpl.Clone()
+}
+
+// parseCustomTemplate parses a template and rejects constructs that could
+// run unbounded
+func parseCustomTemplate(name, text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("the response template is empty")
+	}
+	if len(text) > customMaxTemplate {
+		return nil, fmt.Errorf("the response template is longer than %d characters", customMaxTemplate)
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=zero").Funcs((&customRun{}).funcs()).Parse(text)
+	if err != nil {
+		return nil, cleanTemplateError(err)
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, errors.New("templates cannot define other templates")
+	}
+	if err := checkCustomNode(tmpl.Tree.Root, 0); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func checkCustomNode(node parse.Node, depth int) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkCustomNode(child, depth); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return checkCustomBranch(&n.BranchNode, depth)
+	case *parse.WithNode:
+		return checkCustomBranch(&n.BranchNode, depth)
+	case *parse.RangeNode:
+		if depth+1 > customMaxRangeDepth {
+			return fmt.Errorf("range can be nested at most %d levels deep", customMaxRangeDepth)
+		}
+		if !rangesOverList(n.Pipe) {
+			return errors.New("range is only allowed over .Args or split")
+		}
+		return checkCustomBranch(&n.BranchNode, depth+1)
+	case *parse.TemplateNode:
+		return errors.New("templates cannot call other templates")
+	}
+	return nil
+}
+
+func checkCustomBranch(branch *parse.BranchNode, depth int) error {
+	if err := checkCustomNode(branch.List, depth); err != nil {
+		return err
+	}
+	return checkCustomNode(branch.ElseList, depth)
+}
+
+// rangesOverList reports whether a range pipeline is .Args, $.Args or a
+// split call
+func rangesOverList(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) == 0 {
+		return false
+	}
+
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		return len(arg.Ident) == 1 && arg.Ident[0] == "Args"
+	case *parse.VariableNode:
+		return len(arg.Ident) == 2 && arg.Ident[0] == "$" && arg.Ident[1] == "Args"
+	case *parse.IdentifierNode:
+		return arg.Ident == "split"
+	}
+	return false
+}
+
+// cleanTemplateError strips the "template: name:line:col:" noise from
+// errors shown to users
+func cleanTemplateError(err error) error {
+	message := err.Error()
+	if index := strings.LastIndex(message, ": "); strings.HasPrefix(message, "template: ") && index >= 0 {
+		message = message[index+2:]
+	}
+	return errors.New(message)
+}
+
+// Template data
+type (
+	customCommandData struct {
+		Args    []string
+		Author  customAuthor
+		Guild   customGuild
+		Channel customChannel
+		Prefix  string
+	}
+	customAuthor struct {
+		ID, Username, Mention string
+	}
+	customGuild struct {
+		ID string
+	}
+	customChannel struct {
+		ID, Mention string
+	}
+)
+
+// customRun holds the state of one template execution
+type customRun struct {
+	store    *CustomCommands
+	guildID  string
+	args     []string
+	calls    int
+	steps    int
+	deadline time.Time
+}
+
+// step consumes one unit of the work budget and enforces the deadline, so
+// templates that loop or build large values stop early. Templates being
+// parsed have no budget yet and are not limited.
+func (r *customRun) step() error {
+	if r.deadline.IsZero() {
+		return nil
+	}
+	r.steps--
+	if r.steps < 0 || time.Now().After(r.deadline) {
+		return errCustomTooSlow
+	}
+	return nil
+}
+
+// value bounds the strings functions produce; nesting functions such as
+// join and html could otherwise grow them exponentially
+func (r *customRun) value(s string) (string, error) {
+	if err := r.step(); err != nil {
+		return "", err
+	}
+	if len(s) > customMaxValueLength {
+		return "", fmt.Errorf("values are limited to %d characters", customMaxValueLength)
+	}
+	return s, nil
+}
+
+// spend consumes one unit of the call budget
+func (r *customRun) spend() error {
+	if r.calls <= 0 {
+		return fmt.Errorf("too many storage or random calls (limit %d)", customMaxCalls)
+	}
+	r.calls--
+	return nil
+}
+
+func (r *customRun) dataKey(key string) (string, error) {
+	if key == "" || len(key) > 100 {
+		return "", errors.New("storage keys must be 1-100 characters")
+	}
+	return key, nil
+}
+
+// dataNamespace is the storage namespace holding the guild's values
+func (r *customRun) dataNamespace() string {
+	return customDataNamespace + "_" + r.guildID
+}
+
+// saveData stores a value, refusing new keys once the guild has
+// customMaxKeys of them
+func (r *customRun) saveData(key, value string) error {
+	namespace := r.dataNamespace()
+	keys, err := r.store.bot.Storage.Keys(namespace)
+	if err != nil {
+		return err
+	}
+	if len(keys) >= customMaxKeys && !containsString(keys, key) {
+		return fmt.Errorf("a server can store at most %d values", customMaxKeys)
+	}
+	return r.store.bot.Storage.Save(namespace, key, value)
+}
+
+// funcs returns the functions available to templates. Every function that
+// produces a string bounds it, and the builtins that could produce
+// unbounded output are replaced.
+func (r *customRun) funcs() template.FuncMap {
+	noFormatting := func(...interface{}) (string, error) { return "", errCustomNoFormatting }
+	return template.FuncMap{
+		"printf":   func(string, ...interface{}) (string, error) { return "", errCustomNoFormatting },
+		"print":    noFormatting,
+		"println":  noFormatting,
+		"html":     func(args ...interface{}) (string, error) { return r.value(template.HTMLEscaper(args...)) },
+		"js":       func(args ...interface{}) (string, error) { return r.value(template.JSEscaper(args...)) },
+		"urlquery": func(args ...interface{}) (string, error) { return r.value(template.URLQueryEscaper(args...)) },
+		"arg": func(n int) string {
+			if n < 1 || n > len(r.args) {
+				return ""
+			}
+			return r.args[n-1]
+		},
+		"join": func(list []string, sep string) (string, error) {
+			size := len(sep) * len(list)
+			for _, item := range list {
+				size += len(item)
+			}
+			if size > customMaxValueLength {
+				return "", fmt.Errorf("values are limited to %d characters", customMaxValueLength)
+			}
+			return r.value(strings.Join(list, sep))
+		},
+		"split": func(s, sep string) ([]string, error) {
+			if err := r.step(); err != nil {
+				return nil, err
+			}
+			parts := strings.SplitN(s, sep, customMaxListLength+1)
+			if len(parts) > customMaxListLength {
+				parts = parts[:customMaxListLength]
+			}
+			return parts, nil
+		},
+		"upper":    func(s string) (string, error) { return r.value(strings.ToUpper(s)) },
+		"lower":    func(s string) (string, error) { return r.value(strings.ToLower(s)) },
+		"trim":     func(s string) (string, error) { return r.value(strings.TrimSpace(s)) },
+		"contains": strings.Contains,
+		"default": func(fallback, value string) (string, error) {
+			if value == "" {
+				return r.value(fallback)
+			}
+			return r.value(value)
+		},
+		"add": func(a, b int) int { return a + b },
+		"sub": func(a, b int) int { return a - b },
+		"mul": func(a, b int) int { return a * b },
+		"div": func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		},
+		"atoi": func(s string) int {
+			n, _ := strconv.Atoi(strings.TrimSpace(s))
+			return n
+		},
+		"randInt": func(min, max int) (int, error) {
+			if err := r.spend(); err != nil {
+				return 0, err
+			}
+			if max < min {
+				return 0, errors.New("randInt: max is less than min")
+			}
+			return min + rand.Intn(max-min+1), nil
+		},
+		"choice": func(options ...string) (string, error) {
+			if err := r.spend(); err != nil {
+				return "", err
+			}
+			if len(options) == 0 {
+				return "", nil
+			}
+			return options[rand.Intn(len(options))], nil
+		},
+		"get": func(key string) (string, error) {
+			if err := r.spend(); err != nil {
+				return "", err
+			}
+			storageKey, err := r.dataKey(key)
+			if err != nil {
+				return "", err
+			}
+			var value string
+			if err := r.store.bot.Storage.Load(r.dataNamespace(), storageKey, &value); err != nil && !errors.Is(err, ErrNotFound) {
+				return "", err
+			}
+			return value, nil
+		},
+		"set": func(key string, value interface{}) (string, error) {
+			if err := r.spend(); err != nil {
+				return "", err
+			}
+			storageKey, err := r.dataKey(key)
+			if err != nil {
+				return "", err
+			}
+			text := fmt.Sprint(value)
+			if len(text) > customMaxValueLength {
+				return "", fmt.Errorf("stored values are limited to %d characters", customMaxValueLength)
+			}
+			return "", r.saveData(storageKey, text)
+		},
+		"incr": func(key string) (int, error) {
+			if err := r.spend(); err != nil {
+				return 0, err
+			}
+			storageKey, err := r.dataKey(key)
+			if err != nil {
+				return 0, err
+			}
+			var value string
+			if err := r.store.bot.Storage.Load(r.dataNamespace(), storageKey, &value); err != nil && !errors.Is(err, ErrNotFound) {
+				return 0, err
+			}
+			n, _ := strconv.Atoi(value)
+			n++
+			return n, r.saveData(storageKey, strconv.Itoa(n))
+		},
+		"del": func(key string) (string, error) {
+			if err := r.spend(); err != nil {
+				return "", err
+			}
+			storageKey, err := r.dataKey(key)
+			if err != nil {
+				return "", err
+			}
+			return "", r.store.bot.Storage.Delete(r.dataNamespace(), storageKey)
+		},
+	}
+}
+
+// limitedBuilder is a strings.Builder that fails once max bytes are written
+// or the execution runs out of budget
+type limitedBuilder struct {
+	strings.Builder
+	max int
+	run *customRun
+}
+
+func (b *limitedBuilder) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, errCustomOutputLimit
+	}
+	if err := b.run.step(); err != nil {
+		return 0, err
+	}
+	return b.Builder.Write(p)
+}
+
+// customCommandAdapter runs a custom command through the regular command
+// pipeline
+type customCommandAdapter struct {
+	store *CustomCommands
+	def   CustomCommand
+}
+
+func (c *customCommandAdapter) Name() string        { return c.def.Name }
+func (c *customCommandAdapter) Description() string { return c.def.Description }
+func (c *customCommandAdapter) Usage() string {
+	if c.def.Usage == "" {
+		return c.def.Name
+	}
+	return c.def.Usage
+}
+func (c *customCommandAdapter) Permissions() []string { return nil }
+func (c *customCommandAdapter) Cooldown() int         { return c.def.Cooldown }
+func (c *customCommandAdapter) Category() string      { return c.def.Category }
+
+func (c *customCommandAdapter) Execute(s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	output, err := c.store.Render(c.def, m, args)
+	if err != nil {
+		_, sendErr := c.store.bot.Messages.SendText(context.Background(), m.ChannelID, fmt.Sprintf("❌ Custom command `%s` failed: %v", c.def.Name, err))
+		return sendErr
+	}
+	if output == "" {
+		return nil
+	}
+	_, err = c.store.bot.Messages.SendText(context.Background(), m.ChannelID, output)
+	return err
+}
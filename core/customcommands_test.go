@@ -0,0 +1,137 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func newTestCustomCommands(t *testing.T) *CustomCommands {
+	t.Helper()
+
+	bot, err := NewBot(&Config{Token: "test", Prefix: "!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bot.Close() })
+	return bot.CustomCommands
+}
+
+func testCustomMessage() *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		GuildID:   "1",
+		ChannelID: "2",
+		Author:    &discordgo.User{ID: "3", Username: "tester"},
+	}}
+}
+
+func TestCustomCommandsRejectFormattingBuiltins(t *testing.T) {
+	cc := newTestCustomCommands(t)
+
+	for i, text := range []string{
+		`{{printf "%0*d" 999999 0}}`,
+		`{{print "a"}}`,
+		`{{println "a"}}`,
+	} {
+		cmd := CustomCommand{Name: fmt.Sprintf("fmt%d", i), Template: text}
+		if _, err := cc.Render(cmd, testCustomMessage(), nil); err == nil || !strings.Contains(err.Error(), errCustomNoFormatting.Error()) {
+			t.Errorf("Render(%s) error = %v, want %v", text, err, errCustomNoFormatting)
+		}
+	}
+}
+
+func TestCustomCommandsBoundExpensiveTemplates(t *testing.T) {
+	cc := newTestCustomCommands(t)
+	long := strings.Repeat("x", 100)
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		// Each join multiplies the value's length
+		{`{{$a := "` + long + `"}}{{$a = join (split $a "") $a}}{{$a = join (split $a "") $a}}`, "values are limited"},
+		// Nested loops that call functions without writing output
+		{`{{range split "` + long + `" ""}}{{range split "` + long + `" ""}}{{html "" | lower | upper | trim}}{{end}}{{end}}`, errCustomTooSlow.Error()},
+	}
+	for i, tt := range tests {
+		cmd := CustomCommand{Name: fmt.Sprintf("heavy%d", i), Template: tt.text}
+		start := time.Now()
+		_, err := cc.Render(cmd, testCustomMessage(), nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Render(%.40s...) error = %v, want %q", tt.text, err, tt.want)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Render(%.40s...) took %v", tt.text, elapsed)
+		}
+	}
+}
+
+func TestCustomCommandsCreateEnforcesLimitConcurrently(t *testing.T) {
+	cc := newTestCustomCommands(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < customMaxCommands+20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cc.Create("1", CustomCommand{Name: fmt.Sprintf("cmd%d", i), Template: "hi"})
+		}(i)
+	}
+	wg.Wait()
+
+	commands, err := cc.List("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != customMaxCommands {
+		t.Errorf("created %d commands, want the limit of %d", len(commands), customMaxCommands)
+	}
+}
+
+func TestCustomCommandsLimitStoredKeysPerGuild(t *testing.T) {
+	cc := newTestCustomCommands(t)
+
+	// Templates are cached by name, so every run gets its own
+	runs := 0
+	set := func(guildID string, keys []string) error {
+		t.Helper()
+		m := testCustomMessage()
+		m.GuildID = guildID
+		runs++
+		cmd := CustomCommand{Name: fmt.Sprintf("fill%d", runs), Template: `{{range split "` + strings.Join(keys, " ") + `" " "}}{{set . "x"}}{{end}}`}
+		_, err := cc.Render(cmd, m, nil)
+		return err
+	}
+
+	for batch := 0; batch < customMaxKeys/customMaxCalls; batch++ {
+		keys := make([]string, customMaxCalls)
+		for i := range keys {
+			keys[i] = fmt.Sprintf("k%d-%d", batch, i)
+		}
+		if err := set("1", keys); err != nil {
+			t.Fatalf("batch %d: %v", batch, err)
+		}
+	}
+
+	if err := set("1", []string{"new"}); err == nil || !strings.Contains(err.Error(), "at most") {
+		t.Errorf("set of a new key past the limit: error = %v", err)
+	}
+	if err := set("1", []string{"k0-0"}); err != nil {
+		t.Errorf("set of an existing key at the limit: %v", err)
+	}
+	if err := set("2", []string{"new"}); err != nil {
+		t.Errorf("another server is limited by the first one's keys: %v", err)
+	}
+
+	keys, err := cc.bot.Storage.Keys(customDataNamespace + "_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != customMaxKeys {
+		t.Errorf("server 1 stored %d keys, want %d", len(keys), customMaxKeys)
+	}
+}
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DependentModule is implemented by modules that require other modules to
+// be running before they initialize. Dependencies are module names.
+type DependentModule interface {
+	Dependencies() []string
+}
+
+// OptionalDependentModule is implemented by modules that should initialize
+// after the named modules when those are registered, but can run without them
+type OptionalDependentModule interface {
+	OptionalDependencies() []string
+}
+
+// providedService is an API object a module exposes to other modules
+type providedService struct {
+	owner   string
+	service interface{}
+}
+
+func requiredDependencies(module Module) []string {
+	if dependent, ok := module.(DependentModule); ok {
+		return dependent.Dependencies()
+	}
+	return nil
+}
+
+func optionalDependencies(module Module) []string {
+	if dependent, ok := module.(OptionalDependentModule); ok {
+		return dependent.OptionalDependencies()
+	}
+	return nil
+}
+
+// resolveModuleOrder sorts the registered modules so every module comes
+// after the modules it depends on. Modules without ordering constraints keep
+// their registration order. Missing required dependencies are not an error
+// here; the module fails when it is started instead.
+func (b *Bot) resolveModuleOrder() ([]string, error) {
+	registered := make(map[string]bool, len(b.Modules))
+	for _, module := range b.Modules {
+		registered[module.Name()] = true
+	}
+
+	// edges[x] lists the registered modules x must wait for
+	edges := make(map[string][]string, len(b.Modules))
+	for _, module := range b.Modules {
+		var deps []string
+		deps = append(deps, requiredDependencies(module)...)
+		deps = append(deps, optionalDependencies(module)...)
+		for _, dep := range deps {
+			if registered[dep] && dep != module.Name() {
+				edges[module.Name()] = append(edges[module.Name()], dep)
+			}
+		}
+	}
+
+	order := make([]string, 0, len(b.Modules))
+	placed := make(map[string]bool, len(b.Modules))
+	for len(order) < len(b.Modules) {
+		progressed := false
+		for _, module := range b.Modules {
+			name := module.Name()
+			if placed[name] {
+				continue
+			}
+
+			ready := true
+			for _, dep := range edges[name] {
+				if !placed[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				order = append(order, name)
+				placed[name] = true
+				progressed = true
+				break
+			}
+		}
+
+		if !progressed {
+			return nil, fmt.Errorf("module dependency cycle: %s", findCycle(edges, placed))
+		}
+	}
+
+	return order, nil
+}
+
+// findCycle returns a readable dependency cycle among unplaced modules
+func findCycle(edges map[string][]string, placed map[string]bool) string {
+	var start string
+	for name := range edges {
+		if !placed[name] && (start == "" || name < start) {
+			start = name
+		}
+	}
+
+	// Follow unplaced dependencies until a module repeats
+	path := []string{start}
+	seen := map[string]int{start: 0}
+	current := start
+	for {
+		var next string
+		for _, dep := range edges[current] {
+			if !placed[dep] {
+				next = dep
+				break
+			}
+		}
+		if index, visited := seen[next]; visited {
+			return strings.Join(append(path[index:], next), " -> ")
+		}
+		seen[next] = len(path)
+		path = append(path, next)
+		current = next
+	}
+}
+
+// checkDependencies verifies that every required dependency of module is
+// running
+func (b *Bot) checkDependencies(module Module) error {
+	for _, dep := range requiredDependencies(module) {
+		status, exists := b.ModuleStatus(dep)
+		if !exists {
+			return fmt.Errorf("missing dependency %s", dep)
+		}
+		if status.State != ModuleRunning {
+			return fmt.Errorf("dependency %s is %s", dep, status.State)
+		}
+	}
+	return nil
+}
+
+// runningDependents lists running modules that require name
+func (b *Bot) runningDependents(name string) []string {
+	var dependents []string
+	for _, module := range b.Modules {
+		for _, dep := range requiredDependencies(module) {
+			if dep != name {
+				continue
+			}
+			if status, _ := b.ModuleStatus(module.Name()); status.State == ModuleRunning {
+				dependents = append(dependents, module.Name())
+			}
+		}
+	}
+	return dependents
+}
+
+// Module returns a registered module by name
+func (b *Bot) Module(name string) (Module, bool) {
+	entry, exists := b.modules.lookup(name)
+	if !exists {
+		return nil, false
+	}
+	return entry.module, true
+}
+
+// ProvideService exposes svc to other modules through Service. It is
+// withdrawn automatically when module stops.
+func (b *Bot) ProvideService(module Module, svc interface{}) {
+	owner := module.Name()
+
+	b.modules.mu.Lock()
+	b.modules.services = append(b.modules.services, providedService{owner: owner, service: svc})
+	b.modules.mu.Unlock()
+
+	b.OnModuleStop(module, func() {
+		b.modules.mu.Lock()
+		defer b.modules.mu.Unlock()
+
+		services := b.modules.services[:0]
+		for _, provided := range b.modules.services {
+			if provided.owner != owner {
+				services = append(services, provided)
+			}
+		}
+		b.modules.services = services
+	})
+}
+
+// Service returns the first service of type T provided by a module, or the
+// first running module that itself implements T. T is usually an interface
+// describing the API a module needs, e.g.
+//
+//	logger, ok := core.Service[interface{ Log(string) }](bot)
+func Service[T any](b *Bot) (T, bool) {
+	b.modules.mu.Lock()
+	defer b.modules.mu.Unlock()
+
+	for _, provided := range b.modules.services {
+		if svc, ok := provided.service.(T); ok {
+			return svc, true
+		}
+	}
+
+	for _, module := range b.Modules {
+		entry, exists := b.modules.entries[module.Name()]
+		if !exists || entry.state != ModuleRunning {
+			continue
+		}
+		if svc, ok := module.(T); ok {
+			return svc, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
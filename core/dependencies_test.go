@@ -0,0 +1,211 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// optionalModule is a lifecycleModule that also has optional dependencies
+type optionalModule struct {
+	*lifecycleModule
+	optional []string
+}
+
+func (m optionalModule) OptionalDependencies() []string { return m.optional }
+
+func TestResolveModuleOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		modules []Module
+		want    string
+	}{
+		{
+			name: "independent modules keep registration order",
+			modules: []Module{
+				&lifecycleModule{name: "c"},
+				&lifecycleModule{name: "a"},
+				&lifecycleModule{name: "b"},
+			},
+			want: "c,a,b",
+		},
+		{
+			name: "dependencies come first",
+			modules: []Module{
+				&lifecycleModule{name: "web", deps: []string{"storage", "auth"}},
+				&lifecycleModule{name: "auth", deps: []string{"storage"}},
+				&lifecycleModule{name: "storage"},
+			},
+			want: "storage,auth,web",
+		},
+		{
+			name: "only constrained modules move",
+			modules: []Module{
+				&lifecycleModule{name: "a"},
+				&lifecycleModule{name: "b", deps: []string{"d"}},
+				&lifecycleModule{name: "c"},
+				&lifecycleModule{name: "d"},
+			},
+			want: "a,c,d,b",
+		},
+		{
+			name: "optional dependencies order when registered",
+			modules: []Module{
+				optionalModule{&lifecycleModule{name: "stats"}, []string{"logging", "metrics"}},
+				&lifecycleModule{name: "logging"},
+			},
+			want: "logging,stats",
+		},
+		{
+			name: "missing dependencies are left to start time",
+			modules: []Module{
+				&lifecycleModule{name: "a", deps: []string{"missing"}},
+				&lifecycleModule{name: "b"},
+			},
+			want: "a,b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := newLifecycleBot(t, tt.modules...)
+
+			order, err := bot.resolveModuleOrder()
+			if err != nil {
+				t.Fatalf("resolveModuleOrder: %v", err)
+			}
+			if got := strings.Join(order, ","); got != tt.want {
+				t.Errorf("order = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveModuleOrderReportsCycles(t *testing.T) {
+	tests := []struct {
+		name    string
+		modules []Module
+		want    string
+	}{
+		{
+			name: "two modules",
+			modules: []Module{
+				&lifecycleModule{name: "a", deps: []string{"b"}},
+				&lifecycleModule{name: "b", deps: []string{"a"}},
+			},
+			want: "a -> b -> a",
+		},
+		{
+			name: "cycle behind a dependent",
+			modules: []Module{
+				&lifecycleModule{name: "a", deps: []string{"b"}},
+				&lifecycleModule{name: "b", deps: []string{"c"}},
+				&lifecycleModule{name: "c", deps: []string{"d"}},
+				&lifecycleModule{name: "d", deps: []string{"b"}},
+				&lifecycleModule{name: "e"},
+			},
+			want: "b -> c -> d -> b",
+		},
+		{
+			name: "optional dependencies count",
+			modules: []Module{
+				optionalModule{&lifecycleModule{name: "x"}, []string{"y"}},
+				&lifecycleModule{name: "y", deps: []string{"x"}},
+			},
+			want: "x -> y -> x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := newLifecycleBot(t, tt.modules...)
+
+			_, err := bot.resolveModuleOrder()
+			if err == nil || !strings.HasSuffix(err.Error(), "module dependency cycle: "+tt.want) {
+				t.Errorf("error = %v, want cycle %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartModulesFailsModulesWithMissingDependencies(t *testing.T) {
+	needy := &lifecycleModule{name: "needy", deps: []string{"missing"}}
+	chained := &lifecycleModule{name: "chained", deps: []string{"needy"}}
+	fine := &lifecycleModule{name: "fine"}
+	bot := newLifecycleBot(t, chained, needy, fine)
+
+	if err := bot.StartModules(); err != nil {
+		t.Fatalf("StartModules: %v", err)
+	}
+
+	tests := []struct {
+		module  string
+		state   ModuleState
+		wantErr string
+	}{
+		{"needy", ModuleFailed, "missing dependency missing"},
+		{"chained", ModuleFailed, "dependency needy is failed"},
+		{"fine", ModuleRunning, ""},
+	}
+	for _, tt := range tests {
+		status := moduleState(t, bot, tt.module)
+		if status.State != tt.state || !strings.Contains(status.Error, tt.wantErr) {
+			t.Errorf("%s: %s %q, want %s %q", tt.module, status.State, status.Error, tt.state, tt.wantErr)
+		}
+	}
+	if inits, _ := needy.counts(); inits != 0 {
+		t.Errorf("needy was initialized %d times without its dependency", inits)
+	}
+}
+
+type greeter interface{ Greet() string }
+
+type greeterModule struct{ *lifecycleModule }
+
+func (greeterModule) Greet() string { return "module" }
+
+type greeterService struct{}
+
+func (greeterService) Greet() string { return "service" }
+
+func TestServiceLookup(t *testing.T) {
+	provider := &lifecycleModule{name: "provider"}
+	provider.initialize = func(bot *Bot) error {
+		bot.ProvideService(provider, greeterService{})
+		return nil
+	}
+	implementer := greeterModule{&lifecycleModule{name: "implementer"}}
+	bot := newLifecycleBot(t, implementer, provider)
+
+	if _, ok := Service[greeter](bot); ok {
+		t.Fatal("found a service before any module started")
+	}
+
+	if err := bot.StartModule("implementer"); err != nil {
+		t.Fatal(err)
+	}
+	if svc, ok := Service[greeter](bot); !ok || svc.Greet() != "module" {
+		t.Errorf("with the implementer running: %v, %v; want the module itself", svc, ok)
+	}
+
+	// Provided services win over modules implementing T
+	if err := bot.StartModule("provider"); err != nil {
+		t.Fatal(err)
+	}
+	if svc, ok := Service[greeter](bot); !ok || svc.Greet() != "service" {
+		t.Errorf("with the provider running: %v, %v; want the provided service", svc, ok)
+	}
+
+	// Stopping a module withdraws what it provided
+	if err := bot.StopModule("provider"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.StopModule("implementer"); err != nil {
+		t.Fatal(err)
+	}
+	if svc, ok := Service[greeter](bot); ok {
+		t.Errorf("found %v after every module stopped", svc)
+	}
+	if _, ok := Service[interface{ Missing() }](bot); ok {
+		t.Error("found a service for an interface nothing implements")
+	}
+}
@@ -0,0 +1,316 @@
+package core
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// asyncQueueSize is how many events an async subscriber may fall behind
+// before new events are dropped
+const asyncQueueSize = 256
+
+// Event is a message published on the bot's event bus. EventName identifies
+// the kind of event and must not depend on the receiver's field values, so
+// that the zero value of an event type names it as well.
+type Event interface {
+	EventName() string
+}
+
+// Framework events published by the bot
+type (
+	// CommandExecutedEvent is published after a command completes successfully
+	CommandExecutedEvent struct {
+		Command   string        `json:"command"`
+		Args      []string      `json:"args"`
+		GuildID   string        `json:"guild_id"`
+		ChannelID string        `json:"channel_id"`
+		UserID    string        `json:"user_id"`
+		Duration  time.Duration `json:"duration"`
+	}
+
+	// CommandFailedEvent is published when a command returns an error or panics
+	CommandFailedEvent struct {
+		Command   string        `json:"command"`
+		Args      []string      `json:"args"`
+		GuildID   string        `json:"guild_id"`
+		ChannelID string        `json:"channel_id"`
+		UserID    string        `json:"user_id"`
+		Duration  time.Duration `json:"duration"`
+		Err       error         `json:"-"`
+	}
+
+	// ModuleStartedEvent is published when a module finishes initializing
+	ModuleStartedEvent struct {
+		Module string `json:"module"`
+	}
+
+	// ModuleStoppedEvent is published when a module shuts down cleanly
+	ModuleStoppedEvent struct {
+		Module string `json:"module"`
+	}
+
+	// ModuleFailedEvent is published when a module fails to start or stop
+	ModuleFailedEvent struct {
+		Module string `json:"module"`
+		Err    error  `json:"-"`
+	}
+
+	// ConfigReloadedEvent is published after a configuration reload with changes
+	ConfigReloadedEvent struct {
+		Report *ReloadReport `json:"report"`
+	}
+
+	// ShardConnectedEvent is published when a shard's gateway websocket opens
+	ShardConnectedEvent struct {
+		Shard int `json:"shard"`
+	}
+
+	// ShardDisconnectedEvent is published when a shard loses its connection
+	ShardDisconnectedEvent struct {
+		Shard int `json:"shard"`
+	}
+
+	// ShardReadyEvent is published when a shard starts a new gateway session.
+	// Reconnect is true when the shard had a session before that could not be
+	// resumed, so events were missed and caches should be re-synced.
+	ShardReadyEvent struct {
+		Shard     int    `json:"shard"`
+		SessionID string `json:"session_id"`
+		Guilds    int    `json:"guilds"`
+		Reconnect bool   `json:"reconnect"`
+	}
+
+	// ShardResumedEvent is published when a shard resumes its session after a
+	// disconnect without missing events
+	ShardResumedEvent struct {
+		Shard    int           `json:"shard"`
+		Downtime time.Duration `json:"downtime"`
+	}
+
+	// MessageFailedEvent is published when the message queue gives up on an
+	// outgoing message
+	MessageFailedEvent struct {
+		ChannelID string `json:"channel_id"`
+		Priority  string `json:"priority"`
+		Attempts  int    `json:"attempts"`
+		Err       error  `json:"-"`
+	}
+)
+
+func (CommandExecutedEvent) EventName() string   { return "command.executed" }
+func (CommandFailedEvent) EventName() string     { return "command.failed" }
+func (ModuleStartedEvent) EventName() string     { return "module.started" }
+func (ModuleStoppedEvent) EventName() string     { return "module.stopped" }
+func (ModuleFailedEvent) EventName() string      { return "module.failed" }
+func (ConfigReloadedEvent) EventName() string    { return "config.reloaded" }
+func (ShardConnectedEvent) EventName() string    { return "shard.connected" }
+func (ShardDisconnectedEvent) EventName() string { return "shard.disconnected" }
+func (ShardReadyEvent) EventName() string        { return "shard.ready" }
+func (ShardResumedEvent) EventName() string      { return "shard.resumed" }
+func (MessageFailedEvent) EventName() string     { return "message.failed" }
+
+// MarshalJSON encodes the error as a string
+func (e CommandFailedEvent) MarshalJSON() ([]byte, error) {
+	type plain CommandFailedEvent
+	return json.Marshal(struct {
+		plain
+		Error string `json:"error"`
+	}{plain(e), errorString(e.Err)})
+}
+
+// MarshalJSON encodes the error as a string
+func (e ModuleFailedEvent) MarshalJSON() ([]byte, error) {
+	type plain ModuleFailedEvent
+	return json.Marshal(struct {
+		plain
+		Error string `json:"error"`
+	}{plain(e), errorString(e.Err)})
+}
+
+// MarshalJSON encodes the error as a string
+func (e MessageFailedEvent) MarshalJSON() ([]byte, error) {
+	type plain MessageFailedEvent
+	return json.Marshal(struct {
+		plain
+		Error string `json:"error"`
+	}{plain(e), errorString(e.Err)})
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// EventBus delivers events to subscribers. Synchronous subscribers run on
+// the publishing goroutine in subscription order; asynchronous subscribers
+// each get their own goroutine and receive events in publish order.
+type EventBus struct {
+	mu     sync.RWMutex
+	subs   map[string][]*Subscription
+	nextID uint64
+	log    *slog.Logger
+}
+
+// Subscription is a handle to a registered event handler
+type Subscription struct {
+	bus     *EventBus
+	id      uint64
+	name    string
+	handler func(Event)
+	queue   chan Event // nil for synchronous subscribers
+
+	mu     sync.Mutex // guards closed and sends on queue
+	closed bool
+}
+
+// NewEventBus creates an empty event bus
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[string][]*Subscription), log: slog.Default()}
+}
+
+// Subscribe registers a handler that runs synchronously for events named name
+func (eb *EventBus) Subscribe(name string, handler func(Event)) *Subscription {
+	return eb.subscribe(name, handler, false)
+}
+
+// SubscribeAsync registers a handler that runs on its own goroutine for
+// events named name
+func (eb *EventBus) SubscribeAsync(name string, handler func(Event)) *Subscription {
+	return eb.subscribe(name, handler, true)
+}
+
+func (eb *EventBus) subscribe(name string, handler func(Event), async bool) *Subscription {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.nextID++
+	sub := &Subscription{
+		bus:     eb,
+		id:      eb.nextID,
+		name:    name,
+		handler: handler,
+	}
+	if async {
+		sub.queue = make(chan Event, asyncQueueSize)
+		go sub.run()
+	}
+	eb.subs[name] = append(eb.subs[name], sub)
+
+	return sub
+}
+
+// Publish delivers an event to every subscriber of its name
+func (eb *EventBus) Publish(event Event) {
+	eb.mu.RLock()
+	subs := eb.subs[event.EventName()]
+	eb.mu.RUnlock()
+
+	for _, sub := range subs {
+		if sub.queue == nil {
+			sub.deliver(event)
+		} else {
+			sub.enqueue(event)
+		}
+	}
+}
+
+// Unsubscribe removes the handler. Pending async events are discarded; a
+// handler call already running is not interrupted. It is safe to call more
+// than once.
+func (s *Subscription) Unsubscribe() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	if s.queue != nil {
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	subs := s.bus.subs[s.name]
+	for i, sub := range subs {
+		if sub.id == s.id {
+			// Copy so concurrent publishers keep iterating the old slice
+			updated := make([]*Subscription, 0, len(subs)-1)
+			updated = append(updated, subs[:i]...)
+			s.bus.subs[s.name] = append(updated, subs[i+1:]...)
+			break
+		}
+	}
+	if len(s.bus.subs[s.name]) == 0 {
+		delete(s.bus.subs, s.name)
+	}
+}
+
+// enqueue hands an event to an async subscriber without blocking
+func (s *Subscription) enqueue(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.queue <- event:
+	default:
+		s.bus.log.Warn("dropping event for slow subscriber", "event", event.EventName())
+	}
+}
+
+// run drains an async subscriber's queue until it is unsubscribed. Events
+// still queued at that point are discarded by deliver.
+func (s *Subscription) run() {
+	for event := range s.queue {
+		s.deliver(event)
+	}
+}
+
+// deliver calls the handler, recovering from panics so one subscriber
+// cannot break the publisher or other subscribers
+func (s *Subscription) deliver(event Event) {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.bus.log.Error("event handler panicked", "event", event.EventName(), "panic", r)
+		}
+	}()
+	s.handler(event)
+}
+
+// On subscribes a typed handler to events of type T
+//
+//	core.On(bot.Events, func(e core.CommandExecutedEvent) { ... })
+func On[T Event](bus *EventBus, handler func(T)) *Subscription {
+	var zero T
+	return bus.Subscribe(zero.EventName(), func(event Event) {
+		if typed, ok := event.(T); ok {
+			handler(typed)
+		}
+	})
+}
+
+// OnAsync subscribes a typed handler that runs on its own goroutine
+func OnAsync[T Event](bus *EventBus, handler func(T)) *Subscription {
+	var zero T
+	return bus.SubscribeAsync(zero.EventName(), func(event Event) {
+		if typed, ok := event.(T); ok {
+			handler(typed)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUnsubscribeDiscardsPendingAsyncEvents(t *testing.T) {
+	bus := NewEventBus()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	var delivered atomic.Int32
+	sub := bus.SubscribeAsync(ModuleStartedEvent{}.EventName(), func(Event) {
+		delivered.Add(1)
+		started <- struct{}{}
+		<-release
+	})
+
+	// The first event blocks the handler so the rest stay queued
+	for i := 0; i < 5; i++ {
+		bus.Publish(ModuleStartedEvent{})
+	}
+	<-started
+	sub.Unsubscribe()
+	close(release)
+
+	time.Sleep(50 * time.Millisecond)
+	if got := delivered.Load(); got != 1 {
+		t.Errorf("handler ran %d times, want 1: queued events ran after Unsubscribe", got)
+	}
+}
+
+func TestPublishOrderForSyncAndAsyncSubscribers(t *testing.T) {
+	bus := NewEventBus()
+
+	var order []int
+	On(bus, func(e CommandExecutedEvent) { order = append(order, len(e.Command)) })
+
+	received := make(chan int, 3)
+	OnAsync(bus, func(e CommandExecutedEvent) { received <- len(e.Command) })
+
+	for _, name := range []string{"a", "bb", "ccc"} {
+		bus.Publish(CommandExecutedEvent{Command: name})
+	}
+
+	if len(order) != 3 || order[0] != 1 || order[2] != 3 {
+		t.Errorf("sync deliveries = %v, want [1 2 3]", order)
+	}
+	for want := 1; want <= 3; want++ {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("async delivery %d = %d", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("async event not delivered")
+		}
+	}
+}
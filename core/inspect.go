@@ -0,0 +1,120 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Definition describes what a bot registered, for tools that inspect it
+// without connecting. Commands added by plugins and WASM modules only exist
+// once the bot is running and are not included.
+type Definition struct {
+	Version             string                          `json:"version"`
+	Prefix              string                          `json:"prefix"`
+	Commands            []CommandDefinition             `json:"commands"`
+	Modules             []ModuleDefinition              `json:"modules"`
+	Middleware          []string                        `json:"middleware"`
+	RequiredIntents     []string                        `json:"required_intents"`
+	Intents             []string                        `json:"intents"` // what the bot would identify with
+	ApplicationCommands []*discordgo.ApplicationCommand `json:"application_commands"`
+}
+
+// CommandDefinition describes a registered command
+type CommandDefinition struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Usage       string   `json:"usage"`
+	Category    string   `json:"category"`
+	Permissions []string `json:"permissions"`
+	Cooldown    int      `json:"cooldown"`
+	Slash       bool     `json:"slash"`
+}
+
+// ModuleDefinition describes a registered module, in start order
+type ModuleDefinition struct {
+	Name                 string   `json:"name"`
+	Version              string   `json:"version"`
+	Dependencies         []string `json:"dependencies,omitempty"`
+	OptionalDependencies []string `json:"optional_dependencies,omitempty"`
+}
+
+// Definition describes the commands, modules and middleware registered so
+// far and the intents the bot would identify with
+func (b *Bot) Definition() (*Definition, error) {
+	intents := b.RequiredIntents()
+	if len(b.Config().Intents) > 0 {
+		configured, err := ParseIntents(b.Config().Intents)
+		if err != nil {
+			return nil, err
+		}
+		intents = configured
+	}
+
+	definition := &Definition{
+		Version:             b.Version,
+		Prefix:              b.Config().Prefix,
+		Commands:            make([]CommandDefinition, 0),
+		Modules:             make([]ModuleDefinition, 0),
+		Middleware:          make([]string, 0),
+		RequiredIntents:     IntentNames(b.RequiredIntents()),
+		Intents:             IntentNames(intents),
+		ApplicationCommands: b.ApplicationCommands(),
+	}
+
+	b.commandsMu.RLock()
+	for _, cmd := range b.Commands {
+		_, slash := cmd.(SlashCommand)
+		definition.Commands = append(definition.Commands, CommandDefinition{
+			Name:        cmd.Name(),
+			Description: cmd.Description(),
+			Usage:       cmd.Usage(),
+			Category:    cmd.Category(),
+			Permissions: cmd.Permissions(),
+			Cooldown:    cmd.Cooldown(),
+			Slash:       slash,
+		})
+	}
+	b.commandsMu.RUnlock()
+	sort.Slice(definition.Commands, func(i, j int) bool { return definition.Commands[i].Name < definition.Commands[j].Name })
+
+	order, err := b.resolveModuleOrder()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range order {
+		module, _ := b.Module(name)
+		definition.Modules = append(definition.Modules, ModuleDefinition{
+			Name:                 module.Name(),
+			Version:              module.Version(),
+			Dependencies:         requiredDependencies(module),
+			OptionalDependencies: optionalDependencies(module),
+		})
+	}
+
+	for _, middleware := range b.Middleware {
+		definition.Middleware = append(definition.Middleware, middleware.Name())
+	}
+	return definition, nil
+}
+
+// Inspect writes the bot's definition as JSON to path. forge list, check
+// and slash call it from a ToolRunner instead of connecting.
+func (b *Bot) Inspect(path string) error {
+	definition, err := b.Definition()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(definition, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding bot definition: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing bot definition: %w", err)
+	}
+	return nil
+}
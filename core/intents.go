@@ -0,0 +1,199 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+)
+
+// intentNames maps configuration names to gateway intents
+var intentNames = map[string]discordgo.Intent{
+	"guilds":                        discordgo.IntentsGuilds,
+	"guild_members":                 discordgo.IntentsGuildMembers,
+	"guild_bans":                    discordgo.IntentsGuildBans,
+	"guild_emojis":                  discordgo.IntentsGuildEmojis,
+	"guild_integrations":            discordgo.IntentsGuildIntegrations,
+	"guild_webhooks":                discordgo.IntentsGuildWebhooks,
+	"guild_invites":                 discordgo.IntentsGuildInvites,
+	"guild_voice_states":            discordgo.IntentsGuildVoiceStates,
+	"guild_presences":               discordgo.IntentsGuildPresences,
+	"guild_messages":                discordgo.IntentsGuildMessages,
+	"guild_message_reactions":       discordgo.IntentsGuildMessageReactions,
+	"guild_message_typing":          discordgo.IntentsGuildMessageTyping,
+	"direct_messages":               discordgo.IntentsDirectMessages,
+	"direct_message_reactions":      discordgo.IntentsDirectMessageReactions,
+	"direct_message_typing":         discordgo.IntentsDirectMessageTyping,
+	"message_content":               discordgo.IntentMessageContent,
+	"guild_scheduled_events":        discordgo.IntentGuildScheduledEvents,
+	"auto_moderation_configuration": discordgo.IntentAutoModerationConfiguration,
+	"auto_moderation_execution":     discordgo.IntentAutoModerationExecution,
+}
+
+// ParseIntents converts intent names into a gateway intent bitmask. The
+// names "all" and "all_unprivileged" are also accepted.
+func ParseIntents(names []string) (discordgo.Intent, error) {
+	var intents discordgo.Intent
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "all":
+			intents |= discordgo.IntentsAll
+			continue
+		case "all_unprivileged":
+			intents |= discordgo.IntentsAllWithoutPrivileged
+			continue
+		}
+
+		intent, exists := intentNames[name]
+		if !exists {
+			return 0, fmt.Errorf("unknown intent %q", name)
+		}
+		intents |= intent
+	}
+	return intents, nil
+}
+
+// DefaultIntents are the intents the framework needs for prefix commands:
+// guild and direct messages with their content, plus guilds for the state
+// cache
+const DefaultIntents = discordgo.IntentsGuilds |
+	discordgo.IntentsGuildMessages |
+	discordgo.IntentsDirectMessages |
+	discordgo.IntentMessageContent
+
+// PrivilegedIntents must also be enabled for the application in the Discord
+// Developer Portal
+const PrivilegedIntents = discordgo.IntentsGuildMembers |
+	discordgo.IntentsGuildPresences |
+	discordgo.IntentMessageContent
+
+// closeDisallowedIntents is the gateway close code for intents the
+// application is not allowed to use
+const closeDisallowedIntents = 4014
+
+// IntentsProvider is implemented by commands and modules that need gateway
+// intents, e.g. a welcome module that handles GuildMemberAdd events
+type IntentsProvider interface {
+	RequiredIntents() discordgo.Intent
+}
+
+// IntentNames returns the configuration names of the intents in a bitmask
+func IntentNames(intents discordgo.Intent) []string {
+	names := make([]string, 0)
+	for bit := discordgo.Intent(1); bit != 0 && bit <= intents; bit <<= 1 {
+		if intents&bit == 0 {
+			continue
+		}
+		for name, intent := range intentNames {
+			if intent == bit {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// RequiredIntents returns the minimum intents needed by the framework and
+// every registered command and module
+func (b *Bot) RequiredIntents() discordgo.Intent {
+	intents := DefaultIntents
+	for _, requirers := range b.intentRequirers() {
+		intents |= requirers.intents
+	}
+	return intents
+}
+
+// Intents returns the gateway intents the bot identifies with. Until Start
+// is called this is discordgo's default.
+func (b *Bot) Intents() discordgo.Intent {
+	return b.Session.Identify.Intents
+}
+
+// intentRequirer is a command or module that declared intents
+type intentRequirer struct {
+	name    string
+	intents discordgo.Intent
+}
+
+// intentRequirers lists every registered command and module that declares
+// the intents it needs
+func (b *Bot) intentRequirers() []intentRequirer {
+	var requirers []intentRequirer
+
+	b.commandsMu.RLock()
+	for name, cmd := range b.Commands {
+		if provider, ok := cmd.(IntentsProvider); ok {
+			requirers = append(requirers, intentRequirer{"command " + name, provider.RequiredIntents()})
+		}
+	}
+	b.commandsMu.RUnlock()
+
+	for _, module := range b.Modules {
+		if provider, ok := module.(IntentsProvider); ok {
+			requirers = append(requirers, intentRequirer{"module " + module.Name(), provider.RequiredIntents()})
+		}
+	}
+	return requirers
+}
+
+// resolveIntents sets the intents used to identify. Without configured
+// intents the bot asks only for what it requires; with them, the configured
+// set is used as is and missing requirements are reported.
+func (b *Bot) resolveIntents() error {
+	required := b.RequiredIntents()
+	intents := required
+
+	if len(b.Config().Intents) > 0 {
+		configured, err := ParseIntents(b.Config().Intents)
+		if err != nil {
+			return err
+		}
+		intents = configured
+
+		if missing := required &^ configured; missing != 0 {
+			for _, name := range IntentNames(missing) {
+				intent := intentNames[name]
+				var users []string
+				if DefaultIntents&intent != 0 {
+					users = append(users, "the framework")
+				}
+				for _, requirer := range b.intentRequirers() {
+					if requirer.intents&intent != 0 {
+						users = append(users, requirer.name)
+					}
+				}
+
+				b.Logger("gateway").Warn("intent is required but not enabled in the configuration",
+					"intent", name,
+					"privileged", PrivilegedIntents&intent != 0,
+					"required_by", strings.Join(users, ", "))
+			}
+		}
+	}
+
+	if privileged := intents & PrivilegedIntents; privileged != 0 {
+		b.Logger("gateway").Info("using privileged intents; they must be enabled in the Discord Developer Portal", "intents", strings.Join(IntentNames(privileged), ","))
+	}
+
+	b.Session.Identify.Intents = intents
+	b.Logger("gateway").Info("gateway intents", "intents", strings.Join(IntentNames(intents), ","))
+	return nil
+}
+
+// explainOpenError turns a disallowed intents close into an actionable error
+func (b *Bot) explainOpenError(err error) error {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) && closeErr.Code == closeDisallowedIntents {
+		intents := IntentNames(b.Intents() & PrivilegedIntents)
+		if len(intents) == 0 {
+			intents = IntentNames(b.Intents())
+		}
+		return fmt.Errorf("error opening connection: Discord rejected the intents %s; enable them for the bot in the Discord Developer Portal: %w",
+			strings.Join(intents, ", "), err)
+	}
+	return fmt.Errorf("error opening connection: %w", err)
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestIntentNamesRoundTrip(t *testing.T) {
+	for name, intent := range intentNames {
+		parsed, err := ParseIntents([]string{name})
+		if err != nil || parsed != intent {
+			t.Errorf("ParseIntents(%s) = %v, %v; want %v", name, parsed, err, intent)
+		}
+		if names := IntentNames(intent); len(names) != 1 || names[0] != name {
+			t.Errorf("IntentNames(%v) = %v, want [%s]", intent, names, name)
+		}
+	}
+
+	for _, set := range []string{"all", "all_unprivileged"} {
+		intents, err := ParseIntents([]string{set})
+		if err != nil {
+			t.Fatalf("ParseIntents(%s): %v", set, err)
+		}
+		again, err := ParseIntents(IntentNames(intents))
+		if err != nil || again != intents {
+			t.Errorf("%s: names %v parse to %v, %v; want %v", set, IntentNames(intents), again, err, intents)
+		}
+	}
+}
+
+func TestParseIntents(t *testing.T) {
+	tests := []struct {
+		names   []string
+		want    discordgo.Intent
+		wantErr string
+	}{
+		{nil, 0, ""},
+		{[]string{" Guilds ", "GUILD_MESSAGES"}, discordgo.IntentsGuilds | discordgo.IntentsGuildMessages, ""},
+		{[]string{"guilds", "guilds"}, discordgo.IntentsGuilds, ""},
+		{[]string{"all_unprivileged", "message_content"}, discordgo.IntentsAllWithoutPrivileged | discordgo.IntentMessageContent, ""},
+		{[]string{"guilds", "guild_mesages"}, 0, `unknown intent "guild_mesages"`},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseIntents(tt.names)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseIntents(%q) error = %v, want %q", tt.names, err, tt.wantErr)
+			}
+		case err != nil || got != tt.want:
+			t.Errorf("ParseIntents(%q) = %v, %v; want %v", tt.names, got, err, tt.want)
+		}
+	}
+}
+
+// intentsModule is a module that declares the intents it needs
+type intentsModule struct {
+	*lifecycleModule
+	intents discordgo.Intent
+}
+
+func (m intentsModule) RequiredIntents() discordgo.Intent { return m.intents }
+
+func TestResolveIntents(t *testing.T) {
+	members := intentsModule{&lifecycleModule{name: "welcome"}, discordgo.IntentsGuildMembers}
+
+	tests := []struct {
+		name       string
+		configured []string
+		want       []string
+	}{
+		{
+			name: "required intents without configuration",
+			want: []string{"direct_messages", "guild_members", "guild_messages", "guilds", "message_content"},
+		},
+		{
+			name:       "configured intents are used as is",
+			configured: []string{"guilds", "guild_messages"},
+			want:       []string{"guild_messages", "guilds"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, err := NewBot(&Config{Token: "test", Prefix: "!", Intents: tt.configured})
+			if err != nil {
+				t.Fatal(err)
+			}
+			bot.RegisterModule(members)
+
+			if err := bot.resolveIntents(); err != nil {
+				t.Fatalf("resolveIntents: %v", err)
+			}
+			got := IntentNames(bot.Intents())
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("intents = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
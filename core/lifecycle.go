@@ -0,0 +1,329 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModuleState is the lifecycle state of a registered module
+type ModuleState string
+
+const (
+	ModuleRegistered   ModuleState = "registered"
+	ModuleInitializing ModuleState = "initializing"
+	ModuleRunning      ModuleState = "running"
+	ModuleFailed       ModuleState = "failed"
+	ModuleStopped      ModuleState = "stopped"
+)
+
+var (
+	// ErrUnknownModule is returned when no module with the given name is registered
+	ErrUnknownModule = errors.New("unknown module")
+	// ErrModuleShuttingDown is returned when a module is started while a
+	// Shutdown call that timed out is still running
+	ErrModuleShuttingDown = errors.New("module is still shutting down")
+)
+
+// ModuleStatus is a snapshot of a module's lifecycle state
+type ModuleStatus struct {
+	Name     string      `json:"name"`
+	Version  string      `json:"version"`
+	State    ModuleState `json:"state"`
+	Error    string      `json:"error,omitempty"`
+	Since    time.Time   `json:"since"`
+	Restarts int         `json:"restarts"`
+}
+
+// moduleEntry tracks the state of one module and the resources it acquired
+// through the bot, so they can be released when it stops
+type moduleEntry struct {
+	module   Module
+	state    ModuleState
+	err      error
+	since    time.Time
+	restarts int
+	cleanups []func()
+	stopping chan struct{} // closed when the last Shutdown call returns
+}
+
+// moduleRegistry tracks every registered module. Transitions are
+// serialized by transition, which is held while Initialize and Shutdown run;
+// mu only guards the map and entry fields so modules can register cleanups
+// from inside Initialize.
+type moduleRegistry struct {
+	transition sync.Mutex
+	mu         sync.Mutex
+	entries    map[string]*moduleEntry
+	services   []providedService
+	order      []string // initialization order resolved by Start
+}
+
+func newModuleRegistry() *moduleRegistry {
+	return &moduleRegistry{entries: make(map[string]*moduleEntry)}
+}
+
+func (r *moduleRegistry) add(module Module) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[module.Name()] = &moduleEntry{
+		module: module,
+		state:  ModuleRegistered,
+		since:  time.Now(),
+	}
+}
+
+// AddModuleHandler registers a discordgo event handler on every shard on
+// behalf of a module. The handler is removed automatically when the module
+// stops, so modules should prefer it over calling Session.AddHandler directly.
+func (b *Bot) AddModuleHandler(module Module, handler interface{}) {
+	remove := b.AddHandler(handler)
+	b.OnModuleStop(module, remove)
+}
+
+// OnModuleStop registers a function to run when module stops or fails,
+// after its Shutdown method returns
+func (b *Bot) OnModuleStop(module Module, cleanup func()) {
+	b.modules.mu.Lock()
+	defer b.modules.mu.Unlock()
+
+	if entry, exists := b.modules.entries[module.Name()]; exists {
+		entry.cleanups = append(entry.cleanups, cleanup)
+	}
+}
+
+// ModuleStatus returns the lifecycle status of a module
+func (b *Bot) ModuleStatus(name string) (ModuleStatus, bool) {
+	b.modules.mu.Lock()
+	defer b.modules.mu.Unlock()
+
+	entry, exists := b.modules.entries[name]
+	if !exists {
+		return ModuleStatus{}, false
+	}
+	return entry.status(), true
+}
+
+// ModuleStatuses returns the status of every module in registration order
+func (b *Bot) ModuleStatuses() []ModuleStatus {
+	b.modules.mu.Lock()
+	defer b.modules.mu.Unlock()
+
+	statuses := make([]ModuleStatus, 0, len(b.Modules))
+	for _, module := range b.Modules {
+		if entry, exists := b.modules.entries[module.Name()]; exists {
+			statuses = append(statuses, entry.status())
+		}
+	}
+	return statuses
+}
+
+// StartModule initializes a registered, stopped or failed module
+func (b *Bot) StartModule(name string) error {
+	b.modules.transition.Lock()
+	defer b.modules.transition.Unlock()
+
+	entry, exists := b.modules.lookup(name)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownModule, name)
+	}
+	return b.startModule(entry)
+}
+
+// StartModules starts every registered module in dependency order without
+// connecting to Discord, as Open does once the gateway is connected. It is
+// meant for tests that exercise modules offline. Failures are recorded in
+// each module's status; only an unresolvable dependency order is returned.
+func (b *Bot) StartModules() error {
+	order, err := b.resolveModuleOrder()
+	if err != nil {
+		return err
+	}
+	b.startModules(order)
+	return nil
+}
+
+// startModules initializes modules in the given order
+func (b *Bot) startModules(order []string) {
+	b.modules.order = order
+	for _, name := range order {
+		b.StartModule(name)
+	}
+}
+
+// StopModule shuts down a running module and removes its handlers
+func (b *Bot) StopModule(name string) error {
+	b.modules.transition.Lock()
+	defer b.modules.transition.Unlock()
+
+	entry, exists := b.modules.lookup(name)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownModule, name)
+	}
+
+	if dependents := b.runningDependents(name); len(dependents) > 0 {
+		return fmt.Errorf("module %s is required by %s", name, strings.Join(dependents, ", "))
+	}
+	return b.stopModule(entry)
+}
+
+// RestartModule stops a module if it is running and starts it again. Like
+// StopModule it refuses while modules that depend on it are running.
+func (b *Bot) RestartModule(name string) error {
+	b.modules.transition.Lock()
+	defer b.modules.transition.Unlock()
+
+	entry, exists := b.modules.lookup(name)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownModule, name)
+	}
+
+	if entry.currentState() == ModuleRunning {
+		if dependents := b.runningDependents(name); len(dependents) > 0 {
+			return fmt.Errorf("module %s is required by %s", name, strings.Join(dependents, ", "))
+		}
+		if err := b.stopModule(entry); err != nil {
+			return err
+		}
+	}
+
+	b.modules.mu.Lock()
+	entry.restarts++
+	b.modules.mu.Unlock()
+
+	return b.startModule(entry)
+}
+
+// startModule runs Initialize and records the outcome. Callers must hold
+// b.modules.transition.
+func (b *Bot) startModule(entry *moduleEntry) error {
+	switch state := entry.currentState(); state {
+	case ModuleRunning, ModuleInitializing:
+		return fmt.Errorf("module %s is already %s", entry.module.Name(), state)
+	}
+
+	// A Shutdown that timed out may still be releasing the module's
+	// resources; initializing now would run both at once
+	if entry.stopping != nil {
+		select {
+		case <-entry.stopping:
+		default:
+			return fmt.Errorf("%w: %s", ErrModuleShuttingDown, entry.module.Name())
+		}
+	}
+
+	if err := b.checkDependencies(entry.module); err != nil {
+		b.modules.setState(entry, ModuleFailed, err)
+		b.Logger("modules").Error("error initializing module", "module", entry.module.Name(), "error", err)
+		b.Events.Publish(ModuleFailedEvent{Module: entry.module.Name(), Err: err})
+		return fmt.Errorf("error initializing module %s: %w", entry.module.Name(), err)
+	}
+
+	b.modules.setState(entry, ModuleInitializing, nil)
+
+	if err := safeCall(func() error { return entry.module.Initialize(b) }); err != nil {
+		// Release whatever the module registered before failing
+		b.runCleanups(entry)
+		b.modules.setState(entry, ModuleFailed, err)
+		b.Logger("modules").Error("error initializing module", "module", entry.module.Name(), "error", err)
+		b.Events.Publish(ModuleFailedEvent{Module: entry.module.Name(), Err: err})
+		return fmt.Errorf("error initializing module %s: %w", entry.module.Name(), err)
+	}
+
+	b.modules.setState(entry, ModuleRunning, nil)
+	b.Logger("modules").Info("module initialized", "module", entry.module.Name(), "version", entry.module.Version())
+	b.Events.Publish(ModuleStartedEvent{Module: entry.module.Name()})
+	return nil
+}
+
+// stopModule runs Shutdown and releases the module's handlers. Callers must
+// hold b.modules.transition.
+func (b *Bot) stopModule(entry *moduleEntry) error {
+	if state := entry.currentState(); state != ModuleRunning {
+		return fmt.Errorf("module %s is not running (%s)", entry.module.Name(), state)
+	}
+
+	stopping := make(chan struct{})
+	entry.stopping = stopping
+	err := withTimeout(b.Config().Shutdown.ModuleTimeout.Duration(), func(context.Context) error {
+		defer close(stopping)
+		return entry.module.Shutdown()
+	})
+	b.runCleanups(entry)
+
+	if err != nil {
+		b.modules.setState(entry, ModuleFailed, err)
+		b.Logger("modules").Error("error shutting down module", "module", entry.module.Name(), "error", err)
+		b.Events.Publish(ModuleFailedEvent{Module: entry.module.Name(), Err: err})
+		return fmt.Errorf("error shutting down module %s: %w", entry.module.Name(), err)
+	}
+
+	b.modules.setState(entry, ModuleStopped, nil)
+	b.Logger("modules").Info("module shut down", "module", entry.module.Name())
+	b.Events.Publish(ModuleStoppedEvent{Module: entry.module.Name()})
+	return nil
+}
+
+// runCleanups releases everything registered through OnModuleStop
+func (b *Bot) runCleanups(entry *moduleEntry) {
+	b.modules.mu.Lock()
+	cleanups := entry.cleanups
+	entry.cleanups = nil
+	b.modules.mu.Unlock()
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+}
+
+func (r *moduleRegistry) lookup(name string) (*moduleEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, exists := r.entries[name]
+	return entry, exists
+}
+
+func (r *moduleRegistry) setState(entry *moduleEntry, state ModuleState, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry.state = state
+	entry.err = err
+	entry.since = time.Now()
+}
+
+// currentState reads the state without the registry lock; it is only used
+// by transitions, which are the sole writers
+func (e *moduleEntry) currentState() ModuleState {
+	return e.state
+}
+
+func (e *moduleEntry) status() ModuleStatus {
+	status := ModuleStatus{
+		Name:     e.module.Name(),
+		Version:  e.module.Version(),
+		State:    e.state,
+		Since:    e.since,
+		Restarts: e.restarts,
+	}
+	if e.err != nil {
+		status.Error = e.err.Error()
+	}
+	return status
+}
+
+// safeCall runs fn, converting a panic into an error so one misbehaving
+// module cannot take down the bot
+func safeCall(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
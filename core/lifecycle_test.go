@@ -0,0 +1,279 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// lifecycleModule is a module whose Initialize and Shutdown are scripted
+// by the test
+type lifecycleModule struct {
+	name       string
+	deps       []string
+	initialize func(bot *Bot) error
+	shutdown   func() error
+
+	mu        sync.Mutex
+	inits     int
+	shutdowns int
+}
+
+func (m *lifecycleModule) Name() string           { return m.name }
+func (m *lifecycleModule) Version() string        { return "1.0.0" }
+func (m *lifecycleModule) Dependencies() []string { return m.deps }
+
+func (m *lifecycleModule) Initialize(bot *Bot) error {
+	m.mu.Lock()
+	m.inits++
+	m.mu.Unlock()
+	if m.initialize != nil {
+		return m.initialize(bot)
+	}
+	return nil
+}
+
+func (m *lifecycleModule) Shutdown() error {
+	m.mu.Lock()
+	m.shutdowns++
+	m.mu.Unlock()
+	if m.shutdown != nil {
+		return m.shutdown()
+	}
+	return nil
+}
+
+func (m *lifecycleModule) counts() (int, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inits, m.shutdowns
+}
+
+func newLifecycleBot(t *testing.T, modules ...Module) *Bot {
+	t.Helper()
+
+	bot, err := NewBot(&Config{
+		Token:    "test",
+		Prefix:   "!",
+		Shutdown: ShutdownConfig{DrainTimeout: Duration(time.Second), ModuleTimeout: Duration(50 * time.Millisecond)},
+	})
+	if err != nil {
+		t.Fatalf("NewBot: %v", err)
+	}
+	for _, module := range modules {
+		bot.RegisterModule(module)
+	}
+	return bot
+}
+
+func moduleState(t *testing.T, bot *Bot, name string) ModuleStatus {
+	t.Helper()
+
+	status, exists := bot.ModuleStatus(name)
+	if !exists {
+		t.Fatalf("module %s is not registered", name)
+	}
+	return status
+}
+
+func TestModuleLifecycleTransitions(t *testing.T) {
+	module := &lifecycleModule{name: "alpha"}
+	bot := newLifecycleBot(t, module)
+
+	if state := moduleState(t, bot, "alpha").State; state != ModuleRegistered {
+		t.Fatalf("state before start = %s, want registered", state)
+	}
+
+	if err := bot.StartModule("alpha"); err != nil {
+		t.Fatalf("StartModule: %v", err)
+	}
+	if state := moduleState(t, bot, "alpha").State; state != ModuleRunning {
+		t.Fatalf("state after start = %s, want running", state)
+	}
+	if err := bot.StartModule("alpha"); err == nil {
+		t.Fatal("starting a running module succeeded")
+	}
+
+	if err := bot.RestartModule("alpha"); err != nil {
+		t.Fatalf("RestartModule: %v", err)
+	}
+	status := moduleState(t, bot, "alpha")
+	if status.State != ModuleRunning || status.Restarts != 1 {
+		t.Fatalf("after restart: state %s, restarts %d; want running, 1", status.State, status.Restarts)
+	}
+
+	if err := bot.StopModule("alpha"); err != nil {
+		t.Fatalf("StopModule: %v", err)
+	}
+	if state := moduleState(t, bot, "alpha").State; state != ModuleStopped {
+		t.Fatalf("state after stop = %s, want stopped", state)
+	}
+	if err := bot.StopModule("alpha"); err == nil {
+		t.Fatal("stopping a stopped module succeeded")
+	}
+
+	if inits, shutdowns := module.counts(); inits != 2 || shutdowns != 2 {
+		t.Errorf("Initialize ran %d times and Shutdown %d times, want 2 and 2", inits, shutdowns)
+	}
+
+	if err := bot.StartModule("missing"); !errors.Is(err, ErrUnknownModule) {
+		t.Errorf("StartModule(missing) = %v, want ErrUnknownModule", err)
+	}
+}
+
+func TestModuleFailuresAreRecorded(t *testing.T) {
+	tests := []struct {
+		name       string
+		initialize func(bot *Bot) error
+		wantErr    string
+	}{
+		{
+			name:       "error",
+			initialize: func(*Bot) error { return errors.New("no database") },
+			wantErr:    "no database",
+		},
+		{
+			name:       "panic",
+			initialize: func(*Bot) error { panic("boom") },
+			wantErr:    "panic: boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleaned := false
+			module := &lifecycleModule{name: "alpha"}
+			module.initialize = func(bot *Bot) error {
+				bot.OnModuleStop(module, func() { cleaned = true })
+				return tt.initialize(bot)
+			}
+			bot := newLifecycleBot(t, module)
+
+			if err := bot.StartModule("alpha"); err == nil {
+				t.Fatal("StartModule succeeded")
+			}
+
+			status := moduleState(t, bot, "alpha")
+			if status.State != ModuleFailed || !strings.Contains(status.Error, tt.wantErr) {
+				t.Errorf("status = %s %q, want failed with %q", status.State, status.Error, tt.wantErr)
+			}
+			if !cleaned {
+				t.Error("cleanups registered before the failure did not run")
+			}
+		})
+	}
+}
+
+func TestStopModuleRemovesHandlers(t *testing.T) {
+	module := &lifecycleModule{name: "alpha"}
+	module.initialize = func(bot *Bot) error {
+		bot.AddModuleHandler(module, func(*discordgo.Session, *discordgo.MessageCreate) {})
+		return nil
+	}
+	bot := newLifecycleBot(t, module)
+
+	handlers := func() int {
+		bot.shards.mu.Lock()
+		defer bot.shards.mu.Unlock()
+		return len(bot.shards.handlers)
+	}
+	before := handlers()
+
+	if err := bot.StartModule("alpha"); err != nil {
+		t.Fatalf("StartModule: %v", err)
+	}
+	if got := handlers(); got != before+1 {
+		t.Fatalf("handlers while running = %d, want %d", got, before+1)
+	}
+
+	if err := bot.StopModule("alpha"); err != nil {
+		t.Fatalf("StopModule: %v", err)
+	}
+	if got := handlers(); got != before {
+		t.Errorf("handlers after stop = %d, want %d", got, before)
+	}
+}
+
+func TestStopAndRestartRefusedWhileDependentsRun(t *testing.T) {
+	base := &lifecycleModule{name: "base"}
+	dependent := &lifecycleModule{name: "dependent", deps: []string{"base"}}
+	bot := newLifecycleBot(t, dependent, base)
+
+	if err := bot.StartModules(); err != nil {
+		t.Fatalf("StartModules: %v", err)
+	}
+
+	for name, action := range map[string]func(string) error{
+		"stop":    bot.StopModule,
+		"restart": bot.RestartModule,
+	} {
+		err := action("base")
+		if err == nil || !strings.Contains(err.Error(), "required by dependent") {
+			t.Errorf("%s base = %v, want it refused because dependent runs", name, err)
+		}
+	}
+	if _, shutdowns := base.counts(); shutdowns != 0 {
+		t.Errorf("base was shut down %d times while dependent ran", shutdowns)
+	}
+
+	if err := bot.StopModule("dependent"); err != nil {
+		t.Fatalf("StopModule(dependent): %v", err)
+	}
+	if err := bot.RestartModule("base"); err != nil {
+		t.Errorf("RestartModule(base) after dependent stopped: %v", err)
+	}
+}
+
+func TestStartModuleWaitsForTimedOutShutdown(t *testing.T) {
+	release := make(chan struct{})
+	module := &lifecycleModule{name: "slow"}
+	module.shutdown = func() error {
+		<-release
+		return nil
+	}
+	bot := newLifecycleBot(t, module)
+
+	if err := bot.StartModule("slow"); err != nil {
+		t.Fatalf("StartModule: %v", err)
+	}
+
+	err := bot.StopModule("slow")
+	if !errors.Is(err, ErrShutdownTimeout) {
+		t.Fatalf("StopModule = %v, want ErrShutdownTimeout", err)
+	}
+	if state := moduleState(t, bot, "slow").State; state != ModuleFailed {
+		t.Fatalf("state after timeout = %s, want failed", state)
+	}
+
+	for name, action := range map[string]func(string) error{
+		"start":   bot.StartModule,
+		"restart": bot.RestartModule,
+	} {
+		if err := action("slow"); !errors.Is(err, ErrModuleShuttingDown) {
+			t.Errorf("%s during Shutdown = %v, want ErrModuleShuttingDown", name, err)
+		}
+	}
+	if inits, _ := module.counts(); inits != 1 {
+		t.Fatalf("Initialize ran %d times while Shutdown was running", inits)
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := bot.StartModule("slow")
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrModuleShuttingDown) || time.Now().After(deadline) {
+			t.Fatalf("StartModule after Shutdown returned: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if state := moduleState(t, bot, "slow").State; state != ModuleRunning {
+		t.Errorf("state after Shutdown returned = %s, want running", state)
+	}
+}
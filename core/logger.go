@@ -0,0 +1,428 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// recentLogSize is how many log entries are kept for the web interface
+const recentLogSize = 500
+
+// Log field keys shared by every subsystem, so records about the same
+// guild, user or command can be found together
+const (
+	LogKeySubsystem     = "subsystem"
+	LogKeyGuild         = "guild"
+	LogKeyChannel       = "channel"
+	LogKeyUser          = "user"
+	LogKeyCommand       = "command"
+	LogKeyCorrelationID = "correlation_id"
+)
+
+// ParseLogLevel parses debug, info, warn or error
+func ParseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("%q must be debug, info, warn or error", name)
+}
+
+// parseLogLevels parses subsystem overrides such as "web=warn,gateway=debug"
+func parseLogLevels(value string) (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, item := range splitList(value) {
+		subsystem, level, ok := strings.Cut(item, "=")
+		if !ok || subsystem == "" {
+			return nil, fmt.Errorf("%q must be subsystem=level", item)
+		}
+		if _, err := ParseLogLevel(level); err != nil {
+			return nil, fmt.Errorf("%s %w", subsystem, err)
+		}
+		levels[subsystem] = level
+	}
+	return levels, nil
+}
+
+// sortedLogSubsystems returns the subsystems with a level override in order
+func sortedLogSubsystems(levels map[string]string) []string {
+	subsystems := make([]string, 0, len(levels))
+	for subsystem := range levels {
+		subsystems = append(subsystems, subsystem)
+	}
+	sort.Strings(subsystems)
+	return subsystems
+}
+
+// formatLogLevels renders subsystem overrides the way LOG_LEVELS takes them
+func formatLogLevels(levels map[string]string) string {
+	items := make([]string, 0, len(levels))
+	for _, subsystem := range sortedLogSubsystems(levels) {
+		items = append(items, subsystem+"="+levels[subsystem])
+	}
+	return strings.Join(items, ",")
+}
+
+// LogEntry is a recent log record as served by the web interface
+type LogEntry struct {
+	Time      time.Time              `json:"time"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"msg"`
+	Subsystem string                 `json:"subsystem,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// logManager creates the bot's subsystem loggers and keeps their levels in
+// line with the configuration
+type logManager struct {
+	output slog.Handler // writes every record; subsystem handlers filter
+	recent *logRing
+	sinks  *logSinks
+
+	mu     sync.Mutex
+	config LoggingConfig
+	debug  bool
+	levels map[string]*slog.LevelVar
+}
+
+// newLogManager creates loggers writing to w in the configured format
+func newLogManager(config LoggingConfig, debug bool, w io.Writer) *logManager {
+	options := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var output slog.Handler
+	if strings.EqualFold(config.Format, "json") {
+		output = slog.NewJSONHandler(w, options)
+	} else {
+		output = slog.NewTextHandler(w, options)
+	}
+
+	return &logManager{
+		output: output,
+		recent: newLogRing(recentLogSize),
+		sinks:  &logSinks{},
+		config: config,
+		debug:  debug,
+		levels: make(map[string]*slog.LevelVar),
+	}
+}
+
+// logger returns the logger for a subsystem
+func (l *logManager) logger(subsystem string) *slog.Logger {
+	l.mu.Lock()
+	level, ok := l.levels[subsystem]
+	if !ok {
+		level = new(slog.LevelVar)
+		level.Set(l.levelFor(subsystem))
+		l.levels[subsystem] = level
+	}
+	l.mu.Unlock()
+
+	return slog.New(&subsystemHandler{
+		Handler:   l.output.WithAttrs([]slog.Attr{slog.String(LogKeySubsystem, subsystem)}),
+		level:     level,
+		recent:    l.recent,
+		sinks:     l.sinks,
+		subsystem: subsystem,
+	})
+}
+
+// configure applies changed levels to every logger already handed out
+func (l *logManager) configure(config LoggingConfig, debug bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config = config
+	l.debug = debug
+	for subsystem, level := range l.levels {
+		level.Set(l.levelFor(subsystem))
+	}
+}
+
+// levelFor returns a subsystem's override, or the default level, which
+// debug mode lowers to debug
+func (l *logManager) levelFor(subsystem string) slog.Level {
+	if name, ok := l.config.Levels[subsystem]; ok {
+		if level, err := ParseLogLevel(name); err == nil {
+			return level
+		}
+	}
+	if l.debug {
+		return slog.LevelDebug
+	}
+	level, _ := ParseLogLevel(l.config.Level)
+	return level
+}
+
+// subsystemHandler filters records by its subsystem's level and remembers
+// the ones it writes
+type subsystemHandler struct {
+	slog.Handler
+	level     *slog.LevelVar
+	recent    *logRing
+	sinks     *logSinks
+	subsystem string
+	attrs     []slog.Attr
+	group     string
+}
+
+func (h *subsystemHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *subsystemHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.recent.add(h.entry(record))
+	if sinks := h.sinks.list(); len(sinks) > 0 {
+		h.forward(ctx, sinks, record)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// forward passes a record to the added handlers with the subsystem and
+// the logger's attributes, groups flattened into dotted keys
+func (h *subsystemHandler) forward(ctx context.Context, sinks []*logSink, record slog.Record) {
+	forwarded := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	forwarded.AddAttrs(slog.String(LogKeySubsystem, h.subsystem))
+	forwarded.AddAttrs(h.attrs...)
+	record.Attrs(func(attr slog.Attr) bool {
+		forwarded.AddAttrs(prefixAttr(h.group, attr))
+		return true
+	})
+
+	for _, sink := range sinks {
+		if sink.handler.Enabled(ctx, record.Level) {
+			// A failing sink must not stop the others or the caller
+			sink.handler.Handle(ctx, forwarded.Clone())
+		}
+	}
+}
+
+func (h *subsystemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.Handler = h.Handler.WithAttrs(attrs)
+	clone.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, attr := range attrs {
+		clone.attrs = append(clone.attrs, prefixAttr(h.group, attr))
+	}
+	return &clone
+}
+
+func (h *subsystemHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.Handler = h.Handler.WithGroup(name)
+	clone.group = h.group + name + "."
+	return &clone
+}
+
+// entry converts a record into a LogEntry, flattening groups into dotted
+// field names
+func (h *subsystemHandler) entry(record slog.Record) LogEntry {
+	entry := LogEntry{
+		Time:      record.Time,
+		Level:     record.Level.String(),
+		Message:   record.Message,
+		Subsystem: h.subsystem,
+	}
+
+	fields := make(map[string]interface{}, len(h.attrs)+record.NumAttrs())
+	add := func(attr slog.Attr) {
+		addLogField(fields, "", attr)
+	}
+	for _, attr := range h.attrs {
+		add(attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		add(prefixAttr(h.group, attr))
+		return true
+	})
+	if len(fields) > 0 {
+		entry.Fields = fields
+	}
+	return entry
+}
+
+// prefixAttr qualifies an attribute's key with the open groups
+func prefixAttr(group string, attr slog.Attr) slog.Attr {
+	if group == "" {
+		return attr
+	}
+	return slog.Attr{Key: group + attr.Key, Value: attr.Value}
+}
+
+// addLogField stores an attribute's value, flattening nested groups
+func addLogField(fields map[string]interface{}, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		for _, nested := range value.Group() {
+			addLogField(fields, prefix+attr.Key+".", nested)
+		}
+		return
+	}
+	if attr.Key == "" {
+		return
+	}
+	if value.Kind() == slog.KindDuration {
+		fields[prefix+attr.Key] = value.Duration().String()
+		return
+	}
+	if err, ok := value.Any().(error); ok {
+		fields[prefix+attr.Key] = err.Error()
+		return
+	}
+	fields[prefix+attr.Key] = value.Any()
+}
+
+// logRing keeps the most recent log entries
+type logRing struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	next    int
+	full    bool
+}
+
+func newLogRing(size int) *logRing {
+	return &logRing{entries: make([]LogEntry, size)}
+}
+
+func (r *logRing) add(entry LogEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the entries from oldest to newest
+func (r *logRing) list() []LogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]LogEntry(nil), r.entries[:r.next]...)
+	}
+	return append(append([]LogEntry(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// logSinks holds the handlers added with AddLogHandler. The list is
+// replaced on change so logging never waits for a lock.
+type logSinks struct {
+	mu    sync.Mutex
+	sinks atomic.Pointer[[]*logSink]
+}
+
+type logSink struct {
+	handler slog.Handler
+}
+
+func (s *logSinks) list() []*logSink {
+	if sinks := s.sinks.Load(); sinks != nil {
+		return *sinks
+	}
+	return nil
+}
+
+func (s *logSinks) add(handler slog.Handler) (remove func()) {
+	sink := &logSink{handler: handler}
+
+	s.mu.Lock()
+	sinks := append(append([]*logSink(nil), s.list()...), sink)
+	s.sinks.Store(&sinks)
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		var kept []*logSink
+		for _, existing := range s.list() {
+			if existing != sink {
+				kept = append(kept, existing)
+			}
+		}
+		s.sinks.Store(&kept)
+	}
+}
+
+// AddLogHandler sends every record the bot's loggers write to handler as
+// well, e.g. a file or a chat channel. Records have already passed their
+// subsystem's level; handler's Enabled filters them further. Each record
+// carries its subsystem and fields as attributes. The returned function
+// removes the handler.
+func (b *Bot) AddLogHandler(handler slog.Handler) (remove func()) {
+	return b.logs.sinks.add(handler)
+}
+
+// Logger returns the logger for a subsystem such as "gateway", "web" or a
+// module's name. Its level follows logging.level, debug mode and the
+// subsystem's entry in logging.levels, including after a reload.
+func (b *Bot) Logger(subsystem string) *slog.Logger {
+	return b.logs.logger(subsystem)
+}
+
+// RecentLogs returns the most recent log entries, oldest first
+func (b *Bot) RecentLogs() []LogEntry {
+	return b.logs.recent.list()
+}
+
+// MessageLogAttrs returns the guild, channel and user of a message as log
+// fields
+func MessageLogAttrs(m *discordgo.Message) []any {
+	attrs := []any{slog.String(LogKeyChannel, m.ChannelID)}
+	if m.GuildID != "" {
+		attrs = append(attrs, slog.String(LogKeyGuild, m.GuildID))
+	}
+	if m.Author != nil {
+		attrs = append(attrs, slog.String(LogKeyUser, m.Author.ID))
+	}
+	return attrs
+}
+
+// newCorrelationID identifies the log records of one command invocation.
+// The Discord message or interaction ID is used when there is one, so a
+// report about a message can be matched to its logs.
+func newCorrelationID(id string) string {
+	if id != "" {
+		return id
+	}
+	var random [8]byte
+	rand.Read(random[:])
+	return hex.EncodeToString(random[:])
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a context carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or slog's default.
+// The context a ContextCommand runs with carries a logger with the
+// invocation's guild, channel, user, command and correlation ID.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
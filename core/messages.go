@@ -0,0 +1,513 @@
+package core
+
+import (
+	"container/heap"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Outgoing message limits
+const (
+	messageQueueSize   = 100 // pending messages per channel
+	messageMaxAttempts = 5
+	messageBackoffBase = 500 * time.Millisecond
+	messageBackoffMax  = 30 * time.Second
+)
+
+var (
+	// ErrQueueFull is reported when a channel has too many pending messages
+	ErrQueueFull = errors.New("message queue is full")
+
+	// ErrQueueClosed is reported for messages sent after shutdown started or
+	// still pending when it gave up waiting
+	ErrQueueClosed = errors.New("message queue is closed")
+)
+
+// Priority orders pending messages within a channel
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+func (p Priority) String() string {
+	switch {
+	case p > PriorityNormal:
+		return "high"
+	case p < PriorityNormal:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// SendOption configures a queued message
+type SendOption func(*outgoingMessage)
+
+// WithPriority sends the message ahead of or behind others in its channel
+func WithPriority(priority Priority) SendOption {
+	return func(m *outgoingMessage) {
+		m.priority = priority
+	}
+}
+
+// MessageStats counts what the message queue has done since startup
+type MessageStats struct {
+	Pending     int   `json:"pending"`
+	Sent        int64 `json:"sent"`
+	Retries     int64 `json:"retries"`
+	RateLimited int64 `json:"rate_limited"`
+	Failed      int64 `json:"failed"`
+	Dropped     int64 `json:"dropped"`
+}
+
+// MessageQueue sends outgoing messages one channel at a time. Each channel
+// has its own queue ordered by priority, waits for Discord's rate limit
+// bucket before sending and retries transient failures with backoff. A nonce
+// makes retries idempotent, so a message is never posted twice.
+type MessageQueue struct {
+	bot *Bot
+	log *slog.Logger
+
+	mu        sync.Mutex
+	lanes     map[string]*messageLane
+	seq       uint64
+	stats     MessageStats
+	closed    bool
+	stopOnce  sync.Once
+	idle      []chan struct{} // closed when the last lane empties
+	stop      chan struct{}   // closed when Close gives up on pending messages
+	errorHook func(MessageFailedEvent)
+}
+
+// outgoingMessage is a message waiting in a channel's queue
+type outgoingMessage struct {
+	channelID string
+	data      *discordgo.MessageSend
+	priority  Priority
+	nonce     string
+	seq       uint64
+	attempts  int
+	ctx       context.Context
+	result    chan sendResult // nil for fire-and-forget messages
+}
+
+type sendResult struct {
+	message *discordgo.Message
+	err     error
+}
+
+// messageLane is one channel's pending messages
+type messageLane []*outgoingMessage
+
+func (l messageLane) Len() int      { return len(l) }
+func (l messageLane) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l messageLane) Less(i, j int) bool {
+	if l[i].priority != l[j].priority {
+		return l[i].priority > l[j].priority
+	}
+	return l[i].seq < l[j].seq
+}
+func (l *messageLane) Push(x interface{}) { *l = append(*l, x.(*outgoingMessage)) }
+func (l *messageLane) Pop() interface{} {
+	old := *l
+	m := old[len(old)-1]
+	*l = old[:len(old)-1]
+	return m
+}
+
+func newMessageQueue(bot *Bot) *MessageQueue {
+	return &MessageQueue{
+		bot:   bot,
+		log:   bot.Logger("messages"),
+		lanes: make(map[string]*messageLane),
+		stop:  make(chan struct{}),
+	}
+}
+
+// Send queues a message and waits until it is delivered or fails for good
+func (q *MessageQueue) Send(ctx context.Context, channelID string, data *discordgo.MessageSend, opts ...SendOption) (*discordgo.Message, error) {
+	msg := q.newMessage(ctx, channelID, data, opts)
+	msg.result = make(chan sendResult, 1)
+	if err := q.enqueue(msg); err != nil {
+		return nil, err
+	}
+
+	select {
+	case result := <-msg.result:
+		return result.message, result.err
+	case <-ctx.Done():
+		// The worker notices the cancelled context and drops the message
+		return nil, ctx.Err()
+	}
+}
+
+// SendText queues a plain text message and waits for it to be delivered
+func (q *MessageQueue) SendText(ctx context.Context, channelID, content string, opts ...SendOption) (*discordgo.Message, error) {
+	return q.Send(ctx, channelID, &discordgo.MessageSend{Content: content}, opts...)
+}
+
+// Enqueue queues a message without waiting for it. Failures are reported
+// to the error hook.
+func (q *MessageQueue) Enqueue(channelID string, data *discordgo.MessageSend, opts ...SendOption) {
+	msg := q.newMessage(context.Background(), channelID, data, opts)
+	if err := q.enqueue(msg); err != nil {
+		q.fail(msg, err)
+	}
+}
+
+// EnqueueText queues a plain text message without waiting for it
+func (q *MessageQueue) EnqueueText(channelID, content string, opts ...SendOption) {
+	q.Enqueue(channelID, &discordgo.MessageSend{Content: content}, opts...)
+}
+
+// SetErrorHook replaces the default logging of messages that could not be
+// delivered
+func (q *MessageQueue) SetErrorHook(hook func(MessageFailedEvent)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.errorHook = hook
+}
+
+// Stats returns the queue's counters
+func (q *MessageQueue) Stats() MessageStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := q.stats
+	for _, lane := range q.lanes {
+		stats.Pending += lane.Len()
+	}
+	return stats
+}
+
+// Wait blocks until every queued message has been sent or has failed, or
+// until ctx is done
+func (q *MessageQueue) Wait(ctx context.Context) error {
+	q.mu.Lock()
+	if len(q.lanes) == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	idle := make(chan struct{})
+	q.idle = append(q.idle, idle)
+	q.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting messages and waits for pending ones until ctx is
+// done. Messages still pending then fail with ErrQueueClosed.
+func (q *MessageQueue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	if err := q.Wait(ctx); err != nil {
+		q.stopOnce.Do(func() { close(q.stop) })
+		pending := q.Stats().Pending
+		return fmt.Errorf("%d queued messages were not sent: %w", pending, err)
+	}
+	return nil
+}
+
+func (q *MessageQueue) newMessage(ctx context.Context, channelID string, data *discordgo.MessageSend, opts []SendOption) *outgoingMessage {
+	msg := &outgoingMessage{
+		channelID: channelID,
+		data:      data,
+		priority:  PriorityNormal,
+		nonce:     newNonce(),
+		ctx:       ctx,
+	}
+	for _, opt := range opts {
+		opt(msg)
+	}
+	return msg
+}
+
+// enqueue adds a message to its channel's queue, starting a worker for the
+// channel if none is running. When the queue is full the lowest priority
+// message is dropped, which may be the new one.
+func (q *MessageQueue) enqueue(msg *outgoingMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	q.seq++
+	msg.seq = q.seq
+
+	lane, running := q.lanes[msg.channelID]
+	if !running {
+		lane = &messageLane{}
+		q.lanes[msg.channelID] = lane
+	}
+
+	if lane.Len() >= messageQueueSize {
+		// The newest message of the lowest priority goes first
+		lowest := 0
+		for i := range *lane {
+			if lane.Less(lowest, i) {
+				lowest = i
+			}
+		}
+		if (*lane)[lowest].priority >= msg.priority {
+			q.stats.Dropped++
+			return ErrQueueFull
+		}
+		dropped := heap.Remove(lane, lowest).(*outgoingMessage)
+		q.stats.Dropped++
+		go q.fail(dropped, ErrQueueFull)
+	}
+
+	heap.Push(lane, msg)
+	if !running {
+		go q.work(msg.channelID, lane)
+	}
+	return nil
+}
+
+// work sends a channel's messages until its queue is empty
+func (q *MessageQueue) work(channelID string, lane *messageLane) {
+	for {
+		q.mu.Lock()
+		if lane.Len() == 0 {
+			delete(q.lanes, channelID)
+			if len(q.lanes) == 0 {
+				for _, idle := range q.idle {
+					close(idle)
+				}
+				q.idle = nil
+			}
+			q.mu.Unlock()
+			return
+		}
+		msg := heap.Pop(lane).(*outgoingMessage)
+		q.mu.Unlock()
+
+		retryAfter, err := q.attempt(msg)
+		if err == nil {
+			continue
+		}
+		if retryAfter <= 0 {
+			q.fail(msg, err)
+			continue
+		}
+
+		// Put the message back so it keeps its place, but let anything of
+		// higher priority go first once the wait is over
+		select {
+		case <-time.After(retryAfter):
+			q.mu.Lock()
+			heap.Push(lane, msg)
+			q.mu.Unlock()
+		case <-msg.ctx.Done():
+			q.fail(msg, msg.ctx.Err())
+		case <-q.stop:
+			q.fail(msg, ErrQueueClosed)
+		}
+	}
+}
+
+// attempt sends a message once. It returns how long to wait before the next
+// attempt, or zero when the message is done (sent or failed for good).
+func (q *MessageQueue) attempt(msg *outgoingMessage) (time.Duration, error) {
+	select {
+	case <-q.stop:
+		return 0, ErrQueueClosed
+	default:
+	}
+	if err := msg.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	session := q.bot.Session
+	endpoint := discordgo.EndpointChannelMessages(msg.channelID)
+
+	// Wait for the channel's rate limit bucket here rather than inside
+	// discordgo, so the wait can be cancelled
+	if wait := bucketWait(session, endpoint); wait > 0 {
+		q.count(func(s *MessageStats) { s.RateLimited++ })
+		select {
+		case <-time.After(wait):
+		case <-msg.ctx.Done():
+			return 0, msg.ctx.Err()
+		case <-q.stop:
+			return 0, ErrQueueClosed
+		}
+	}
+
+	msg.attempts++
+	sent, err := q.post(session, endpoint, msg)
+	if err == nil {
+		q.count(func(s *MessageStats) { s.Sent++ })
+		if msg.result != nil {
+			msg.result <- sendResult{message: sent}
+		}
+		return 0, nil
+	}
+
+	retryAfter, retry := retryDelay(err, msg)
+	if !retry || msg.attempts >= messageMaxAttempts {
+		return 0, err
+	}
+
+	q.count(func(s *MessageStats) {
+		s.Retries++
+		var rateLimited *discordgo.RateLimitError
+		if errors.As(err, &rateLimited) {
+			s.RateLimited++
+		}
+	})
+	return retryAfter, err
+}
+
+// post creates the message. Messages without files are sent with an
+// enforced nonce, so Discord returns the existing message if an earlier
+// attempt was created but its response was lost.
+func (q *MessageQueue) post(session *discordgo.Session, endpoint string, msg *outgoingMessage) (*discordgo.Message, error) {
+	options := []discordgo.RequestOption{
+		discordgo.WithRetryOnRatelimit(false),
+		discordgo.WithContext(msg.ctx),
+	}
+
+	if len(msg.data.Files) > 0 || msg.data.File != nil {
+		return session.ChannelMessageSendComplex(msg.channelID, msg.data, options...)
+	}
+
+	// Fill in defaults on copies; the caller still owns msg.data
+	data := *msg.data
+	if data.Embed != nil && data.Embeds == nil {
+		data.Embeds = []*discordgo.MessageEmbed{data.Embed}
+	}
+	embeds := make([]*discordgo.MessageEmbed, len(data.Embeds))
+	for i, embed := range data.Embeds {
+		if embed.Type == "" {
+			copied := *embed
+			copied.Type = "rich"
+			embed = &copied
+		}
+		embeds[i] = embed
+	}
+	data.Embeds = embeds
+
+	body, err := session.RequestWithBucketID("POST", endpoint, struct {
+		*discordgo.MessageSend
+		Nonce        string `json:"nonce"`
+		EnforceNonce bool   `json:"enforce_nonce"`
+	}{&data, msg.nonce, true}, endpoint, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	var sent discordgo.Message
+	if err := discordgo.Unmarshal(body, &sent); err != nil {
+		return nil, fmt.Errorf("error decoding sent message: %w", err)
+	}
+	return &sent, nil
+}
+
+// retryDelay decides whether a failed send is worth retrying and after how
+// long. Rate limits, server errors and network failures are transient;
+// anything else, such as missing permissions, is not.
+func retryDelay(err error, msg *outgoingMessage) (time.Duration, bool) {
+	var rateLimited *discordgo.RateLimitError
+	if errors.As(err, &rateLimited) {
+		return rateLimited.RetryAfter, true
+	}
+
+	// Without a nonce a retry could post the message twice
+	if len(msg.data.Files) > 0 || msg.data.File != nil {
+		return 0, false
+	}
+
+	var restErr *discordgo.RESTError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &restErr):
+		if restErr.Response == nil || restErr.Response.StatusCode < http.StatusInternalServerError {
+			return 0, false
+		}
+	case errors.As(err, &netErr):
+	default:
+		return 0, false
+	}
+
+	backoff := messageBackoffBase << (msg.attempts - 1)
+	if backoff > messageBackoffMax {
+		backoff = messageBackoffMax
+	}
+	return backoff, true
+}
+
+// bucketWait returns how long until a rate limit bucket has requests left
+func bucketWait(session *discordgo.Session, bucketID string) time.Duration {
+	bucket := session.Ratelimiter.GetBucket(bucketID)
+	bucket.Lock()
+	defer bucket.Unlock()
+
+	return session.Ratelimiter.GetWaitTime(bucket, 1)
+}
+
+// fail reports a message that will not be delivered
+func (q *MessageQueue) fail(msg *outgoingMessage, err error) {
+	q.mu.Lock()
+	if !errors.Is(err, ErrQueueFull) {
+		q.stats.Failed++
+	}
+	hook := q.errorHook
+	q.mu.Unlock()
+
+	event := MessageFailedEvent{
+		ChannelID: msg.channelID,
+		Priority:  msg.priority.String(),
+		Attempts:  msg.attempts,
+		Err:       err,
+	}
+
+	// Callers of Send get the error themselves
+	switch {
+	case msg.result != nil:
+		msg.result <- sendResult{err: err}
+	case hook != nil:
+		hook(event)
+	default:
+		q.log.Error("could not send message", LogKeyChannel, msg.channelID, "attempts", msg.attempts, "error", err)
+	}
+	q.bot.Events.Publish(event)
+}
+
+// count updates the queue's counters
+func (q *MessageQueue) count(update func(*MessageStats)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	update(&q.stats)
+}
+
+// newNonce returns a random nonce for idempotent message creation
+func newNonce() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,301 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"discord-bot-forge/core"
+	"discord-bot-forge/forgetest"
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestMessageQueueLeavesCallerMessageUnchanged(t *testing.T) {
+	h := forgetest.New(t)
+
+	embed := &discordgo.MessageEmbed{Title: "Status"}
+	data := &discordgo.MessageSend{Embed: embed}
+	if _, err := h.Bot.Messages.Send(context.Background(), forgetest.ChannelID, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Embeds != nil {
+		t.Errorf("Send set Embeds on the caller's message: %v", data.Embeds)
+	}
+	if embed.Type != "" {
+		t.Errorf("Send set the caller's embed type to %q", embed.Type)
+	}
+	if sent := h.AssertEmbed("Status"); sent.Type != "rich" {
+		t.Errorf("sent embed type = %q, want rich", sent.Type)
+	}
+}
+
+// fakeTransport stands in for Discord's REST API. respond picks the status
+// and body for each attempt; a zero status accepts the message.
+type fakeTransport struct {
+	respond func(attempt int) (int, string)
+	gate    chan struct{} // when set, requests wait until it is closed
+	started chan struct{} // receives a value as each request arrives
+
+	mu       sync.Mutex
+	attempts []time.Time
+	nonces   []string
+	sent     []string
+}
+
+func (f *fakeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var payload struct {
+		Content string `json:"content"`
+		Nonce   string `json:"nonce"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	attempt := len(f.attempts)
+	f.attempts = append(f.attempts, time.Now())
+	f.nonces = append(f.nonces, payload.Nonce)
+	f.mu.Unlock()
+
+	if f.started != nil {
+		f.started <- struct{}{}
+	}
+	if f.gate != nil {
+		<-f.gate
+	}
+
+	status, body := 0, ""
+	if f.respond != nil {
+		status, body = f.respond(attempt)
+	}
+	if status == 0 {
+		f.mu.Lock()
+		f.sent = append(f.sent, payload.Content)
+		f.mu.Unlock()
+		status = http.StatusOK
+		body = fmt.Sprintf(`{"id": "%d", "channel_id": "%s", "content": %q}`, attempt+1, forgetest.ChannelID, payload.Content)
+	}
+
+	return &http.Response{
+		Status:     http.StatusText(status),
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}, nil
+}
+
+func (f *fakeTransport) snapshot() ([]time.Time, []string, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]time.Time(nil), f.attempts...), append([]string(nil), f.nonces...), append([]string(nil), f.sent...)
+}
+
+func newQueueBot(t *testing.T, transport *fakeTransport) *core.Bot {
+	t.Helper()
+
+	bot, err := core.NewBot(&core.Config{Token: "test", Prefix: "!"})
+	if err != nil {
+		t.Fatalf("NewBot: %v", err)
+	}
+	bot.Session.Client = &http.Client{Transport: transport}
+	return bot
+}
+
+func TestMessageQueueRetriesWithBackoff(t *testing.T) {
+	transport := &fakeTransport{respond: func(attempt int) (int, string) {
+		if attempt < 2 {
+			return http.StatusServiceUnavailable, `{"message": "unavailable"}`
+		}
+		return 0, ""
+	}}
+	bot := newQueueBot(t, transport)
+
+	sent, err := bot.Messages.SendText(context.Background(), forgetest.ChannelID, "hello")
+	if err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	if sent.Content != "hello" {
+		t.Errorf("sent content = %q", sent.Content)
+	}
+
+	attempts, nonces, messages := transport.snapshot()
+	if len(attempts) != 3 || len(messages) != 1 {
+		t.Fatalf("%d attempts posted %d messages, want 3 attempts and 1 message", len(attempts), len(messages))
+	}
+	// The backoff doubles from messageBackoffBase (500ms)
+	for i, want := range []time.Duration{500 * time.Millisecond, time.Second} {
+		if gap := attempts[i+1].Sub(attempts[i]); gap < want {
+			t.Errorf("retry %d came after %v, want at least %v", i+1, gap, want)
+		}
+	}
+	if nonces[0] == "" || nonces[1] != nonces[0] || nonces[2] != nonces[0] {
+		t.Errorf("retries used nonces %v, want the same nonce every time", nonces)
+	}
+	if stats := bot.Messages.Stats(); stats.Retries != 2 || stats.Sent != 1 || stats.Failed != 0 {
+		t.Errorf("stats = %+v, want 2 retries and 1 sent", stats)
+	}
+}
+
+func TestMessageQueueHonorsRetryAfter(t *testing.T) {
+	transport := &fakeTransport{respond: func(attempt int) (int, string) {
+		if attempt == 0 {
+			return http.StatusTooManyRequests, `{"message": "You are being rate limited.", "retry_after": 0.3, "global": false}`
+		}
+		return 0, ""
+	}}
+	bot := newQueueBot(t, transport)
+
+	if _, err := bot.Messages.SendText(context.Background(), forgetest.ChannelID, "hello"); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+
+	attempts, _, _ := transport.snapshot()
+	if len(attempts) != 2 {
+		t.Fatalf("%d attempts, want 2", len(attempts))
+	}
+	if gap := attempts[1].Sub(attempts[0]); gap < 300*time.Millisecond {
+		t.Errorf("retried after %v, want at least the 300ms retry_after", gap)
+	}
+	if stats := bot.Messages.Stats(); stats.RateLimited < 1 || stats.Retries != 1 {
+		t.Errorf("stats = %+v, want the rate limit counted", stats)
+	}
+}
+
+func TestMessageQueueDoesNotRetryClientErrors(t *testing.T) {
+	transport := &fakeTransport{respond: func(int) (int, string) {
+		return http.StatusForbidden, `{"message": "Missing Permissions", "code": 50013}`
+	}}
+	bot := newQueueBot(t, transport)
+
+	_, err := bot.Messages.SendText(context.Background(), forgetest.ChannelID, "hello")
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) || restErr.Response.StatusCode != http.StatusForbidden {
+		t.Fatalf("SendText = %v, want the 403", err)
+	}
+	if attempts, _, _ := transport.snapshot(); len(attempts) != 1 {
+		t.Errorf("%d attempts, want 1", len(attempts))
+	}
+}
+
+func TestMessageQueueSendsHigherPriorityFirst(t *testing.T) {
+	transport := &fakeTransport{gate: make(chan struct{}), started: make(chan struct{}, 10)}
+	bot := newQueueBot(t, transport)
+
+	// Hold the channel's worker on the first message while the rest queue up
+	bot.Messages.EnqueueText(forgetest.ChannelID, "first")
+	<-transport.started
+
+	bot.Messages.EnqueueText(forgetest.ChannelID, "low", core.WithPriority(core.PriorityLow))
+	bot.Messages.EnqueueText(forgetest.ChannelID, "normal 1")
+	bot.Messages.EnqueueText(forgetest.ChannelID, "high", core.WithPriority(core.PriorityHigh))
+	bot.Messages.EnqueueText(forgetest.ChannelID, "normal 2")
+	close(transport.gate)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bot.Messages.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+
+	_, _, sent := transport.snapshot()
+	want := []string{"first", "high", "normal 1", "normal 2", "low"}
+	if strings.Join(sent, ",") != strings.Join(want, ",") {
+		t.Errorf("sent %v, want %v", sent, want)
+	}
+}
+
+func TestMessageQueueDropsLowestPriorityWhenFull(t *testing.T) {
+	transport := &fakeTransport{gate: make(chan struct{}), started: make(chan struct{}, 200)}
+	bot := newQueueBot(t, transport)
+
+	failures := make(chan core.MessageFailedEvent, 10)
+	bot.Messages.SetErrorHook(func(event core.MessageFailedEvent) { failures <- event })
+
+	bot.Messages.EnqueueText(forgetest.ChannelID, "first")
+	<-transport.started
+
+	const queueSize = 100
+	for i := 0; i < queueSize; i++ {
+		bot.Messages.EnqueueText(forgetest.ChannelID, fmt.Sprintf("normal %d", i))
+	}
+
+	// A full queue rejects a message that does not outrank anything in it
+	_, err := bot.Messages.SendText(context.Background(), forgetest.ChannelID, "low", core.WithPriority(core.PriorityLow))
+	if !errors.Is(err, core.ErrQueueFull) {
+		t.Fatalf("SendText(low) = %v, want ErrQueueFull", err)
+	}
+
+	// A higher priority message pushes out the newest normal one
+	bot.Messages.EnqueueText(forgetest.ChannelID, "high", core.WithPriority(core.PriorityHigh))
+	select {
+	case event := <-failures:
+		if !errors.Is(event.Err, core.ErrQueueFull) || event.Priority != "normal" {
+			t.Errorf("dropped message event = %+v, want a normal message with ErrQueueFull", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the dropped message was not reported")
+	}
+
+	close(transport.gate)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bot.Messages.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+
+	_, _, sent := transport.snapshot()
+	if len(sent) != queueSize+1 || sent[1] != "high" {
+		t.Fatalf("sent %d messages starting %v, want %d with high second", len(sent), sent[:2], queueSize+1)
+	}
+	for _, content := range sent {
+		if content == fmt.Sprintf("normal %d", queueSize-1) {
+			t.Errorf("the newest normal message was sent instead of dropped")
+		}
+	}
+	if stats := bot.Messages.Stats(); stats.Dropped != 2 || stats.Failed != 0 {
+		t.Errorf("stats = %+v, want 2 dropped and none failed", stats)
+	}
+}
+
+func TestMessageQueueReportsFailuresToErrorHook(t *testing.T) {
+	transport := &fakeTransport{respond: func(int) (int, string) {
+		return http.StatusForbidden, `{"message": "Missing Permissions", "code": 50013}`
+	}}
+	bot := newQueueBot(t, transport)
+
+	failures := make(chan core.MessageFailedEvent, 1)
+	bot.Messages.SetErrorHook(func(event core.MessageFailedEvent) { failures <- event })
+
+	published := make(chan core.MessageFailedEvent, 1)
+	core.On(bot.Events, func(event core.MessageFailedEvent) { published <- event })
+
+	bot.Messages.EnqueueText(forgetest.ChannelID, "hello", core.WithPriority(core.PriorityHigh))
+
+	select {
+	case event := <-failures:
+		var restErr *discordgo.RESTError
+		if event.ChannelID != forgetest.ChannelID || event.Priority != "high" || event.Attempts != 1 || !errors.As(event.Err, &restErr) {
+			t.Errorf("hook event = %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("error hook was not called")
+	}
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("MessageFailedEvent was not published")
+	}
+	if stats := bot.Messages.Stats(); stats.Failed != 1 {
+		t.Errorf("stats = %+v, want 1 failed", stats)
+	}
+}
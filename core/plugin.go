@@ -0,0 +1,420 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"discord-bot-forge/plugin/protocol"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Plugin process supervision timings
+const (
+	pluginHandshakeTimeout = 10 * time.Second
+	pluginExecuteTimeout   = 30 * time.Second
+	pluginStopTimeout      = 5 * time.Second
+	pluginMinBackoff       = time.Second
+	pluginMaxBackoff       = time.Minute
+	pluginStableAfter      = time.Minute // uptime after which backoff resets
+)
+
+// PluginConfig describes an out-of-process plugin executable
+type PluginConfig struct {
+	Name string   `json:"name"`
+	Path string   `json:"path"`
+	Args []string `json:"args,omitempty"`
+	Env  []string `json:"env,omitempty"` // extra KEY=VALUE pairs
+}
+
+// Plugin is a module that runs a plugin executable and speaks the plugin
+// protocol with it over stdin/stdout. Commands and event subscriptions
+// declared in the plugin's handshake are registered with the bot, and the
+// process is restarted with exponential backoff if it exits unexpectedly.
+type Plugin struct {
+	config PluginConfig
+	bot    *Bot
+	log    *slog.Logger
+
+	mu       sync.Mutex
+	process  *pluginProcess
+	manifest *protocol.Manifest
+	commands []string
+	subs     []*Subscription
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// pluginProcess is one run of a plugin executable
+type pluginProcess struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	conn    *protocol.Conn
+	started time.Time
+	exited  chan struct{}
+	err     error // exit status, valid once exited is closed
+}
+
+// NewPlugin creates a module for a plugin executable
+func NewPlugin(config PluginConfig) *Plugin {
+	return &Plugin{config: config}
+}
+
+func (p *Plugin) Name() string {
+	return "plugin:" + p.config.Name
+}
+
+func (p *Plugin) Version() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.manifest == nil || p.manifest.Version == "" {
+		return "unknown"
+	}
+	return p.manifest.Version
+}
+
+func (p *Plugin) Initialize(bot *Bot) error {
+	p.bot = bot
+	p.log = bot.Logger("plugins").With("plugin", p.config.Name)
+	p.stop = make(chan struct{})
+
+	proc, err := p.launch()
+	if err != nil {
+		return err
+	}
+
+	p.wg.Add(1)
+	go p.supervise(proc)
+	return nil
+}
+
+func (p *Plugin) Shutdown() error {
+	close(p.stop)
+	p.wg.Wait()
+	p.unregister()
+	return nil
+}
+
+// launch starts the executable and performs the handshake
+func (p *Plugin) launch() (*pluginProcess, error) {
+	cmd := exec.Command(p.config.Path, p.config.Args...)
+	cmd.Env = append(os.Environ(), p.config.Env...)
+	cmd.Stderr = &pluginLogWriter{log: p.log}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error creating plugin stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error creating plugin stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting plugin %s: %w", p.config.Name, err)
+	}
+
+	proc := &pluginProcess{
+		cmd:     cmd,
+		stdin:   stdin,
+		conn:    protocol.NewConn(stdout, stdin),
+		started: time.Now(),
+		exited:  make(chan struct{}),
+	}
+	go func() {
+		// Wait must not be called before stdout has been read to the end
+		if err := proc.conn.Serve(func(msg *protocol.Message) { p.handle(proc.conn, msg) }); err != nil {
+			// Nothing reads stdout any more, so the plugin would block on
+			// its next write and Wait would never return
+			p.log.Error("plugin protocol failed, stopping plugin", "error", err)
+			cmd.Process.Kill()
+		}
+		proc.err = cmd.Wait()
+		close(proc.exited)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginHandshakeTimeout)
+	defer cancel()
+
+	var manifest protocol.Manifest
+	handshake := protocol.Handshake{
+		ProtocolVersion: protocol.Version,
+		BotVersion:      p.bot.Version,
+		Prefix:          p.bot.Config().Prefix,
+	}
+	if err := proc.conn.Call(ctx, protocol.MethodHandshake, handshake, &manifest); err != nil {
+		proc.kill()
+		return nil, fmt.Errorf("error during plugin %s handshake: %w", p.config.Name, err)
+	}
+	if manifest.ProtocolVersion != protocol.Version {
+		proc.kill()
+		return nil, fmt.Errorf("plugin %s speaks protocol version %d, expected %d",
+			p.config.Name, manifest.ProtocolVersion, protocol.Version)
+	}
+
+	p.mu.Lock()
+	p.process = proc
+	p.manifest = &manifest
+	p.mu.Unlock()
+
+	p.register(&manifest)
+	p.log.Info("plugin started", "version", manifest.Version, "commands", len(manifest.Commands))
+	return proc, nil
+}
+
+// supervise restarts the plugin whenever it exits until the module stops
+func (p *Plugin) supervise(proc *pluginProcess) {
+	defer p.wg.Done()
+
+	backoff := pluginMinBackoff
+	for {
+		if proc != nil {
+			select {
+			case <-p.stop:
+				proc.terminate()
+				return
+			case <-proc.exited:
+			}
+
+			p.mu.Lock()
+			p.process = nil
+			p.mu.Unlock()
+
+			if time.Since(proc.started) >= pluginStableAfter {
+				backoff = pluginMinBackoff
+			}
+			p.log.Warn("plugin exited, restarting", "error", proc.err, "backoff", backoff)
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > pluginMaxBackoff {
+			backoff = pluginMaxBackoff
+		}
+
+		next, err := p.launch()
+		if err != nil {
+			p.log.Error("error restarting plugin", "error", err)
+		}
+		proc = next
+	}
+}
+
+// register replaces the plugin's commands and event subscriptions with the
+// ones declared in manifest
+func (p *Plugin) register(manifest *protocol.Manifest) {
+	p.unregister()
+
+	var commands []string
+	for _, spec := range manifest.Commands {
+		if _, exists := p.bot.Command(spec.Name); exists {
+			p.log.Warn("command is already registered, skipping", LogKeyCommand, spec.Name)
+			continue
+		}
+		p.bot.RegisterCommand(&pluginCommand{plugin: p, spec: spec})
+		commands = append(commands, spec.Name)
+	}
+
+	var subs []*Subscription
+	for _, name := range manifest.Events {
+		subs = append(subs, p.bot.Events.SubscribeAsync(name, p.forwardEvent))
+	}
+
+	p.mu.Lock()
+	p.commands = commands
+	p.subs = subs
+	p.mu.Unlock()
+}
+
+// unregister removes the plugin's commands and event subscriptions
+func (p *Plugin) unregister() {
+	p.mu.Lock()
+	commands, subs := p.commands, p.subs
+	p.commands, p.subs = nil, nil
+	p.mu.Unlock()
+
+	for _, name := range commands {
+		p.bot.UnregisterCommand(name)
+	}
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+}
+
+// conn returns the connection to the running process, if any
+func (p *Plugin) conn() *protocol.Conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.process == nil {
+		return nil
+	}
+	return p.process.conn
+}
+
+// forwardEvent sends a bus event to the plugin
+func (p *Plugin) forwardEvent(event Event) {
+	conn := p.conn()
+	if conn == nil {
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		p.log.Error("error encoding event", "event", event.EventName(), "error", err)
+		return
+	}
+	conn.Notify(protocol.MethodEvent, protocol.EventParams{Name: event.EventName(), Data: data})
+}
+
+// handle serves requests and notifications sent by the plugin
+func (p *Plugin) handle(conn *protocol.Conn, msg *protocol.Message) {
+	switch msg.Method {
+	case protocol.MethodSendMessage:
+		var params protocol.SendMessageParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			conn.Reply(msg.ID, nil, fmt.Errorf("invalid params: %w", err))
+			return
+		}
+		sent, err := p.bot.Messages.SendText(context.Background(), params.ChannelID, params.Content)
+		if err != nil {
+			conn.Reply(msg.ID, nil, err)
+			return
+		}
+		conn.Reply(msg.ID, protocol.SendMessageResult{MessageID: sent.ID}, nil)
+
+	case protocol.MethodLog:
+		var params protocol.LogParams
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			p.log.Info(params.Message)
+		}
+
+	default:
+		if msg.ID != 0 {
+			conn.Reply(msg.ID, nil, fmt.Errorf("unknown method %q", msg.Method))
+		}
+	}
+}
+
+// terminate asks the process to exit and kills it if it does not
+func (proc *pluginProcess) terminate() {
+	proc.conn.Notify(protocol.MethodShutdown, struct{}{})
+	proc.stdin.Close()
+
+	select {
+	case <-proc.exited:
+	case <-time.After(pluginStopTimeout):
+		proc.kill()
+	}
+}
+
+// kill stops the process immediately and waits for it to exit
+func (proc *pluginProcess) kill() {
+	proc.cmd.Process.Kill()
+	<-proc.exited
+}
+
+// pluginCommand proxies a command to its plugin
+type pluginCommand struct {
+	plugin *Plugin
+	spec   protocol.CommandSpec
+}
+
+func (c *pluginCommand) Name() string          { return c.spec.Name }
+func (c *pluginCommand) Description() string   { return c.spec.Description }
+func (c *pluginCommand) Usage() string         { return c.spec.Usage }
+func (c *pluginCommand) Permissions() []string { return c.spec.Permissions }
+func (c *pluginCommand) Cooldown() int         { return c.spec.Cooldown }
+
+func (c *pluginCommand) Category() string {
+	if c.spec.Category == "" {
+		return "Plugins"
+	}
+	return c.spec.Category
+}
+
+func (c *pluginCommand) Execute(s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	return c.ExecuteContext(context.Background(), s, m, args)
+}
+
+// ExecuteContext runs the command, abandoning the call when ctx is cancelled
+func (c *pluginCommand) ExecuteContext(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	conn := c.plugin.conn()
+	if conn == nil {
+		return fmt.Errorf("plugin %s is not running", c.plugin.config.Name)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, pluginExecuteTimeout)
+	defer cancel()
+
+	params := protocol.ExecuteParams{
+		Command: c.spec.Name,
+		Args:    args,
+		Message: protocol.MessageContext{
+			ID:         m.ID,
+			ChannelID:  m.ChannelID,
+			GuildID:    m.GuildID,
+			AuthorID:   m.Author.ID,
+			AuthorName: m.Author.Username,
+			Content:    m.Content,
+		},
+	}
+
+	var result protocol.ExecuteResult
+	if err := conn.Call(callCtx, protocol.MethodExecute, params, &result); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("plugin %s timed out", c.plugin.config.Name)
+		}
+		return fmt.Errorf("plugin %s: %w", c.plugin.config.Name, err)
+	}
+
+	if result.Reply != "" {
+		_, err := c.plugin.bot.Messages.SendText(ctx, m.ChannelID, result.Reply)
+		return err
+	}
+	return nil
+}
+
+// maxPluginLogLine bounds a line of plugin output; longer lines are logged
+// in pieces
+const maxPluginLogLine = 64 * 1024
+
+// pluginLogWriter logs a plugin's output line by line
+type pluginLogWriter struct {
+	log *slog.Logger
+	buf bytes.Buffer
+}
+
+func (w *pluginLogWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			if len(line) >= maxPluginLogLine {
+				// Don't buffer a line without an end forever
+				w.log.Info(line, "stream", "stderr", "truncated", true)
+				break
+			}
+			// Keep the incomplete line for the next write
+			w.buf.WriteString(line)
+			break
+		}
+		w.log.Info(strings.TrimRight(line, "\r\n"), "stream", "stderr")
+	}
+	return len(data), nil
+}
@@ -0,0 +1,442 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordingVersion is the version of the recording format written
+const RecordingVersion = 1
+
+// RecordingHeader is the first line of a recording
+type RecordingHeader struct {
+	Version    int       `json:"version"`
+	Started    time.Time `json:"started"`
+	BotVersion string    `json:"bot_version,omitempty"`
+	Prefix     string    `json:"prefix,omitempty"`
+	Redacted   []string  `json:"redacted,omitempty"` // "content" and/or "ids"
+}
+
+// RecordedEvent is one gateway dispatch in a recording
+type RecordedEvent struct {
+	Offset time.Duration   `json:"t"` // since the recording started
+	Shard  int             `json:"s,omitempty"`
+	Type   string          `json:"e"`
+	Data   json.RawMessage `json:"d"`
+}
+
+// Recording is a stream of gateway events captured from a running bot.
+//
+// On disk it is JSON lines, a header followed by one event per line,
+// gzip-compressed when the file name ends in .gz.
+type Recording struct {
+	Header RecordingHeader
+	Events []RecordedEvent
+}
+
+// LoadRecording reads a recording file, compressed or not. A recording cut
+// short by a crash is read up to its last complete event.
+func LoadRecording(path string) (*Recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening recording: %w", err)
+	}
+	defer file.Close()
+
+	return ReadRecording(file)
+}
+
+// ReadRecording reads a recording, detecting gzip compression
+func ReadRecording(r io.Reader) (*Recording, error) {
+	buffered := bufio.NewReader(r)
+	var reader io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("error reading recording: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	recording := &Recording{}
+	line := 0
+	var torn error // a line that failed to parse, fatal unless it is the last
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if torn != nil {
+			return nil, torn
+		}
+
+		if line == 1 {
+			if err := json.Unmarshal(data, &recording.Header); err != nil {
+				return nil, fmt.Errorf("error reading recording header: %w", err)
+			}
+			if recording.Header.Version != RecordingVersion {
+				return nil, fmt.Errorf("unsupported recording version %d", recording.Header.Version)
+			}
+			continue
+		}
+
+		// A torn last line means the recorder did not close cleanly
+		var event RecordedEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			torn = fmt.Errorf("error reading recording line %d: %w", line, err)
+			continue
+		}
+		recording.Events = append(recording.Events, event)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("error reading recording: %w", err)
+	}
+	if line == 0 {
+		return nil, errors.New("recording is empty")
+	}
+	return recording, nil
+}
+
+// RecordingWriter appends events to a recording. It is safe for concurrent
+// use.
+type RecordingWriter struct {
+	mu      sync.Mutex
+	started time.Time
+	file    io.Closer
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	encoder *json.Encoder
+	events  int
+	closed  bool
+}
+
+// CreateRecording creates a recording file, and its directory if needed.
+// The file is gzip-compressed when its name ends in .gz.
+func CreateRecording(path string, header RecordingHeader) (*RecordingWriter, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("error creating recording directory: %w", err)
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating recording: %w", err)
+	}
+
+	w, err := newRecordingWriter(file, strings.HasSuffix(path, ".gz"), header)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	w.file = file
+	return w, nil
+}
+
+// NewRecordingWriter writes an uncompressed recording to w
+func NewRecordingWriter(w io.Writer, header RecordingHeader) (*RecordingWriter, error) {
+	return newRecordingWriter(w, false, header)
+}
+
+func newRecordingWriter(w io.Writer, compress bool, header RecordingHeader) (*RecordingWriter, error) {
+	if header.Version == 0 {
+		header.Version = RecordingVersion
+	}
+	if header.Started.IsZero() {
+		header.Started = time.Now()
+	}
+
+	rw := &RecordingWriter{started: header.Started}
+	if compress {
+		rw.gz = gzip.NewWriter(w)
+		w = rw.gz
+	}
+	rw.buf = bufio.NewWriter(w)
+	rw.encoder = json.NewEncoder(rw.buf)
+
+	if err := rw.encoder.Encode(header); err != nil {
+		return nil, fmt.Errorf("error writing recording header: %w", err)
+	}
+	return rw, nil
+}
+
+// Write appends an event, setting its offset from the current time when it
+// has none
+func (w *RecordingWriter) Write(event RecordedEvent) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errors.New("recording is closed")
+	}
+	if event.Offset == 0 {
+		event.Offset = time.Since(w.started)
+	}
+	if err := w.encoder.Encode(event); err != nil {
+		return fmt.Errorf("error writing recorded event: %w", err)
+	}
+	w.events++
+	return nil
+}
+
+// Events returns how many events have been written
+func (w *RecordingWriter) Events() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.events
+}
+
+// Flush pushes buffered events to the underlying writer so they survive a
+// crash
+func (w *RecordingWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		return w.gz.Flush()
+	}
+	return nil
+}
+
+// Close flushes the recording and closes its file
+func (w *RecordingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var errs []error
+	if err := w.buf.Flush(); err != nil {
+		errs = append(errs, err)
+	}
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// redactedText replaces redacted message text
+const redactedText = "[redacted]"
+
+// redactedIDBase is where pseudonymous snowflakes start counting
+const redactedIDBase = 1000000000000000000
+
+// contentKeys are JSON keys holding text written by users
+var contentKeys = map[string]bool{
+	"content": true, "title": true, "description": true, "value": true, "text": true,
+	"filename": true, "url": true, "proxy_url": true, "icon_url": true, "topic": true,
+}
+
+// nameKeys are JSON keys identifying people
+var nameKeys = map[string]bool{
+	"username": true, "global_name": true, "nick": true, "display_name": true,
+	"avatar": true, "banner": true, "email": true, "discriminator": true,
+}
+
+// mentionIDPattern and urlIDPattern find snowflakes inside text: mentions such as <@id>,
+// <#id>, <@&id> and custom emoji in messages, and path segments of CDN
+// URLs for attachments and avatars. The first group is the ID.
+var (
+	mentionIDPattern = regexp.MustCompile(`<(?:@[!&]?|#|a?:\w+:)(\d{15,20})>`)
+	urlIDPattern     = regexp.MustCompile(`/(\d{15,20})\b`)
+)
+
+// Redactor strips sensitive data from gateway events before they are
+// recorded. ID redaction maps every snowflake to a stable pseudonym, so a
+// redacted recording still replays consistently.
+type Redactor struct {
+	// Content replaces message text, embeds and attachment names. Messages
+	// starting with Prefix keep their command name.
+	Content bool
+	Prefix  string
+
+	// IDs replaces snowflakes and user names with pseudonyms, including
+	// IDs in mentions and in attachment and avatar URLs
+	IDs bool
+
+	mu    sync.Mutex
+	ids   map[string]string
+	names map[string]string
+}
+
+// Redacted lists what the redactor removes, for a recording header
+func (r *Redactor) Redacted() []string {
+	var redacted []string
+	if r.Content {
+		redacted = append(redacted, "content")
+	}
+	if r.IDs {
+		redacted = append(redacted, "ids")
+	}
+	return redacted
+}
+
+// Redact returns a copy of an event's data with sensitive values replaced
+func (r *Redactor) Redact(data json.RawMessage) (json.RawMessage, error) {
+	if !r.Content && !r.IDs {
+		return data, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("error decoding event for redaction: %w", err)
+	}
+
+	r.mu.Lock()
+	value = r.redact("", value)
+	r.mu.Unlock()
+
+	redacted, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding redacted event: %w", err)
+	}
+	return redacted, nil
+}
+
+// redact walks a decoded JSON value; key is the object key holding it
+func (r *Redactor) redact(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = r.redact(k, child)
+		}
+		return v
+
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.redact(key, child)
+		}
+		return v
+
+	case string:
+		switch {
+		case r.IDs && isIDKey(key) && isSnowflake(v):
+			return r.pseudonym(v)
+		case r.IDs && nameKeys[key]:
+			return r.name(v)
+		case r.Content && key == "content":
+			return r.redactContent(v)
+		case r.Content && contentKeys[key]:
+			return redactedText
+		case r.IDs && strings.HasSuffix(key, "url"):
+			return r.replaceIDs(urlIDPattern, v)
+		case r.IDs:
+			return r.replaceIDs(mentionIDPattern, v)
+		}
+	}
+	return value
+}
+
+// replaceIDs swaps the snowflakes pattern finds in text for their
+// pseudonyms; r.mu must be held
+func (r *Redactor) replaceIDs(pattern *regexp.Regexp, text string) string {
+	matches := pattern.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[2], match[3]
+		b.WriteString(text[last:start])
+		b.WriteString(r.pseudonym(text[start:end]))
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// redactContent keeps the command name of a prefixed message
+func (r *Redactor) redactContent(content string) string {
+	if content == "" {
+		return content
+	}
+	if r.Prefix != "" && strings.HasPrefix(content, r.Prefix) {
+		if fields := strings.Fields(content); len(fields) > 1 {
+			return fields[0] + " " + redactedText
+		}
+		return content
+	}
+	return redactedText
+}
+
+// pseudonym returns the stable replacement for a snowflake; r.mu must be held
+func (r *Redactor) pseudonym(id string) string {
+	if r.ids == nil {
+		r.ids = make(map[string]string)
+	}
+	if replacement, ok := r.ids[id]; ok {
+		return replacement
+	}
+	replacement := strconv.FormatInt(redactedIDBase+int64(len(r.ids))+1, 10)
+	r.ids[id] = replacement
+	return replacement
+}
+
+// name returns the stable replacement for a user name; r.mu must be held
+func (r *Redactor) name(name string) string {
+	if name == "" {
+		return name
+	}
+	if r.names == nil {
+		r.names = make(map[string]string)
+	}
+	if replacement, ok := r.names[name]; ok {
+		return replacement
+	}
+	replacement := fmt.Sprintf("user%d", len(r.names)+1)
+	r.names[name] = replacement
+	return replacement
+}
+
+// isIDKey reports whether a JSON key holds snowflakes
+func isIDKey(key string) bool {
+	return key == "id" || key == "roles" || key == "mention_roles" ||
+		strings.HasSuffix(key, "_id") || strings.HasSuffix(key, "_ids")
+}
+
+// isSnowflake reports whether s looks like a Discord ID
+func isSnowflake(s string) bool {
+	if len(s) < 15 || len(s) > 20 {
+		return false
+	}
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	for _, name := range []string{"session.jsonl", "session.jsonl.gz"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "recordings", name)
+			header := RecordingHeader{BotVersion: "1.2.3", Prefix: "!", Redacted: []string{"ids"}}
+			w, err := CreateRecording(path, header)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			events := []RecordedEvent{
+				{Offset: time.Second, Type: "MESSAGE_CREATE", Data: json.RawMessage(`{"content":"!ping"}`)},
+				{Offset: 2 * time.Second, Shard: 1, Type: "GUILD_DELETE", Data: json.RawMessage(`{"id":"1"}`)},
+			}
+			for _, event := range events {
+				if err := w.Write(event); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			recording, err := LoadRecording(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recording.Header.Version != RecordingVersion || recording.Header.BotVersion != "1.2.3" ||
+				recording.Header.Prefix != "!" || len(recording.Header.Redacted) != 1 {
+				t.Errorf("header = %+v", recording.Header)
+			}
+			if len(recording.Events) != len(events) {
+				t.Fatalf("read %d events, want %d", len(recording.Events), len(events))
+			}
+			for i, event := range recording.Events {
+				want := events[i]
+				if event.Offset != want.Offset || event.Shard != want.Shard || event.Type != want.Type ||
+					string(event.Data) != string(want.Data) {
+					t.Errorf("event %d = %+v, want %+v", i, event, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadRecordingIgnoresTornLastLine(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewRecordingWriter(&buf, RecordingHeader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(RecordedEvent{Offset: time.Second, Type: "READY", Data: json.RawMessage(`{}`)})
+	w.Close()
+	buf.WriteString(`{"t":2000000000,"e":"MESSA`)
+
+	recording, err := ReadRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recording.Events) != 1 {
+		t.Errorf("read %d events, want the 1 complete one", len(recording.Events))
+	}
+}
+
+const testMessageEvent = `{
+	"id": "111111111111111111",
+	"channel_id": "222222222222222222",
+	"content": "!kick <@333333333333333333> <@!333333333333333333> from <#222222222222222222> <@&444444444444444444>",
+	"author": {"id": "333333333333333333", "username": "alice", "avatar": "a1b2c3"},
+	"attachments": [{
+		"id": "555555555555555555",
+		"filename": "secret.png",
+		"url": "https://cdn.discordapp.com/attachments/222222222222222222/555555555555555555/secret.png?ex=1",
+		"proxy_url": "https://media.discordapp.net/attachments/222222222222222222/555555555555555555/secret.png"
+	}],
+	"embeds": [{"author": {"icon_url": "https://cdn.discordapp.com/avatars/333333333333333333/a1b2c3.png"}}],
+	"member": {"permissions": "1099511627775"}
+}`
+
+func TestRedactorIDsCoversMentionsAndURLs(t *testing.T) {
+	r := &Redactor{IDs: true}
+	redacted, err := r.Redact(json.RawMessage(testMessageEvent))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := string(redacted)
+	for _, id := range []string{"111111111111111111", "222222222222222222", "333333333333333333", "444444444444444444", "555555555555555555"} {
+		if strings.Contains(text, id) {
+			t.Errorf("redacted event still contains %s: %s", id, text)
+		}
+	}
+	if strings.Contains(text, "alice") {
+		t.Errorf("redacted event still contains the user name: %s", text)
+	}
+	if !strings.Contains(text, "1099511627775") {
+		t.Errorf("redaction changed the permissions: %s", text)
+	}
+
+	var message struct {
+		ChannelID string `json:"channel_id"`
+		Content   string `json:"content"`
+		Author    struct {
+			ID string `json:"id"`
+		} `json:"author"`
+		Attachments []struct {
+			ID  string `json:"id"`
+			URL string `json:"url"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal(redacted, &message); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pseudonyms are stable, so mentions and URLs still point at the
+	// redacted objects
+	user, channel := message.Author.ID, message.ChannelID
+	wantContent := "!kick <@" + user + "> <@!" + user + "> from <#" + channel + "> <@&"
+	if !strings.HasPrefix(message.Content, wantContent) {
+		t.Errorf("content = %q, want prefix %q", message.Content, wantContent)
+	}
+	wantURL := "https://cdn.discordapp.com/attachments/" + channel + "/" + message.Attachments[0].ID + "/secret.png?ex=1"
+	if message.Attachments[0].URL != wantURL {
+		t.Errorf("attachment url = %q, want %q", message.Attachments[0].URL, wantURL)
+	}
+}
+
+func TestRedactorContentKeepsCommandName(t *testing.T) {
+	r := &Redactor{Content: true, Prefix: "!"}
+	redacted, err := r.Redact(json.RawMessage(testMessageEvent))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var message struct {
+		Content     string `json:"content"`
+		Attachments []struct {
+			Filename string `json:"filename"`
+			URL      string `json:"url"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal(redacted, &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Content != "!kick "+redactedText {
+		t.Errorf("content = %q, want the command name only", message.Content)
+	}
+	if message.Attachments[0].Filename != redactedText || message.Attachments[0].URL != redactedText {
+		t.Errorf("attachment = %+v, want it redacted", message.Attachments[0])
+	}
+	if !strings.Contains(string(redacted), "111111111111111111") {
+		t.Error("content redaction changed IDs")
+	}
+}
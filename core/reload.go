@@ -0,0 +1,241 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// configWatchInterval is how often the config file is checked for changes
+const configWatchInterval = 2 * time.Second
+
+// ConfigChangeListener is implemented by modules and middleware that need
+// to react when the configuration is reloaded. It is called after the
+// configuration with the live changes has replaced the bot's Config.
+type ConfigChangeListener interface {
+	OnConfigChange(old, new *Config) error
+}
+
+// ConfigChange describes one setting that differs after a reload
+type ConfigChange struct {
+	Field           string `json:"field"`
+	Old             string `json:"old"`
+	New             string `json:"new"`
+	RequiresRestart bool   `json:"requires_restart"`
+}
+
+// ReloadReport summarizes the outcome of a configuration reload
+type ReloadReport struct {
+	Changes         []ConfigChange `json:"changes"`
+	RestartRequired bool           `json:"restart_required"`
+	Errors          []string       `json:"errors,omitempty"`
+}
+
+// configField describes how a Config field is compared and whether it can
+// change while the gateway connection stays open
+type configField struct {
+	name   string
+	live   bool
+	secret bool
+	value  func(c *Config) string
+	apply  func(dst, src *Config)
+}
+
+var configFields = []configField{
+	{name: "prefix", live: true, value: func(c *Config) string { return c.Prefix }, apply: func(d, s *Config) { d.Prefix = s.Prefix }},
+	{name: "owner_id", live: true, value: func(c *Config) string { return c.OwnerID }, apply: func(d, s *Config) { d.OwnerID = s.OwnerID }},
+	{name: "debug", live: true, value: func(c *Config) string { return fmt.Sprint(c.DebugMode) }, apply: func(d, s *Config) { d.DebugMode = s.DebugMode }},
+	{name: "cooldown", live: true, value: func(c *Config) string { return c.Cooldown.String() }, apply: func(d, s *Config) { d.Cooldown = s.Cooldown }},
+	{name: "logging.level", live: true, value: func(c *Config) string { return c.Logging.Level }, apply: func(d, s *Config) { d.Logging.Level = s.Logging.Level }},
+	{name: "logging.levels", live: true, value: func(c *Config) string { return formatLogLevels(c.Logging.Levels) }, apply: func(d, s *Config) { d.Logging.Levels = s.Logging.Levels }},
+	{name: "shutdown", live: true, value: func(c *Config) string { return fmt.Sprint(c.Shutdown) }, apply: func(d, s *Config) { d.Shutdown = s.Shutdown }},
+	{name: "token", live: false, secret: true, value: func(c *Config) string { return c.Token }},
+	{name: "data_dir", live: false, value: func(c *Config) string { return c.DataDir }},
+	{name: "intents", live: false, value: func(c *Config) string { return strings.Join(c.Intents, ",") }},
+	{name: "sharding", live: false, value: func(c *Config) string { return fmt.Sprintf("%d %s", c.Sharding.Count, c.Sharding.IDs) }},
+	{name: "web.token", live: true, secret: true, value: func(c *Config) string { return c.Web.Token }, apply: func(d, s *Config) { d.Web.Token = s.Web.Token }},
+	{name: "web.enabled", live: false, value: func(c *Config) string { return fmt.Sprint(c.Web.Enabled) }},
+	{name: "web.bind", live: false, value: func(c *Config) string { return c.Web.Bind }},
+	{name: "web.port", live: false, value: func(c *Config) string { return fmt.Sprint(c.Web.Port) }},
+	{name: "logging.format", live: false, value: func(c *Config) string { return c.Logging.Format }},
+	{name: "logging.file", live: false, value: func(c *Config) string { return c.Logging.File }},
+	{name: "wasm", live: false, value: func(c *Config) string { data, _ := json.Marshal(c.Wasm); return string(data) }},
+	{name: "plugins", live: false, value: func(c *Config) string { data, _ := json.Marshal(c.Plugins); return string(data) }},
+}
+
+// redact hides a secret value in reports
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "********"
+}
+
+// diffConfig lists every field that differs between old and new
+func diffConfig(old, new *Config) []ConfigChange {
+	var changes []ConfigChange
+	for _, field := range configFields {
+		before, after := field.value(old), field.value(new)
+		if before == after {
+			continue
+		}
+		if field.secret {
+			before, after = redact(before), redact(after)
+		}
+		changes = append(changes, ConfigChange{
+			Field:           field.name,
+			Old:             before,
+			New:             after,
+			RequiresRestart: !field.live,
+		})
+	}
+
+	names := make(map[string]bool)
+	for name := range old.Modules {
+		names[name] = true
+	}
+	for name := range new.Modules {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		before, _ := json.Marshal(old.Modules[name])
+		after, _ := json.Marshal(new.Modules[name])
+		if string(before) != string(after) {
+			changes = append(changes, ConfigChange{
+				Field: "modules." + name,
+				Old:   string(before),
+				New:   string(after),
+			})
+		}
+	}
+
+	return changes
+}
+
+// ReloadConfig reloads the configuration from its original sources, applies
+// the changes that are safe while connected and notifies listeners. Changes
+// that need a restart are reported but not applied. The live changes are
+// applied to a copy that replaces the current Config, so readers never see
+// a half-updated configuration.
+func (b *Bot) ReloadConfig() (*ReloadReport, error) {
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+
+	current := b.Config()
+	next, err := current.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReloadReport{Changes: diffConfig(current, next)}
+	if len(report.Changes) == 0 {
+		return report, nil
+	}
+
+	updated := current.clone()
+	for _, field := range configFields {
+		if field.live {
+			field.apply(updated, next)
+		}
+	}
+	updated.Modules = next.Modules
+	b.config.Store(updated)
+	b.logs.configure(updated.Logging, updated.DebugMode)
+
+	for _, change := range report.Changes {
+		if change.RequiresRestart {
+			report.RestartRequired = true
+		}
+	}
+
+	for _, listener := range b.configListeners() {
+		if err := listener.OnConfigChange(current, updated); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+
+	b.Events.Publish(ConfigReloadedEvent{Report: report})
+	return report, nil
+}
+
+// configListeners returns every module and middleware that wants to be told
+// about configuration changes
+func (b *Bot) configListeners() []ConfigChangeListener {
+	var listeners []ConfigChangeListener
+	for _, module := range b.Modules {
+		if listener, ok := module.(ConfigChangeListener); ok {
+			listeners = append(listeners, listener)
+		}
+	}
+	for _, middleware := range b.Middleware {
+		if listener, ok := middleware.(ConfigChangeListener); ok {
+			listeners = append(listeners, listener)
+		}
+	}
+	return listeners
+}
+
+// reloadAndLog reloads the configuration and logs the outcome
+func (b *Bot) reloadAndLog(reason string) {
+	logger := b.Logger("config").With("reason", reason)
+
+	report, err := b.ReloadConfig()
+	if err != nil {
+		logger.Error("config reload failed", "error", err)
+		return
+	}
+
+	if len(report.Changes) == 0 {
+		logger.Info("config reloaded with no changes")
+		return
+	}
+
+	for _, change := range report.Changes {
+		if change.RequiresRestart {
+			logger.Warn("config changed but requires a restart", "field", change.Field, "old", change.Old, "new", change.New)
+		} else {
+			logger.Info("config changed", "field", change.Field, "old", change.Old, "new", change.New)
+		}
+	}
+	for _, msg := range report.Errors {
+		logger.Error("error applying config change", "error", msg)
+	}
+}
+
+// watchConfig reloads the configuration whenever its file changes
+func (b *Bot) watchConfig(path string, done <-chan struct{}) {
+	stat := func() (time.Time, int64) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, -1
+		}
+		return info.ModTime(), info.Size()
+	}
+
+	lastMod, lastSize := stat()
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			mod, size := stat()
+			if size < 0 || (mod.Equal(lastMod) && size == lastSize) {
+				continue
+			}
+			lastMod, lastSize = mod, size
+			b.reloadAndLog("file changed")
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestReloadConfigPublishesNewConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(prefix string) {
+		t.Helper()
+		data := "token: test\nowner_id: \"500000000000000005\"\nprefix: \"" + prefix + "\"\nweb:\n  enabled: false\nlogging:\n  file: \"\"\n"
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("!")
+
+	config, err := LoadConfig([]string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bot.Close() })
+
+	before := bot.Config()
+
+	// Readers run concurrently with the reload; go test -race reports any
+	// write to a Config they can see
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = bot.Prefix("1")
+			}
+		}
+	}()
+
+	write("?")
+	report, err := bot.ReloadConfig()
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Changes) != 1 || report.Changes[0].Field != "prefix" {
+		t.Fatalf("changes = %+v, want prefix only", report.Changes)
+	}
+	if got := bot.Config().Prefix; got != "?" {
+		t.Errorf("prefix after reload = %q, want ?", got)
+	}
+	if before.Prefix != "!" {
+		t.Errorf("reload changed the previous Config's prefix to %q", before.Prefix)
+	}
+}
+
+func TestReloadConfigMovesOwnerOnlyToNewOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(owner string) {
+		t.Helper()
+		data := "token: test\nowner_id: \"" + owner + "\"\nweb:\n  enabled: false\nlogging:\n  file: \"\"\n" +
+			// The rejected owner's reply cannot be sent, so do not wait for it
+			"shutdown:\n  module_timeout: 100ms\n"
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("111111111111111111")
+
+	config, err := LoadConfig([]string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bot.Close() })
+
+	configured := NewOwnerOnlyMiddleware(bot.Config().OwnerID)
+	explicit := NewOwnerOnlyMiddleware("333333333333333333")
+	bot.AddMiddleware(configured)
+	bot.AddMiddleware(explicit)
+
+	allows := func(middleware Middleware, userID string) bool {
+		allowed := false
+		m := &discordgo.MessageCreate{Message: &discordgo.Message{ChannelID: "10", Author: &discordgo.User{ID: userID}}}
+		if err := middleware.Process(nil, m, func() { allowed = true }); err != nil {
+			t.Fatal(err)
+		}
+		return allowed
+	}
+
+	write("222222222222222222")
+	report, err := bot.ReloadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changes) != 1 || report.Changes[0].Field != "owner_id" || report.RestartRequired {
+		t.Fatalf("report = %+v, want a live owner_id change", report)
+	}
+
+	if allows(configured, "111111111111111111") {
+		t.Error("OwnerOnly still allows the previous owner after a reload")
+	}
+	if !allows(configured, "222222222222222222") {
+		t.Error("OwnerOnly does not allow the reloaded owner")
+	}
+	if !allows(explicit, "333333333333333333") || allows(explicit, "222222222222222222") {
+		t.Error("OwnerOnly created for an explicit owner followed the configured owner")
+	}
+}
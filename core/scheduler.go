@@ -0,0 +1,535 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	mathrand "math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// scheduledTasksNamespace stores pending one-shot tasks so they survive restarts
+const scheduledTasksNamespace = "scheduled_tasks"
+
+// Scheduler limits
+const (
+	schedulerHistorySize = 20               // finished one-shot tasks kept for the dashboard
+	schedulerStopTimeout = 10 * time.Second // how long Stop waits for running jobs
+)
+
+// Scheduler errors
+var (
+	ErrJobExists   = errors.New("job already exists")
+	ErrJobNotFound = errors.New("job not found")
+)
+
+// JobFunc is the work done by a scheduled job. ctx is cancelled when the job
+// is cancelled, its module stops or the bot shuts down.
+type JobFunc func(ctx context.Context) error
+
+// TaskFunc handles a one-shot task scheduled with ScheduleTask
+type TaskFunc func(ctx context.Context, payload json.RawMessage) error
+
+// JobOption configures a scheduled job
+type JobOption func(*Job)
+
+// WithJitter delays each run by a random duration up to max, spreading out
+// jobs that would otherwise fire at the same moment
+func WithJitter(max time.Duration) JobOption {
+	return func(j *Job) {
+		j.jitter = max
+	}
+}
+
+// AllowOverlap lets a run start while the previous one is still going. By
+// default such runs are skipped.
+func AllowOverlap() JobOption {
+	return func(j *Job) {
+		j.overlap = true
+	}
+}
+
+// JobStatus is a snapshot of a scheduled job for the dashboard and API
+type JobStatus struct {
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	Module       string        `json:"module,omitempty"`
+	Schedule     string        `json:"schedule"`
+	NextRun      *time.Time    `json:"next_run,omitempty"`
+	LastRun      *time.Time    `json:"last_run,omitempty"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error,omitempty"`
+	Running      bool          `json:"running"`
+	Runs         int           `json:"runs"`
+	Skipped      int           `json:"skipped"`
+	Done         bool          `json:"done"`
+}
+
+// Job is a scheduled unit of work
+type Job struct {
+	id       string
+	name     string
+	module   string
+	schedule Schedule
+	fn       JobFunc
+	jitter   time.Duration
+	overlap  bool
+	once     bool
+
+	scheduler *Scheduler
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	mu           sync.Mutex
+	next         time.Time
+	lastRun      time.Time
+	lastDuration time.Duration
+	lastErr      error
+	running      int
+	runs         int
+	skipped      int
+	done         bool
+}
+
+// storedTask is a pending one-shot task as saved in storage
+type storedTask struct {
+	ID        string          `json:"id"`
+	Task      string          `json:"task"`
+	At        time.Time       `json:"at"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// taskHandler is a registered handler for one kind of one-shot task
+type taskHandler struct {
+	module string
+	fn     TaskFunc
+}
+
+// Scheduler runs cron, interval and one-shot jobs for the bot and its
+// modules. Jobs scheduled on behalf of a module are cancelled when it stops.
+type Scheduler struct {
+	bot    *Bot
+	log    *slog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	handlers map[string]*taskHandler
+	history  []JobStatus
+}
+
+func newScheduler(bot *Bot) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		bot:      bot,
+		log:      bot.Logger("scheduler"),
+		ctx:      ctx,
+		cancel:   cancel,
+		jobs:     make(map[string]*Job),
+		handlers: make(map[string]*taskHandler),
+	}
+}
+
+// Cron schedules fn using a cron expression (see ParseCron)
+func (s *Scheduler) Cron(module Module, name, spec string, fn JobFunc, opts ...JobOption) (*Job, error) {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	return s.Schedule(module, name, schedule, fn, opts...)
+}
+
+// Every schedules fn to run at a fixed interval
+func (s *Scheduler) Every(module Module, name string, interval time.Duration, fn JobFunc, opts ...JobOption) (*Job, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval for job %s: %v", name, interval)
+	}
+	return s.Schedule(module, name, Every(interval), fn, opts...)
+}
+
+// Schedule runs fn on a recurring schedule. Job names must be unique. When
+// module is not nil the job is cancelled as soon as the module stops.
+func (s *Scheduler) Schedule(module Module, name string, schedule Schedule, fn JobFunc, opts ...JobOption) (*Job, error) {
+	job := s.newJob(name, name, module, schedule, fn)
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	if err := s.add(job); err != nil {
+		return nil, err
+	}
+	if module != nil {
+		s.bot.OnModuleStop(module, job.Cancel)
+	}
+	return job, nil
+}
+
+// HandleTask registers the handler for one-shot tasks of the given kind and
+// arms every pending task of that kind, including ones saved before a
+// restart. Overdue tasks run right away. The handler is removed when module
+// stops; its pending tasks stay saved until a handler is registered again.
+func (s *Scheduler) HandleTask(module Module, task string, fn TaskFunc) error {
+	handler := &taskHandler{fn: fn}
+	if module != nil {
+		handler.module = module.Name()
+	}
+
+	s.mu.Lock()
+	if _, exists := s.handlers[task]; exists {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: handler for task %s", ErrJobExists, task)
+	}
+	s.handlers[task] = handler
+	s.mu.Unlock()
+
+	if module != nil {
+		s.bot.OnModuleStop(module, func() { s.removeHandler(task, handler) })
+	}
+
+	ids, err := s.bot.Storage.Keys(scheduledTasksNamespace)
+	if err != nil {
+		return fmt.Errorf("error loading scheduled tasks: %w", err)
+	}
+	for _, id := range ids {
+		var stored storedTask
+		if err := s.bot.Storage.Load(scheduledTasksNamespace, id, &stored); err != nil {
+			s.log.Error("error loading scheduled task", "task_id", id, "error", err)
+			continue
+		}
+		if stored.Task == task {
+			s.armTask(stored, handler)
+		}
+	}
+	return nil
+}
+
+// ScheduleTask saves a one-shot task to run at the given time with payload
+// and returns its ID. The task survives restarts and runs once a handler is
+// registered with HandleTask.
+func (s *Scheduler) ScheduleTask(task string, at time.Time, payload interface{}) (string, error) {
+	stored := storedTask{
+		ID:        newTaskID(),
+		Task:      task,
+		At:        at,
+		CreatedAt: time.Now(),
+	}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return "", fmt.Errorf("error encoding payload for task %s: %w", task, err)
+		}
+		stored.Payload = data
+	}
+
+	if err := s.bot.Storage.Save(scheduledTasksNamespace, stored.ID, stored); err != nil {
+		return "", fmt.Errorf("error saving task %s: %w", task, err)
+	}
+
+	s.mu.Lock()
+	handler := s.handlers[task]
+	s.mu.Unlock()
+	if handler != nil {
+		s.armTask(stored, handler)
+	}
+	return stored.ID, nil
+}
+
+// Cancel stops a job, or deletes a pending one-shot task, by ID
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	job, exists := s.jobs[id]
+	s.mu.Unlock()
+
+	if exists {
+		job.Cancel()
+		if !job.once {
+			return nil
+		}
+	}
+
+	var stored storedTask
+	if err := s.bot.Storage.Load(scheduledTasksNamespace, id, &stored); err != nil {
+		if exists {
+			return nil
+		}
+		if errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("%w: %s", ErrJobNotFound, id)
+		}
+		return err
+	}
+	return s.bot.Storage.Delete(scheduledTasksNamespace, id)
+}
+
+// Jobs returns every scheduled job ordered by next run, followed by recently
+// finished one-shot tasks
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	history := append([]JobStatus(nil), s.history...)
+	s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(jobs)+len(history))
+	for _, job := range jobs {
+		statuses = append(statuses, job.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		a, b := statuses[i].NextRun, statuses[j].NextRun
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.Before(*b)
+	})
+
+	for i := len(history) - 1; i >= 0; i-- {
+		statuses = append(statuses, history[i])
+	}
+	return statuses
+}
+
+// Stop cancels every job and waits briefly for running jobs to return
+func (s *Scheduler) Stop() {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(schedulerStopTimeout):
+		s.log.Warn("some scheduled jobs did not stop in time", "timeout", schedulerStopTimeout)
+	}
+}
+
+// Cancel stops the job; a run in progress sees its context cancelled
+func (j *Job) Cancel() {
+	j.cancel()
+	j.scheduler.remove(j)
+}
+
+// ID returns the job's identifier
+func (j *Job) ID() string {
+	return j.id
+}
+
+// Status returns a snapshot of the job's state
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := JobStatus{
+		ID:           j.id,
+		Name:         j.name,
+		Module:       j.module,
+		Schedule:     j.schedule.String(),
+		LastDuration: j.lastDuration,
+		Running:      j.running > 0,
+		Runs:         j.runs,
+		Skipped:      j.skipped,
+		Done:         j.done,
+	}
+	if !j.next.IsZero() && !j.done {
+		next := j.next
+		status.NextRun = &next
+	}
+	if !j.lastRun.IsZero() {
+		lastRun := j.lastRun
+		status.LastRun = &lastRun
+	}
+	if j.lastErr != nil {
+		status.LastError = j.lastErr.Error()
+	}
+	return status
+}
+
+func (s *Scheduler) newJob(id, name string, module Module, schedule Schedule, fn JobFunc) *Job {
+	ctx, cancel := context.WithCancel(s.ctx)
+	job := &Job{
+		id:        id,
+		name:      name,
+		schedule:  schedule,
+		fn:        fn,
+		scheduler: s,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	if module != nil {
+		job.module = module.Name()
+	}
+	return job
+}
+
+// add registers a job and starts its timer loop
+func (s *Scheduler) add(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx.Err() != nil {
+		return fmt.Errorf("scheduler is stopped")
+	}
+	if _, exists := s.jobs[job.id]; exists {
+		return fmt.Errorf("%w: %s", ErrJobExists, job.id)
+	}
+	s.jobs[job.id] = job
+
+	s.wg.Add(1)
+	go job.loop()
+	return nil
+}
+
+func (s *Scheduler) remove(job *Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.jobs[job.id] == job {
+		delete(s.jobs, job.id)
+	}
+}
+
+// armTask starts the timer for a stored one-shot task
+func (s *Scheduler) armTask(stored storedTask, handler *taskHandler) {
+	job := s.newJob(stored.ID, stored.Task, nil, onceSchedule{at: stored.At}, func(ctx context.Context) error {
+		return handler.fn(ctx, stored.Payload)
+	})
+	job.module = handler.module
+	job.once = true
+
+	if err := s.add(job); err != nil && !errors.Is(err, ErrJobExists) {
+		s.log.Error("error scheduling task", "task_id", stored.ID, "error", err)
+	}
+}
+
+// removeHandler unregisters a task handler and disarms its pending tasks
+// without deleting them
+func (s *Scheduler) removeHandler(task string, handler *taskHandler) {
+	s.mu.Lock()
+	if s.handlers[task] == handler {
+		delete(s.handlers, task)
+	}
+	var armed []*Job
+	for _, job := range s.jobs {
+		if job.once && job.name == task {
+			armed = append(armed, job)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, job := range armed {
+		job.Cancel()
+	}
+}
+
+// finishTask removes a one-shot task once it ran and records it in the
+// history. Tasks interrupted by shutdown stay saved and run again on the
+// next start.
+func (s *Scheduler) finishTask(job *Job) {
+	if job.ctx.Err() == nil {
+		if err := s.bot.Storage.Delete(scheduledTasksNamespace, job.id); err != nil && !errors.Is(err, ErrNotFound) {
+			s.log.Error("error removing finished task", "task_id", job.id, "error", err)
+		}
+	}
+
+	job.mu.Lock()
+	job.done = true
+	job.mu.Unlock()
+
+	s.remove(job)
+
+	s.mu.Lock()
+	s.history = append(s.history, job.Status())
+	if len(s.history) > schedulerHistorySize {
+		s.history = s.history[len(s.history)-schedulerHistorySize:]
+	}
+	s.mu.Unlock()
+}
+
+// loop waits for each scheduled time and starts a run
+func (j *Job) loop() {
+	defer j.scheduler.wg.Done()
+
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			j.scheduler.remove(j)
+			return
+		}
+		if j.jitter > 0 {
+			next = next.Add(time.Duration(mathrand.Int63n(int64(j.jitter))))
+		}
+
+		j.mu.Lock()
+		j.next = next
+		j.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-j.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if j.once {
+			j.run()
+			j.scheduler.finishTask(j)
+			return
+		}
+
+		j.scheduler.wg.Add(1)
+		go func() {
+			defer j.scheduler.wg.Done()
+			j.run()
+		}()
+	}
+}
+
+// run executes the job once, skipping the run if the previous one is still
+// going and overlap is not allowed
+func (j *Job) run() {
+	j.mu.Lock()
+	if j.running > 0 && !j.overlap {
+		j.skipped++
+		j.mu.Unlock()
+		j.scheduler.log.Warn("skipping job run: previous run still in progress", "job", j.name, "module", j.module)
+		return
+	}
+	j.running++
+	j.mu.Unlock()
+
+	started := time.Now()
+	err := safeCall(func() error { return j.fn(j.ctx) })
+	elapsed := time.Since(started)
+
+	if err != nil {
+		j.scheduler.log.Error("error running job", "job", j.name, "module", j.module, "error", err)
+	}
+
+	j.mu.Lock()
+	j.running--
+	j.runs++
+	j.lastRun = started
+	j.lastDuration = elapsed
+	j.lastErr = err
+	j.mu.Unlock()
+}
+
+// newTaskID returns a random identifier for a one-shot task
+func newTaskID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return "task-" + hex.EncodeToString(buf)
+}
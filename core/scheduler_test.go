@@ -0,0 +1,204 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newSchedulerBot(t *testing.T, dataDir string) *Bot {
+	t.Helper()
+
+	bot, err := NewBot(&Config{Token: "test", Prefix: "!", DataDir: dataDir})
+	if err != nil {
+		t.Fatalf("NewBot: %v", err)
+	}
+	t.Cleanup(bot.Scheduler.Stop)
+	return bot
+}
+
+// waitFor polls cond until it holds or a second passes
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func storedTaskIDs(t *testing.T, bot *Bot) []string {
+	t.Helper()
+
+	ids, err := bot.Storage.Keys(scheduledTasksNamespace)
+	if err != nil {
+		t.Fatalf("Keys: %v", err)
+	}
+	return ids
+}
+
+func TestScheduleTaskIsSavedUntilItRuns(t *testing.T) {
+	bot := newSchedulerBot(t, "")
+
+	id, err := bot.Scheduler.ScheduleTask("remind", time.Now().Add(30*time.Millisecond), map[string]string{"text": "hello"})
+	if err != nil {
+		t.Fatalf("ScheduleTask: %v", err)
+	}
+	if ids := storedTaskIDs(t, bot); len(ids) != 1 || ids[0] != id {
+		t.Fatalf("stored tasks = %v, want [%s]", ids, id)
+	}
+
+	payloads := make(chan json.RawMessage, 1)
+	err = bot.Scheduler.HandleTask(nil, "remind", func(ctx context.Context, payload json.RawMessage) error {
+		payloads <- payload
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("HandleTask: %v", err)
+	}
+
+	select {
+	case payload := <-payloads:
+		if string(payload) != `{"text":"hello"}` {
+			t.Errorf("payload = %s", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task did not run")
+	}
+
+	waitFor(t, "the finished task to be deleted", func() bool { return len(storedTaskIDs(t, bot)) == 0 })
+
+	jobs := bot.Scheduler.Jobs()
+	if len(jobs) != 1 || jobs[0].ID != id || !jobs[0].Done || jobs[0].Runs != 1 {
+		t.Errorf("jobs = %+v, want the task in the history as done", jobs)
+	}
+}
+
+func TestScheduledTasksAreArmedAgainAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	first := newSchedulerBot(t, dir)
+	var ranEarly atomic.Bool
+	err := first.Scheduler.HandleTask(nil, "remind", func(context.Context, json.RawMessage) error {
+		ranEarly.Store(true)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("HandleTask: %v", err)
+	}
+
+	future, err := first.Scheduler.ScheduleTask("remind", time.Now().Add(time.Hour), nil)
+	if err != nil {
+		t.Fatalf("ScheduleTask: %v", err)
+	}
+	overdue, err := first.Scheduler.ScheduleTask("remind", time.Now().Add(time.Hour), nil)
+	if err != nil {
+		t.Fatalf("ScheduleTask: %v", err)
+	}
+	first.Scheduler.Stop()
+	if ranEarly.Load() {
+		t.Fatal("a task ran before its time")
+	}
+
+	// Move one task into the past, as if the bot was offline when it was due
+	var stored storedTask
+	if err := first.Storage.Load(scheduledTasksNamespace, overdue, &stored); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	stored.At = time.Now().Add(-time.Minute)
+	if err := first.Storage.Save(scheduledTasksNamespace, overdue, stored); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	second := newSchedulerBot(t, dir)
+	ran := make(chan struct{}, 2)
+	err = second.Scheduler.HandleTask(nil, "remind", func(context.Context, json.RawMessage) error {
+		ran <- struct{}{}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("HandleTask after restart: %v", err)
+	}
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("overdue task did not run after restart")
+	}
+	waitFor(t, "the overdue task to be deleted", func() bool {
+		ids := storedTaskIDs(t, second)
+		return len(ids) == 1 && ids[0] == future
+	})
+
+	waitFor(t, "the future task to be armed", func() bool {
+		for _, job := range second.Scheduler.Jobs() {
+			if job.ID == future && job.NextRun != nil && !job.Done {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func TestModuleStopCancelsItsJobs(t *testing.T) {
+	var runs atomic.Int32
+	var jobCtx atomic.Value
+
+	module := &lifecycleModule{name: "ticker"}
+	module.initialize = func(bot *Bot) error {
+		if _, err := bot.Scheduler.Every(module, "tick", 5*time.Millisecond, func(ctx context.Context) error {
+			jobCtx.Store(ctx)
+			runs.Add(1)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return bot.Scheduler.HandleTask(module, "ticker.remind", func(context.Context, json.RawMessage) error {
+			return nil
+		})
+	}
+
+	bot := newSchedulerBot(t, "")
+	bot.RegisterModule(module)
+
+	id, err := bot.Scheduler.ScheduleTask("ticker.remind", time.Now().Add(time.Hour), nil)
+	if err != nil {
+		t.Fatalf("ScheduleTask: %v", err)
+	}
+	if err := bot.StartModule("ticker"); err != nil {
+		t.Fatalf("StartModule: %v", err)
+	}
+	if len(bot.Scheduler.Jobs()) != 2 {
+		t.Fatalf("jobs while running = %+v, want the interval job and the task", bot.Scheduler.Jobs())
+	}
+	waitFor(t, "the job to run", func() bool { return runs.Load() > 0 })
+
+	if err := bot.StopModule("ticker"); err != nil {
+		t.Fatalf("StopModule: %v", err)
+	}
+
+	if jobs := bot.Scheduler.Jobs(); len(jobs) != 0 {
+		t.Errorf("jobs after stop = %+v, want none", jobs)
+	}
+	if ctx := jobCtx.Load().(context.Context); ctx.Err() == nil {
+		t.Error("job context was not cancelled when the module stopped")
+	}
+
+	// A run that fired just before the stop may still finish
+	time.Sleep(10 * time.Millisecond)
+	stopped := runs.Load()
+	time.Sleep(30 * time.Millisecond)
+	if got := runs.Load(); got != stopped {
+		t.Errorf("job ran %d more times after the module stopped", got-stopped)
+	}
+
+	// The pending task stays saved for the next time a handler registers
+	if ids := storedTaskIDs(t, bot); len(ids) != 1 || ids[0] != id {
+		t.Errorf("stored tasks after stop = %v, want [%s]", ids, id)
+	}
+}
@@ -0,0 +1,468 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// settingsNamespace is the storage namespace holding per-guild settings
+const settingsNamespace = "guild_settings"
+
+// Built-in guild setting keys
+const (
+	SettingPrefix           = "prefix"
+	SettingLocale           = "locale"
+	SettingLogChannel       = "log_channel"
+	SettingDisabledCommands = "disabled_commands"
+	SettingDisabledModules  = "disabled_modules"
+)
+
+// ErrUnknownSetting is returned when a key has no registered definition
+var ErrUnknownSetting = errors.New("unknown setting")
+
+// SettingType describes how a setting value is parsed and stored
+type SettingType int
+
+const (
+	SettingTypeString SettingType = iota
+	SettingTypeInt
+	SettingTypeBool
+	SettingTypeChannel
+	SettingTypeStringList
+)
+
+// String returns the human readable name of the type
+func (t SettingType) String() string {
+	switch t {
+	case SettingTypeInt:
+		return "int"
+	case SettingTypeBool:
+		return "bool"
+	case SettingTypeChannel:
+		return "channel"
+	case SettingTypeStringList:
+		return "list"
+	default:
+		return "string"
+	}
+}
+
+// Setting declares a typed per-guild setting with its default and validation
+type Setting struct {
+	Key         string
+	Description string
+	Type        SettingType
+	Default     interface{}
+	Validate    func(value interface{}) error
+}
+
+// SettingsProvider is implemented by commands and modules that declare
+// their own guild settings. Declared settings are registered together with
+// the command or module.
+type SettingsProvider interface {
+	Settings() []Setting
+}
+
+var channelMentionPattern = regexp.MustCompile(`^<#(\d+)>$`)
+
+// Parse converts a raw user supplied value into the setting's typed value
+// and runs its validator
+func (s Setting) Parse(raw string) (interface{}, error) {
+	raw = strings.TrimSpace(raw)
+
+	var value interface{}
+	switch s.Type {
+	case SettingTypeInt:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number", s.Key)
+		}
+		value = n
+	case SettingTypeBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			switch strings.ToLower(raw) {
+			case "on", "yes", "enable", "enabled":
+				b = true
+			case "off", "no", "disable", "disabled":
+				b = false
+			default:
+				return nil, fmt.Errorf("%s must be true or false", s.Key)
+			}
+		}
+		value = b
+	case SettingTypeChannel:
+		if match := channelMentionPattern.FindStringSubmatch(raw); match != nil {
+			raw = match[1]
+		}
+		if _, err := strconv.ParseUint(raw, 10, 64); err != nil {
+			return nil, fmt.Errorf("%s must be a channel mention or ID", s.Key)
+		}
+		value = raw
+	case SettingTypeStringList:
+		list := strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ' ' })
+		if list == nil {
+			list = []string{}
+		}
+		value = list
+	default:
+		value = raw
+	}
+
+	if s.Validate != nil {
+		if err := s.Validate(value); err != nil {
+			return nil, err
+		}
+	}
+
+	return value, nil
+}
+
+// Format renders a typed value the way it would be entered by a user
+func (s Setting) Format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, ", ")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// settingsRegistry holds the schema and the cached per-guild values
+type settingsRegistry struct {
+	mu          sync.RWMutex
+	definitions map[string]Setting
+	guilds      map[string]*GuildSettings
+	storage     Storage
+	log         *slog.Logger
+}
+
+func newSettingsRegistry(storage Storage, logger *slog.Logger) *settingsRegistry {
+	return &settingsRegistry{
+		definitions: make(map[string]Setting),
+		guilds:      make(map[string]*GuildSettings),
+		storage:     storage,
+		log:         logger,
+	}
+}
+
+func (r *settingsRegistry) define(setting Setting) error {
+	if setting.Key == "" {
+		return errors.New("setting key cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.definitions[setting.Key]; exists {
+		return fmt.Errorf("setting %s is already defined", setting.Key)
+	}
+	r.definitions[setting.Key] = setting
+
+	return nil
+}
+
+func (r *settingsRegistry) lookup(key string) (Setting, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	setting, exists := r.definitions[key]
+	return setting, exists
+}
+
+func (r *settingsRegistry) all() []Setting {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	settings := make([]Setting, 0, len(r.definitions))
+	for _, setting := range r.definitions {
+		settings = append(settings, setting)
+	}
+	sort.Slice(settings, func(i, j int) bool {
+		return settings[i].Key < settings[j].Key
+	})
+
+	return settings
+}
+
+func (r *settingsRegistry) guild(guildID string) *GuildSettings {
+	r.mu.RLock()
+	gs, exists := r.guilds[guildID]
+	r.mu.RUnlock()
+	if exists {
+		return gs
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if gs, exists := r.guilds[guildID]; exists {
+		return gs
+	}
+
+	gs = &GuildSettings{
+		GuildID:  guildID,
+		registry: r,
+		raw:      make(map[string]string),
+		defaults: make(map[string]string),
+	}
+	if guildID != "" {
+		if err := r.storage.Load(settingsNamespace, guildID, &gs.raw); err != nil && !errors.Is(err, ErrNotFound) {
+			// Fall back to defaults rather than failing every command in the guild
+			r.log.Error("error loading guild settings", LogKeyGuild, guildID, "error", err)
+		}
+	}
+	r.guilds[guildID] = gs
+
+	return gs
+}
+
+// GuildSettings provides typed access to one guild's settings. Values that
+// were never set fall back to the guild's default from SetDefault, then to
+// the setting's declared default.
+type GuildSettings struct {
+	GuildID string
+
+	mu       sync.RWMutex
+	registry *settingsRegistry
+	raw      map[string]string
+	defaults map[string]string // not persisted
+}
+
+// Get returns the effective value of a setting
+func (g *GuildSettings) Get(key string) interface{} {
+	setting, exists := g.registry.lookup(key)
+	if !exists {
+		return nil
+	}
+
+	g.mu.RLock()
+	raw, isSet := g.raw[key]
+	fallback, hasDefault := g.defaults[key]
+	g.mu.RUnlock()
+
+	if isSet {
+		// Stored values were validated on the way in, but the schema may have
+		// changed since; treat unparsable values as unset
+		if value, err := setting.Parse(raw); err == nil {
+			return value
+		}
+	}
+	if hasDefault {
+		if value, err := setting.Parse(fallback); err == nil {
+			return value
+		}
+	}
+
+	return setting.Default
+}
+
+// IsSet reports whether the guild overrides the default for key
+func (g *GuildSettings) IsSet(key string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	_, isSet := g.raw[key]
+	return isSet
+}
+
+// String returns a string setting value
+func (g *GuildSettings) String(key string) string {
+	value, _ := g.Get(key).(string)
+	return value
+}
+
+// Int returns an int setting value
+func (g *GuildSettings) Int(key string) int {
+	value, _ := g.Get(key).(int)
+	return value
+}
+
+// Bool returns a bool setting value
+func (g *GuildSettings) Bool(key string) bool {
+	value, _ := g.Get(key).(bool)
+	return value
+}
+
+// StringList returns a list setting value
+func (g *GuildSettings) StringList(key string) []string {
+	value, _ := g.Get(key).([]string)
+	return value
+}
+
+// Set parses, validates and persists a new value for key
+func (g *GuildSettings) Set(key, raw string) error {
+	if g.GuildID == "" {
+		return errors.New("settings can only be changed inside a server")
+	}
+
+	setting, exists := g.registry.lookup(key)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownSetting, key)
+	}
+
+	value, err := setting.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	previous, wasSet := g.raw[key]
+	g.raw[key] = setting.Format(value)
+	if err := g.persist(); err != nil {
+		g.restore(key, previous, wasSet)
+		return err
+	}
+	return nil
+}
+
+// SetDefault parses and validates a default for key that applies in this
+// guild until a value is set. It is kept in memory only, so values set with
+// Set take precedence and Reset falls back to it.
+func (g *GuildSettings) SetDefault(key, raw string) error {
+	if g.GuildID == "" {
+		return errors.New("settings can only be changed inside a server")
+	}
+
+	setting, exists := g.registry.lookup(key)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownSetting, key)
+	}
+
+	value, err := setting.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.defaults[key] = setting.Format(value)
+	return nil
+}
+
+// Reset removes the guild override for key so the default applies again
+func (g *GuildSettings) Reset(key string) error {
+	if g.GuildID == "" {
+		return errors.New("settings can only be changed inside a server")
+	}
+
+	if _, exists := g.registry.lookup(key); !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownSetting, key)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	previous, wasSet := g.raw[key]
+	delete(g.raw, key)
+	if err := g.persist(); err != nil {
+		g.restore(key, previous, wasSet)
+		return err
+	}
+	return nil
+}
+
+// restore puts back a value after persisting a change failed, so memory
+// keeps matching storage. Callers must hold g.mu.
+func (g *GuildSettings) restore(key, previous string, wasSet bool) {
+	if wasSet {
+		g.raw[key] = previous
+	} else {
+		delete(g.raw, key)
+	}
+}
+
+// persist writes the guild's overrides to storage. Callers must hold g.mu.
+func (g *GuildSettings) persist() error {
+	if len(g.raw) == 0 {
+		return g.registry.storage.Delete(settingsNamespace, g.GuildID)
+	}
+	return g.registry.storage.Save(settingsNamespace, g.GuildID, g.raw)
+}
+
+// Prefix returns the guild's command prefix, or fallback when unset
+func (g *GuildSettings) Prefix(fallback string) string {
+	if prefix := g.String(SettingPrefix); prefix != "" {
+		return prefix
+	}
+	return fallback
+}
+
+// CommandDisabled reports whether a command is disabled in the guild
+func (g *GuildSettings) CommandDisabled(name string) bool {
+	return containsFold(g.StringList(SettingDisabledCommands), name)
+}
+
+// ModuleEnabled reports whether a module should act in the guild
+func (g *GuildSettings) ModuleEnabled(name string) bool {
+	return !containsFold(g.StringList(SettingDisabledModules), name)
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+var localePattern = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+
+// builtinSettings are defined on every bot
+func builtinSettings() []Setting {
+	return []Setting{
+		{
+			Key:         SettingPrefix,
+			Description: "Command prefix used in this server",
+			Type:        SettingTypeString,
+			Validate: func(value interface{}) error {
+				prefix := value.(string)
+				if prefix == "" || len(prefix) > 5 || strings.ContainsAny(prefix, " \t\n") {
+					return errors.New("prefix must be 1-5 characters without spaces")
+				}
+				return nil
+			},
+		},
+		{
+			Key:         SettingLocale,
+			Description: "Language used for bot responses (e.g. en, de, pt-BR)",
+			Type:        SettingTypeString,
+			Default:     "en",
+			Validate: func(value interface{}) error {
+				if !localePattern.MatchString(value.(string)) {
+					return errors.New("locale must look like en or pt-BR")
+				}
+				return nil
+			},
+		},
+		{
+			Key:         SettingLogChannel,
+			Description: "Channel that receives bot log messages",
+			Type:        SettingTypeChannel,
+		},
+		{
+			Key:         SettingDisabledCommands,
+			Description: "Commands that cannot be used in this server",
+			Type:        SettingTypeStringList,
+			Default:     []string{},
+		},
+		{
+			Key:         SettingDisabledModules,
+			Description: "Modules that are turned off in this server",
+			Type:        SettingTypeStringList,
+			Default:     []string{},
+		},
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+// failingStorage fails every write after fail is set
+type failingStorage struct {
+	Storage
+	fail bool
+}
+
+var errStorageFailed = errors.New("storage failed")
+
+func (s *failingStorage) Save(namespace, key string, v interface{}) error {
+	if s.fail {
+		return errStorageFailed
+	}
+	return s.Storage.Save(namespace, key, v)
+}
+
+func (s *failingStorage) Delete(namespace, key string) error {
+	if s.fail {
+		return errStorageFailed
+	}
+	return s.Storage.Delete(namespace, key)
+}
+
+func newTestSettings(t *testing.T, storage Storage) *settingsRegistry {
+	t.Helper()
+
+	registry := newSettingsRegistry(storage, slog.Default())
+	for _, setting := range builtinSettings() {
+		if err := registry.define(setting); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return registry
+}
+
+func TestSettingsSetRollsBackWhenPersistingFails(t *testing.T) {
+	storage := &failingStorage{Storage: NewMemoryStorage()}
+	settings := newTestSettings(t, storage).guild("1")
+
+	if err := settings.Set(SettingPrefix, "?"); err != nil {
+		t.Fatal(err)
+	}
+
+	storage.fail = true
+	if err := settings.Set(SettingPrefix, "$"); !errors.Is(err, errStorageFailed) {
+		t.Fatalf("Set error = %v, want %v", err, errStorageFailed)
+	}
+	if got := settings.String(SettingPrefix); got != "?" {
+		t.Errorf("prefix after failed Set = %q, want ?", got)
+	}
+
+	if err := settings.Set(SettingLocale, "de"); err == nil {
+		t.Fatal("Set of an unset key succeeded with failing storage")
+	}
+	if settings.IsSet(SettingLocale) {
+		t.Error("locale is set after a failed Set")
+	}
+
+	if err := settings.Reset(SettingPrefix); !errors.Is(err, errStorageFailed) {
+		t.Fatalf("Reset error = %v, want %v", err, errStorageFailed)
+	}
+	if got := settings.String(SettingPrefix); got != "?" {
+		t.Errorf("prefix after failed Reset = %q, want ?", got)
+	}
+}
+
+func TestSettingsDefaultsAreNotPersisted(t *testing.T) {
+	storage := NewMemoryStorage()
+	settings := newTestSettings(t, storage).guild("1")
+
+	if err := settings.SetDefault(SettingPrefix, "?"); err != nil {
+		t.Fatal(err)
+	}
+	if got := settings.String(SettingPrefix); got != "?" {
+		t.Errorf("prefix with default = %q, want ?", got)
+	}
+	if settings.IsSet(SettingPrefix) {
+		t.Error("a default counts as set")
+	}
+	var stored map[string]string
+	if err := storage.Load(settingsNamespace, "1", &stored); !errors.Is(err, ErrNotFound) {
+		t.Errorf("default was persisted: %v, %v", stored, err)
+	}
+
+	if err := settings.Set(SettingPrefix, "$"); err != nil {
+		t.Fatal(err)
+	}
+	if got := settings.String(SettingPrefix); got != "$" {
+		t.Errorf("prefix after Set = %q, want $", got)
+	}
+
+	// A restart applies the default again without replacing the stored value
+	restarted := newTestSettings(t, storage).guild("1")
+	if err := restarted.SetDefault(SettingPrefix, "?"); err != nil {
+		t.Fatal(err)
+	}
+	if got := restarted.String(SettingPrefix); got != "$" {
+		t.Errorf("prefix after restart = %q, want $", got)
+	}
+
+	if err := restarted.Reset(SettingPrefix); err != nil {
+		t.Fatal(err)
+	}
+	if got := restarted.String(SettingPrefix); got != "?" {
+		t.Errorf("prefix after Reset = %q, want ?", got)
+	}
+
+	if err := restarted.SetDefault(SettingPrefix, ""); err == nil {
+		t.Error("SetDefault accepted an invalid value")
+	}
+}
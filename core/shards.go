@@ -0,0 +1,375 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// identifyInterval is how long Discord requires between identifies that
+// share a rate limit bucket
+const identifyInterval = 5 * time.Second
+
+// ShardState is the connection state of a shard
+type ShardState string
+
+const (
+	ShardIdle         ShardState = "idle"
+	ShardConnecting   ShardState = "connecting"
+	ShardConnected    ShardState = "connected"
+	ShardReady        ShardState = "ready"
+	ShardDisconnected ShardState = "disconnected"
+)
+
+// ShardStatus is a snapshot of one shard for the dashboard and API
+type ShardStatus struct {
+	ID             int           `json:"id"`
+	State          ShardState    `json:"state"`
+	Since          time.Time     `json:"since"`
+	Latency        time.Duration `json:"latency"`
+	Guilds         int           `json:"guilds"`
+	Reconnects     int           `json:"reconnects"`
+	Resumes        int           `json:"resumes"`
+	Sessions       int           `json:"sessions"`
+	LastDisconnect *time.Time    `json:"last_disconnect,omitempty"`
+}
+
+// Shard is one gateway connection handling a subset of the bot's guilds
+type Shard struct {
+	ID      int
+	Session *discordgo.Session
+
+	mu             sync.Mutex
+	state          ShardState
+	since          time.Time
+	connected      bool // Connect was recorded for the current connection
+	connects       int
+	identifies     int
+	resumes        int
+	lastDisconnect time.Time
+	recentDrops    []time.Time // disconnects within reconnectStormWindow
+	stormWarned    time.Time
+}
+
+// sessionHandler is a handler added to every shard through Bot.AddHandler
+type sessionHandler struct {
+	handler  interface{}
+	removers []func()
+}
+
+// shardManager owns the bot's shard sessions and the handlers shared by them
+type shardManager struct {
+	mu       sync.Mutex
+	shards   []*Shard
+	count    int
+	handlers []*sessionHandler
+	history  []ConnectionEvent
+}
+
+func newShardManager(session *discordgo.Session) *shardManager {
+	return &shardManager{
+		shards: []*Shard{{ID: 0, Session: session, state: ShardIdle, since: time.Now()}},
+		count:  1,
+	}
+}
+
+// AddHandler registers a discordgo event handler on every shard, including
+// shards created later by Start, and returns a function that removes it
+func (b *Bot) AddHandler(handler interface{}) func() {
+	m := b.shards
+	h := &sessionHandler{handler: handler}
+
+	m.mu.Lock()
+	for _, shard := range m.shards {
+		h.removers = append(h.removers, shard.Session.AddHandler(handler))
+	}
+	m.handlers = append(m.handlers, h)
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		for i, registered := range m.handlers {
+			if registered == h {
+				m.handlers = append(m.handlers[:i], m.handlers[i+1:]...)
+				break
+			}
+		}
+		for _, remove := range h.removers {
+			remove()
+		}
+		h.removers = nil
+	}
+}
+
+// Dispatch delivers an event to the handlers added with AddHandler, as if
+// it had arrived on session's gateway connection. The session's state is
+// updated first, like discordgo does, and handlers run on the calling
+// goroutine. Before Open, messages and interactions also go to the command
+// handlers. It lets tests and replays feed events to the bot offline.
+func (b *Bot) Dispatch(session *discordgo.Session, event interface{}) {
+	if session.StateEnabled && session.State != nil {
+		session.State.OnInterface(session, event)
+	}
+
+	b.shards.mu.Lock()
+	handlers := make([]interface{}, len(b.shards.handlers))
+	for i, h := range b.shards.handlers {
+		handlers[i] = h.handler
+	}
+	b.shards.mu.Unlock()
+
+	eventType := reflect.TypeOf(event)
+	args := []reflect.Value{reflect.ValueOf(session), reflect.ValueOf(event)}
+	for _, handler := range handlers {
+		fn := reflect.ValueOf(handler)
+		if fn.Kind() != reflect.Func || fn.Type().NumIn() != 2 || !eventType.AssignableTo(fn.Type().In(1)) {
+			continue
+		}
+		fn.Call(args)
+	}
+
+	// Open registers the command handlers; until then, call them directly
+	if !b.opened.Load() {
+		switch e := event.(type) {
+		case *discordgo.MessageCreate:
+			b.messageHandler(session, e)
+		case *discordgo.InteractionCreate:
+			b.interactionHandler(session, e)
+		}
+	}
+}
+
+// Shards returns the status of every shard run by this process
+func (b *Bot) Shards() []ShardStatus {
+	b.shards.mu.Lock()
+	shards := append([]*Shard(nil), b.shards.shards...)
+	b.shards.mu.Unlock()
+
+	statuses := make([]ShardStatus, len(shards))
+	for i, shard := range shards {
+		statuses[i] = shard.status()
+	}
+	return statuses
+}
+
+// ShardCount returns the total number of shards across every process
+func (b *Bot) ShardCount() int {
+	b.shards.mu.Lock()
+	defer b.shards.mu.Unlock()
+
+	return b.shards.count
+}
+
+// ShardForGuild returns the session of the shard that handles a guild, or
+// nil when that shard is run by another process
+func (b *Bot) ShardForGuild(guildID string) *discordgo.Session {
+	id, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	b.shards.mu.Lock()
+	defer b.shards.mu.Unlock()
+
+	shardID := int((id >> 22) % uint64(b.shards.count))
+	for _, shard := range b.shards.shards {
+		if shard.ID == shardID {
+			return shard.Session
+		}
+	}
+	return nil
+}
+
+// Guilds returns the guilds cached by every shard
+func (b *Bot) Guilds() []*discordgo.Guild {
+	b.shards.mu.Lock()
+	shards := append([]*Shard(nil), b.shards.shards...)
+	b.shards.mu.Unlock()
+
+	var guilds []*discordgo.Guild
+	for _, shard := range shards {
+		state := shard.Session.State
+		if state == nil {
+			continue
+		}
+		state.RLock()
+		guilds = append(guilds, state.Guilds...)
+		state.RUnlock()
+	}
+	return guilds
+}
+
+// configureShards decides how many shards to run and creates a session for
+// each one. The first shard reuses b.Session. It returns Discord's identify
+// concurrency.
+func (b *Bot) configureShards() (int, error) {
+	count := b.Config().Sharding.Count
+	concurrency := 1
+
+	gateway, err := b.Session.GatewayBot()
+	switch {
+	case err == nil:
+		if count == 0 {
+			count = gateway.Shards
+		}
+		if gateway.SessionStartLimit.MaxConcurrency > 0 {
+			concurrency = gateway.SessionStartLimit.MaxConcurrency
+		}
+	case count == 0:
+		return 0, fmt.Errorf("error getting the recommended shard count: %w", err)
+	default:
+		b.Logger("gateway").Warn("could not get gateway information, identifying one shard at a time", "error", err)
+	}
+	if count < 1 {
+		count = 1
+	}
+
+	ids, err := b.Config().Sharding.ShardIDs(count)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, errors.New("no shards selected")
+	}
+
+	m := b.shards
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.count = count
+	shards := make([]*Shard, len(ids))
+	for i, id := range ids {
+		session := b.Session
+		if i > 0 {
+			session = b.newShardSession()
+			// Handlers registered so far also apply to the new shard
+			for _, h := range m.handlers {
+				h.removers = append(h.removers, session.AddHandler(h.handler))
+			}
+		}
+		session.ShardID = id
+		session.ShardCount = count
+
+		shard := &Shard{ID: id, Session: session, state: ShardIdle, since: time.Now()}
+		b.trackShard(shard)
+		shards[i] = shard
+	}
+	m.shards = shards
+
+	if count > 1 {
+		b.Logger("gateway").Info("running shards", "shards", ids, "count", count)
+	}
+	return concurrency, nil
+}
+
+// newShardSession creates a session configured like b.Session
+func (b *Bot) newShardSession() *discordgo.Session {
+	session, _ := discordgo.New(b.Session.Token)
+	session.Identify = b.Session.Identify
+	session.StateEnabled = b.Session.StateEnabled
+	session.ShouldReconnectOnError = b.Session.ShouldReconnectOnError
+	session.ShouldRetryOnRateLimit = b.Session.ShouldRetryOnRateLimit
+	session.MaxRestRetries = b.Session.MaxRestRetries
+	session.Client = b.Session.Client
+	session.UserAgent = b.Session.UserAgent
+	session.LogLevel = b.Session.LogLevel
+	return session
+}
+
+// openShards connects every shard, waiting between identifies that share a
+// rate limit bucket (shard ID modulo max concurrency). If any shard fails,
+// the ones already opened are closed again.
+func (b *Bot) openShards(concurrency int) error {
+	b.shards.mu.Lock()
+	shards := append([]*Shard(nil), b.shards.shards...)
+	b.shards.mu.Unlock()
+
+	lastIdentify := make(map[int]time.Time)
+	for i, shard := range shards {
+		bucket := shard.ID % concurrency
+		if last, ok := lastIdentify[bucket]; ok {
+			if wait := time.Until(last.Add(identifyInterval)); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		shard.setState(ShardConnecting)
+		lastIdentify[bucket] = time.Now()
+		if err := shard.Session.Open(); err != nil {
+			shard.setState(ShardDisconnected)
+			for _, opened := range shards[:i] {
+				opened.Session.Close()
+			}
+			if len(shards) > 1 {
+				return fmt.Errorf("shard %d: %w", shard.ID, b.explainOpenError(err))
+			}
+			return b.explainOpenError(err)
+		}
+	}
+	return nil
+}
+
+// closeShards disconnects every shard
+func (b *Bot) closeShards() error {
+	b.shards.mu.Lock()
+	shards := append([]*Shard(nil), b.shards.shards...)
+	b.shards.mu.Unlock()
+
+	var errs []error
+	for _, shard := range shards {
+		if err := shard.Session.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("shard %d: %w", shard.ID, err))
+		}
+		shard.setState(ShardDisconnected)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Shard) setState(state ShardState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state != state {
+		s.state = state
+		s.since = time.Now()
+	}
+}
+
+func (s *Shard) status() ShardStatus {
+	s.mu.Lock()
+	status := ShardStatus{
+		ID:       s.ID,
+		State:    s.state,
+		Since:    s.since,
+		Resumes:  s.resumes,
+		Sessions: s.identifies,
+	}
+	if s.connects > 1 {
+		status.Reconnects = s.connects - 1
+	}
+	if !s.lastDisconnect.IsZero() {
+		lastDisconnect := s.lastDisconnect
+		status.LastDisconnect = &lastDisconnect
+	}
+	s.mu.Unlock()
+
+	// Latency is only meaningful once a heartbeat has been acknowledged
+	if status.State == ShardReady || status.State == ShardConnected {
+		if latency := s.Session.HeartbeatLatency(); latency > 0 {
+			status.Latency = latency
+		}
+	}
+	if state := s.Session.State; state != nil {
+		state.RLock()
+		status.Guilds = len(state.Guilds)
+		state.RUnlock()
+	}
+	return status
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestShardingShardIDs(t *testing.T) {
+	tests := []struct {
+		ids     string
+		count   int
+		want    string
+		wantErr string
+	}{
+		{"", 3, "0,1,2", ""},
+		{"1", 2, "1", ""},
+		{"0-1,3", 4, "0,1,3", ""},
+		{"3, 0-1 1", 4, "0,1,3", ""},
+		{"x", 4, "", `invalid shard ID "x"`},
+		{"-1", 4, "", `invalid shard ID "-1"`},
+		{"3-1", 4, "", `invalid shard range "3-1"`},
+		{"2-4", 4, "", "shard 4 is out of range for 4 shards"},
+	}
+
+	for _, tt := range tests {
+		ids, err := ShardingConfig{IDs: tt.ids}.ShardIDs(tt.count)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ShardIDs(%q, %d) error = %v, want %q", tt.ids, tt.count, err, tt.wantErr)
+			}
+			continue
+		}
+
+		got := make([]string, len(ids))
+		for i, id := range ids {
+			got[i] = strconv.Itoa(id)
+		}
+		if err != nil || strings.Join(got, ",") != tt.want {
+			t.Errorf("ShardIDs(%q, %d) = %v, %v; want %s", tt.ids, tt.count, ids, err, tt.want)
+		}
+	}
+}
+
+// gatewayTransport answers GET /gateway/bot with a fixed body, or fails
+// when the body is empty
+type gatewayTransport struct {
+	body string
+}
+
+func (g gatewayTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	status := http.StatusOK
+	if g.body == "" || !strings.HasSuffix(r.URL.Path, "/gateway/bot") {
+		status = http.StatusServiceUnavailable
+	}
+	return &http.Response{
+		Status:     http.StatusText(status),
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(g.body)),
+		Request:    r,
+	}, nil
+}
+
+func newShardedBot(t *testing.T, sharding ShardingConfig, gateway string) *Bot {
+	t.Helper()
+
+	bot, err := NewBot(&Config{Token: "test", Prefix: "!", Sharding: sharding})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot.Session.Client = &http.Client{Transport: gatewayTransport{body: gateway}}
+	return bot
+}
+
+// guildOnShard returns a guild ID that Discord assigns to shard out of count
+func guildOnShard(shard, count int) string {
+	return strconv.FormatUint(uint64(shard+count*7)<<22|12345, 10)
+}
+
+func TestShardForGuild(t *testing.T) {
+	bot := newShardedBot(t, ShardingConfig{Count: 4, IDs: "0,2"}, "")
+
+	// Handlers added before the shards exist apply to every shard
+	bot.AddHandler(func(*discordgo.Session, *discordgo.MessageCreate) {})
+
+	if _, err := bot.configureShards(); err != nil {
+		t.Fatalf("configureShards: %v", err)
+	}
+	if count := bot.ShardCount(); count != 4 {
+		t.Errorf("ShardCount = %d, want 4", count)
+	}
+	if handlers := len(bot.shards.handlers[0].removers); handlers != 2 {
+		t.Errorf("handler was added to %d shards, want 2", handlers)
+	}
+
+	tests := []struct {
+		guildID string
+		shard   int // -1 when no local shard handles the guild
+	}{
+		{guildOnShard(0, 4), 0},
+		{guildOnShard(2, 4), 2},
+		{guildOnShard(1, 4), -1},
+		{guildOnShard(3, 4), -1},
+		{"not-a-snowflake", -1},
+	}
+	for _, tt := range tests {
+		session := bot.ShardForGuild(tt.guildID)
+		switch {
+		case tt.shard < 0 && session != nil:
+			t.Errorf("ShardForGuild(%s) = shard %d, want none", tt.guildID, session.ShardID)
+		case tt.shard >= 0 && (session == nil || session.ShardID != tt.shard || session.ShardCount != 4):
+			t.Errorf("ShardForGuild(%s) = %v, want shard %d of 4", tt.guildID, session, tt.shard)
+		}
+	}
+
+	// The first shard keeps the bot's own session
+	if bot.ShardForGuild(guildOnShard(0, 4)) != bot.Session {
+		t.Error("shard 0 does not use bot.Session")
+	}
+}
+
+func TestConfigureShardsUsesRecommendedCount(t *testing.T) {
+	gateway := `{"url": "wss://gateway.discord.gg", "shards": 3, "session_start_limit": {"max_concurrency": 2}}`
+	bot := newShardedBot(t, ShardingConfig{}, gateway)
+
+	concurrency, err := bot.configureShards()
+	if err != nil {
+		t.Fatalf("configureShards: %v", err)
+	}
+	if concurrency != 2 || bot.ShardCount() != 3 {
+		t.Errorf("concurrency %d, count %d; want 2 and 3", concurrency, bot.ShardCount())
+	}
+	for shard := 0; shard < 3; shard++ {
+		if session := bot.ShardForGuild(guildOnShard(shard, 3)); session == nil || session.ShardID != shard {
+			t.Errorf("no session for shard %d", shard)
+		}
+	}
+
+	// Without a configured count the recommendation is required
+	failing := newShardedBot(t, ShardingConfig{}, "")
+	if _, err := failing.configureShards(); err == nil {
+		t.Error("configureShards succeeded without a shard count")
+	}
+}
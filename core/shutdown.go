@@ -0,0 +1,245 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// cancelGrace is how long Shutdown waits for commands to return after their
+// contexts were cancelled
+const cancelGrace = time.Second
+
+// ShutdownHook is cleanup work run during shutdown after modules have
+// stopped and before the gateway closes, such as stopping a web server
+type ShutdownHook func(ctx context.Context) error
+
+// ShutdownReport describes what happened during a graceful shutdown
+type ShutdownReport struct {
+	Started   time.Time        `json:"started"`
+	Duration  time.Duration    `json:"duration"`
+	Drained   int              `json:"drained"`   // commands that finished while draining
+	Cancelled int              `json:"cancelled"` // commands still running at the deadline
+	Steps     []ShutdownResult `json:"steps"`
+}
+
+// ShutdownResult is the outcome of stopping one module, hook or connection
+type ShutdownResult struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	TimedOut bool          `json:"timed_out"`
+	Err      error         `json:"-"`
+}
+
+// Errors returns every error recorded during shutdown
+func (r *ShutdownReport) Errors() error {
+	var errs []error
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", step.Name, step.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// log writes the report, one line per step that did not succeed
+func (r *ShutdownReport) log(logger *slog.Logger) {
+	failed := 0
+	for _, step := range r.Steps {
+		switch {
+		case step.TimedOut:
+			failed++
+			logger.Warn("shutdown step did not stop within its timeout", "step", step.Name)
+		case step.Err != nil:
+			failed++
+			logger.Error("shutdown step failed", "step", step.Name, "error", step.Err)
+		}
+	}
+
+	logger.Info("shutdown finished",
+		"duration", r.Duration.Round(time.Millisecond),
+		"drained", r.Drained,
+		"cancelled", r.Cancelled,
+		"failed_steps", failed,
+		"steps", len(r.Steps))
+}
+
+// commandTracker counts running commands so shutdown can wait for them
+type commandTracker struct {
+	mu       sync.Mutex
+	draining bool
+	running  int
+	idle     chan struct{} // closed when running drops to zero while draining
+	ctx      context.Context
+	cancel   context.CancelFunc
+	log      *slog.Logger
+}
+
+func newCommandTracker(logger *slog.Logger) *commandTracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &commandTracker{ctx: ctx, cancel: cancel, log: logger}
+}
+
+// begin registers a command execution. It reports false once the bot is
+// draining; otherwise the returned context is cancelled if the command is
+// still running when the drain deadline passes, and done must be called
+// when the command finishes.
+func (t *commandTracker) begin() (ctx context.Context, done func(), ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.draining {
+		return nil, nil, false
+	}
+	t.running++
+
+	ctx, cancel := context.WithCancel(t.ctx)
+	return ctx, func() {
+		cancel()
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.running--
+		if t.running == 0 && t.idle != nil {
+			close(t.idle)
+			t.idle = nil
+		}
+	}, true
+}
+
+// drain stops new commands and waits up to timeout for running ones. It
+// returns how many finished in time and how many had to be cancelled.
+func (t *commandTracker) drain(timeout time.Duration) (drained, cancelled int) {
+	t.mu.Lock()
+	t.draining = true
+	running := t.running
+	if running == 0 {
+		t.mu.Unlock()
+		return 0, 0
+	}
+	idle := make(chan struct{})
+	t.idle = idle
+	t.mu.Unlock()
+
+	t.log.Info("waiting for running commands", "running", running, "timeout", timeout)
+	select {
+	case <-idle:
+		return running, 0
+	case <-time.After(timeout):
+	}
+
+	t.mu.Lock()
+	cancelled = t.running
+	t.mu.Unlock()
+
+	// Cancel what is left and give it a moment to notice
+	t.cancel()
+	select {
+	case <-idle:
+	case <-time.After(cancelGrace):
+	}
+	return running - cancelled, cancelled
+}
+
+// count returns the number of commands currently executing
+func (t *commandTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.running
+}
+
+// RunningCommands returns how many commands are executing right now
+func (b *Bot) RunningCommands() int {
+	return b.commands.count()
+}
+
+// AddShutdownHook registers cleanup work to run during shutdown, after
+// modules have stopped and before the gateway connection closes. Hooks run
+// in reverse registration order and get the module timeout as a deadline.
+func (b *Bot) AddShutdownHook(name string, hook ShutdownHook) {
+	b.hooksMu.Lock()
+	defer b.hooksMu.Unlock()
+
+	b.shutdownHooks = append(b.shutdownHooks, namedShutdownHook{name: name, hook: hook})
+}
+
+// namedShutdownHook is a registered shutdown hook
+type namedShutdownHook struct {
+	name string
+	hook ShutdownHook
+}
+
+// ErrShutdownTimeout is returned when a module or hook does not stop in time
+var ErrShutdownTimeout = errors.New("shutdown timed out")
+
+// withTimeout runs fn, giving up after timeout. fn keeps running in the
+// background if it does not return in time.
+func withTimeout(timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return safeCall(func() error { return fn(context.Background()) })
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- safeCall(func() error { return fn(ctx) })
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w after %v", ErrShutdownTimeout, timeout)
+	}
+}
+
+// step runs one shutdown step and records its outcome
+func (r *ShutdownReport) step(name string, fn func() error) {
+	started := time.Now()
+	err := fn()
+	r.Steps = append(r.Steps, ShutdownResult{
+		Name:     name,
+		Duration: time.Since(started),
+		TimedOut: errors.Is(err, ErrShutdownTimeout),
+		Err:      err,
+	})
+}
+
+// shutdownModules stops running modules in reverse initialization order so
+// dependents stop before the modules they rely on. Each module's Shutdown
+// gets the configured module timeout.
+func (b *Bot) shutdownModules(report *ShutdownReport) {
+	order := b.modules.order
+	if order == nil {
+		for _, module := range b.Modules {
+			order = append(order, module.Name())
+		}
+	}
+
+	for i := len(order) - 1; i >= 0; i-- {
+		name := order[i]
+		if status, _ := b.ModuleStatus(name); status.State != ModuleRunning {
+			continue
+		}
+		report.step("module "+name, func() error { return b.StopModule(name) })
+	}
+}
+
+// runShutdownHooks runs registered hooks in reverse order
+func (b *Bot) runShutdownHooks(report *ShutdownReport) {
+	b.hooksMu.Lock()
+	hooks := append([]namedShutdownHook(nil), b.shutdownHooks...)
+	b.hooksMu.Unlock()
+
+	timeout := b.Config().Shutdown.ModuleTimeout.Duration()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		report.step(hook.name, func() error { return withTimeout(timeout, hook.hook) })
+	}
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestTracker() *commandTracker {
+	return newCommandTracker(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestCommandTrackerDrain(t *testing.T) {
+	tests := []struct {
+		name          string
+		finish        []time.Duration // when each command returns; 0 waits for cancellation
+		wantDrained   int
+		wantCancelled int
+	}{
+		{name: "nothing running"},
+		{name: "all finish in time", finish: []time.Duration{5 * time.Millisecond, 10 * time.Millisecond}, wantDrained: 2},
+		{name: "stragglers are cancelled", finish: []time.Duration{5 * time.Millisecond, 0, 0}, wantDrained: 1, wantCancelled: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newTestTracker()
+
+			var finished sync.WaitGroup
+			for _, after := range tt.finish {
+				ctx, done, ok := tracker.begin()
+				if !ok {
+					t.Fatal("begin refused a command before draining")
+				}
+
+				finished.Add(1)
+				go func(after time.Duration) {
+					defer done()
+					if after > 0 {
+						time.Sleep(after)
+						finished.Done()
+						return
+					}
+					<-ctx.Done()
+					finished.Done()
+				}(after)
+			}
+
+			drained, cancelled := tracker.drain(100 * time.Millisecond)
+			if drained != tt.wantDrained || cancelled != tt.wantCancelled {
+				t.Errorf("drain = %d drained, %d cancelled; want %d, %d", drained, cancelled, tt.wantDrained, tt.wantCancelled)
+			}
+			finished.Wait()
+
+			if _, _, ok := tracker.begin(); ok {
+				t.Error("begin accepted a command after draining started")
+			}
+			if running := tracker.count(); running != 0 {
+				t.Errorf("%d commands still counted as running", running)
+			}
+		})
+	}
+}
+
+func TestShutdownStopsModulesAndHooksInReverseOrder(t *testing.T) {
+	var mu sync.Mutex
+	var steps []string
+	record := func(step string) {
+		mu.Lock()
+		steps = append(steps, step)
+		mu.Unlock()
+	}
+
+	base := &lifecycleModule{name: "base", shutdown: func() error { record("module base"); return nil }}
+	dependent := &lifecycleModule{name: "dependent", deps: []string{"base"}, shutdown: func() error {
+		record("module dependent")
+		return nil
+	}}
+	bot := newLifecycleBot(t, dependent, base)
+	if err := bot.StartModules(); err != nil {
+		t.Fatal(err)
+	}
+
+	bot.AddShutdownHook("web", func(context.Context) error { record("hook web"); return nil })
+	bot.AddShutdownHook("metrics", func(context.Context) error { record("hook metrics"); return errors.New("flush failed") })
+	bot.AddShutdownHook("stuck", func(ctx context.Context) error {
+		record("hook stuck")
+		<-ctx.Done()
+		return nil
+	})
+
+	err := bot.Shutdown()
+	if err == nil || !strings.Contains(err.Error(), "metrics: flush failed") || !errors.Is(err, ErrShutdownTimeout) {
+		t.Errorf("Shutdown = %v, want the failed and the timed out hook", err)
+	}
+	if again := bot.Shutdown(); again != err {
+		t.Errorf("second Shutdown = %v, want the first result", again)
+	}
+
+	mu.Lock()
+	got := strings.Join(steps, ",")
+	mu.Unlock()
+	want := "module dependent,module base,hook stuck,hook metrics,hook web"
+	if got != want {
+		t.Errorf("shutdown order = %s, want %s", got, want)
+	}
+
+	for _, name := range []string{"base", "dependent"} {
+		if state := moduleState(t, bot, name).State; state != ModuleStopped {
+			t.Errorf("%s is %s after shutdown, want stopped", name, state)
+		}
+	}
+	if _, _, ok := bot.commands.begin(); ok {
+		t.Error("commands are still accepted after shutdown")
+	}
+}
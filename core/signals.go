@@ -0,0 +1,31 @@
+package core
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// SignalContext returns a copy of parent that is cancelled on SIGINT or
+// SIGTERM, for passing to Run. Until then SIGHUP reloads the configuration.
+// Calling stop releases the signal handlers.
+func (b *Bot) SignalContext(parent context.Context) (ctx context.Context, stop context.CancelFunc) {
+	ctx, stop = signal.NotifyContext(parent, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-hup:
+				b.reloadAndLog("SIGHUP")
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ctx, stop
+}
@@ -0,0 +1,284 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// SlashCommand is implemented by commands that are also registered with
+// Discord as application commands. Interactions invoking the command are
+// routed to ExecuteInteraction; middleware only applies to prefix
+// invocations.
+type SlashCommand interface {
+	ApplicationCommand() *discordgo.ApplicationCommand
+	ExecuteInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) error
+}
+
+// ApplicationCommands returns the application commands of every registered
+// SlashCommand, sorted by name. The command's name and description are used
+// when the definition leaves them empty.
+func (b *Bot) ApplicationCommands() []*discordgo.ApplicationCommand {
+	b.commandsMu.RLock()
+	defer b.commandsMu.RUnlock()
+
+	commands := make([]*discordgo.ApplicationCommand, 0)
+	for _, cmd := range b.Commands {
+		if slash, ok := cmd.(SlashCommand); ok {
+			commands = append(commands, applicationCommand(cmd, slash))
+		}
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// applicationCommand fills in a slash command's definition from the command
+func applicationCommand(cmd Command, slash SlashCommand) *discordgo.ApplicationCommand {
+	definition := &discordgo.ApplicationCommand{}
+	if def := slash.ApplicationCommand(); def != nil {
+		copied := *def
+		definition = &copied
+	}
+	if definition.Name == "" {
+		definition.Name = cmd.Name()
+	}
+	chat := definition.Type == 0 || definition.Type == discordgo.ChatApplicationCommand
+	if chat && definition.Description == "" {
+		definition.Description = cmd.Description()
+	}
+	return definition
+}
+
+// slashCommand finds the registered slash command with an application
+// command name
+func (b *Bot) slashCommand(name string) (Command, SlashCommand, bool) {
+	b.commandsMu.RLock()
+	defer b.commandsMu.RUnlock()
+
+	for _, cmd := range b.Commands {
+		if slash, ok := cmd.(SlashCommand); ok && applicationCommand(cmd, slash).Name == name {
+			return cmd, slash, true
+		}
+	}
+	return nil, nil, false
+}
+
+// interactionHandler runs the slash command an interaction invokes
+func (b *Bot) interactionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	userID := ""
+	if i.Member != nil && i.Member.User != nil {
+		userID = i.Member.User.ID
+	} else if i.User != nil {
+		userID = i.User.ID
+	}
+
+	name := i.ApplicationCommandData().Name
+	logger := b.Logger("commands").With(
+		LogKeyChannel, i.ChannelID,
+		LogKeyGuild, i.GuildID,
+		LogKeyUser, userID,
+		LogKeyCommand, name,
+		LogKeyCorrelationID, newCorrelationID(i.ID),
+	)
+
+	cmd, slash, exists := b.slashCommand(name)
+	if !exists {
+		logger.Debug("ignoring unknown slash command")
+		return
+	}
+	if b.GuildSettings(i.GuildID).CommandDisabled(cmd.Name()) {
+		logger.Debug("refusing slash command disabled in this guild")
+		b.respondEphemeral(s, i, "🚫 This command is disabled in this server.")
+		return
+	}
+
+	// Refuse new commands once shutdown has started
+	_, done, ok := b.commands.begin()
+	if !ok {
+		logger.Debug("ignoring slash command during shutdown")
+		return
+	}
+	defer done()
+
+	logger.Debug("executing slash command")
+
+	started := time.Now()
+	err := safeCall(func() error {
+		return slash.ExecuteInteraction(s, i)
+	})
+	elapsed := time.Since(started)
+
+	if err != nil {
+		logger.Error("error executing slash command", "duration", elapsed, "error", err)
+		b.respondEphemeral(s, i, "❌ An error occurred while executing the command.")
+		b.Events.Publish(CommandFailedEvent{
+			Command:   cmd.Name(),
+			GuildID:   i.GuildID,
+			ChannelID: i.ChannelID,
+			UserID:    userID,
+			Duration:  elapsed,
+			Err:       err,
+		})
+		return
+	}
+
+	logger.Debug("slash command finished", "duration", elapsed)
+	b.Events.Publish(CommandExecutedEvent{
+		Command:   cmd.Name(),
+		GuildID:   i.GuildID,
+		ChannelID: i.ChannelID,
+		UserID:    userID,
+		Duration:  elapsed,
+	})
+}
+
+// respondEphemeral answers an interaction with a message only its user sees
+func (b *Bot) respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		b.Logger("commands").Error("error responding to interaction", "interaction", i.ID, "error", err)
+	}
+}
+
+// ApplicationCommandChange is one difference between the application
+// commands registered with Discord and the ones a bot defines
+type ApplicationCommandChange struct {
+	Action string   `json:"action"` // "create", "update" or "delete"
+	Name   string   `json:"name"`
+	Fields []string `json:"fields,omitempty"` // what an update changes
+}
+
+// DiffApplicationCommands compares the application commands registered with
+// Discord against the desired ones. IDs, versions and other values Discord
+// assigns are ignored, as are unset fields Discord fills with defaults.
+func DiffApplicationCommands(registered, desired []*discordgo.ApplicationCommand) []ApplicationCommandChange {
+	key := func(cmd *discordgo.ApplicationCommand) string {
+		kind := cmd.Type
+		if kind == 0 {
+			kind = discordgo.ChatApplicationCommand
+		}
+		return fmt.Sprintf("%d/%s", kind, cmd.Name)
+	}
+
+	existing := make(map[string]*discordgo.ApplicationCommand, len(registered))
+	for _, cmd := range registered {
+		existing[key(cmd)] = cmd
+	}
+
+	changes := make([]ApplicationCommandChange, 0)
+	wanted := make(map[string]bool, len(desired))
+	for _, cmd := range desired {
+		wanted[key(cmd)] = true
+		current, ok := existing[key(cmd)]
+		if !ok {
+			changes = append(changes, ApplicationCommandChange{Action: "create", Name: cmd.Name})
+			continue
+		}
+		if fields := applicationCommandFieldChanges(current, cmd); len(fields) > 0 {
+			changes = append(changes, ApplicationCommandChange{Action: "update", Name: cmd.Name, Fields: fields})
+		}
+	}
+	for _, cmd := range registered {
+		if !wanted[key(cmd)] {
+			changes = append(changes, ApplicationCommandChange{Action: "delete", Name: cmd.Name})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// applicationCommandFieldChanges lists the fields that differ between two
+// versions of an application command
+func applicationCommandFieldChanges(current, desired *discordgo.ApplicationCommand) []string {
+	fields := func(cmd *discordgo.ApplicationCommand) map[string]interface{} {
+		dmPermission := cmd.DMPermission == nil || *cmd.DMPermission
+		return map[string]interface{}{
+			"description":                cmd.Description,
+			"description_localizations":  cmd.DescriptionLocalizations,
+			"name_localizations":         cmd.NameLocalizations,
+			"options":                    cmd.Options,
+			"default_member_permissions": cmd.DefaultMemberPermissions,
+			"dm_permission":              dmPermission,
+			"nsfw":                       cmd.NSFW != nil && *cmd.NSFW,
+		}
+	}
+
+	before, after := fields(current), fields(desired)
+	var changed []string
+	for name := range after {
+		if !reflect.DeepEqual(canonicalJSON(before[name]), canonicalJSON(after[name])) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// canonicalJSON decodes a value's JSON form with empty values removed, so
+// an omitted field and its zero value compare equal
+func canonicalJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil
+	}
+	return pruneEmpty(decoded)
+}
+
+// pruneEmpty drops nulls, false, zero, empty strings and empty collections
+func pruneEmpty(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if pruned := pruneEmpty(child); pruned == nil {
+				delete(value, key)
+			} else {
+				value[key] = pruned
+			}
+		}
+		if len(value) == 0 {
+			return nil
+		}
+		return value
+	case []interface{}:
+		pruned := make([]interface{}, 0, len(value))
+		for _, child := range value {
+			pruned = append(pruned, pruneEmpty(child))
+		}
+		if len(pruned) == 0 {
+			return nil
+		}
+		return pruned
+	case bool:
+		if !value {
+			return nil
+		}
+	case float64:
+		if value == 0 {
+			return nil
+		}
+	case string:
+		if value == "" {
+			return nil
+		}
+	}
+	return v
+}
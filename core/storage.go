@@ -0,0 +1,198 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ErrNotFound is returned by Storage.Load when a key does not exist
+var ErrNotFound = errors.New("storage: key not found")
+
+// Storage is a namespaced key/value store for persistent bot data.
+// Values are encoded as JSON so any serializable type can be stored.
+type Storage interface {
+	Load(namespace, key string, v interface{}) error
+	Save(namespace, key string, v interface{}) error
+	Delete(namespace, key string) error
+	Keys(namespace string) ([]string, error)
+}
+
+// jsonStorage keeps every namespace as a map of raw JSON values, optionally
+// mirrored to one file per namespace inside dir
+type jsonStorage struct {
+	mu         sync.Mutex
+	dir        string
+	namespaces map[string]map[string]json.RawMessage
+}
+
+// NewMemoryStorage creates a Storage that only lives for the process lifetime
+func NewMemoryStorage() Storage {
+	return &jsonStorage{
+		namespaces: make(map[string]map[string]json.RawMessage),
+	}
+}
+
+// NewFileStorage creates a Storage persisted as JSON files under dir
+func NewFileStorage(dir string) (Storage, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("error creating storage directory: %w", err)
+	}
+
+	return &jsonStorage{
+		dir:        dir,
+		namespaces: make(map[string]map[string]json.RawMessage),
+	}, nil
+}
+
+// Load decodes the value stored under namespace/key into v
+func (s *jsonStorage) Load(namespace, key string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, err := s.namespace(namespace)
+	if err != nil {
+		return err
+	}
+
+	raw, exists := ns[key]
+	if !exists {
+		return ErrNotFound
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+// Save encodes v and stores it under namespace/key
+func (s *jsonStorage) Save(namespace, key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error encoding %s/%s: %w", namespace, key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, err := s.namespace(namespace)
+	if err != nil {
+		return err
+	}
+	previous, existed := ns[key]
+	ns[key] = raw
+
+	if err := s.flush(namespace, ns); err != nil {
+		restoreRaw(ns, key, previous, existed)
+		return err
+	}
+	return nil
+}
+
+// restoreRaw undoes an in-memory change after writing it to disk failed, so
+// memory keeps matching the file
+func restoreRaw(ns map[string]json.RawMessage, key string, previous json.RawMessage, existed bool) {
+	if existed {
+		ns[key] = previous
+	} else {
+		delete(ns, key)
+	}
+}
+
+// Delete removes namespace/key; deleting a missing key is not an error
+func (s *jsonStorage) Delete(namespace, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, err := s.namespace(namespace)
+	if err != nil {
+		return err
+	}
+	previous, exists := ns[key]
+	if !exists {
+		return nil
+	}
+	delete(ns, key)
+
+	if err := s.flush(namespace, ns); err != nil {
+		restoreRaw(ns, key, previous, true)
+		return err
+	}
+	return nil
+}
+
+// Keys returns the sorted keys stored in a namespace
+func (s *jsonStorage) Keys(namespace string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, err := s.namespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(ns))
+	for key := range ns {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// namespace returns the in-memory map for a namespace, loading it from disk
+// on first access. Callers must hold s.mu.
+func (s *jsonStorage) namespace(name string) (map[string]json.RawMessage, error) {
+	if ns, exists := s.namespaces[name]; exists {
+		return ns, nil
+	}
+
+	ns := make(map[string]json.RawMessage)
+	if s.dir != "" {
+		data, err := os.ReadFile(s.path(name))
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(data, &ns); err != nil {
+				return nil, fmt.Errorf("error decoding storage namespace %s: %w", name, err)
+			}
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, fmt.Errorf("error reading storage namespace %s: %w", name, err)
+		}
+	}
+
+	s.namespaces[name] = ns
+	return ns, nil
+}
+
+// flush writes a namespace to disk atomically. Callers must hold s.mu.
+func (s *jsonStorage) flush(name string, ns map[string]json.RawMessage) error {
+	if s.dir == "" {
+		return nil
+	}
+	if len(ns) == 0 {
+		// Namespaces that are created and emptied again, such as one per
+		// day, do not leave files behind
+		if err := os.Remove(s.path(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing storage namespace %s: %w", name, err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(ns, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding storage namespace %s: %w", name, err)
+	}
+
+	tmp := s.path(name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("error writing storage namespace %s: %w", name, err)
+	}
+
+	return os.Rename(tmp, s.path(name))
+}
+
+func (s *jsonStorage) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
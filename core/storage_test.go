@@ -0,0 +1,79 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newBrokenFileStorage returns file storage holding a and b whose directory
+// has been removed, so every write fails
+func newBrokenFileStorage(t *testing.T) Storage {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "data")
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range []string{"a", "b"} {
+		if err := storage.Save("test", key, i+1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+func TestFileStorageSaveKeepsMemoryOnWriteFailure(t *testing.T) {
+	storage := newBrokenFileStorage(t)
+
+	if err := storage.Save("test", "a", 3); err == nil {
+		t.Fatal("Save succeeded without a storage directory")
+	}
+	if err := storage.Save("test", "c", 4); err == nil {
+		t.Fatal("Save succeeded without a storage directory")
+	}
+
+	var value int
+	if err := storage.Load("test", "a", &value); err != nil || value != 1 {
+		t.Errorf("a = %d, %v after failed write; want 1", value, err)
+	}
+	if err := storage.Load("test", "c", &value); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load c error = %v after failed write, want %v", err, ErrNotFound)
+	}
+}
+
+func TestFileStorageDeleteKeepsMemoryOnWriteFailure(t *testing.T) {
+	storage := newBrokenFileStorage(t)
+
+	if err := storage.Delete("test", "a"); err == nil {
+		t.Fatal("Delete succeeded without a storage directory")
+	}
+
+	var value int
+	if err := storage.Load("test", "a", &value); err != nil || value != 1 {
+		t.Errorf("a = %d, %v after failed delete; want 1", value, err)
+	}
+}
+
+func TestFileStorageRemovesEmptyNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.Save("test", "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Delete("test", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("empty namespace file still exists: %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"context"
+	"errors"
+)
+
+// ToolRunner takes over Run for a developer tool, such as describing the
+// bot for forge list or replaying a recording into it. forge installs one from a file it adds to the
+// bot's main package at build time, so production builds never contain it.
+type ToolRunner func(ctx context.Context, b *Bot) error
+
+var toolRunner ToolRunner
+
+// ErrToolRunning is returned by Open while a ToolRunner is installed. Tools
+// drive the bot through Run.
+var ErrToolRunning = errors.New("a tool has taken over the bot; start it with Run")
+
+// SetToolRunner makes Run call r instead of connecting, and Open fail with
+// ErrToolRunning so a bot that skips Run never connects. It is meant to be
+// called from an init function.
+func SetToolRunner(r ToolRunner) {
+	toolRunner = r
+}
+
+// ToolRunning reports whether a tool has taken over Run. Setup code uses it
+// to skip side effects such as starting servers.
+func ToolRunning() bool {
+	return toolRunner != nil
+}
+
+// runTool runs the installed tool and shuts the bot down afterwards
+func (b *Bot) runTool(ctx context.Context) error {
+	err := toolRunner(ctx, b)
+	return errors.Join(err, b.Close())
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestToolRunnerTakesOverRun(t *testing.T) {
+	bot, err := NewBot(&Config{Token: "test", Prefix: "?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bot.Close() })
+
+	path := filepath.Join(t.TempDir(), "definition.json")
+	SetToolRunner(func(ctx context.Context, b *Bot) error {
+		return b.Inspect(path)
+	})
+	t.Cleanup(func() { SetToolRunner(nil) })
+
+	if err := bot.Open(); !errors.Is(err, ErrToolRunning) {
+		t.Fatalf("Open error = %v, want %v", err, ErrToolRunning)
+	}
+	if err := bot.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var definition Definition
+	if err := json.Unmarshal(data, &definition); err != nil {
+		t.Fatal(err)
+	}
+	if definition.Prefix != "?" {
+		t.Errorf("definition prefix = %q, want ?", definition.Prefix)
+	}
+}
@@ -0,0 +1,547 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Limits of the host API exposed to WASM commands
+const (
+	wasmHostModule   = "forge"
+	wasmMaxReply     = 2000 // Discord message limit
+	wasmMaxKeyLength = 128
+	wasmMaxValueSize = 64 * 1024
+	wasmMaxKeys      = 1000
+)
+
+// wasmNamePattern restricts plugin file names, which become command names
+var wasmNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ErrWasmNotLoaded is returned when unloading a WASM command that is not loaded
+var ErrWasmNotLoaded = errors.New("wasm command not loaded")
+
+// ErrWasmHostNotInitialized is returned when commands are compiled or
+// loaded before the host module has been initialized
+var ErrWasmHostNotInitialized = errors.New("wasm host is not initialized")
+
+// WasmConfig configures sandboxed WebAssembly commands
+type WasmConfig struct {
+	Dir           string   `json:"dir"`             // directory of <name>.wasm files; disabled when empty
+	MemoryLimitMB int      `json:"memory_limit_mb"` // memory limit per invocation
+	Timeout       Duration `json:"timeout"`         // execution time limit per invocation
+}
+
+// WasmCommandMeta describes a WASM command. It is read from an optional
+// <name>.json file next to <name>.wasm.
+type WasmCommandMeta struct {
+	Description string   `json:"description"`
+	Usage       string   `json:"usage"`
+	Category    string   `json:"category"`
+	Permissions []string `json:"permissions"`
+	Cooldown    int      `json:"cooldown"`
+}
+
+// WasmHost is a module that runs untrusted commands compiled to WebAssembly.
+// Each invocation gets a fresh instance limited in memory and execution time,
+// with no filesystem or network access. Guests talk to the bot only through
+// the "forge" host module:
+//
+//	input_size() -> i32               length of the JSON invocation input
+//	input_read(ptr)                   copy the input to guest memory
+//	reply(ptr, len)                   append text to the reply
+//	fail(ptr, len)                    set an error message for the user
+//	log(ptr, len)                     write a line to the bot log
+//	kv_get(kptr, klen, vptr, vcap) -> i32  value length, or -1 if missing;
+//	                                  the value is copied only if it fits
+//	kv_set(kptr, klen, vptr, vlen) -> i32  0 on success, -1 on error
+//	kv_delete(kptr, klen) -> i32      0 on success, -1 on error
+//
+// Guests export "execute() -> i32" returning 0 on success, and may export
+// "_initialize", which is called first (as in Go -buildmode=c-shared).
+type WasmHost struct {
+	config  WasmConfig
+	bot     *Bot
+	log     *slog.Logger
+	runtime wazero.Runtime
+
+	mu       sync.Mutex
+	commands map[string]*WasmCommand
+}
+
+// WasmCommand is a Command implemented by a WASM module
+type WasmCommand struct {
+	host     *WasmHost
+	name     string
+	meta     WasmCommandMeta
+	compiled wazero.CompiledModule
+}
+
+// wasmInput is the JSON document a guest reads with input_read
+type wasmInput struct {
+	Command    string   `json:"command"`
+	Args       []string `json:"args"`
+	GuildID    string   `json:"guild_id"`
+	ChannelID  string   `json:"channel_id"`
+	AuthorID   string   `json:"author_id"`
+	AuthorName string   `json:"author_name"`
+}
+
+// wasmCall is the state of one invocation, passed to host functions through
+// the context
+type wasmCall struct {
+	command *WasmCommand
+	input   []byte
+	reply   strings.Builder
+	failure string
+}
+
+type wasmCallKey struct{}
+
+// NewWasmHost creates the module that loads WASM commands from config.Dir
+func NewWasmHost(config WasmConfig) *WasmHost {
+	return &WasmHost{
+		config:   config,
+		commands: make(map[string]*WasmCommand),
+	}
+}
+
+func (h *WasmHost) Name() string {
+	return "WASM Commands"
+}
+
+func (h *WasmHost) Version() string {
+	return "1.0.0"
+}
+
+func (h *WasmHost) Initialize(bot *Bot) error {
+	h.bot = bot
+	h.log = bot.Logger("wasm")
+	ctx := context.Background()
+
+	runtimeConfig := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(h.config.MemoryLimitMB) * 16). // 64 KiB pages
+		WithCloseOnContextDone(true)
+	h.runtime = wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
+
+	// WASI is instantiated without preopened directories or environment, so
+	// guests built for wasip1 run but cannot reach the host system
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, h.runtime); err != nil {
+		h.runtime.Close(ctx)
+		return fmt.Errorf("error instantiating WASI: %w", err)
+	}
+	if err := h.instantiateHostModule(ctx); err != nil {
+		h.runtime.Close(ctx)
+		return err
+	}
+
+	if err := h.LoadAll(); err != nil {
+		h.log.Error("error loading WASM commands", "error", err)
+	}
+	return nil
+}
+
+func (h *WasmHost) Shutdown() error {
+	if h.runtime == nil {
+		return nil
+	}
+	for _, name := range h.Loaded() {
+		h.Unload(name)
+	}
+	return h.runtime.Close(context.Background())
+}
+
+// LoadAll loads every .wasm file in the plugins directory
+func (h *WasmHost) LoadAll() error {
+	paths, err := filepath.Glob(filepath.Join(h.config.Dir, "*.wasm"))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".wasm")
+		if err := h.Load(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reload loads every .wasm file in the plugins directory again and unloads
+// the commands whose file was removed
+func (h *WasmHost) Reload() error {
+	errs := []error{h.LoadAll()}
+	for _, name := range h.Loaded() {
+		_, err := os.Stat(filepath.Join(h.config.Dir, name+".wasm"))
+		if !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := h.Unload(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Load compiles <name>.wasm from the plugins directory and registers it as a
+// command, replacing a previously loaded version
+func (h *WasmHost) Load(name string) error {
+	if h.bot == nil {
+		return ErrWasmHostNotInitialized
+	}
+	if !wasmNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid wasm command name %q", name)
+	}
+
+	wasm, err := os.ReadFile(filepath.Join(h.config.Dir, name+".wasm"))
+	if err != nil {
+		return fmt.Errorf("error reading wasm command %s: %w", name, err)
+	}
+
+	var meta WasmCommandMeta
+	if data, err := os.ReadFile(filepath.Join(h.config.Dir, name+".json")); err == nil {
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return fmt.Errorf("error parsing metadata for wasm command %s: %w", name, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading metadata for wasm command %s: %w", name, err)
+	}
+
+	cmd, err := h.Compile(name, wasm, meta)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	previous := h.commands[name]
+	h.mu.Unlock()
+
+	if existing, exists := h.bot.Command(name); exists && existing != Command(previous) {
+		cmd.compiled.Close(context.Background())
+		return fmt.Errorf("command %s is already registered", name)
+	}
+
+	h.mu.Lock()
+	h.commands[name] = cmd
+	h.mu.Unlock()
+
+	h.bot.RegisterCommand(cmd)
+	if previous != nil {
+		previous.compiled.Close(context.Background())
+	}
+
+	h.log.Info("loaded WASM command", LogKeyCommand, name)
+	return nil
+}
+
+// Unload unregisters a WASM command and releases its compiled code
+func (h *WasmHost) Unload(name string) error {
+	h.mu.Lock()
+	cmd, exists := h.commands[name]
+	delete(h.commands, name)
+	h.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrWasmNotLoaded, name)
+	}
+
+	h.bot.UnregisterCommand(name)
+	cmd.compiled.Close(context.Background())
+	h.log.Info("unloaded WASM command", LogKeyCommand, name)
+	return nil
+}
+
+// Loaded returns the names of the loaded WASM commands
+func (h *WasmHost) Loaded() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	names := make([]string, 0, len(h.commands))
+	for name := range h.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Compile validates a WASM module and wraps it as a Command. The command is
+// not registered; Load does that for files in the plugins directory. The
+// host must have been initialized.
+func (h *WasmHost) Compile(name string, wasm []byte, meta WasmCommandMeta) (*WasmCommand, error) {
+	if h.runtime == nil {
+		return nil, ErrWasmHostNotInitialized
+	}
+	compiled, err := h.runtime.CompileModule(context.Background(), wasm)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling wasm command %s: %w", name, err)
+	}
+
+	if _, exports := compiled.ExportedFunctions()["execute"]; !exports {
+		compiled.Close(context.Background())
+		return nil, fmt.Errorf("wasm command %s does not export execute", name)
+	}
+	for _, imported := range compiled.ImportedFunctions() {
+		module, _, _ := imported.Import()
+		if module != wasmHostModule && module != wasi_snapshot_preview1.ModuleName {
+			compiled.Close(context.Background())
+			return nil, fmt.Errorf("wasm command %s imports unknown module %s", name, module)
+		}
+	}
+
+	return &WasmCommand{host: h, name: name, meta: meta, compiled: compiled}, nil
+}
+
+func (c *WasmCommand) Name() string          { return c.name }
+func (c *WasmCommand) Usage() string         { return c.meta.Usage }
+func (c *WasmCommand) Permissions() []string { return c.meta.Permissions }
+func (c *WasmCommand) Cooldown() int         { return c.meta.Cooldown }
+
+func (c *WasmCommand) Description() string {
+	if c.meta.Description == "" {
+		return "WASM command"
+	}
+	return c.meta.Description
+}
+
+func (c *WasmCommand) Category() string {
+	if c.meta.Category == "" {
+		return "Plugins"
+	}
+	return c.meta.Category
+}
+
+func (c *WasmCommand) Execute(s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	return c.ExecuteContext(context.Background(), s, m, args)
+}
+
+// ExecuteContext runs the command, aborting the guest when ctx is cancelled
+func (c *WasmCommand) ExecuteContext(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	input, err := json.Marshal(wasmInput{
+		Command:    c.name,
+		Args:       args,
+		GuildID:    m.GuildID,
+		ChannelID:  m.ChannelID,
+		AuthorID:   m.Author.ID,
+		AuthorName: m.Author.Username,
+	})
+	if err != nil {
+		return err
+	}
+
+	call := &wasmCall{command: c, input: input}
+	reply, err := c.run(ctx, call)
+	if err != nil {
+		return err
+	}
+	if reply != "" {
+		_, err = c.host.bot.Messages.SendText(ctx, m.ChannelID, reply)
+	}
+	return err
+}
+
+// run instantiates the module and calls execute within the time limit
+func (c *WasmCommand) run(parent context.Context, call *wasmCall) (string, error) {
+	timeout := c.host.config.Timeout.Duration()
+	ctx, cancel := context.WithTimeout(context.WithValue(parent, wasmCallKey{}, call), timeout)
+	defer cancel()
+
+	guestLog := &pluginLogWriter{log: c.host.log.With(LogKeyCommand, c.name)}
+	moduleConfig := wazero.NewModuleConfig().
+		WithName("").
+		WithStdout(guestLog).
+		WithStderr(guestLog).
+		WithRandSource(rand.Reader).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithStartFunctions()
+	if _, exports := c.compiled.ExportedFunctions()["_initialize"]; exports {
+		moduleConfig = moduleConfig.WithStartFunctions("_initialize")
+	}
+
+	started := time.Now()
+	mod, err := c.host.runtime.InstantiateModule(ctx, c.compiled, moduleConfig)
+	if err != nil {
+		return "", c.wrapError(ctx, started, err)
+	}
+	defer mod.Close(context.Background())
+
+	results, err := mod.ExportedFunction("execute").Call(ctx)
+	if err != nil {
+		return "", c.wrapError(ctx, started, err)
+	}
+
+	if call.failure != "" {
+		return call.failure, nil
+	}
+	if len(results) > 0 && api.DecodeI32(results[0]) != 0 {
+		return "", fmt.Errorf("wasm command %s returned %d", c.name, api.DecodeI32(results[0]))
+	}
+	return call.reply.String(), nil
+}
+
+// wrapError reports time limit violations clearly
+func (c *WasmCommand) wrapError(ctx context.Context, started time.Time, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("wasm command %s exceeded its time limit of %s", c.name, time.Since(started).Round(time.Millisecond))
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return fmt.Errorf("wasm command %s was cancelled", c.name)
+	}
+	// Drop the guest stack trace that follows the first line
+	message, _, _ := strings.Cut(err.Error(), "\n")
+	return fmt.Errorf("wasm command %s: %s", c.name, message)
+}
+
+// namespace is the storage namespace private to this command
+func (c *WasmCommand) namespace() string {
+	return "wasm_" + c.name
+}
+
+// instantiateHostModule defines the "forge" functions guests import
+func (h *WasmHost) instantiateHostModule(ctx context.Context) error {
+	builder := h.runtime.NewHostModuleBuilder(wasmHostModule)
+
+	builder.NewFunctionBuilder().WithFunc(func(ctx context.Context) uint32 {
+		return uint32(len(callFrom(ctx).input))
+	}).Export("input_size")
+
+	builder.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr uint32) {
+		m.Memory().Write(ptr, callFrom(ctx).input)
+	}).Export("input_read")
+
+	builder.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, length uint32) {
+		call := callFrom(ctx)
+		if text, ok := readGuestString(m, ptr, length); ok && call.reply.Len()+len(text) <= wasmMaxReply {
+			call.reply.WriteString(text)
+		}
+	}).Export("reply")
+
+	builder.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, length uint32) {
+		if text, ok := readGuestString(m, ptr, length); ok {
+			callFrom(ctx).failure = "❌ " + truncate(text, wasmMaxReply-4)
+		}
+	}).Export("fail")
+
+	builder.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, length uint32) {
+		if text, ok := readGuestString(m, ptr, length); ok {
+			h.log.Info(truncate(text, 1000), LogKeyCommand, callFrom(ctx).command.name)
+		}
+	}).Export("log")
+
+	builder.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, kptr, klen, vptr, vcap uint32) int32 {
+		call := callFrom(ctx)
+		key, ok := readGuestKey(m, kptr, klen)
+		if !ok {
+			return -1
+		}
+
+		var value string
+		if err := h.bot.Storage.Load(call.command.namespace(), key, &value); err != nil {
+			return -1
+		}
+		if uint32(len(value)) <= vcap {
+			m.Memory().WriteString(vptr, value)
+		}
+		return int32(len(value))
+	}).Export("kv_get")
+
+	builder.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, kptr, klen, vptr, vlen uint32) int32 {
+		call := callFrom(ctx)
+		key, ok := readGuestKey(m, kptr, klen)
+		if !ok || vlen > wasmMaxValueSize {
+			return -1
+		}
+		value, ok := m.Memory().Read(vptr, vlen)
+		if !ok {
+			return -1
+		}
+
+		namespace := call.command.namespace()
+		keys, err := h.bot.Storage.Keys(namespace)
+		if err != nil {
+			return -1
+		}
+		if len(keys) >= wasmMaxKeys && !containsString(keys, key) {
+			return -1
+		}
+		if err := h.bot.Storage.Save(namespace, key, string(value)); err != nil {
+			h.log.Error("error saving WASM command data", LogKeyCommand, call.command.name, "error", err)
+			return -1
+		}
+		return 0
+	}).Export("kv_set")
+
+	builder.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, kptr, klen uint32) int32 {
+		call := callFrom(ctx)
+		key, ok := readGuestKey(m, kptr, klen)
+		if !ok {
+			return -1
+		}
+		if err := h.bot.Storage.Delete(call.command.namespace(), key); err != nil {
+			return -1
+		}
+		return 0
+	}).Export("kv_delete")
+
+	if _, err := builder.Instantiate(ctx); err != nil {
+		return fmt.Errorf("error instantiating wasm host module: %w", err)
+	}
+	return nil
+}
+
+func callFrom(ctx context.Context) *wasmCall {
+	return ctx.Value(wasmCallKey{}).(*wasmCall)
+}
+
+// readGuestString copies valid UTF-8 text out of guest memory
+func readGuestString(m api.Module, ptr, length uint32) (string, bool) {
+	data, ok := m.Memory().Read(ptr, length)
+	if !ok || !utf8.Valid(data) {
+		return "", false
+	}
+	return string(data), true
+}
+
+func readGuestKey(m api.Module, ptr, length uint32) (string, bool) {
+	if length == 0 || length > wasmMaxKeyLength {
+		return "", false
+	}
+	return readGuestString(m, ptr, length)
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate shortens s to at most n bytes without splitting a rune
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,183 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wasmKV replies with the value stored under "count", or "none", then
+// stores "seen" there:
+//
+//	(module
+//	  (import "forge" "reply" (func $reply (param i32 i32)))
+//	  (import "forge" "kv_get" (func $kv_get (param i32 i32 i32 i32) (result i32)))
+//	  (import "forge" "kv_set" (func $kv_set (param i32 i32 i32 i32) (result i32)))
+//	  (memory (export "memory") 1)
+//	  (data (i32.const 0) "count")
+//	  (data (i32.const 16) "none")
+//	  (data (i32.const 32) "seen")
+//	  (func (export "execute") (result i32) (local $n i32)
+//	    (local.set $n (call $kv_get (i32.const 0) (i32.const 5) (i32.const 64) (i32.const 64)))
+//	    (if (i32.lt_s (local.get $n) (i32.const 0))
+//	      (then (call $reply (i32.const 16) (i32.const 4)))
+//	      (else (call $reply (i32.const 64) (local.get $n))))
+//	    (drop (call $kv_set (i32.const 0) (i32.const 5) (i32.const 32) (i32.const 4)))
+//	    (i32.const 0)))
+var wasmKV = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x12, 0x03, 0x60, 0x02, 0x7f, 0x7f, 0x00,
+	0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f, 0x60, 0x00, 0x01, 0x7f, 0x02, 0x2d, 0x03, 0x05,
+	0x66, 0x6f, 0x72, 0x67, 0x65, 0x05, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x00, 0x00, 0x05, 0x66, 0x6f,
+	0x72, 0x67, 0x65, 0x06, 0x6b, 0x76, 0x5f, 0x67, 0x65, 0x74, 0x00, 0x01, 0x05, 0x66, 0x6f, 0x72,
+	0x67, 0x65, 0x06, 0x6b, 0x76, 0x5f, 0x73, 0x65, 0x74, 0x00, 0x01, 0x03, 0x02, 0x01, 0x02, 0x05,
+	0x03, 0x01, 0x00, 0x01, 0x07, 0x14, 0x02, 0x06, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x02, 0x00,
+	0x07, 0x65, 0x78, 0x65, 0x63, 0x75, 0x74, 0x65, 0x00, 0x03, 0x0a, 0x37, 0x01, 0x35, 0x01, 0x01,
+	0x7f, 0x41, 0x00, 0x41, 0x05, 0x41, 0xc0, 0x00, 0x41, 0xc0, 0x00, 0x10, 0x01, 0x21, 0x00, 0x20,
+	0x00, 0x41, 0x00, 0x48, 0x04, 0x40, 0x41, 0x10, 0x41, 0x04, 0x10, 0x00, 0x05, 0x41, 0xc0, 0x00,
+	0x20, 0x00, 0x10, 0x00, 0x0b, 0x41, 0x00, 0x41, 0x05, 0x41, 0x20, 0x41, 0x04, 0x10, 0x02, 0x1a,
+	0x41, 0x00, 0x0b, 0x0b, 0x1d, 0x03, 0x00, 0x41, 0x00, 0x0b, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74,
+	0x00, 0x41, 0x10, 0x0b, 0x04, 0x6e, 0x6f, 0x6e, 0x65, 0x00, 0x41, 0x20, 0x0b, 0x04, 0x73, 0x65,
+	0x65, 0x6e,
+}
+
+// wasmLoop never returns:
+//
+//	(module
+//	  (memory 1)
+//	  (func (export "execute") (result i32) (loop $l (br $l)) (i32.const 0)))
+var wasmLoop = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x05, 0x01, 0x60, 0x00, 0x01, 0x7f, 0x03,
+	0x02, 0x01, 0x00, 0x05, 0x03, 0x01, 0x00, 0x01, 0x07, 0x0b, 0x01, 0x07, 0x65, 0x78, 0x65, 0x63,
+	0x75, 0x74, 0x65, 0x00, 0x00, 0x0a, 0x0b, 0x01, 0x09, 0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x41,
+	0x00, 0x0b,
+}
+
+// wasmGrow traps unless its memory can grow by 32 pages (2 MiB):
+//
+//	(module
+//	  (memory 1)
+//	  (func (export "execute") (result i32)
+//	    (if (i32.eq (memory.grow (i32.const 32)) (i32.const -1)) (then unreachable))
+//	    (i32.const 0)))
+var wasmGrow = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x05, 0x01, 0x60, 0x00, 0x01, 0x7f, 0x03,
+	0x02, 0x01, 0x00, 0x05, 0x03, 0x01, 0x00, 0x01, 0x07, 0x0b, 0x01, 0x07, 0x65, 0x78, 0x65, 0x63,
+	0x75, 0x74, 0x65, 0x00, 0x00, 0x0a, 0x11, 0x01, 0x0f, 0x00, 0x41, 0x20, 0x40, 0x00, 0x41, 0x7f,
+	0x46, 0x04, 0x40, 0x00, 0x0b, 0x41, 0x00, 0x0b,
+}
+
+func newTestWasmHost(t *testing.T, config WasmConfig) *WasmHost {
+	t.Helper()
+
+	bot, err := NewBot(&Config{Token: "test", Prefix: "!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bot.Close() })
+
+	host := NewWasmHost(config)
+	if err := host.Initialize(bot); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { host.Shutdown() })
+	return host
+}
+
+// runWasm compiles wasm as command name and runs it once
+func runWasm(t *testing.T, host *WasmHost, name string, wasm []byte) (string, error) {
+	t.Helper()
+
+	cmd, err := host.Compile(name, wasm, WasmCommandMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmd.run(context.Background(), &wasmCall{command: cmd, input: []byte("{}")})
+}
+
+func TestWasmHostBeforeInitialize(t *testing.T) {
+	host := NewWasmHost(WasmConfig{Dir: t.TempDir()})
+
+	if _, err := host.Compile("echo", []byte("\x00asm"), WasmCommandMeta{}); !errors.Is(err, ErrWasmHostNotInitialized) {
+		t.Errorf("Compile error = %v, want %v", err, ErrWasmHostNotInitialized)
+	}
+	if err := host.Load("echo"); !errors.Is(err, ErrWasmHostNotInitialized) {
+		t.Errorf("Load error = %v, want %v", err, ErrWasmHostNotInitialized)
+	}
+	if err := host.Shutdown(); err != nil {
+		t.Errorf("Shutdown error = %v", err)
+	}
+}
+
+func TestWasmCommandStorageIsPrivate(t *testing.T) {
+	host := newTestWasmHost(t, WasmConfig{MemoryLimitMB: 1, Timeout: Duration(time.Second)})
+
+	for _, tt := range []struct{ name, want string }{
+		{"first", "none"},
+		{"first", "seen"},
+		{"second", "none"},
+	} {
+		reply, err := runWasm(t, host, tt.name, wasmKV)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if reply != tt.want {
+			t.Errorf("%s replied %q, want %q", tt.name, reply, tt.want)
+		}
+	}
+}
+
+func TestWasmCommandTimeLimit(t *testing.T) {
+	host := newTestWasmHost(t, WasmConfig{MemoryLimitMB: 1, Timeout: Duration(100 * time.Millisecond)})
+
+	start := time.Now()
+	_, err := runWasm(t, host, "loop", wasmLoop)
+	if err == nil || !strings.Contains(err.Error(), "time limit") {
+		t.Errorf("error = %v, want a time limit error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the loop ran for %v", elapsed)
+	}
+}
+
+func TestWasmCommandMemoryLimit(t *testing.T) {
+	limited := newTestWasmHost(t, WasmConfig{MemoryLimitMB: 1, Timeout: Duration(time.Second)})
+	if _, err := runWasm(t, limited, "grow", wasmGrow); err == nil {
+		t.Error("memory grew past a 1 MB limit")
+	}
+
+	roomy := newTestWasmHost(t, WasmConfig{MemoryLimitMB: 4, Timeout: Duration(time.Second)})
+	if _, err := runWasm(t, roomy, "grow", wasmGrow); err != nil {
+		t.Errorf("memory could not grow within a 4 MB limit: %v", err)
+	}
+}
+
+func TestWasmHostReloadUnloadsRemovedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"keep", "drop"} {
+		if err := os.WriteFile(filepath.Join(dir, name+".wasm"), wasmKV, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	host := newTestWasmHost(t, WasmConfig{Dir: dir, MemoryLimitMB: 1, Timeout: Duration(time.Second)})
+	if loaded := host.Loaded(); len(loaded) != 2 {
+		t.Fatalf("loaded = %v, want both commands", loaded)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "drop.wasm")); err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded := host.Loaded(); len(loaded) != 1 || loaded[0] != "keep" {
+		t.Errorf("loaded after reload = %v, want [keep]", loaded)
+	}
+	if _, exists := host.bot.Command("drop"); exists {
+		t.Error("the removed command is still registered")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"discord-bot-forge/core"
+	"discord-bot-forge/plugin"
+)
+
+// A sample out-of-process plugin. Build it and add it to the bot's config:
+//
+//	go build -o bin/dice-plugin ./examples/dice_plugin
+//
+//	plugins:
+//	  - name: dice
+//	    path: ./bin/dice-plugin
+func main() {
+	p := plugin.New("dice", "1.0.0")
+
+	var rolls atomic.Int64
+
+	p.Command(plugin.CommandSpec{
+		Name:        "roll",
+		Description: "Roll dice, e.g. 2d6",
+		Usage:       "roll [count]d<sides>",
+		Category:    "Fun",
+	}, func(ctx *plugin.Context) error {
+		spec := "1d6"
+		if len(ctx.Args) > 0 {
+			spec = ctx.Args[0]
+		}
+
+		count, sides, err := parseDice(spec)
+		if err != nil {
+			ctx.Reply("❌ " + err.Error())
+			return nil
+		}
+
+		results := make([]string, count)
+		total := 0
+		for i := range results {
+			roll := rand.Intn(sides) + 1
+			total += roll
+			results[i] = strconv.Itoa(roll)
+		}
+		rolls.Add(1)
+
+		ctx.Reply(fmt.Sprintf("🎲 %s: %s (total %d)", spec, strings.Join(results, ", "), total))
+		return nil
+	})
+
+	p.Command(plugin.CommandSpec{
+		Name:        "rolls",
+		Description: "Show how many times dice were rolled",
+		Usage:       "rolls",
+		Category:    "Fun",
+	}, func(ctx *plugin.Context) error {
+		ctx.Reply(fmt.Sprintf("🎲 Dice rolled %d times since the plugin started", rolls.Load()))
+		return nil
+	})
+
+	p.On(core.CommandFailedEvent{}.EventName(), func(event plugin.Event) {
+		var failed struct {
+			Command string `json:"command"`
+			Error   string `json:"error"`
+		}
+		if err := event.Decode(&failed); err == nil {
+			p.Logf("Command %s failed: %s", failed.Command, failed.Error)
+		}
+	})
+
+	p.OnShutdown(func() {
+		p.Logf("Dice plugin shutting down after %d rolls", rolls.Load())
+	})
+
+	if err := p.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseDice parses dice notation like "d20" or "3d6"
+func parseDice(spec string) (int, int, error) {
+	countText, sidesText, found := strings.Cut(strings.ToLower(spec), "d")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid dice %q, use notation like 2d6", spec)
+	}
+
+	count := 1
+	if countText != "" {
+		n, err := strconv.Atoi(countText)
+		if err != nil || n < 1 || n > 100 {
+			return 0, 0, fmt.Errorf("dice count must be between 1 and 100")
+		}
+		count = n
+	}
+
+	sides, err := strconv.Atoi(sidesText)
+	if err != nil || sides < 2 || sides > 1000 {
+		return 0, 0, fmt.Errorf("dice sides must be between 2 and 1000")
+	}
+	return count, sides, nil
+}
@@ -0,0 +1,103 @@
+//go:build wasip1
+
+// A sample sandboxed WASM command that counts how often it was used in each
+// server. Build it into the bot's wasm directory:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o plugins/counter.wasm ./examples/wasm_counter
+//
+// and enable WASM commands in the config:
+//
+//	wasm:
+//	  dir: ./plugins
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unsafe"
+)
+
+//go:wasmimport forge input_size
+func inputSize() uint32
+
+//go:wasmimport forge input_read
+func inputRead(ptr unsafe.Pointer)
+
+//go:wasmimport forge reply
+func reply(ptr unsafe.Pointer, length uint32)
+
+//go:wasmimport forge fail
+func fail(ptr unsafe.Pointer, length uint32)
+
+//go:wasmimport forge kv_get
+func kvGet(kptr unsafe.Pointer, klen uint32, vptr unsafe.Pointer, vcap uint32) int32
+
+//go:wasmimport forge kv_set
+func kvSet(kptr unsafe.Pointer, klen uint32, vptr unsafe.Pointer, vlen uint32) int32
+
+type input struct {
+	Args    []string `json:"args"`
+	GuildID string   `json:"guild_id"`
+}
+
+//go:wasmexport execute
+func execute() int32 {
+	buf := make([]byte, inputSize())
+	if len(buf) > 0 {
+		inputRead(unsafe.Pointer(&buf[0]))
+	}
+
+	var in input
+	if err := json.Unmarshal(buf, &in); err != nil {
+		sendFail("could not read input")
+		return 1
+	}
+
+	key := "count:" + in.GuildID
+	count := 0
+	if value, ok := get(key); ok {
+		count, _ = strconv.Atoi(value)
+	}
+
+	if len(in.Args) > 0 && in.Args[0] == "reset" {
+		count = 0
+	} else {
+		count++
+	}
+
+	if !set(key, strconv.Itoa(count)) {
+		sendFail("could not save the counter")
+		return 1
+	}
+
+	send(fmt.Sprintf("🔢 Counter: %d", count))
+	return 0
+}
+
+func get(key string) (string, bool) {
+	value := make([]byte, 32)
+	n := kvGet(stringPtr(key), uint32(len(key)), unsafe.Pointer(&value[0]), uint32(len(value)))
+	if n < 0 || int(n) > len(value) {
+		return "", false
+	}
+	return string(value[:n]), true
+}
+
+func set(key, value string) bool {
+	return kvSet(stringPtr(key), uint32(len(key)), stringPtr(value), uint32(len(value))) == 0
+}
+
+func send(text string) {
+	reply(stringPtr(text), uint32(len(text)))
+}
+
+func sendFail(text string) {
+	fail(stringPtr(text), uint32(len(text)))
+}
+
+func stringPtr(s string) unsafe.Pointer {
+	return unsafe.Pointer(unsafe.StringData(s))
+}
+
+func main() {}
@@ -0,0 +1,688 @@
+package forgetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// IDs of the objects every fake Discord starts with
+const (
+	BotID     = "100000000000000001"
+	GuildID   = "200000000000000002"
+	ChannelID = "300000000000000003"
+	UserID    = "400000000000000004"
+	OwnerID   = "500000000000000005"
+)
+
+// DefaultPermissions are the @everyone permissions of the default guild
+const DefaultPermissions = discordgo.PermissionViewChannel |
+	discordgo.PermissionSendMessages |
+	discordgo.PermissionReadMessageHistory |
+	discordgo.PermissionAddReactions |
+	discordgo.PermissionEmbedLinks
+
+// Request is a REST call received by the fake
+type Request struct {
+	Method string
+	Path   string // relative to the API root, e.g. "channels/300/messages"
+	Body   []byte // the JSON payload; payload_json for file uploads
+}
+
+// Discord is an in-memory stand-in for Discord's REST API. Sessions attached
+// to it send their REST calls here instead of over the network; it answers
+// them from the session's state, records what the bot sent and can be told
+// to fail requests.
+type Discord struct {
+	session *discordgo.Session
+
+	mu           sync.Mutex
+	nextID       int64
+	messages     []*discordgo.Message
+	byID         map[string]*discordgo.Message
+	nonces       map[string]*discordgo.Message
+	deleted      []string
+	reactions    []string
+	responses    []*discordgo.InteractionResponse
+	interactions map[string]string                          // interaction token -> channel ID
+	commands     map[string][]*discordgo.ApplicationCommand // by guild ID, "" for global
+	requests     []Request
+	failures     []int // statuses for the next requests
+	gatewayURL   string
+	shards       int
+	appFlags     int
+}
+
+// NewSession returns a session whose REST calls are answered by a fake
+// Discord, with the default guild, channel and users in its state
+func NewSession() (*discordgo.Session, *Discord) {
+	session, _ := discordgo.New("Bot forgetest")
+	return session, Attach(session)
+}
+
+// Attach routes an existing session's REST calls to a new fake Discord and
+// fills its state with the default guild, channel and users
+func Attach(session *discordgo.Session) *Discord {
+	d := newDiscord(session)
+	session.Client = &http.Client{Transport: roundTripper{d}}
+	return d
+}
+
+// newDiscord creates a fake Discord whose world is session's state
+func newDiscord(session *discordgo.Session) *Discord {
+	d := &Discord{
+		session:      session,
+		nextID:       900000000000000000,
+		byID:         make(map[string]*discordgo.Message),
+		nonces:       make(map[string]*discordgo.Message),
+		interactions: make(map[string]string),
+		commands:     make(map[string][]*discordgo.ApplicationCommand),
+		gatewayURL:   "wss://gateway.forgetest.invalid",
+		shards:       1,
+	}
+
+	session.StateEnabled = true
+	session.State.User = &discordgo.User{ID: BotID, Username: "forgebot", Bot: true}
+
+	d.AddGuild(GuildID, "Test Server", OwnerID)
+	d.AddChannel(GuildID, ChannelID, "general")
+	d.AddMember(GuildID, &discordgo.User{ID: BotID, Username: "forgebot", Bot: true})
+	d.AddMember(GuildID, &discordgo.User{ID: UserID, Username: "tester"})
+	d.AddMember(GuildID, &discordgo.User{ID: OwnerID, Username: "owner"})
+	return d
+}
+
+// roundTripper serves HTTP requests with a handler in memory
+type roundTripper struct {
+	handler http.Handler
+}
+
+func (t roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	t.handler.ServeHTTP(recorder, r)
+	response := recorder.Result()
+	response.Request = r
+	return response, nil
+}
+
+// AddGuild adds a guild with an @everyone role granting DefaultPermissions
+func (d *Discord) AddGuild(id, name, ownerID string) *discordgo.Guild {
+	guild := &discordgo.Guild{
+		ID:      id,
+		Name:    name,
+		OwnerID: ownerID,
+		Roles: []*discordgo.Role{
+			{ID: id, Name: "@everyone", Permissions: DefaultPermissions},
+		},
+	}
+	d.session.State.GuildAdd(guild)
+	return guild
+}
+
+// AddChannel adds a text channel to a guild
+func (d *Discord) AddChannel(guildID, id, name string) *discordgo.Channel {
+	channel := &discordgo.Channel{ID: id, GuildID: guildID, Name: name, Type: discordgo.ChannelTypeGuildText}
+	d.session.State.ChannelAdd(channel)
+	return channel
+}
+
+// AddMember adds a user to a guild with the given roles
+func (d *Discord) AddMember(guildID string, user *discordgo.User, roleIDs ...string) *discordgo.Member {
+	member := &discordgo.Member{GuildID: guildID, User: user, Roles: roleIDs}
+	d.session.State.MemberAdd(member)
+	return member
+}
+
+// GrantPermissions gives a member a new role with the given permissions
+func (d *Discord) GrantPermissions(guildID, userID string, permissions int64) error {
+	member, err := d.session.State.Member(guildID, userID)
+	if err != nil {
+		return fmt.Errorf("member %s of guild %s: %w", userID, guildID, err)
+	}
+
+	role := &discordgo.Role{ID: d.newID(), Name: fmt.Sprintf("granted-%d", permissions), Permissions: permissions}
+	d.session.State.RoleAdd(guildID, role)
+
+	updated := *member
+	updated.Roles = append(append([]string(nil), member.Roles...), role.ID)
+	return d.session.State.MemberAdd(&updated)
+}
+
+// FailNext makes the next n REST requests fail with the given status. A 429
+// carries a short retry_after like Discord's rate limit responses.
+func (d *Discord) FailNext(n, status int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		d.failures = append(d.failures, status)
+	}
+}
+
+// Messages returns every message the bot created, in order, as last edited
+func (d *Discord) Messages() []*discordgo.Message {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	messages := make([]*discordgo.Message, len(d.messages))
+	for i, message := range d.messages {
+		copied := *message
+		messages[i] = &copied
+	}
+	return messages
+}
+
+// MessagesIn returns the messages the bot created in a channel
+func (d *Discord) MessagesIn(channelID string) []*discordgo.Message {
+	var messages []*discordgo.Message
+	for _, message := range d.Messages() {
+		if message.ChannelID == channelID {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// LastMessage returns the most recent message the bot created, or nil
+func (d *Discord) LastMessage() *discordgo.Message {
+	messages := d.Messages()
+	if len(messages) == 0 {
+		return nil
+	}
+	return messages[len(messages)-1]
+}
+
+// Deleted returns the IDs of messages the bot deleted
+func (d *Discord) Deleted() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]string(nil), d.deleted...)
+}
+
+// Reactions returns the reactions the bot added as "messageID:emoji"
+func (d *Discord) Reactions() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]string(nil), d.reactions...)
+}
+
+// InteractionResponses returns the bot's responses to interactions, in order
+func (d *Discord) InteractionResponses() []*discordgo.InteractionResponse {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]*discordgo.InteractionResponse(nil), d.responses...)
+}
+
+// ApplicationCommands returns the slash commands the bot registered in a
+// guild, or globally for an empty guild ID
+func (d *Discord) ApplicationCommands(guildID string) []*discordgo.ApplicationCommand {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]*discordgo.ApplicationCommand(nil), d.commands[guildID]...)
+}
+
+// SetApplicationFlags sets the flags of the bot's application, such as the
+// ones enabling privileged intents
+func (d *Discord) SetApplicationFlags(flags int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.appFlags = flags
+}
+
+// Requests returns every REST call received, in order
+func (d *Discord) Requests() []Request {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]Request(nil), d.requests...)
+}
+
+// Reset forgets recorded messages, responses and requests
+func (d *Discord) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.messages = nil
+	d.byID = make(map[string]*discordgo.Message)
+	d.nonces = make(map[string]*discordgo.Message)
+	d.deleted = nil
+	d.reactions = nil
+	d.responses = nil
+	d.commands = make(map[string][]*discordgo.ApplicationCommand)
+	d.requests = nil
+	d.failures = nil
+}
+
+// registerInteraction remembers which channel an interaction token replies in
+func (d *Discord) registerInteraction(i *discordgo.Interaction) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.interactions[i.Token] = i.ChannelID
+}
+
+// ServeHTTP answers a REST call
+func (d *Discord) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := apiPath(r.URL.Path)
+	payload, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	d.mu.Lock()
+	d.requests = append(d.requests, Request{Method: r.Method, Path: path, Body: payload})
+	var status int
+	if len(d.failures) > 0 {
+		status, d.failures = d.failures[0], d.failures[1:]
+	}
+	d.mu.Unlock()
+
+	if status != 0 {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.01, "global": false}`))
+			return
+		}
+		writeError(w, status, http.StatusText(status))
+		return
+	}
+
+	d.route(w, r.Method, strings.Split(path, "/"), payload)
+}
+
+// route dispatches a REST call by method and path segments
+func (d *Discord) route(w http.ResponseWriter, method string, p []string, payload []byte) {
+	switch {
+	case method == "GET" && match(p, "users", "@me"):
+		writeJSON(w, d.session.State.User)
+
+	case method == "GET" && match(p, "oauth2", "applications", "@me"):
+		d.mu.Lock()
+		writeJSON(w, &discordgo.Application{ID: BotID, Name: d.session.State.User.Username, Flags: d.appFlags})
+		d.mu.Unlock()
+
+	case method == "GET" && match(p, "gateway"):
+		d.mu.Lock()
+		writeJSON(w, map[string]string{"url": d.gatewayURL})
+		d.mu.Unlock()
+
+	case method == "GET" && match(p, "gateway", "bot"):
+		d.mu.Lock()
+		writeJSON(w, map[string]interface{}{
+			"url":    d.gatewayURL,
+			"shards": d.shards,
+			"session_start_limit": map[string]int{
+				"total": 1000, "remaining": 1000, "reset_after": 0, "max_concurrency": 1,
+			},
+		})
+		d.mu.Unlock()
+
+	case method == "POST" && match(p, "channels", "*", "messages"):
+		d.createMessage(w, p[1], payload)
+
+	case method == "GET" && match(p, "channels", "*", "messages", "*"):
+		d.withMessage(w, p[3], func(m *discordgo.Message) { writeJSON(w, m) })
+
+	case method == "PATCH" && match(p, "channels", "*", "messages", "*"):
+		d.withMessage(w, p[3], func(m *discordgo.Message) {
+			d.editMessage(m, payload)
+			writeJSON(w, m)
+		})
+
+	case method == "DELETE" && match(p, "channels", "*", "messages", "*"):
+		d.mu.Lock()
+		d.deleted = append(d.deleted, p[3])
+		d.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+
+	case method == "PUT" && match(p, "channels", "*", "messages", "*", "reactions", "*", "@me"):
+		d.mu.Lock()
+		d.reactions = append(d.reactions, p[3]+":"+p[5])
+		d.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+
+	case method == "POST" && match(p, "channels", "*", "typing"):
+		w.WriteHeader(http.StatusNoContent)
+
+	case method == "GET" && match(p, "channels", "*"):
+		d.fromState(w, func(s *discordgo.State) (interface{}, error) { return s.Channel(p[1]) })
+
+	case method == "GET" && match(p, "guilds", "*"):
+		d.fromState(w, func(s *discordgo.State) (interface{}, error) { return s.Guild(p[1]) })
+
+	case method == "GET" && match(p, "guilds", "*", "roles"):
+		d.fromState(w, func(s *discordgo.State) (interface{}, error) {
+			guild, err := s.Guild(p[1])
+			if err != nil {
+				return nil, err
+			}
+			return guild.Roles, nil
+		})
+
+	case method == "GET" && match(p, "guilds", "*", "members", "*"):
+		d.fromState(w, func(s *discordgo.State) (interface{}, error) { return s.Member(p[1], p[3]) })
+
+	case method == "POST" && match(p, "users", "@me", "channels"):
+		var params struct {
+			RecipientID string `json:"recipient_id"`
+		}
+		json.Unmarshal(payload, &params)
+		writeJSON(w, &discordgo.Channel{
+			ID:         "dm-" + params.RecipientID,
+			Type:       discordgo.ChannelTypeDM,
+			Recipients: []*discordgo.User{{ID: params.RecipientID}},
+		})
+
+	case match(p, "applications", "*", "commands"):
+		d.applicationCommands(w, method, "", payload)
+
+	case match(p, "applications", "*", "guilds", "*", "commands"):
+		d.applicationCommands(w, method, p[3], payload)
+
+	case method == "DELETE" && match(p, "applications", "*", "commands", "*"):
+		d.deleteApplicationCommand(w, "", p[3])
+
+	case method == "DELETE" && match(p, "applications", "*", "guilds", "*", "commands", "*"):
+		d.deleteApplicationCommand(w, p[3], p[5])
+
+	case method == "POST" && match(p, "interactions", "*", "*", "callback"):
+		d.respond(w, p[2], payload)
+
+	case match(p, "webhooks", "*", "*", "messages", "@original"):
+		d.originalResponse(w, method, p[2], payload)
+
+	case method == "POST" && match(p, "webhooks", "*", "*"):
+		d.mu.Lock()
+		channelID := d.interactions[p[2]]
+		d.mu.Unlock()
+		d.createMessage(w, channelID, payload)
+
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("forgetest does not implement %s %s", method, strings.Join(p, "/")))
+	}
+}
+
+// createMessage stores a message sent by the bot. A repeated enforced nonce
+// returns the earlier message, like Discord does.
+func (d *Discord) createMessage(w http.ResponseWriter, channelID string, payload []byte) {
+	var data struct {
+		discordgo.MessageSend
+		Nonce        string `json:"nonce"`
+		EnforceNonce bool   `json:"enforce_nonce"`
+	}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if data.EnforceNonce && data.Nonce != "" {
+		if existing, ok := d.nonces[data.Nonce]; ok {
+			writeJSON(w, existing)
+			return
+		}
+	}
+
+	message := &discordgo.Message{
+		ID:         d.nextMessageID(),
+		ChannelID:  channelID,
+		Content:    data.Content,
+		Embeds:     data.Embeds,
+		Components: data.Components,
+		TTS:        data.TTS,
+		Author:     d.session.State.User,
+		Timestamp:  time.Now(),
+	}
+	if channel, err := d.session.State.Channel(channelID); err == nil {
+		message.GuildID = channel.GuildID
+	}
+	if data.Reference != nil {
+		message.MessageReference = data.Reference
+	}
+
+	d.messages = append(d.messages, message)
+	d.byID[message.ID] = message
+	if data.Nonce != "" {
+		d.nonces[data.Nonce] = message
+	}
+	writeJSON(w, message)
+}
+
+// applicationCommands lists, creates or bulk overwrites slash commands
+func (d *Discord) applicationCommands(w http.ResponseWriter, method, guildID string, payload []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch method {
+	case "GET":
+		writeJSON(w, append([]*discordgo.ApplicationCommand{}, d.commands[guildID]...))
+
+	case "POST":
+		var command discordgo.ApplicationCommand
+		if err := json.Unmarshal(payload, &command); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		command.ID = d.nextMessageID()
+		command.ApplicationID = d.session.State.User.ID
+		command.GuildID = guildID
+
+		// Creating a command with an existing name replaces it
+		commands := d.commands[guildID][:0:0]
+		for _, existing := range d.commands[guildID] {
+			if existing.Name != command.Name {
+				commands = append(commands, existing)
+			}
+		}
+		d.commands[guildID] = append(commands, &command)
+		writeJSON(w, &command)
+
+	case "PUT":
+		var commands []*discordgo.ApplicationCommand
+		if err := json.Unmarshal(payload, &commands); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for _, command := range commands {
+			command.ID = d.nextMessageID()
+			command.ApplicationID = d.session.State.User.ID
+			command.GuildID = guildID
+		}
+		d.commands[guildID] = commands
+		writeJSON(w, commands)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+// deleteApplicationCommand removes a slash command
+func (d *Discord) deleteApplicationCommand(w http.ResponseWriter, guildID, id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	commands := d.commands[guildID]
+	for i, command := range commands {
+		if command.ID == id {
+			d.commands[guildID] = append(commands[:i:i], commands[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Unknown application command")
+}
+
+// editMessage applies an edit to a stored message
+func (d *Discord) editMessage(message *discordgo.Message, payload []byte) {
+	var edit struct {
+		Content    *string                   `json:"content"`
+		Embeds     []*discordgo.MessageEmbed `json:"embeds"`
+		Components json.RawMessage           `json:"components"`
+	}
+	json.Unmarshal(payload, &edit)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if edit.Content != nil {
+		message.Content = *edit.Content
+	}
+	if edit.Embeds != nil {
+		message.Embeds = edit.Embeds
+	}
+	now := time.Now()
+	message.EditedTimestamp = &now
+}
+
+// withMessage runs fn with a stored message or answers 404
+func (d *Discord) withMessage(w http.ResponseWriter, id string, fn func(*discordgo.Message)) {
+	d.mu.Lock()
+	message, ok := d.byID[id]
+	d.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Unknown Message")
+		return
+	}
+	fn(message)
+}
+
+// respond records an interaction response. Responses carrying a message
+// also become the interaction's original response message.
+func (d *Discord) respond(w http.ResponseWriter, token string, payload []byte) {
+	var response discordgo.InteractionResponse
+	if err := json.Unmarshal(payload, &response); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	d.mu.Lock()
+	d.responses = append(d.responses, &response)
+	channelID := d.interactions[token]
+	if data := response.Data; data != nil {
+		message := &discordgo.Message{
+			ID:         d.nextMessageID(),
+			ChannelID:  channelID,
+			Content:    data.Content,
+			Embeds:     data.Embeds,
+			Components: data.Components,
+			Author:     d.session.State.User,
+			Timestamp:  time.Now(),
+		}
+		d.messages = append(d.messages, message)
+		d.byID[message.ID] = message
+		d.byID["@original:"+token] = message
+	}
+	d.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// originalResponse reads, edits or deletes an interaction's first response
+func (d *Discord) originalResponse(w http.ResponseWriter, method, token string, payload []byte) {
+	d.withMessage(w, "@original:"+token, func(m *discordgo.Message) {
+		switch method {
+		case "PATCH":
+			d.editMessage(m, payload)
+			writeJSON(w, m)
+		case "DELETE":
+			d.mu.Lock()
+			d.deleted = append(d.deleted, m.ID)
+			d.mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeJSON(w, m)
+		}
+	})
+}
+
+// fromState answers with an object from the session's state
+func (d *Discord) fromState(w http.ResponseWriter, lookup func(*discordgo.State) (interface{}, error)) {
+	value, err := lookup(d.session.State)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, value)
+}
+
+// nextMessageID returns a new snowflake-like ID; d.mu must be held
+func (d *Discord) nextMessageID() string {
+	d.nextID++
+	return fmt.Sprint(d.nextID)
+}
+
+// newID returns a new ID for objects created outside a request
+func (d *Discord) newID() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.nextMessageID()
+}
+
+// apiPath strips the scheme, host and API version from a request path
+func apiPath(path string) string {
+	if i := strings.Index(path, "/api/v"); i >= 0 {
+		path = path[i+len("/api/v"):]
+		if j := strings.Index(path, "/"); j >= 0 {
+			path = path[j:]
+		}
+	}
+	return strings.Trim(path, "/")
+}
+
+// match reports whether path segments fit a pattern where "*" matches any
+// one segment
+func match(segments []string, pattern ...string) bool {
+	if len(segments) != len(pattern) {
+		return false
+	}
+	for i, part := range pattern {
+		if part != "*" && part != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readBody returns the JSON payload of a request, which multipart requests
+// carry in their payload_json field
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+		return []byte(r.FormValue("payload_json")), nil
+	}
+	return io.ReadAll(r.Body)
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": message, "code": 0})
+}
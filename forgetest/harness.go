@@ -0,0 +1,336 @@
+// Package forgetest runs DiscordBotForge commands, middleware and modules
+// offline for tests.
+//
+// A Harness owns a Bot whose session talks to an in-memory fake of
+// Discord's REST API instead of the network. Tests inject gateway events,
+// then assert on the messages, embeds and interaction responses the bot
+// sent:
+//
+//	func TestPing(t *testing.T) {
+//		h := forgetest.New(t)
+//		h.Bot.RegisterCommand(&commands.PingCommand{})
+//
+//		h.Send("!ping")
+//		h.AssertReplyContains("Pong!")
+//	}
+//
+// Commands can also be executed directly with h.Session, which is an
+// ordinary *discordgo.Session:
+//
+//	err := cmd.Execute(h.Session, h.Message("!ping"), nil)
+//
+// The fake is installed by replacing the session's HTTP transport rather
+// than by putting an interface in front of *discordgo.Session. This is
+// deliberate: commands and modules keep their discordgo signatures, and
+// every REST call discordgo makes, including its rate limit handling, runs
+// unchanged against the fake. Routes the fake does not know answer 404, so
+// a test fails loudly instead of reaching the network.
+package forgetest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"discord-bot-forge/core"
+	"github.com/bwmarrin/discordgo"
+)
+
+// waitTimeout bounds how long the harness waits for queued messages
+const waitTimeout = 5 * time.Second
+
+// Harness is a bot wired to a fake Discord for one test
+type Harness struct {
+	T       testing.TB
+	Bot     *core.Bot
+	Session *discordgo.Session
+	Discord *Discord
+
+	nextID int64
+}
+
+// New creates a harness with a bot using the default configuration. Options
+// may adjust the configuration before the bot is created. The bot is shut
+// down when the test ends.
+func New(t testing.TB, options ...func(*core.Config)) *Harness {
+	t.Helper()
+
+	config := core.DefaultConfig()
+	config.Token = "forgetest"
+	config.Web.Enabled = false
+	config.Logging.File = ""
+	for _, option := range options {
+		option(config)
+	}
+
+	bot, err := core.NewBot(config)
+	if err != nil {
+		t.Fatalf("forgetest: creating bot: %v", err)
+	}
+
+	h := &Harness{
+		T:       t,
+		Bot:     bot,
+		Session: bot.Session,
+		Discord: Attach(bot.Session),
+		nextID:  800000000000000000,
+	}
+	t.Cleanup(func() { bot.Close() })
+	return h
+}
+
+// StartModules starts the registered modules in dependency order
+func (h *Harness) StartModules() {
+	h.T.Helper()
+
+	if err := h.Bot.StartModules(); err != nil {
+		h.T.Fatalf("forgetest: starting modules: %v", err)
+	}
+}
+
+// MessageOption adjusts a message built by Message
+type MessageOption func(*discordgo.MessageCreate)
+
+// FromUser sets the message's author
+func FromUser(id, username string) MessageOption {
+	return func(m *discordgo.MessageCreate) {
+		m.Author = &discordgo.User{ID: id, Username: username}
+		if m.Member != nil {
+			m.Member.User = m.Author
+		}
+	}
+}
+
+// FromBot marks the author as a bot account
+func FromBot() MessageOption {
+	return func(m *discordgo.MessageCreate) {
+		m.Author.Bot = true
+	}
+}
+
+// InChannel sets the channel the message is posted in
+func InChannel(channelID string) MessageOption {
+	return func(m *discordgo.MessageCreate) {
+		m.ChannelID = channelID
+	}
+}
+
+// InDM turns the message into a direct message
+func InDM() MessageOption {
+	return func(m *discordgo.MessageCreate) {
+		m.GuildID = ""
+		m.Member = nil
+		m.ChannelID = "dm-" + m.Author.ID
+	}
+}
+
+// Message builds a MessageCreate event from the default user in the default
+// channel
+func (h *Harness) Message(content string, options ...MessageOption) *discordgo.MessageCreate {
+	h.nextID++
+	return newMessage(fmt.Sprint(h.nextID), content, options)
+}
+
+// newMessage builds a MessageCreate event with the given ID
+func newMessage(id, content string, options []MessageOption) *discordgo.MessageCreate {
+	author := &discordgo.User{ID: UserID, Username: "tester"}
+	m := &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        id,
+		ChannelID: ChannelID,
+		GuildID:   GuildID,
+		Content:   content,
+		Author:    author,
+		Member:    &discordgo.Member{User: author},
+		Timestamp: time.Now(),
+	}}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// Send dispatches a message to the bot and waits for its replies
+func (h *Harness) Send(content string, options ...MessageOption) *discordgo.MessageCreate {
+	h.T.Helper()
+
+	m := h.Message(content, options...)
+	h.Dispatch(m)
+	return m
+}
+
+// SlashCommand builds an application command interaction from the default
+// user in the default channel
+func (h *Harness) SlashCommand(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	h.nextID++
+	return newSlashCommand(fmt.Sprint(h.nextID), name, options)
+}
+
+// newSlashCommand builds an application command interaction with the given ID
+func newSlashCommand(id, name string, options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        id,
+		AppID:     BotID,
+		Type:      discordgo.InteractionApplicationCommand,
+		ChannelID: ChannelID,
+		GuildID:   GuildID,
+		Token:     "token-" + id,
+		Member:    &discordgo.Member{User: &discordgo.User{ID: UserID, Username: "tester"}},
+		Data: discordgo.ApplicationCommandInteractionData{
+			ID:      id,
+			Name:    name,
+			Options: options,
+		},
+	}}
+}
+
+// Interact dispatches an interaction to the bot and waits for its replies
+func (h *Harness) Interact(i *discordgo.InteractionCreate) {
+	h.T.Helper()
+
+	h.Discord.registerInteraction(i.Interaction)
+	h.Dispatch(i)
+}
+
+// Dispatch delivers any gateway event to the bot's handlers and waits for
+// the messages they queued
+func (h *Harness) Dispatch(event interface{}) {
+	h.T.Helper()
+
+	h.Bot.Dispatch(h.Session, event)
+	h.Wait()
+}
+
+// Wait blocks until the bot's message queue is empty
+func (h *Harness) Wait() {
+	h.T.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	if err := h.Bot.Messages.Wait(ctx); err != nil {
+		h.T.Fatalf("forgetest: queued messages were not sent within %v", waitTimeout)
+	}
+}
+
+// AssertSent fails the test unless the bot sent a message with exactly
+// this content
+func (h *Harness) AssertSent(content string) {
+	h.T.Helper()
+
+	for _, message := range h.Discord.Messages() {
+		if message.Content == content {
+			return
+		}
+	}
+	h.T.Errorf("no message with content %q was sent; sent: %s", content, h.describeMessages())
+}
+
+// AssertReply fails the test unless the last message sent has exactly this
+// content
+func (h *Harness) AssertReply(content string) {
+	h.T.Helper()
+
+	last := h.Discord.LastMessage()
+	switch {
+	case last == nil:
+		h.T.Errorf("expected reply %q, but nothing was sent", content)
+	case last.Content != content:
+		h.T.Errorf("expected reply %q, got %q", content, last.Content)
+	}
+}
+
+// AssertReplyContains fails the test unless the last message sent contains
+// substr in its content or embeds
+func (h *Harness) AssertReplyContains(substr string) {
+	h.T.Helper()
+
+	last := h.Discord.LastMessage()
+	switch {
+	case last == nil:
+		h.T.Errorf("expected a reply containing %q, but nothing was sent", substr)
+	case !strings.Contains(messageText(last), substr):
+		h.T.Errorf("expected a reply containing %q, got %s", substr, describeMessage(last))
+	}
+}
+
+// AssertEmbed fails the test unless a sent message has an embed with this
+// title
+func (h *Harness) AssertEmbed(title string) *discordgo.MessageEmbed {
+	h.T.Helper()
+
+	for _, message := range h.Discord.Messages() {
+		for _, embed := range message.Embeds {
+			if embed.Title == title {
+				return embed
+			}
+		}
+	}
+	h.T.Errorf("no embed titled %q was sent; sent: %s", title, h.describeMessages())
+	return nil
+}
+
+// AssertNoMessages fails the test if the bot sent anything
+func (h *Harness) AssertNoMessages() {
+	h.T.Helper()
+
+	if messages := h.Discord.Messages(); len(messages) > 0 {
+		h.T.Errorf("expected no messages, but sent: %s", h.describeMessages())
+	}
+}
+
+// AssertInteractionResponse fails the test unless the bot responded to an
+// interaction with this content
+func (h *Harness) AssertInteractionResponse(content string) {
+	h.T.Helper()
+
+	var got []string
+	for _, response := range h.Discord.InteractionResponses() {
+		if response.Data == nil {
+			continue
+		}
+		if response.Data.Content == content {
+			return
+		}
+		got = append(got, fmt.Sprintf("%q", response.Data.Content))
+	}
+	h.T.Errorf("no interaction response %q; got [%s]", content, strings.Join(got, ", "))
+}
+
+// describeMessages lists sent messages for failure output
+func (h *Harness) describeMessages() string {
+	messages := h.Discord.Messages()
+	if len(messages) == 0 {
+		return "nothing"
+	}
+
+	parts := make([]string, len(messages))
+	for i, message := range messages {
+		parts[i] = describeMessage(message)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func describeMessage(message *discordgo.Message) string {
+	if len(message.Embeds) == 0 {
+		return fmt.Sprintf("%q", message.Content)
+	}
+
+	titles := make([]string, len(message.Embeds))
+	for i, embed := range message.Embeds {
+		titles[i] = embed.Title
+	}
+	return fmt.Sprintf("%q with embeds %q", message.Content, titles)
+}
+
+// messageText joins a message's content with its embeds' text
+func messageText(message *discordgo.Message) string {
+	parts := []string{message.Content}
+	for _, embed := range message.Embeds {
+		parts = append(parts, embed.Title, embed.Description)
+		for _, field := range embed.Fields {
+			parts = append(parts, field.Name, field.Value)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
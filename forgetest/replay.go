@@ -0,0 +1,322 @@
+package forgetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"discord-bot-forge/core"
+	"github.com/bwmarrin/discordgo"
+)
+
+// UpdateGoldenEnv names the environment variable that makes AssertGolden
+// rewrite golden files instead of comparing against them
+const UpdateGoldenEnv = "FORGETEST_UPDATE"
+
+// Call is an outgoing REST call captured during a replay. Bodies are
+// normalized for comparison: nonces are dropped and keys are sorted.
+type Call struct {
+	Method string
+	Path   string
+	Body   string
+}
+
+func (c Call) String() string {
+	if c.Body == "" {
+		return c.Method + " " + c.Path
+	}
+	return c.Method + " " + c.Path + " " + c.Body
+}
+
+// Replayer feeds a recording made by the recorder module into a bot and
+// captures what the bot sends back. Events are delivered with Bot.Dispatch,
+// one at a time, and each waits for the messages the previous one queued,
+// so the captured calls are deterministic.
+type Replayer struct {
+	Bot     *core.Bot
+	Discord *Discord
+
+	// Speed scales the recorded delays between events: 1 replays in real
+	// time, 10 ten times faster and 0 as fast as possible
+	Speed float64
+
+	skipped map[string]int
+}
+
+// NewReplayer attaches a fake Discord to the bot's session to capture its
+// outgoing calls. The bot does not need to be opened.
+func NewReplayer(bot *core.Bot) *Replayer {
+	return newReplayer(bot, Attach(bot.Session))
+}
+
+func newReplayer(bot *core.Bot, discord *Discord) *Replayer {
+	return &Replayer{Bot: bot, Discord: discord, skipped: make(map[string]int)}
+}
+
+// Replay dispatches the recording's events in order. It stops early when
+// ctx is cancelled.
+func (r *Replayer) Replay(ctx context.Context, recording *core.Recording) error {
+	started := time.Now()
+	for i, recorded := range recording.Events {
+		if r.Speed > 0 {
+			due := started.Add(time.Duration(float64(recorded.Offset) / r.Speed))
+			timer := time.NewTimer(time.Until(due))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := r.dispatch(int64(i+1), recorded); err != nil {
+			return err
+		}
+		if err := r.Bot.Messages.Wait(ctx); err != nil {
+			return fmt.Errorf("error waiting for replies to %s event %d: %w", recorded.Type, i+1, err)
+		}
+	}
+	return nil
+}
+
+// dispatch delivers one recorded event, typed as discordgo would decode it,
+// followed by the raw *discordgo.Event
+func (r *Replayer) dispatch(seq int64, recorded core.RecordedEvent) error {
+	session := r.Bot.Session
+	event := &discordgo.Event{Sequence: seq, Type: recorded.Type, RawData: recorded.Data}
+
+	if newEvent, ok := eventTypes[recorded.Type]; ok {
+		event.Struct = newEvent()
+		if err := json.Unmarshal(recorded.Data, event.Struct); err != nil {
+			return fmt.Errorf("error decoding %s event %d: %w", recorded.Type, seq, err)
+		}
+		r.Bot.Dispatch(session, event.Struct)
+	} else {
+		r.skipped[recorded.Type]++
+	}
+
+	r.Bot.Dispatch(session, event)
+	return nil
+}
+
+// Skipped counts recorded events of types discordgo does not know, which
+// were only delivered as raw *discordgo.Event
+func (r *Replayer) Skipped() map[string]int {
+	skipped := make(map[string]int, len(r.skipped))
+	for eventType, count := range r.skipped {
+		skipped[eventType] = count
+	}
+	return skipped
+}
+
+// Calls returns the REST calls the bot made so far, normalized
+func (r *Replayer) Calls() []Call {
+	requests := r.Discord.Requests()
+	calls := make([]Call, len(requests))
+	for i, request := range requests {
+		calls[i] = Call{Method: request.Method, Path: request.Path, Body: normalizeBody(request.Body)}
+	}
+	return calls
+}
+
+// normalizeBody re-encodes a JSON body with sorted keys and without the
+// random nonce the message queue adds
+func normalizeBody(body []byte) string {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return ""
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return string(body)
+	}
+	if object, ok := value.(map[string]interface{}); ok {
+		delete(object, "nonce")
+	}
+
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return string(body)
+	}
+	return string(normalized)
+}
+
+// FormatCalls renders calls one per line, as stored in golden files
+func FormatCalls(calls []Call) string {
+	var b strings.Builder
+	for _, call := range calls {
+		b.WriteString(call.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// AssertGolden fails the test unless calls match the golden file at path.
+// With FORGETEST_UPDATE=1 in the environment the file is rewritten instead.
+func AssertGolden(t testing.TB, path string, calls []Call) {
+	t.Helper()
+
+	got := FormatCalls(calls)
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("forgetest: creating golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("forgetest: writing golden file: %v", err)
+		}
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("forgetest: reading golden file (run with %s=1 to create it): %v", UpdateGoldenEnv, err)
+	}
+	want := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if got == want {
+		return
+	}
+
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var wantLine, gotLine string
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+		if wantLine != gotLine {
+			t.Errorf("calls differ from %s at call %d:\n  want: %s\n   got: %s\n(run with %s=1 to update)",
+				path, i+1, wantLine, gotLine, UpdateGoldenEnv)
+			return
+		}
+	}
+}
+
+// Replay loads a recording and replays it into the harness's bot as fast
+// as possible, returning the calls the bot made
+func (h *Harness) Replay(path string) []Call {
+	h.T.Helper()
+
+	recording, err := core.LoadRecording(path)
+	if err != nil {
+		h.T.Fatalf("forgetest: %v", err)
+	}
+
+	replayer := newReplayer(h.Bot, h.Discord)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := replayer.Replay(ctx, recording); err != nil {
+		h.T.Fatalf("forgetest: replaying %s: %v", path, err)
+	}
+	return replayer.Calls()
+}
+
+// ReplayTool returns a core.ToolRunner that starts the bot's modules,
+// replays the recording at path into it and writes the REST calls the bot
+// made to out, followed by the event types it could only deliver raw.
+// forge replay installs it to debug a recording against a bot's own code.
+func ReplayTool(path string, speed float64, out io.Writer) core.ToolRunner {
+	return func(ctx context.Context, bot *core.Bot) error {
+		recording, err := core.LoadRecording(path)
+		if err != nil {
+			return err
+		}
+
+		replayer := NewReplayer(bot)
+		replayer.Speed = speed
+		if err := bot.StartModules(); err != nil {
+			return err
+		}
+		replayErr := replayer.Replay(ctx, recording)
+
+		if _, err := io.WriteString(out, FormatCalls(replayer.Calls())); err != nil {
+			return err
+		}
+		skipped := replayer.Skipped()
+		types := make([]string, 0, len(skipped))
+		for eventType := range skipped {
+			types = append(types, eventType)
+		}
+		sort.Strings(types)
+		for _, eventType := range types {
+			fmt.Fprintf(out, "# %d %s events delivered raw only\n", skipped[eventType], eventType)
+		}
+		return replayErr
+	}
+}
+
+// eventTypes creates the value discordgo decodes each gateway event into
+var eventTypes = map[string]func() interface{}{
+	"APPLICATION_COMMAND_PERMISSIONS_UPDATE": func() interface{} { return &discordgo.ApplicationCommandPermissionsUpdate{} },
+	"AUTO_MODERATION_ACTION_EXECUTION":       func() interface{} { return &discordgo.AutoModerationActionExecution{} },
+	"AUTO_MODERATION_RULE_CREATE":            func() interface{} { return &discordgo.AutoModerationRuleCreate{} },
+	"AUTO_MODERATION_RULE_DELETE":            func() interface{} { return &discordgo.AutoModerationRuleDelete{} },
+	"AUTO_MODERATION_RULE_UPDATE":            func() interface{} { return &discordgo.AutoModerationRuleUpdate{} },
+	"CHANNEL_CREATE":                         func() interface{} { return &discordgo.ChannelCreate{} },
+	"CHANNEL_DELETE":                         func() interface{} { return &discordgo.ChannelDelete{} },
+	"CHANNEL_PINS_UPDATE":                    func() interface{} { return &discordgo.ChannelPinsUpdate{} },
+	"CHANNEL_UPDATE":                         func() interface{} { return &discordgo.ChannelUpdate{} },
+	"GUILD_BAN_ADD":                          func() interface{} { return &discordgo.GuildBanAdd{} },
+	"GUILD_BAN_REMOVE":                       func() interface{} { return &discordgo.GuildBanRemove{} },
+	"GUILD_CREATE":                           func() interface{} { return &discordgo.GuildCreate{} },
+	"GUILD_DELETE":                           func() interface{} { return &discordgo.GuildDelete{} },
+	"GUILD_EMOJIS_UPDATE":                    func() interface{} { return &discordgo.GuildEmojisUpdate{} },
+	"GUILD_INTEGRATIONS_UPDATE":              func() interface{} { return &discordgo.GuildIntegrationsUpdate{} },
+	"GUILD_MEMBER_ADD":                       func() interface{} { return &discordgo.GuildMemberAdd{} },
+	"GUILD_MEMBER_REMOVE":                    func() interface{} { return &discordgo.GuildMemberRemove{} },
+	"GUILD_MEMBER_UPDATE":                    func() interface{} { return &discordgo.GuildMemberUpdate{} },
+	"GUILD_MEMBERS_CHUNK":                    func() interface{} { return &discordgo.GuildMembersChunk{} },
+	"GUILD_ROLE_CREATE":                      func() interface{} { return &discordgo.GuildRoleCreate{} },
+	"GUILD_ROLE_DELETE":                      func() interface{} { return &discordgo.GuildRoleDelete{} },
+	"GUILD_ROLE_UPDATE":                      func() interface{} { return &discordgo.GuildRoleUpdate{} },
+	"GUILD_SCHEDULED_EVENT_CREATE":           func() interface{} { return &discordgo.GuildScheduledEventCreate{} },
+	"GUILD_SCHEDULED_EVENT_DELETE":           func() interface{} { return &discordgo.GuildScheduledEventDelete{} },
+	"GUILD_SCHEDULED_EVENT_UPDATE":           func() interface{} { return &discordgo.GuildScheduledEventUpdate{} },
+	"GUILD_SCHEDULED_EVENT_USER_ADD":         func() interface{} { return &discordgo.GuildScheduledEventUserAdd{} },
+	"GUILD_SCHEDULED_EVENT_USER_REMOVE":      func() interface{} { return &discordgo.GuildScheduledEventUserRemove{} },
+	"GUILD_UPDATE":                           func() interface{} { return &discordgo.GuildUpdate{} },
+	"INTERACTION_CREATE":                     func() interface{} { return &discordgo.InteractionCreate{} },
+	"INVITE_CREATE":                          func() interface{} { return &discordgo.InviteCreate{} },
+	"INVITE_DELETE":                          func() interface{} { return &discordgo.InviteDelete{} },
+	"MESSAGE_CREATE":                         func() interface{} { return &discordgo.MessageCreate{} },
+	"MESSAGE_DELETE":                         func() interface{} { return &discordgo.MessageDelete{} },
+	"MESSAGE_DELETE_BULK":                    func() interface{} { return &discordgo.MessageDeleteBulk{} },
+	"MESSAGE_REACTION_ADD":                   func() interface{} { return &discordgo.MessageReactionAdd{} },
+	"MESSAGE_REACTION_REMOVE":                func() interface{} { return &discordgo.MessageReactionRemove{} },
+	"MESSAGE_REACTION_REMOVE_ALL":            func() interface{} { return &discordgo.MessageReactionRemoveAll{} },
+	"MESSAGE_UPDATE":                         func() interface{} { return &discordgo.MessageUpdate{} },
+	"PRESENCE_UPDATE":                        func() interface{} { return &discordgo.PresenceUpdate{} },
+	"PRESENCES_REPLACE":                      func() interface{} { return &discordgo.PresencesReplace{} },
+	"READY":                                  func() interface{} { return &discordgo.Ready{} },
+	"RESUMED":                                func() interface{} { return &discordgo.Resumed{} },
+	"STAGE_INSTANCE_EVENT_CREATE":            func() interface{} { return &discordgo.StageInstanceEventCreate{} },
+	"STAGE_INSTANCE_EVENT_DELETE":            func() interface{} { return &discordgo.StageInstanceEventDelete{} },
+	"STAGE_INSTANCE_EVENT_UPDATE":            func() interface{} { return &discordgo.StageInstanceEventUpdate{} },
+	"THREAD_CREATE":                          func() interface{} { return &discordgo.ThreadCreate{} },
+	"THREAD_DELETE":                          func() interface{} { return &discordgo.ThreadDelete{} },
+	"THREAD_LIST_SYNC":                       func() interface{} { return &discordgo.ThreadListSync{} },
+	"THREAD_MEMBER_UPDATE":                   func() interface{} { return &discordgo.ThreadMemberUpdate{} },
+	"THREAD_MEMBERS_UPDATE":                  func() interface{} { return &discordgo.ThreadMembersUpdate{} },
+	"THREAD_UPDATE":                          func() interface{} { return &discordgo.ThreadUpdate{} },
+	"TYPING_START":                           func() interface{} { return &discordgo.TypingStart{} },
+	"USER_UPDATE":                            func() interface{} { return &discordgo.UserUpdate{} },
+	"VOICE_SERVER_UPDATE":                    func() interface{} { return &discordgo.VoiceServerUpdate{} },
+	"VOICE_STATE_UPDATE":                     func() interface{} { return &discordgo.VoiceStateUpdate{} },
+	"WEBHOOKS_UPDATE":                        func() interface{} { return &discordgo.WebhooksUpdate{} },
+}
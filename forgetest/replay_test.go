@@ -0,0 +1,52 @@
+package forgetest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"discord-bot-forge/commands"
+	"discord-bot-forge/core"
+	"discord-bot-forge/forgetest"
+)
+
+const testRecording = `{"version":1,"started":"2026-01-01T10:00:00Z","prefix":"!"}
+{"t":1000000,"e":"MESSAGE_CREATE","d":{"id":"900000000000000001","channel_id":"300000000000000003","guild_id":"200000000000000002","content":"!help","author":{"id":"400000000000000004","username":"tester"}}}
+{"t":2000000,"e":"SOMETHING_NEW","d":{}}
+`
+
+func TestReplayToolPrintsCalls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(path, []byte(testRecording), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The tool attaches its own fake Discord, so the bot needs no harness
+	config := core.DefaultConfig()
+	config.Token = "forgetest"
+	config.Logging.File = ""
+	bot, err := core.NewBot(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bot.Close() })
+	bot.RegisterCommand(commands.NewHelpCommand(bot))
+
+	var out strings.Builder
+	if err := forgetest.ReplayTool(path, 0, &out)(context.Background(), bot); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("output has %d lines, want a call and a skipped event:\n%s", len(lines), out.String())
+	}
+	if !strings.HasPrefix(lines[0], "POST channels/"+forgetest.ChannelID+"/messages ") || !strings.Contains(lines[0], "DiscordBotForge Commands") {
+		t.Errorf("call = %s, want the help reply", lines[0])
+	}
+	if lines[1] != "# 1 SOMETHING_NEW events delivered raw only" {
+		t.Errorf("skipped line = %q", lines[1])
+	}
+}
@@ -0,0 +1,522 @@
+package forgetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"discord-bot-forge/core"
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+)
+
+// Gateway close codes the server uses
+const (
+	closeAuthenticationFailed = 4004
+	closeDisallowedIntents    = 4014
+)
+
+// Server is a local stand-in for Discord's gateway websocket and REST API,
+// for end-to-end tests that run a real Bot. The embedded Discord answers
+// REST calls and holds the world the bot sees: guilds, channels and members
+// added to it before the bot connects arrive in READY and GUILD_CREATE.
+//
+// NewServer points discordgo's endpoints at the server until the test ends,
+// so tests using a Server must not run in parallel.
+type Server struct {
+	*Discord
+
+	// URL is the server's base URL
+	URL string
+
+	// HeartbeatInterval is the interval sent to clients in HELLO
+	HeartbeatInterval time.Duration
+
+	// Token, when set, is the only bot token accepted on identify
+	Token string
+
+	// DisallowedIntents are intents that close the connection with 4014
+	// when requested, like privileged intents not enabled for the bot
+	DisallowedIntents discordgo.Intent
+
+	t        testing.TB
+	http     *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu          sync.Mutex
+	conns       map[*gatewayConn]bool
+	sessions    map[string]*gatewaySession
+	nextSession int
+	identifies  int
+	resumes     int
+}
+
+// gatewayConn is one websocket connection to the server
+type gatewayConn struct {
+	ws      *websocket.Conn
+	session *gatewaySession
+}
+
+// gatewaySession is an identified gateway session, which outlives its
+// connection so a client can resume it
+type gatewaySession struct {
+	id    string
+	shard [2]int
+	seq   int64
+	log   []gatewayPayload // dispatched events, replayed on resume
+	conn  *gatewayConn
+}
+
+// gatewayPayload is a gateway message
+type gatewayPayload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  int64           `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+// NewServer starts a fake Discord server and points discordgo's endpoints
+// at it. Both are restored when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	world, _ := discordgo.New("")
+	s := &Server{
+		Discord:           newDiscord(world),
+		HeartbeatInterval: 41250 * time.Millisecond,
+		t:                 t,
+		conns:             make(map[*gatewayConn]bool),
+		sessions:          make(map[string]*gatewaySession),
+	}
+
+	s.http = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimSuffix(r.URL.Path, "/") == "/gateway" && websocket.IsWebSocketUpgrade(r) {
+			s.serveGateway(w, r)
+			return
+		}
+		s.Discord.ServeHTTP(w, r)
+	}))
+	s.URL = s.http.URL
+	s.Discord.gatewayURL = "ws" + strings.TrimPrefix(s.URL, "http") + "/gateway"
+
+	restore := overrideEndpoints(s.URL + "/")
+	t.Cleanup(func() {
+		s.Close()
+		restore()
+	})
+	return s
+}
+
+// overrideEndpoints points discordgo's REST endpoints at base and returns a
+// function restoring the previous ones
+func overrideEndpoints(base string) (restore func()) {
+	endpoints := []*string{
+		&discordgo.EndpointDiscord, &discordgo.EndpointAPI, &discordgo.EndpointGuilds,
+		&discordgo.EndpointChannels, &discordgo.EndpointUsers, &discordgo.EndpointGateway,
+		&discordgo.EndpointGatewayBot, &discordgo.EndpointWebhooks, &discordgo.EndpointStickers,
+		&discordgo.EndpointStageInstances, &discordgo.EndpointVoice, &discordgo.EndpointVoiceRegions,
+		&discordgo.EndpointNitroStickersPacks, &discordgo.EndpointGuildCreate, &discordgo.EndpointApplications,
+		&discordgo.EndpointOAuth2, &discordgo.EndpointOAuth2Applications,
+	}
+	saved := make([]string, len(endpoints))
+	for i, endpoint := range endpoints {
+		saved[i] = *endpoint
+	}
+
+	api := base + "api/v" + discordgo.APIVersion + "/"
+	discordgo.EndpointDiscord = base
+	discordgo.EndpointAPI = api
+	discordgo.EndpointGuilds = api + "guilds/"
+	discordgo.EndpointChannels = api + "channels/"
+	discordgo.EndpointUsers = api + "users/"
+	discordgo.EndpointGateway = api + "gateway"
+	discordgo.EndpointGatewayBot = api + "gateway/bot"
+	discordgo.EndpointWebhooks = api + "webhooks/"
+	discordgo.EndpointStickers = api + "stickers/"
+	discordgo.EndpointStageInstances = api + "stage-instances"
+	discordgo.EndpointVoice = api + "voice/"
+	discordgo.EndpointVoiceRegions = api + "voice/regions"
+	discordgo.EndpointNitroStickersPacks = api + "sticker-packs"
+	discordgo.EndpointGuildCreate = api + "guilds"
+	discordgo.EndpointApplications = api + "applications"
+	discordgo.EndpointOAuth2 = api + "oauth2/"
+	discordgo.EndpointOAuth2Applications = api + "oauth2/applications"
+
+	return func() {
+		for i, endpoint := range endpoints {
+			*endpoint = saved[i]
+		}
+	}
+}
+
+// NewBot creates a bot using the default configuration that connects to
+// the server. It is shut down when the test ends.
+func (s *Server) NewBot(options ...func(*core.Config)) *core.Bot {
+	s.t.Helper()
+
+	config := core.DefaultConfig()
+	config.Token = "forgetest"
+	config.Web.Enabled = false
+	config.Logging.File = ""
+	for _, option := range options {
+		option(config)
+	}
+
+	bot, err := core.NewBot(config)
+	if err != nil {
+		s.t.Fatalf("forgetest: creating bot: %v", err)
+	}
+	s.t.Cleanup(func() { bot.Close() })
+	return bot
+}
+
+// StartBot creates a bot with NewBot, opens it and waits until it is ready
+func (s *Server) StartBot(options ...func(*core.Config)) *core.Bot {
+	s.t.Helper()
+
+	bot := s.NewBot(options...)
+	if err := bot.Open(); err != nil {
+		s.t.Fatalf("forgetest: opening bot: %v", err)
+	}
+
+	select {
+	case <-bot.Ready():
+	case <-time.After(waitTimeout):
+		s.t.Fatalf("forgetest: bot was not ready within %v", waitTimeout)
+	}
+	return bot
+}
+
+// SetShardCount sets the shard count recommended by GET /gateway/bot
+func (s *Server) SetShardCount(count int) {
+	s.Discord.mu.Lock()
+	defer s.Discord.mu.Unlock()
+
+	s.Discord.shards = count
+}
+
+// Close disconnects every client and stops the server
+func (s *Server) Close() {
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.ws.Close()
+	}
+	s.mu.Unlock()
+
+	s.http.Close()
+}
+
+// Dispatch sends a gateway event, such as "MESSAGE_CREATE", to the session
+// of the shard that owns the event's guild. Events without a guild go to
+// shard 0. Events for a disconnected session are replayed when it resumes.
+func (s *Server) Dispatch(eventType string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encoding %s: %w", eventType, err)
+	}
+
+	var target struct {
+		GuildID string `json:"guild_id"`
+	}
+	json.Unmarshal(raw, &target)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.shard[0] == guildShard(target.GuildID, session.shard[1]) {
+			s.dispatchTo(session, eventType, raw)
+		}
+	}
+	return nil
+}
+
+// Send posts a message from the default user in the default channel, as if
+// a member had typed it. It returns without waiting for the bot.
+func (s *Server) Send(content string, options ...MessageOption) *discordgo.Message {
+	s.t.Helper()
+
+	m := newMessage(s.newID(), content, options)
+	if err := s.Dispatch("MESSAGE_CREATE", m.Message); err != nil {
+		s.t.Fatalf("forgetest: %v", err)
+	}
+	return m.Message
+}
+
+// SlashCommand builds an application command interaction from the default
+// user in the default channel
+func (s *Server) SlashCommand(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return newSlashCommand(s.newID(), name, options)
+}
+
+// Interact sends an interaction to the bot. It returns without waiting for
+// the bot's response.
+func (s *Server) Interact(i *discordgo.InteractionCreate) {
+	s.t.Helper()
+
+	s.registerInteraction(i.Interaction)
+	if err := s.Dispatch("INTERACTION_CREATE", i.Interaction); err != nil {
+		s.t.Fatalf("forgetest: %v", err)
+	}
+}
+
+// WaitForMessages waits until the bot has created at least n messages and
+// returns them, failing the test after a timeout
+func (s *Server) WaitForMessages(n int) []*discordgo.Message {
+	s.t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		messages := s.Messages()
+		if len(messages) >= n {
+			return messages
+		}
+		if time.Now().After(deadline) {
+			s.t.Fatalf("forgetest: expected %d messages within %v, got %d", n, waitTimeout, len(messages))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Reconnect asks every client to reconnect (op 7); discordgo resumes its
+// session on a new connection
+func (s *Server) Reconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		s.write(conn, gatewayPayload{Op: 7, D: json.RawMessage("null")})
+	}
+}
+
+// Disconnect drops every connection without a close frame, like a network
+// failure. Sessions survive and can be resumed.
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		conn.ws.UnderlyingConn().Close()
+	}
+}
+
+// InvalidateSessions forgets every session, so the next resume is refused
+// with op 9 and the client has to identify again
+func (s *Server) InvalidateSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions = make(map[string]*gatewaySession)
+}
+
+// Identifies returns how many times clients identified
+func (s *Server) Identifies() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.identifies
+}
+
+// Resumes returns how many sessions clients resumed
+func (s *Server) Resumes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.resumes
+}
+
+// Connections returns how many gateway connections are open
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+// serveGateway runs one gateway websocket connection
+func (s *Server) serveGateway(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	conn := &gatewayConn{ws: ws}
+
+	s.mu.Lock()
+	s.conns[conn] = true
+	s.write(conn, gatewayPayload{Op: 10, D: mustJSON(map[string]int64{
+		"heartbeat_interval": s.HeartbeatInterval.Milliseconds(),
+	})})
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		if conn.session != nil && conn.session.conn == conn {
+			conn.session.conn = nil
+		}
+		s.mu.Unlock()
+		ws.Close()
+	}()
+
+	for {
+		var payload gatewayPayload
+		if err := ws.ReadJSON(&payload); err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.handle(conn, payload)
+		s.mu.Unlock()
+	}
+}
+
+// handle answers a client payload; s.mu must be held
+func (s *Server) handle(conn *gatewayConn, payload gatewayPayload) {
+	switch payload.Op {
+	case 1: // heartbeat
+		s.write(conn, gatewayPayload{Op: 11, D: json.RawMessage("null")})
+
+	case 2: // identify
+		var identify struct {
+			Token   string           `json:"token"`
+			Intents discordgo.Intent `json:"intents"`
+			Shard   *[2]int          `json:"shard"`
+		}
+		json.Unmarshal(payload.D, &identify)
+
+		if s.Token != "" && strings.TrimPrefix(identify.Token, "Bot ") != s.Token {
+			s.close(conn, closeAuthenticationFailed, "Authentication failed.")
+			return
+		}
+		if identify.Intents&s.DisallowedIntents != 0 {
+			s.close(conn, closeDisallowedIntents, "Disallowed intent(s).")
+			return
+		}
+
+		shard := [2]int{0, 1}
+		if identify.Shard != nil {
+			shard = *identify.Shard
+		}
+		s.identify(conn, shard)
+
+	case 6: // resume
+		var resume struct {
+			SessionID string `json:"session_id"`
+			Seq       int64  `json:"seq"`
+		}
+		json.Unmarshal(payload.D, &resume)
+
+		session, ok := s.sessions[resume.SessionID]
+		if !ok {
+			s.write(conn, gatewayPayload{Op: 9, D: json.RawMessage("false")})
+			return
+		}
+
+		s.resumes++
+		s.bind(conn, session)
+		for _, missed := range session.log {
+			if missed.S > resume.Seq {
+				s.write(conn, missed)
+			}
+		}
+		s.dispatchTo(session, "RESUMED", json.RawMessage("{}"))
+	}
+}
+
+// identify starts a new session on conn and sends READY followed by a
+// GUILD_CREATE for each guild of the shard; s.mu must be held
+func (s *Server) identify(conn *gatewayConn, shard [2]int) {
+	// A new session replaces the shard's previous one
+	for id, session := range s.sessions {
+		if session.shard == shard {
+			delete(s.sessions, id)
+		}
+	}
+
+	s.identifies++
+	s.nextSession++
+	session := &gatewaySession{id: fmt.Sprintf("session-%d", s.nextSession), shard: shard}
+	s.sessions[session.id] = session
+	s.bind(conn, session)
+
+	state := s.Discord.session.State
+	state.RLock()
+	var guilds []json.RawMessage
+	unavailable := []map[string]interface{}{}
+	for _, guild := range state.Guilds {
+		if guildShard(guild.ID, shard[1]) != shard[0] {
+			continue
+		}
+		unavailable = append(unavailable, map[string]interface{}{"id": guild.ID, "unavailable": true})
+		guilds = append(guilds, mustJSON(guild))
+	}
+	user := mustJSON(state.User)
+	state.RUnlock()
+
+	s.dispatchTo(session, "READY", mustJSON(map[string]interface{}{
+		"v":                9,
+		"user":             user,
+		"session_id":       session.id,
+		"guilds":           unavailable,
+		"private_channels": []interface{}{},
+		"shard":            shard,
+		"application":      map[string]string{"id": BotID},
+	}))
+	for _, guild := range guilds {
+		s.dispatchTo(session, "GUILD_CREATE", guild)
+	}
+}
+
+// bind makes conn the session's current connection; s.mu must be held
+func (s *Server) bind(conn *gatewayConn, session *gatewaySession) {
+	conn.session = session
+	session.conn = conn
+}
+
+// dispatchTo sends an event to a session, logging it for resumes; s.mu
+// must be held
+func (s *Server) dispatchTo(session *gatewaySession, eventType string, data json.RawMessage) {
+	session.seq++
+	payload := gatewayPayload{Op: 0, T: eventType, S: session.seq, D: data}
+	session.log = append(session.log, payload)
+	if session.conn != nil {
+		s.write(session.conn, payload)
+	}
+}
+
+// write sends a payload on conn; s.mu must be held so writes don't interleave
+func (s *Server) write(conn *gatewayConn, payload gatewayPayload) {
+	conn.ws.SetWriteDeadline(time.Now().Add(waitTimeout))
+	conn.ws.WriteJSON(payload)
+}
+
+// close closes conn with a gateway close code; s.mu must be held
+func (s *Server) close(conn *gatewayConn, code int, text string) {
+	message := websocket.FormatCloseMessage(code, text)
+	conn.ws.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	conn.ws.Close()
+}
+
+// guildShard returns the shard that owns a guild; events without a guild
+// belong to shard 0
+func guildShard(guildID string, count int) int {
+	id, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil || count < 1 {
+		return 0
+	}
+	return int((id >> 22) % uint64(count))
+}
+
+func mustJSON(value interface{}) json.RawMessage {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(fmt.Sprintf("forgetest: encoding %T: %v", value, err))
+	}
+	return data
+}
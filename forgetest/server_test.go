@@ -0,0 +1,83 @@
+package forgetest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// eventually fails the test unless cond becomes true within waitTimeout
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerEndpointsHaveNoDoubleSlashes(t *testing.T) {
+	server := NewServer(t)
+
+	for _, endpoint := range []string{
+		discordgo.EndpointVoice, discordgo.EndpointVoiceRegions, discordgo.EndpointNitroStickersPacks,
+		discordgo.EndpointGuilds, discordgo.EndpointOAuth2Applications,
+	} {
+		if !strings.HasPrefix(endpoint, server.URL+"/api/v") {
+			t.Errorf("endpoint %q does not point at the server", endpoint)
+		}
+		if strings.Contains(strings.TrimPrefix(endpoint, "http://"), "//") {
+			t.Errorf("endpoint %q has a double slash", endpoint)
+		}
+	}
+}
+
+func TestServerGatewayIdentifyDisconnectResume(t *testing.T) {
+	server := NewServer(t)
+	bot := server.StartBot()
+
+	if got := server.Identifies(); got != 1 {
+		t.Fatalf("identifies after start = %d, want 1", got)
+	}
+	if got := server.Connections(); got != 1 {
+		t.Fatalf("connections after start = %d, want 1", got)
+	}
+
+	// A dropped connection is resumed without identifying again
+	server.Disconnect()
+	eventually(t, "the session to resume", func() bool { return server.Resumes() == 1 })
+	if got := server.Identifies(); got != 1 {
+		t.Errorf("identifies after resume = %d, want 1", got)
+	}
+
+	// Events sent after the resume still reach the bot
+	received := make(chan string, 1)
+	bot.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		select {
+		case received <- m.Content:
+		default:
+		}
+	})
+	server.Send("after resume")
+	select {
+	case content := <-received:
+		if content != "after resume" {
+			t.Errorf("received %q, want the message sent after the resume", content)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("message sent after the resume never arrived")
+	}
+
+	// Once the session is forgotten the bot has to identify again
+	server.InvalidateSessions()
+	server.Disconnect()
+	eventually(t, "the bot to identify again", func() bool { return server.Identifies() == 2 })
+	if got := server.Resumes(); got != 1 {
+		t.Errorf("resumes after invalidation = %d, want 1", got)
+	}
+}
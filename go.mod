@@ -1,15 +1,19 @@
-module discord-bot-forge
-
-go 1.21
-
-require (
-	github.com/bwmarrin/discordgo v0.27.1
-	github.com/gorilla/mux v1.8.1
-	github.com/gorilla/websocket v1.5.1
-	github.com/joho/godotenv v1.5.1
-)
-
-require (
-	golang.org/x/crypto v0.17.0 // indirect
-	golang.org/x/sys v0.15.0 // indirect
-)
+module discord-bot-forge
+
+go 1.21
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/bwmarrin/discordgo v0.27.1
+	github.com/gorilla/mux v1.8.1
+	github.com/gorilla/websocket v1.5.1
+	github.com/joho/godotenv v1.5.1
+	github.com/tetratelabs/wazero v1.8.2
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+)
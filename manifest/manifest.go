@@ -0,0 +1,225 @@
+// Package manifest builds bots from a YAML manifest declaring which
+// commands, modules and middleware they run, so a bot's composition can
+// change without recompiling:
+//
+//	config: config.yaml
+//	web:
+//	  port: 8081
+//	commands:
+//	  - ping
+//	  - help
+//	  - name: wasm
+//	    enabled: false
+//	modules:
+//	  - Logging
+//	  - name: Recorder
+//	    options:
+//	      path: recordings/bot.jsonl.gz
+//	middleware:
+//	  - Cooldown
+//	guilds:
+//	  "123456789012345678":
+//	    prefix: "?"
+//
+// Components are created by the factories of a Registry, which knows the
+// built-in ones and can be extended with an application's own.
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"discord-bot-forge/core"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest declares how a bot is composed
+type Manifest struct {
+	// Config is the config file to load unless -config or CONFIG_FILE is
+	// given, relative to the manifest
+	Config string `yaml:"config"`
+
+	// Defaults are configuration values, with the config file's keys,
+	// applied before the config file, environment and flags
+	Defaults map[string]interface{} `yaml:"defaults"`
+
+	// Web sets defaults for the web interface the same way
+	Web Web `yaml:"web"`
+
+	Commands   []Component `yaml:"commands"`
+	Modules    []Component `yaml:"modules"`
+	Middleware []Component `yaml:"middleware"` // in processing order
+
+	// Guilds maps guild IDs to setting overrides, e.g. prefix or
+	// disabled_commands, applied when the bot is built
+	Guilds map[string]map[string]interface{} `yaml:"guilds"`
+
+	dir string
+}
+
+// Web configures the web interface
+type Web struct {
+	Enabled *bool  `yaml:"enabled"`
+	Bind    string `yaml:"bind"`
+	Port    int    `yaml:"port"`
+}
+
+// Component enables one command, module or middleware. In YAML it is either
+// a name or a mapping with options.
+type Component struct {
+	Name    string  `yaml:"name"`
+	Enabled *bool   `yaml:"enabled"` // defaults to true
+	Options Options `yaml:"options"`
+}
+
+// UnmarshalYAML accepts a bare name as well as the full mapping
+func (c *Component) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		c.Name = node.Value
+		return nil
+	}
+
+	type plain Component
+	return node.Decode((*plain)(c))
+}
+
+// IsEnabled reports whether the component should be created
+func (c Component) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// Options are a component's free-form settings from the manifest
+type Options map[string]interface{}
+
+// Decode decodes the options into v, typically a pointer to a struct with
+// json tags. Missing options leave v untouched.
+func (o Options) Decode(v interface{}) error {
+	if len(o) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("error encoding options: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("error decoding options: %w", err)
+	}
+	return nil
+}
+
+// Load reads a manifest file
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+
+	manifest, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	manifest.dir = filepath.Dir(path)
+	return manifest, nil
+}
+
+// Parse decodes and checks a manifest. Relative paths in it are resolved
+// against the working directory.
+func Parse(data []byte) (*Manifest, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	manifest := &Manifest{}
+	if err := decoder.Decode(manifest); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing manifest: %w", err)
+	}
+	if err := manifest.validate(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// validate rejects unnamed and duplicate components
+func (m *Manifest) validate() error {
+	var errs []error
+	check := func(kind string, components []Component) {
+		seen := make(map[string]bool)
+		for i, component := range components {
+			switch {
+			case component.Name == "":
+				errs = append(errs, fmt.Errorf("%s[%d] has no name", kind, i))
+			case seen[component.Name]:
+				errs = append(errs, fmt.Errorf("%s %s is listed more than once", kind, component.Name))
+			}
+			seen[component.Name] = true
+		}
+	}
+	check("commands", m.Commands)
+	check("modules", m.Modules)
+	check("middleware", m.Middleware)
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid manifest: %w", err)
+	}
+	return nil
+}
+
+// LoadConfig builds the bot's configuration. The manifest's defaults, web
+// settings and module options are applied first, then the config file,
+// environment variables and args as in core.LoadConfig.
+func (m *Manifest) LoadConfig(args []string) (*core.Config, error) {
+	config := core.DefaultConfig()
+
+	if len(m.Defaults) > 0 {
+		data, err := json.Marshal(m.Defaults)
+		if err != nil {
+			return nil, fmt.Errorf("error applying manifest defaults: %w", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("error applying manifest defaults: %w", err)
+		}
+	}
+
+	if m.Web.Enabled != nil {
+		config.Web.Enabled = *m.Web.Enabled
+	}
+	if m.Web.Bind != "" {
+		config.Web.Bind = m.Web.Bind
+	}
+	if m.Web.Port != 0 {
+		config.Web.Port = m.Web.Port
+	}
+
+	// Module options become the module's settings, which a config file
+	// can still replace
+	for _, module := range m.Modules {
+		if module.IsEnabled() && len(module.Options) > 0 {
+			config.Modules[module.Name] = module.Options
+		}
+	}
+
+	if m.Config != "" {
+		if _, set := os.LookupEnv("CONFIG_FILE"); !set {
+			args = append([]string{"-config", m.path(m.Config)}, args...)
+		}
+	}
+
+	if err := core.LoadConfigInto(config, args); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// path resolves a path relative to the manifest
+func (m *Manifest) path(path string) string {
+	if m.dir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(m.dir, path)
+}
@@ -0,0 +1,149 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"discord-bot-forge/commands"
+	"discord-bot-forge/core"
+	"discord-bot-forge/modules"
+)
+
+// CommandFactory creates a command from its manifest options
+type CommandFactory func(bot *core.Bot, options Options) (core.Command, error)
+
+// ModuleFactory creates a module from its manifest options. The options are
+// also the module's settings, so modules that read them with
+// Config.DecodeModuleSettings can ignore them here.
+type ModuleFactory func(bot *core.Bot, options Options) (core.Module, error)
+
+// MiddlewareFactory creates a middleware from its manifest options
+type MiddlewareFactory func(bot *core.Bot, options Options) (core.Middleware, error)
+
+// Registry maps manifest names to the factories creating components.
+// Modules should be registered under their Name so their options reach
+// them as settings.
+type Registry struct {
+	commands   map[string]CommandFactory
+	modules    map[string]ModuleFactory
+	middleware map[string]MiddlewareFactory
+}
+
+// NewRegistry creates a registry that knows the built-in commands, modules
+// and middleware
+func NewRegistry() *Registry {
+	r := &Registry{
+		commands:   make(map[string]CommandFactory),
+		modules:    make(map[string]ModuleFactory),
+		middleware: make(map[string]MiddlewareFactory),
+	}
+
+	r.RegisterCommand("ping", func(bot *core.Bot, options Options) (core.Command, error) {
+		return &commands.PingCommand{}, nil
+	})
+	r.RegisterCommand("help", func(bot *core.Bot, options Options) (core.Command, error) {
+		return commands.NewHelpCommand(bot), nil
+	})
+	r.RegisterCommand("info", func(bot *core.Bot, options Options) (core.Command, error) {
+		return commands.NewInfoCommand(bot), nil
+	})
+	r.RegisterCommand("config", func(bot *core.Bot, options Options) (core.Command, error) {
+		return commands.NewConfigCommand(bot), nil
+	})
+	r.RegisterCommand("customcmd", func(bot *core.Bot, options Options) (core.Command, error) {
+		return commands.NewCustomCmdCommand(bot), nil
+	})
+	r.RegisterCommand("wasm", func(bot *core.Bot, options Options) (core.Command, error) {
+		return commands.NewWasmCommand(bot), nil
+	})
+	r.RegisterCommand("audit", func(bot *core.Bot, options Options) (core.Command, error) {
+		return commands.NewAuditCommand(bot), nil
+	})
+
+	r.RegisterModule("Logging", func(bot *core.Bot, options Options) (core.Module, error) {
+		return modules.NewLoggingModule(bot.Config().Logging.File), nil
+	})
+	r.RegisterModule("Statistics", func(bot *core.Bot, options Options) (core.Module, error) {
+		return modules.NewStatsModule(), nil
+	})
+	r.RegisterModule("Recorder", func(bot *core.Bot, options Options) (core.Module, error) {
+		return modules.NewRecorderModule(), nil
+	})
+	r.RegisterModule("Audit", func(bot *core.Bot, options Options) (core.Module, error) {
+		return modules.NewAuditModule(), nil
+	})
+
+	r.RegisterMiddleware("Cooldown", func(bot *core.Bot, options Options) (core.Middleware, error) {
+		// The duration is the cooldown setting, so config reloads apply to it
+		return core.NewCooldownMiddleware(bot.Config().Cooldown.Duration()), nil
+	})
+	r.RegisterMiddleware("Logging", func(bot *core.Bot, options Options) (core.Middleware, error) {
+		return core.NewLoggingMiddleware(), nil
+	})
+	r.RegisterMiddleware("Permission", func(bot *core.Bot, options Options) (core.Middleware, error) {
+		var settings struct {
+			Permissions []string `json:"permissions"`
+		}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		return core.NewPermissionMiddleware(settings.Permissions), nil
+	})
+	r.RegisterMiddleware("OwnerOnly", func(bot *core.Bot, options Options) (core.Middleware, error) {
+		settings := struct {
+			OwnerID string `json:"owner_id"`
+		}{OwnerID: bot.Config().OwnerID}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		return core.NewOwnerOnlyMiddleware(settings.OwnerID), nil
+	})
+
+	return r
+}
+
+// RegisterCommand adds a command factory, replacing any with the same name
+func (r *Registry) RegisterCommand(name string, factory CommandFactory) {
+	r.commands[name] = factory
+}
+
+// RegisterModule adds a module factory, replacing any with the same name
+func (r *Registry) RegisterModule(name string, factory ModuleFactory) {
+	r.modules[name] = factory
+}
+
+// RegisterMiddleware adds a middleware factory, replacing any with the same
+// name
+func (r *Registry) RegisterMiddleware(name string, factory MiddlewareFactory) {
+	r.middleware[name] = factory
+}
+
+// Commands returns the names of the registered command factories
+func (r *Registry) Commands() []string {
+	return sortedKeys(r.commands)
+}
+
+// Modules returns the names of the registered module factories
+func (r *Registry) Modules() []string {
+	return sortedKeys(r.modules)
+}
+
+// Middleware returns the names of the registered middleware factories
+func (r *Registry) Middleware() []string {
+	return sortedKeys(r.middleware)
+}
+
+// unknownError reports a manifest name without a factory
+func unknownError(kind, name string, known []string) error {
+	return fmt.Errorf("unknown %s %q (available: %s)", kind, name, strings.Join(known, ", "))
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
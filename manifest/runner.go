@@ -0,0 +1,155 @@
+package manifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"discord-bot-forge/core"
+	"discord-bot-forge/web"
+	"github.com/joho/godotenv"
+)
+
+// ManifestEnv names the environment variable pointing Main at a manifest
+// file
+const ManifestEnv = "MANIFEST_FILE"
+
+// Build creates a bot with the manifest's components and applies its guild
+// settings as defaults, which values changed with the config command or the
+// dashboard override
+func (m *Manifest) Build(config *core.Config, registry *Registry) (*core.Bot, error) {
+	bot, err := core.NewBot(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating bot: %w", err)
+	}
+
+	for _, component := range m.Commands {
+		if !component.IsEnabled() {
+			continue
+		}
+		factory, ok := registry.commands[component.Name]
+		if !ok {
+			return nil, unknownError("command", component.Name, registry.Commands())
+		}
+		cmd, err := factory(bot, component.Options)
+		if err != nil {
+			return nil, fmt.Errorf("error creating command %s: %w", component.Name, err)
+		}
+		bot.RegisterCommand(cmd)
+	}
+
+	for _, component := range m.Modules {
+		if !component.IsEnabled() {
+			continue
+		}
+		factory, ok := registry.modules[component.Name]
+		if !ok {
+			return nil, unknownError("module", component.Name, registry.Modules())
+		}
+		module, err := factory(bot, component.Options)
+		if err != nil {
+			return nil, fmt.Errorf("error creating module %s: %w", component.Name, err)
+		}
+		bot.RegisterModule(module)
+	}
+
+	for _, component := range m.Middleware {
+		if !component.IsEnabled() {
+			continue
+		}
+		factory, ok := registry.middleware[component.Name]
+		if !ok {
+			return nil, unknownError("middleware", component.Name, registry.Middleware())
+		}
+		middleware, err := factory(bot, component.Options)
+		if err != nil {
+			return nil, fmt.Errorf("error creating middleware %s: %w", component.Name, err)
+		}
+		bot.AddMiddleware(middleware)
+	}
+
+	// Guild settings are applied last so settings declared by the components
+	// above can be given defaults too. They are not persisted, so removing
+	// one from the manifest takes effect on the next start.
+	for _, guildID := range sortedKeys(m.Guilds) {
+		settings := bot.GuildSettings(guildID)
+		overrides := m.Guilds[guildID]
+		for _, key := range sortedKeys(overrides) {
+			if err := settings.SetDefault(key, settingValue(overrides[key])); err != nil {
+				return nil, fmt.Errorf("error applying guild %s setting %s: %w", guildID, key, err)
+			}
+		}
+	}
+
+	return bot, nil
+}
+
+// settingValue renders a manifest value the way a user would enter it
+func settingValue(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// Run builds the bot, starts the web interface when enabled and runs the bot
+// until SIGINT or SIGTERM; SIGHUP reloads the configuration. Args are
+// configuration flags, usually os.Args[1:]. Under a forge tool the web
+// interface is not started.
+func (m *Manifest) Run(registry *Registry, args []string) error {
+	config, err := m.LoadConfig(args)
+	if err != nil {
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	bot, err := m.Build(config, registry)
+	if err != nil {
+		return err
+	}
+
+	if config.Web.Enabled && !core.ToolRunning() {
+		webServer := web.NewWebServerWithAddr(bot, config.Web.Addr())
+		go func() {
+			if err := webServer.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				bot.Logger("web").Error("web server error", "error", err)
+			}
+		}()
+		bot.AddShutdownHook("web server", webServer.Shutdown)
+	}
+
+	ctx, stop := bot.SignalContext(context.Background())
+	defer stop()
+
+	return bot.Run(ctx)
+}
+
+// Main runs a bot for a main function. It loads .env, then the manifest
+// file named by MANIFEST_FILE, or fallback when that is unset, and runs it
+// with the command-line flags.
+func Main(registry *Registry, fallback []byte) error {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system environment variables")
+	}
+
+	var manifest *Manifest
+	var err error
+	if path := os.Getenv(ManifestEnv); path != "" {
+		manifest, err = Load(path)
+		log.Printf("📜 Using manifest %s", path)
+	} else {
+		manifest, err = Parse(fallback)
+	}
+	if err != nil {
+		return err
+	}
+
+	return manifest.Run(registry, os.Args[1:])
+}
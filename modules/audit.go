@@ -0,0 +1,735 @@
+package modules
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"discord-bot-forge/core"
+	"github.com/bwmarrin/discordgo"
+)
+
+// AuditSettings configures the audit log under modules.Audit
+type AuditSettings struct {
+	// CacheSize is how many messages, members and channels are remembered
+	// so edits, deletions and updates can show what they looked like
+	// before; 5000 of each by default
+	CacheSize int `json:"cache_size"`
+
+	// Retention is how long entries are kept for searching; 30 days by
+	// default
+	Retention core.Duration `json:"retention"`
+
+	// IncludeBots also audits messages written by other bots
+	IncludeBots bool `json:"include_bots"`
+}
+
+// AuditModule keeps a per-guild audit log of message edits and deletions,
+// member joins, leaves, nickname and role changes, and channel changes.
+// Entries are posted as embeds to the guild's log_channel and stored for
+// Search.
+type AuditModule struct {
+	bot      *core.Bot
+	log      *slog.Logger
+	settings AuditSettings
+	messages *boundedCache[auditMessage]
+	members  *boundedCache[auditMember]
+	channels *boundedCache[string]
+	store    *auditStore
+	stop     chan struct{}
+	done     chan struct{}
+	version  string
+}
+
+// auditMessage is what the cache remembers about a message
+type auditMessage struct {
+	ChannelID   string
+	AuthorID    string
+	Author      string
+	Content     string
+	Attachments []string
+}
+
+// auditMember is what the cache remembers about a member
+type auditMember struct {
+	Nick  string
+	Roles []string
+}
+
+// NewAuditModule creates a new audit log module
+func NewAuditModule() *AuditModule {
+	return &AuditModule{
+		version: "1.0.0",
+	}
+}
+
+func (a *AuditModule) Name() string {
+	return "Audit"
+}
+
+func (a *AuditModule) Version() string {
+	return a.version
+}
+
+// RequiredIntents declares the message, member and channel events the
+// module audits. Guild members is a privileged intent.
+func (a *AuditModule) RequiredIntents() discordgo.Intent {
+	return discordgo.IntentsGuilds |
+		discordgo.IntentsGuildMessages |
+		discordgo.IntentsGuildMembers |
+		discordgo.IntentMessageContent
+}
+
+func (a *AuditModule) Initialize(bot *core.Bot) error {
+	settings := AuditSettings{
+		CacheSize: 5000,
+		Retention: core.Duration(30 * 24 * time.Hour),
+	}
+	if err := bot.Config().DecodeModuleSettings(a.Name(), &settings); err != nil {
+		return err
+	}
+	if settings.CacheSize <= 0 {
+		return fmt.Errorf("modules.Audit.cache_size must be positive")
+	}
+
+	a.bot = bot
+	a.log = bot.Logger("audit")
+	a.settings = settings
+	a.messages = newBoundedCache[auditMessage](settings.CacheSize)
+	a.members = newBoundedCache[auditMember](settings.CacheSize)
+	a.channels = newBoundedCache[string](settings.CacheSize)
+	a.store = newAuditStore(bot.Storage, settings.Retention.Duration(), a.log)
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go a.store.run(a.stop, a.done)
+
+	bot.AddModuleHandler(a, a.onGuildCreate)
+	bot.AddModuleHandler(a, a.onMessageCreate)
+	bot.AddModuleHandler(a, a.onMessageUpdate)
+	bot.AddModuleHandler(a, a.onMessageDelete)
+	bot.AddModuleHandler(a, a.onMessageDeleteBulk)
+	bot.AddModuleHandler(a, a.onMemberAdd)
+	bot.AddModuleHandler(a, a.onMemberUpdate)
+	bot.AddModuleHandler(a, a.onMemberRemove)
+	bot.AddModuleHandler(a, a.onChannelCreate)
+	bot.AddModuleHandler(a, a.onChannelUpdate)
+	bot.AddModuleHandler(a, a.onChannelDelete)
+
+	a.log.Info("audit log started", "cache_size", settings.CacheSize, "retention", settings.Retention.Duration())
+	return nil
+}
+
+// Shutdown stores the entries that are still pending
+func (a *AuditModule) Shutdown() error {
+	close(a.stop)
+	<-a.done
+	return a.store.flush()
+}
+
+// Search returns a guild's stored entries matching query, newest first
+func (a *AuditModule) Search(guildID string, query AuditQuery) ([]AuditEntry, error) {
+	return a.store.search(guildID, query)
+}
+
+// record stores an entry and posts it to the guild's log channel
+func (a *AuditModule) record(entry AuditEntry) {
+	if !a.bot.GuildSettings(entry.GuildID).ModuleEnabled(a.Name()) {
+		return
+	}
+	entry.Time = time.Now().UTC()
+	a.store.add(entry)
+
+	a.log.Debug("audit entry", core.LogKeyGuild, entry.GuildID, "type", entry.Type, core.LogKeyUser, entry.UserID)
+
+	channelID := a.bot.GuildSettings(entry.GuildID).String(core.SettingLogChannel)
+	if channelID == "" {
+		return
+	}
+	a.bot.Messages.Enqueue(channelID, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{auditEmbed(entry)},
+		// Log embeds must not ping the members they mention
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}, core.WithPriority(core.PriorityLow))
+}
+
+// audited reports whether messages by author belong in the audit log
+func (a *AuditModule) audited(s *discordgo.Session, guildID string, author *discordgo.User) bool {
+	if guildID == "" || author == nil {
+		return false
+	}
+	if s.State != nil && s.State.User != nil && author.ID == s.State.User.ID {
+		return false
+	}
+	return a.settings.IncludeBots || !author.Bot
+}
+
+// onGuildCreate remembers the guild's channels and the members Discord
+// sent along, so their first update can be compared
+func (a *AuditModule) onGuildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
+	if g.Guild == nil {
+		return
+	}
+	for _, channel := range g.Channels {
+		a.channels.put(channel.ID, describeChannel(channel))
+	}
+	for _, member := range g.Members {
+		if member.User != nil {
+			a.members.put(memberKey(g.ID, member.User.ID), newAuditMember(member))
+		}
+	}
+}
+
+func (a *AuditModule) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if !a.audited(s, m.GuildID, m.Author) {
+		return
+	}
+	a.messages.put(m.ID, newAuditMessage(m.Message))
+}
+
+func (a *AuditModule) onMessageUpdate(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	// Embeds being unfurled also update a message, without an edit time
+	if m.EditedTimestamp == nil {
+		return
+	}
+
+	before, cached := a.messages.get(m.ID)
+	if !cached && m.BeforeUpdate != nil {
+		before, cached = newAuditMessage(m.BeforeUpdate), true
+	}
+	author := m.Author
+	if author == nil && cached {
+		author = &discordgo.User{ID: before.AuthorID, Username: before.Author}
+	}
+	if !a.audited(s, m.GuildID, author) {
+		return
+	}
+
+	after := newAuditMessage(m.Message)
+	after.AuthorID, after.Author = author.ID, author.Username
+	a.messages.put(m.ID, after)
+	if cached && before.Content == after.Content {
+		return
+	}
+
+	entry := AuditEntry{
+		GuildID:   m.GuildID,
+		Type:      AuditMessageEdit,
+		UserID:    author.ID,
+		Username:  author.Username,
+		ChannelID: m.ChannelID,
+		MessageID: m.ID,
+		Summary:   fmt.Sprintf("Message by <@%s> edited in <#%s>", author.ID, m.ChannelID),
+		After:     after.Content,
+	}
+	if cached {
+		entry.Before = before.Content
+	} else {
+		entry.Summary += " (earlier content not cached)"
+	}
+	a.record(entry)
+}
+
+func (a *AuditModule) onMessageDelete(s *discordgo.Session, m *discordgo.MessageDelete) {
+	if m.GuildID == "" {
+		return
+	}
+
+	before, cached := a.messages.remove(m.ID)
+	if !cached && m.BeforeDelete != nil {
+		if !a.audited(s, m.GuildID, m.BeforeDelete.Author) {
+			return
+		}
+		before, cached = newAuditMessage(m.BeforeDelete), true
+	}
+	if !cached {
+		a.record(AuditEntry{
+			GuildID:   m.GuildID,
+			Type:      AuditMessageDelete,
+			ChannelID: m.ChannelID,
+			MessageID: m.ID,
+			Summary:   fmt.Sprintf("Message `%s` deleted in <#%s> (content not cached)", m.ID, m.ChannelID),
+		})
+		return
+	}
+	a.record(AuditEntry{
+		GuildID:   m.GuildID,
+		Type:      AuditMessageDelete,
+		UserID:    before.AuthorID,
+		Username:  before.Author,
+		ChannelID: m.ChannelID,
+		MessageID: m.ID,
+		Summary:   fmt.Sprintf("Message by <@%s> deleted in <#%s>", before.AuthorID, m.ChannelID),
+		Before:    before.text(),
+	})
+}
+
+func (a *AuditModule) onMessageDeleteBulk(s *discordgo.Session, m *discordgo.MessageDeleteBulk) {
+	if m.GuildID == "" {
+		return
+	}
+
+	var lines []string
+	for _, id := range m.Messages {
+		if before, cached := a.messages.remove(id); cached {
+			lines = append(lines, fmt.Sprintf("%s: %s", before.Author, before.text()))
+		}
+	}
+
+	summary := fmt.Sprintf("%d messages deleted in <#%s>", len(m.Messages), m.ChannelID)
+	if len(lines) < len(m.Messages) {
+		summary += fmt.Sprintf(" (%d cached)", len(lines))
+	}
+	a.record(AuditEntry{
+		GuildID:   m.GuildID,
+		Type:      AuditMessageBulkDelete,
+		ChannelID: m.ChannelID,
+		Count:     len(m.Messages),
+		Summary:   summary,
+		Before:    strings.Join(lines, "\n"),
+	})
+}
+
+func (a *AuditModule) onMemberAdd(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+	if m.Member == nil || m.User == nil {
+		return
+	}
+	a.members.put(memberKey(m.GuildID, m.User.ID), newAuditMember(m.Member))
+
+	summary := fmt.Sprintf("<@%s> joined", m.User.ID)
+	if created, err := discordgo.SnowflakeTimestamp(m.User.ID); err == nil {
+		summary += fmt.Sprintf(", account created <t:%d:R>", created.Unix())
+	}
+	a.record(AuditEntry{
+		GuildID:  m.GuildID,
+		Type:     AuditMemberJoin,
+		UserID:   m.User.ID,
+		Username: m.User.Username,
+		Summary:  summary,
+	})
+}
+
+func (a *AuditModule) onMemberUpdate(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+	if m.Member == nil || m.User == nil {
+		return
+	}
+	key := memberKey(m.GuildID, m.User.ID)
+	after := newAuditMember(m.Member)
+
+	before, cached := a.members.get(key)
+	if !cached && m.BeforeUpdate != nil {
+		before, cached = newAuditMember(m.BeforeUpdate), true
+	}
+	a.members.put(key, after)
+	// Without an earlier snapshot there is nothing to compare
+	if !cached {
+		return
+	}
+
+	if before.Nick != after.Nick {
+		a.record(AuditEntry{
+			GuildID:  m.GuildID,
+			Type:     AuditMemberNickname,
+			UserID:   m.User.ID,
+			Username: m.User.Username,
+			Summary:  fmt.Sprintf("<@%s> changed nickname", m.User.ID),
+			Before:   nickOrNone(before.Nick),
+			After:    nickOrNone(after.Nick),
+		})
+	}
+
+	added, removed := diffRoles(before.Roles, after.Roles)
+	if len(added) > 0 || len(removed) > 0 {
+		var changes []string
+		for _, role := range added {
+			changes = append(changes, fmt.Sprintf("+ <@&%s>", role))
+		}
+		for _, role := range removed {
+			changes = append(changes, fmt.Sprintf("- <@&%s>", role))
+		}
+		a.record(AuditEntry{
+			GuildID:  m.GuildID,
+			Type:     AuditMemberRoles,
+			UserID:   m.User.ID,
+			Username: m.User.Username,
+			Summary:  fmt.Sprintf("<@%s> roles changed: %s", m.User.ID, strings.Join(changes, ", ")),
+		})
+	}
+}
+
+func (a *AuditModule) onMemberRemove(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+	if m.Member == nil || m.User == nil {
+		return
+	}
+	before, cached := a.members.remove(memberKey(m.GuildID, m.User.ID))
+
+	entry := AuditEntry{
+		GuildID:  m.GuildID,
+		Type:     AuditMemberLeave,
+		UserID:   m.User.ID,
+		Username: m.User.Username,
+		Summary:  fmt.Sprintf("<@%s> (%s) left", m.User.ID, escapeMarkdown(m.User.Username)),
+	}
+	if cached && len(before.Roles) > 0 {
+		roles := make([]string, len(before.Roles))
+		for i, role := range before.Roles {
+			roles[i] = fmt.Sprintf("<@&%s>", role)
+		}
+		entry.Before = "Roles: " + strings.Join(roles, " ")
+	}
+	a.record(entry)
+}
+
+func (a *AuditModule) onChannelCreate(s *discordgo.Session, c *discordgo.ChannelCreate) {
+	if c.Channel == nil || c.GuildID == "" {
+		return
+	}
+	a.channels.put(c.ID, describeChannel(c.Channel))
+	a.record(AuditEntry{
+		GuildID:   c.GuildID,
+		Type:      AuditChannelCreate,
+		ChannelID: c.ID,
+		Summary:   fmt.Sprintf("Channel <#%s> (%s) created", c.ID, escapeMarkdown(c.Name)),
+	})
+}
+
+func (a *AuditModule) onChannelUpdate(s *discordgo.Session, c *discordgo.ChannelUpdate) {
+	if c.Channel == nil || c.GuildID == "" {
+		return
+	}
+	entry := AuditEntry{
+		GuildID:   c.GuildID,
+		Type:      AuditChannelUpdate,
+		ChannelID: c.ID,
+		Summary:   fmt.Sprintf("Channel <#%s> updated", c.ID),
+	}
+	after := describeChannel(c.Channel)
+	if before, cached := a.channels.get(c.ID); cached {
+		// Reordering channels updates every channel below the moved one
+		if before == after {
+			return
+		}
+		entry.Before, entry.After = before, after
+	}
+	a.channels.put(c.ID, after)
+	a.record(entry)
+}
+
+func (a *AuditModule) onChannelDelete(s *discordgo.Session, c *discordgo.ChannelDelete) {
+	if c.Channel == nil || c.GuildID == "" {
+		return
+	}
+	a.channels.remove(c.ID)
+	a.record(AuditEntry{
+		GuildID:   c.GuildID,
+		Type:      AuditChannelDelete,
+		ChannelID: c.ID,
+		Summary:   fmt.Sprintf("Channel #%s deleted", escapeMarkdown(c.Name)),
+		Before:    describeChannel(c.Channel),
+	})
+}
+
+func newAuditMessage(m *discordgo.Message) auditMessage {
+	message := auditMessage{
+		ChannelID: m.ChannelID,
+		Content:   m.Content,
+	}
+	if m.Author != nil {
+		message.AuthorID, message.Author = m.Author.ID, m.Author.Username
+	}
+	for _, attachment := range m.Attachments {
+		message.Attachments = append(message.Attachments, attachment.Filename)
+	}
+	return message
+}
+
+// text is the message content followed by its attachments' names
+func (m auditMessage) text() string {
+	text := m.Content
+	for _, name := range m.Attachments {
+		text += fmt.Sprintf("\n[attachment: %s]", name)
+	}
+	return strings.TrimPrefix(text, "\n")
+}
+
+func newAuditMember(m *discordgo.Member) auditMember {
+	return auditMember{
+		Nick:  m.Nick,
+		Roles: append([]string(nil), m.Roles...),
+	}
+}
+
+// nickOrNone makes a cleared nickname visible in the log
+func nickOrNone(nick string) string {
+	if nick == "" {
+		return "(none)"
+	}
+	return nick
+}
+
+func memberKey(guildID, userID string) string {
+	return guildID + ":" + userID
+}
+
+// diffRoles returns the roles only in after and only in before
+func diffRoles(before, after []string) (added, removed []string) {
+	had := make(map[string]bool, len(before))
+	for _, role := range before {
+		had[role] = true
+	}
+	has := make(map[string]bool, len(after))
+	for _, role := range after {
+		has[role] = true
+		if !had[role] {
+			added = append(added, role)
+		}
+	}
+	for _, role := range before {
+		if !has[role] {
+			removed = append(removed, role)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// describeChannel lists the channel properties worth auditing, one per line
+func describeChannel(c *discordgo.Channel) string {
+	lines := []string{"Name: " + c.Name}
+	if c.Topic != "" {
+		lines = append(lines, "Topic: "+c.Topic)
+	}
+	if c.NSFW {
+		lines = append(lines, "NSFW: yes")
+	}
+	if c.RateLimitPerUser > 0 {
+		lines = append(lines, fmt.Sprintf("Slowmode: %ds", c.RateLimitPerUser))
+	}
+	if c.ParentID != "" {
+		lines = append(lines, fmt.Sprintf("Category: <#%s>", c.ParentID))
+	}
+	lines = append(lines, fmt.Sprintf("Permission overwrites: %d", len(c.PermissionOverwrites)))
+	return strings.Join(lines, "\n")
+}
+
+// auditEmbedStyle is the title and color of an entry type's embed
+var auditEmbedStyle = map[AuditEventType]struct {
+	title string
+	color int
+}{
+	AuditMessageEdit:       {"✏️ Message Edited", 0xf1c40f},
+	AuditMessageDelete:     {"🗑️ Message Deleted", 0xe74c3c},
+	AuditMessageBulkDelete: {"🧹 Messages Bulk Deleted", 0xc0392b},
+	AuditMemberJoin:        {"📥 Member Joined", 0x2ecc71},
+	AuditMemberLeave:       {"📤 Member Left", 0x95a5a6},
+	AuditMemberNickname:    {"🏷️ Nickname Changed", 0x3498db},
+	AuditMemberRoles:       {"🎭 Roles Changed", 0x9b59b6},
+	AuditChannelCreate:     {"📁 Channel Created", 0x2ecc71},
+	AuditChannelUpdate:     {"🛠️ Channel Updated", 0x3498db},
+	AuditChannelDelete:     {"🗑️ Channel Deleted", 0xe74c3c},
+}
+
+// auditEmbed formats an entry for the log channel. Edits show removed
+// words struck through and added words in bold.
+func auditEmbed(entry AuditEntry) *discordgo.MessageEmbed {
+	style := auditEmbedStyle[entry.Type]
+	embed := &discordgo.MessageEmbed{
+		Title:       style.title,
+		Description: entry.Summary,
+		Color:       style.color,
+		Timestamp:   entry.Time.Format(time.RFC3339),
+	}
+	if entry.MessageID != "" && entry.Type == AuditMessageEdit {
+		embed.Description += fmt.Sprintf("\n[Jump to message](https://discord.com/channels/%s/%s/%s)", entry.GuildID, entry.ChannelID, entry.MessageID)
+	}
+
+	before, after := auditFields(entry)
+	name := "Before"
+	if entry.Type == AuditMessageBulkDelete {
+		name = "Messages"
+	}
+	if before != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: name, Value: before})
+	}
+	if after != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "After", Value: after})
+	}
+	if entry.UserID != "" {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: "User ID: " + entry.UserID}
+	}
+	return embed
+}
+
+// auditFieldLimit is the most characters Discord shows in an embed field
+const auditFieldLimit = 1024
+
+// auditFields formats an entry's before and after text for embed fields.
+// The raw text is shortened before it is escaped or marked, and shortened
+// further until the result fits, so a cut never splits an escape or marker.
+func auditFields(entry AuditEntry) (string, string) {
+	format := func(before, after string) (string, string) {
+		return escapeMarkdown(before), escapeMarkdown(after)
+	}
+	if entry.Type == AuditMessageEdit && entry.Before != "" {
+		format = markWordDiff
+	}
+
+	limits := [2]int{auditFieldLimit, auditFieldLimit}
+	for {
+		before, after := format(truncateRunes(entry.Before, limits[0]), truncateRunes(entry.After, limits[1]))
+		fits := true
+		for i, text := range [2]string{before, after} {
+			if count := utf8.RuneCountInString(text); count > auditFieldLimit {
+				limits[i] = max(1, min(limits[i]-1, limits[i]*auditFieldLimit/count))
+				fits = false
+			}
+		}
+		if fits {
+			return before, after
+		}
+	}
+}
+
+// markdownSpecial matches the characters Discord treats as formatting
+var markdownSpecial = regexp.MustCompile("([\\\\*_~`|])")
+
+func escapeMarkdown(text string) string {
+	return markdownSpecial.ReplaceAllString(text, `\$1`)
+}
+
+// truncateRunes shortens text to at most limit characters
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// diffTokens splits text into words and the whitespace between them
+var diffTokens = regexp.MustCompile(`\s+|\S+`)
+
+// maxDiffTokens bounds the word diff, which is quadratic in message length
+const maxDiffTokens = 400
+
+// markWordDiff returns before with removed words struck through and after
+// with added words in bold. Very long messages are returned unmarked.
+func markWordDiff(before, after string) (string, string) {
+	a := diffTokens.FindAllString(before, -1)
+	b := diffTokens.FindAllString(after, -1)
+	if len(a) > maxDiffTokens || len(b) > maxDiffTokens {
+		return escapeMarkdown(before), escapeMarkdown(after)
+	}
+
+	// lcs[i][j] is the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var marked [2]strings.Builder
+	mark := func(side int, token, marker string) {
+		if strings.TrimSpace(token) == "" {
+			marked[side].WriteString(token)
+			return
+		}
+		marked[side].WriteString(marker + escapeMarkdown(token) + marker)
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			marked[0].WriteString(escapeMarkdown(a[i]))
+			marked[1].WriteString(escapeMarkdown(b[j]))
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			mark(1, b[j], "**")
+			j++
+		default:
+			mark(0, a[i], "~~")
+			i++
+		}
+	}
+	return marked[0].String(), marked[1].String()
+}
+
+// boundedCache remembers up to size values, forgetting the oldest first
+type boundedCache[V any] struct {
+	mu     sync.Mutex
+	size   int
+	values map[string]boundedEntry[V]
+	order  []string // ring of keys in insertion order
+	next   int
+}
+
+// boundedEntry is a cached value and the ring slot that owns it
+type boundedEntry[V any] struct {
+	value V
+	slot  int
+}
+
+func newBoundedCache[V any](size int) *boundedCache[V] {
+	return &boundedCache[V]{
+		size:   size,
+		values: make(map[string]boundedEntry[V], size),
+		order:  make([]string, 0, size),
+	}
+}
+
+func (c *boundedCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.values[key]
+	return entry.value, ok
+}
+
+func (c *boundedCache[V]) put(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, exists := c.values[key]; exists {
+		entry.value = value
+		c.values[key] = entry
+		return
+	}
+	slot := len(c.order)
+	if slot < c.size {
+		c.order = append(c.order, key)
+	} else {
+		// A removed key keeps its slot until it comes round, and may have
+		// been cached again in a newer slot that must survive this one
+		slot = c.next
+		if old, exists := c.values[c.order[slot]]; exists && old.slot == slot {
+			delete(c.values, c.order[slot])
+		}
+		c.order[slot] = key
+		c.next = (c.next + 1) % c.size
+	}
+	c.values[key] = boundedEntry[V]{value: value, slot: slot}
+}
+
+func (c *boundedCache[V]) remove(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.values[key]
+	delete(c.values, key)
+	return entry.value, ok
+}
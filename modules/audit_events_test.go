@@ -0,0 +1,117 @@
+package modules_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"discord-bot-forge/core"
+	"discord-bot-forge/forgetest"
+	"discord-bot-forge/modules"
+	"github.com/bwmarrin/discordgo"
+)
+
+// logChannelID receives the audit embeds in these tests
+const logChannelID = "500000000000000005"
+
+func newAuditHarness(t *testing.T) (*forgetest.Harness, *modules.AuditModule) {
+	t.Helper()
+
+	h := forgetest.New(t)
+	audit := modules.NewAuditModule()
+	h.Bot.RegisterModule(audit)
+	h.StartModules()
+	if err := h.Bot.GuildSettings(forgetest.GuildID).Set(core.SettingLogChannel, logChannelID); err != nil {
+		t.Fatal(err)
+	}
+	return h, audit
+}
+
+// field returns the value of an embed field, or "" without one
+func field(embed *discordgo.MessageEmbed, name string) string {
+	for _, f := range embed.Fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+func TestAuditLogsMessageEditsAndDeletes(t *testing.T) {
+	h, audit := newAuditHarness(t)
+
+	m := h.Message("hello world")
+	h.Dispatch(m)
+	h.AssertNoMessages()
+
+	edited := time.Now()
+	h.Dispatch(&discordgo.MessageUpdate{Message: &discordgo.Message{
+		ID:              m.ID,
+		GuildID:         forgetest.GuildID,
+		ChannelID:       forgetest.ChannelID,
+		Content:         "hello there",
+		Author:          m.Author,
+		EditedTimestamp: &edited,
+	}})
+	if embed := h.AssertEmbed("✏️ Message Edited"); embed != nil {
+		if before := field(embed, "Before"); before != "hello ~~world~~" {
+			t.Errorf("Before = %q, want the removed word struck through", before)
+		}
+		if after := field(embed, "After"); after != "hello **there**" {
+			t.Errorf("After = %q, want the added word in bold", after)
+		}
+	}
+
+	h.Dispatch(&discordgo.MessageDelete{Message: &discordgo.Message{
+		ID:        m.ID,
+		GuildID:   forgetest.GuildID,
+		ChannelID: forgetest.ChannelID,
+	}})
+	if embed := h.AssertEmbed("🗑️ Message Deleted"); embed != nil {
+		if before := field(embed, "Before"); !strings.Contains(before, "hello there") {
+			t.Errorf("deleted message Before = %q, want the edited content", before)
+		}
+	}
+
+	entries, err := audit.Search(forgetest.GuildID, modules.AuditQuery{Types: []modules.AuditEventType{modules.AuditMessageEdit}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Before != "hello world" || entries[0].After != "hello there" {
+		t.Errorf("stored edits = %+v, want one from hello world to hello there", entries)
+	}
+}
+
+func TestAuditLogsNicknameAndRoleChanges(t *testing.T) {
+	h, _ := newAuditHarness(t)
+
+	user := &discordgo.User{ID: forgetest.UserID, Username: "tester"}
+	member := func(nick string, roles ...string) *discordgo.Member {
+		return &discordgo.Member{GuildID: forgetest.GuildID, User: user, Nick: nick, Roles: roles}
+	}
+
+	h.Dispatch(&discordgo.GuildMemberAdd{Member: member("", "600000000000000001")})
+	h.AssertEmbed("📥 Member Joined")
+
+	h.Dispatch(&discordgo.GuildMemberUpdate{Member: member("newbie", "600000000000000002")})
+	if embed := h.AssertEmbed("🏷️ Nickname Changed"); embed != nil {
+		if after := field(embed, "After"); after != "newbie" {
+			t.Errorf("nickname After = %q, want newbie", after)
+		}
+	}
+	if embed := h.AssertEmbed("🎭 Roles Changed"); embed != nil {
+		for _, change := range []string{"+ <@&600000000000000002>", "- <@&600000000000000001>"} {
+			if !strings.Contains(embed.Description, change) {
+				t.Errorf("roles description %q is missing %q", embed.Description, change)
+			}
+		}
+	}
+
+	// Rejoining caches the member again, and the next change still has a
+	// snapshot to compare with
+	h.Dispatch(&discordgo.GuildMemberRemove{Member: member("newbie", "600000000000000002")})
+	h.Dispatch(&discordgo.GuildMemberAdd{Member: member("")})
+	h.Discord.Reset()
+	h.Dispatch(&discordgo.GuildMemberUpdate{Member: member("back")})
+	h.AssertEmbed("🏷️ Nickname Changed")
+}
@@ -0,0 +1,184 @@
+package modules
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"discord-bot-forge/core"
+)
+
+// failingStorage fails every write while fail is set
+type failingStorage struct {
+	core.Storage
+	fail bool
+}
+
+var errStorageFailed = errors.New("storage failed")
+
+func (s *failingStorage) Save(namespace, key string, v interface{}) error {
+	if s.fail {
+		return errStorageFailed
+	}
+	return s.Storage.Save(namespace, key, v)
+}
+
+func TestAuditStoreRetriesFailedEntries(t *testing.T) {
+	storage := &failingStorage{Storage: core.NewMemoryStorage(), fail: true}
+	store := newAuditStore(storage, 0, slog.Default())
+
+	now := time.Now().UTC()
+	store.add(AuditEntry{Time: now, GuildID: "1", Type: AuditMemberJoin, Summary: "first"})
+	store.add(AuditEntry{Time: now, GuildID: "2", Type: AuditMemberJoin, Summary: "second"})
+	if err := store.flush(); !errors.Is(err, errStorageFailed) {
+		t.Fatalf("flush error = %v, want %v", err, errStorageFailed)
+	}
+
+	storage.fail = false
+	store.add(AuditEntry{Time: now, GuildID: "1", Type: AuditMemberLeave, Summary: "third"})
+	if err := store.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := store.search("1", AuditQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Summary != "third" || entries[1].Summary != "first" {
+		t.Errorf("guild 1 entries = %+v, want third and first", entries)
+	}
+	if entries, _ := store.search("2", AuditQuery{}); len(entries) != 1 {
+		t.Errorf("guild 2 has %d entries, want 1", len(entries))
+	}
+}
+
+// countingStorage counts writes per namespace
+type countingStorage struct {
+	core.Storage
+	saves map[string]int
+}
+
+func (s *countingStorage) Save(namespace, key string, v interface{}) error {
+	s.saves[namespace]++
+	return s.Storage.Save(namespace, key, v)
+}
+
+func TestAuditStoreWritesOnlyChangedDays(t *testing.T) {
+	files, err := core.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := &countingStorage{Storage: files, saves: make(map[string]int)}
+	store := newAuditStore(storage, 0, slog.Default())
+
+	now := time.Now().UTC()
+	for _, guildID := range []string{"1", "2", "3"} {
+		store.add(AuditEntry{Time: now, GuildID: guildID, Type: AuditMemberJoin})
+		store.add(AuditEntry{Time: now.Add(-24 * time.Hour), GuildID: guildID, Type: AuditMemberJoin})
+	}
+	if err := store.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if days, _ := storage.Keys(auditNamespace); len(days) != 6 {
+		t.Errorf("listed days = %v, want 6", days)
+	}
+
+	clear(storage.saves)
+	store.add(AuditEntry{Time: now, GuildID: "1", Type: AuditMemberLeave})
+	if err := store.flush(); err != nil {
+		t.Fatal(err)
+	}
+	today := auditDayNamespace("1:" + now.Format(auditDayFormat))
+	if len(storage.saves) != 1 || storage.saves[today] != 1 {
+		t.Errorf("second flush wrote %v, want one write to %s", storage.saves, today)
+	}
+
+	entries, err := store.search("1", AuditQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Type != AuditMemberLeave {
+		t.Errorf("guild 1 entries = %+v, want the leave first of 3", entries)
+	}
+}
+
+func TestAuditStorePrunesExpiredDays(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := core.NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newAuditStore(storage, 24*time.Hour, slog.Default())
+
+	now := time.Now().UTC()
+	store.add(AuditEntry{Time: now, GuildID: "1", Type: AuditMemberJoin})
+	store.add(AuditEntry{Time: now.Add(-72 * time.Hour), GuildID: "1", Type: AuditMemberJoin})
+	if err := store.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	days, err := storage.Keys(auditNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || days[0] != "1:"+now.Format(auditDayFormat) {
+		t.Errorf("days after pruning = %v, want only today", days)
+	}
+	expired := auditDayNamespace("1:" + now.Add(-72*time.Hour).Format(auditDayFormat))
+	if _, err := os.Stat(filepath.Join(dir, expired+".json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expired day's file still exists: %v", err)
+	}
+}
+
+func TestAuditFieldsEscapeAfterTruncating(t *testing.T) {
+	entry := AuditEntry{Type: AuditMessageDelete, Before: strings.Repeat("*", 2000)}
+	before, _ := auditFields(entry)
+
+	if count := utf8.RuneCountInString(before); count > auditFieldLimit {
+		t.Errorf("field has %d characters, want at most %d", count, auditFieldLimit)
+	}
+	if !strings.HasSuffix(before, `\*…`) {
+		t.Errorf("field ends with %q, want an escaped character before the ellipsis", before[len(before)-8:])
+	}
+
+	edit := AuditEntry{Type: AuditMessageEdit, Before: strings.Repeat("a_b ", 300), After: strings.Repeat("c_d ", 300)}
+	before, after := auditFields(edit)
+	for _, text := range []string{before, after} {
+		if count := utf8.RuneCountInString(text); count > auditFieldLimit {
+			t.Errorf("edit field has %d characters, want at most %d", count, auditFieldLimit)
+		}
+	}
+}
+
+func TestBoundedCacheKeepsKeysCachedAgainAfterRemove(t *testing.T) {
+	cache := newBoundedCache[int](3)
+	cache.put("a", 1)
+	cache.put("b", 2)
+	cache.put("c", 3)
+
+	// b leaves and comes back into a's slot while its old slot is still in
+	// the ring
+	cache.remove("b")
+	cache.put("b", 4)
+	cache.put("d", 5)
+
+	if value, ok := cache.get("b"); !ok || value != 4 {
+		t.Errorf("b = %d, %v; evicting its old slot removed the live entry", value, ok)
+	}
+	if _, ok := cache.get("a"); ok {
+		t.Error("a was not evicted to make room for b")
+	}
+	for _, key := range []string{"c", "d"} {
+		if _, ok := cache.get(key); !ok {
+			t.Errorf("%s was evicted early", key)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package modules
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"discord-bot-forge/core"
+)
+
+// auditNamespace is the storage namespace listing the days that have audit
+// entries, keyed "<guild>:<day>". Each day's entries live in a namespace of
+// their own, one key per hour, so a flush only rewrites the current day of
+// the guilds that changed.
+const auditNamespace = "audit_log"
+
+// auditFlushInterval is how often new entries are written to storage
+const auditFlushInterval = 5 * time.Second
+
+// auditMaxPending is how many unstored entries are kept while storage is
+// failing; the oldest are dropped beyond it
+const auditMaxPending = 10000
+
+// auditDayFormat is the day part of an audit storage key
+const auditDayFormat = "2006-01-02"
+
+// auditHourFormat is the key of an hour's entries within a day
+const auditHourFormat = "15"
+
+// AuditEventType is the kind of change an audit entry records
+type AuditEventType string
+
+const (
+	AuditMessageEdit       AuditEventType = "message_edit"
+	AuditMessageDelete     AuditEventType = "message_delete"
+	AuditMessageBulkDelete AuditEventType = "message_bulk_delete"
+	AuditMemberJoin        AuditEventType = "member_join"
+	AuditMemberLeave       AuditEventType = "member_leave"
+	AuditMemberNickname    AuditEventType = "member_nickname"
+	AuditMemberRoles       AuditEventType = "member_roles"
+	AuditChannelCreate     AuditEventType = "channel_create"
+	AuditChannelUpdate     AuditEventType = "channel_update"
+	AuditChannelDelete     AuditEventType = "channel_delete"
+)
+
+// AuditEntry is one change in a guild
+type AuditEntry struct {
+	Time      time.Time      `json:"time"`
+	GuildID   string         `json:"guild_id"`
+	Type      AuditEventType `json:"type"`
+	UserID    string         `json:"user_id,omitempty"`
+	Username  string         `json:"username,omitempty"`
+	ChannelID string         `json:"channel_id,omitempty"`
+	MessageID string         `json:"message_id,omitempty"`
+	Count     int            `json:"count,omitempty"`
+	Summary   string         `json:"summary"`
+	Before    string         `json:"before,omitempty"`
+	After     string         `json:"after,omitempty"`
+}
+
+// AuditQuery selects stored audit entries. Empty fields match everything.
+type AuditQuery struct {
+	Types     []AuditEventType
+	UserID    string
+	ChannelID string
+	// Text matches the summary, content and user name, ignoring case
+	Text  string
+	Since time.Time
+	// Limit is the most entries returned; 25 when zero
+	Limit int
+}
+
+// matches reports whether entry is selected by the query
+func (q AuditQuery) matches(entry AuditEntry) bool {
+	if len(q.Types) > 0 {
+		found := false
+		for _, t := range q.Types {
+			found = found || t == entry.Type
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.UserID != "" && entry.UserID != q.UserID {
+		return false
+	}
+	if q.ChannelID != "" && entry.ChannelID != q.ChannelID {
+		return false
+	}
+	if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+		return false
+	}
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		found := false
+		for _, field := range []string{entry.Summary, entry.Before, entry.After, entry.Username} {
+			found = found || strings.Contains(strings.ToLower(field), text)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// auditStore batches new entries into storage and prunes expired days
+type auditStore struct {
+	storage   core.Storage
+	retention time.Duration
+	log       *slog.Logger
+
+	mu         sync.Mutex
+	pending    []AuditEntry
+	dropped    int
+	lastPruned time.Time
+}
+
+func newAuditStore(storage core.Storage, retention time.Duration, logger *slog.Logger) *auditStore {
+	return &auditStore{
+		storage:   storage,
+		retention: retention,
+		log:       logger,
+	}
+}
+
+func (s *auditStore) add(entry AuditEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) >= auditMaxPending {
+		s.pending = s.pending[1:]
+		s.dropped++
+	}
+	s.pending = append(s.pending, entry)
+}
+
+// run flushes pending entries until stop is closed
+func (s *auditStore) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(auditFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.flush(); err != nil {
+				s.log.Error("error storing audit entries", "error", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// flush appends pending entries to their guild's day in storage and,
+// at most hourly, deletes days past the retention period. Entries that
+// could not be stored stay pending for the next flush.
+func (s *auditStore) flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dropped > 0 {
+		s.log.Warn("dropped audit entries while storage was failing", "count", s.dropped)
+		s.dropped = 0
+	}
+
+	var firstErr error
+	if len(s.pending) > 0 {
+		byHour := make(map[auditHour][]AuditEntry)
+		var hours []auditHour
+		for _, entry := range s.pending {
+			hour := auditHourOf(entry)
+			if _, seen := byHour[hour]; !seen {
+				hours = append(hours, hour)
+			}
+			byHour[hour] = append(byHour[hour], entry)
+		}
+
+		var remaining []AuditEntry
+		for _, hour := range hours {
+			if err := s.store(hour, byHour[hour]); err != nil {
+				remaining = append(remaining, byHour[hour]...)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		s.pending = remaining
+	}
+
+	if time.Since(s.lastPruned) > time.Hour {
+		s.lastPruned = time.Now()
+		if err := s.prune(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// auditHour identifies where an entry is stored
+type auditHour struct {
+	day  string // index key, "<guild>:<day>"
+	hour string
+}
+
+func auditHourOf(entry AuditEntry) auditHour {
+	return auditHour{
+		day:  entry.GuildID + ":" + entry.Time.Format(auditDayFormat),
+		hour: entry.Time.Format(auditHourFormat),
+	}
+}
+
+// auditDayNamespace is the storage namespace of one guild's day
+func auditDayNamespace(day string) string {
+	return auditNamespace + "_" + strings.Replace(day, ":", "_", 1)
+}
+
+// store appends entries to their hour, listing the day first so stored
+// entries can always be found; s.mu must be held
+func (s *auditStore) store(hour auditHour, entries []AuditEntry) error {
+	var listed bool
+	if err := s.storage.Load(auditNamespace, hour.day, &listed); errors.Is(err, core.ErrNotFound) {
+		if err := s.storage.Save(auditNamespace, hour.day, true); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	namespace := auditDayNamespace(hour.day)
+	var stored []AuditEntry
+	if err := s.storage.Load(namespace, hour.hour, &stored); err != nil && !errors.Is(err, core.ErrNotFound) {
+		return err
+	}
+	return s.storage.Save(namespace, hour.hour, append(stored, entries...))
+}
+
+// prune deletes the days that ended before the retention period; s.mu
+// must be held
+func (s *auditStore) prune() error {
+	if s.retention <= 0 {
+		return nil
+	}
+	keys, err := s.storage.Keys(auditNamespace)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().UTC().Add(-s.retention)
+	for _, key := range keys {
+		day, err := time.Parse(auditDayFormat, key[strings.LastIndex(key, ":")+1:])
+		if err != nil || !day.Add(24*time.Hour).Before(cutoff) {
+			continue
+		}
+		if err := s.deleteDay(key); err != nil {
+			return err
+		}
+		s.log.Debug("deleted expired audit entries", core.LogKeyGuild, key[:strings.LastIndex(key, ":")], "day", day.Format(auditDayFormat))
+	}
+	return nil
+}
+
+// deleteDay removes a day's entries and then its index key; s.mu must be
+// held
+func (s *auditStore) deleteDay(day string) error {
+	namespace := auditDayNamespace(day)
+	hours, err := s.storage.Keys(namespace)
+	if err != nil {
+		return err
+	}
+	for _, hour := range hours {
+		if err := s.storage.Delete(namespace, hour); err != nil {
+			return err
+		}
+	}
+	return s.storage.Delete(auditNamespace, day)
+}
+
+// search returns a guild's matching entries, newest first
+func (s *auditStore) search(guildID string, query AuditQuery) ([]AuditEntry, error) {
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+	if query.Limit <= 0 {
+		query.Limit = 25
+	}
+
+	keys, err := s.storage.Keys(auditNamespace)
+	if err != nil {
+		return nil, err
+	}
+	var days []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, guildID+":") {
+			days = append(days, key)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(days)))
+
+	results := []AuditEntry{}
+	for _, day := range days {
+		namespace := auditDayNamespace(day)
+		hours, err := s.storage.Keys(namespace)
+		if err != nil {
+			return nil, err
+		}
+		for h := len(hours) - 1; h >= 0; h-- {
+			var entries []AuditEntry
+			if err := s.storage.Load(namespace, hours[h], &entries); err != nil {
+				return nil, err
+			}
+			for i := len(entries) - 1; i >= 0; i-- {
+				if !query.matches(entries[i]) {
+					continue
+				}
+				results = append(results, entries[i])
+				if len(results) == query.Limit {
+					return results, nil
+				}
+			}
+		}
+	}
+	return results, nil
+}
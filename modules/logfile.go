@@ -0,0 +1,280 @@
+package modules
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"discord-bot-forge/core"
+)
+
+// backupTimeFormat names rotated log files so they sort by age
+const backupTimeFormat = "20060102-150405"
+
+// RotationPolicy decides when a log file is rotated and how long the
+// rotated files are kept
+type RotationPolicy struct {
+	// MaxSizeMB rotates the file before it grows past this size; 0 never
+	// rotates by size
+	MaxSizeMB int `json:"max_size_mb"`
+
+	// Every rotates the file when a period of this length ends, counted
+	// from midnight UTC, e.g. 24h for daily files; 0 never rotates by time
+	Every core.Duration `json:"every"`
+
+	// MaxBackups is how many rotated files are kept; 0 keeps all
+	MaxBackups int `json:"max_backups"`
+
+	// MaxAge deletes rotated files older than this; 0 keeps them
+	MaxAge core.Duration `json:"max_age"`
+
+	// Compress gzips rotated files
+	Compress bool `json:"compress"`
+}
+
+// rotatingFile is an append-only log file that rotates itself according to
+// a RotationPolicy. Rotated files are named after the file with the time of
+// rotation, e.g. bot-20240102-150405.log, and are compressed and pruned in
+// the background.
+type rotatingFile struct {
+	path   string
+	policy RotationPolicy
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time // start of the rotation period the file belongs to
+
+	background sync.Mutex // serializes compressing and pruning backups
+	wg         sync.WaitGroup
+}
+
+// openRotatingFile opens path for appending, creating it and its directory
+// if needed
+func openRotatingFile(path string, policy RotationPolicy) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, policy: policy}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("error creating log directory: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	// Old backups may have expired while the bot was not running
+	f.wg.Add(1)
+	go f.cleanUp("")
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("error opening log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error opening log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	// An existing file belongs to the period it was last written in, so a
+	// restart after midnight still rotates yesterday's file
+	f.period = f.periodOf(time.Now())
+	if info.Size() > 0 {
+		f.period = f.periodOf(info.ModTime())
+	}
+	return nil
+}
+
+// periodOf returns the start of the rotation period containing t
+func (f *rotatingFile) periodOf(t time.Time) time.Time {
+	if f.policy.Every <= 0 {
+		return time.Time{}
+	}
+	return t.UTC().Truncate(f.policy.Every.Duration())
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	maxSize := int64(f.policy.MaxSizeMB) * 1024 * 1024
+	full := maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > maxSize
+	expired := !f.periodOf(time.Now()).Equal(f.period)
+	if full || expired {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file and starts a new one; f.mu must be held
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("error closing log file: %w", err)
+	}
+	f.file = nil
+
+	backup := f.backupName(time.Now())
+	if err := os.Rename(f.path, backup); err != nil {
+		// Keep writing to the current file rather than losing logs
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("error rotating log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.wg.Add(1)
+	go f.cleanUp(backup)
+	return nil
+}
+
+// cleanUp compresses a new backup when configured and prunes old ones
+func (f *rotatingFile) cleanUp(backup string) {
+	defer f.wg.Done()
+
+	f.background.Lock()
+	defer f.background.Unlock()
+
+	if backup != "" && f.policy.Compress {
+		compressFile(backup)
+	}
+	f.prune()
+}
+
+// backupName returns the name a file rotated at t gets
+func (f *rotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	name := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(f.path, ext), t.UTC().Format(backupTimeFormat), ext)
+
+	// Rotating twice within a second must not overwrite the first backup
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			if _, err := os.Stat(name + ".gz"); os.IsNotExist(err) {
+				return name
+			}
+		}
+		name = fmt.Sprintf("%s-%s.%d%s", strings.TrimSuffix(f.path, ext), t.UTC().Format(backupTimeFormat), i, ext)
+	}
+}
+
+// backups returns the rotated files, newest first
+func (f *rotatingFile) backups() []string {
+	ext := filepath.Ext(f.path)
+	prefix := filepath.Base(strings.TrimSuffix(f.path, ext)) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return nil
+	}
+
+	var backups []string
+	order := make(map[string]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)[len(prefix):]
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, stamp[:len(backupTimeFormat)]); err != nil {
+			continue
+		}
+		// Backups from the same second sort by their collision suffix
+		index, _ := strconv.Atoi(strings.TrimPrefix(stamp[len(backupTimeFormat):], "."))
+		path := filepath.Join(filepath.Dir(f.path), name)
+		order[path] = fmt.Sprintf("%s.%06d", stamp[:len(backupTimeFormat)], index)
+		backups = append(backups, path)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return order[backups[i]] > order[backups[j]]
+	})
+	return backups
+}
+
+// prune deletes rotated files beyond MaxBackups or older than MaxAge
+func (f *rotatingFile) prune() {
+	for i, backup := range f.backups() {
+		expired := false
+		if f.policy.MaxAge > 0 {
+			if info, err := os.Stat(backup); err == nil {
+				expired = time.Since(info.ModTime()) > f.policy.MaxAge.Duration()
+			}
+		}
+		if expired || (f.policy.MaxBackups > 0 && i >= f.policy.MaxBackups) {
+			os.Remove(backup)
+		}
+	}
+}
+
+// Close closes the file and waits for background compression and pruning
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.wg.Wait()
+	return err
+}
+
+// compressFile replaces path with a gzipped copy, keeping the original if
+// anything fails
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	// Keep the modification time so age-based pruning still works
+	os.Chtimes(path+".gz", info.ModTime(), info.ModTime())
+	return os.Remove(path)
+}
@@ -1,88 +1,414 @@
-package modules
-
-import (
-	"fmt"
-	"log"
-	"os"
-	"time"
-
-	"discord-bot-forge/core"
-	"github.com/bwmarrin/discordgo"
-)
-
-// LoggingModule provides logging functionality for DiscordBotForge
-type LoggingModule struct {
-	logFile *os.File
-	logger  *log.Logger
-	version string
-}
-
-// NewLoggingModule creates a new logging module
-func NewLoggingModule(logFile string) *LoggingModule {
-	return &LoggingModule{
-		version: "1.0.0",
-	}
-}
-
-func (l *LoggingModule) Name() string {
-	return "Logging"
-}
-
-func (l *LoggingModule) Version() string {
-	return l.version
-}
-
-func (l *LoggingModule) Initialize(bot *core.Bot) error {
-	// Create log file
-	file, err := os.OpenFile("discord-bot-forge.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		return fmt.Errorf("error opening log file: %w", err)
-	}
-	
-	l.logFile = file
-	l.logger = log.New(file, "[DiscordBotForge] ", log.LstdFlags)
-	
-	// Add message logging handler
-	bot.Session.AddHandler(l.messageLogger)
-	
-	l.logger.Println("Logging module initialized")
-	return nil
-}
-
-func (l *LoggingModule) Shutdown() error {
-	if l.logFile != nil {
-		l.logger.Println("Logging module shutting down")
-		return l.logFile.Close()
-	}
-	return nil
-}
-
-func (l *LoggingModule) messageLogger(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.Author.Bot {
-		return
-	}
-	
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	logMessage := fmt.Sprintf("[%s] %s#%s in #%s: %s",
-		timestamp,
-		m.Author.Username,
-		m.Author.Discriminator,
-		m.ChannelID,
-		m.Content,
-	)
-	
-	l.logger.Println(logMessage)
-	
-	if s.State.User.ID == m.Author.ID {
-		return
-	}
-	
-	log.Printf("Message: %s", logMessage)
-}
-
-// Log logs a message to the log file
-func (l *LoggingModule) Log(message string) {
-	if l.logger != nil {
-		l.logger.Println(message)
-	}
-}
+package modules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"discord-bot-forge/core"
+	"github.com/bwmarrin/discordgo"
+)
+
+// defaultFileRotation applies to the log file when no sinks are configured
+var defaultFileRotation = RotationPolicy{
+	MaxSizeMB:  100,
+	MaxBackups: 10,
+	Compress:   true,
+}
+
+// discordLogInterval is how often log lines are posted to a channel by
+// default, so bursts become one message
+const discordLogInterval = 5 * time.Second
+
+// LoggingSettings configures the Logging module under modules.Logging
+type LoggingSettings struct {
+	// Messages logs every message the bot sees; on by default
+	Messages *bool `json:"messages"`
+
+	// Sinks are the destinations for the bot's logs. Without any, logs go
+	// to the module's file, rotated at 100 MB with 10 compressed backups.
+	Sinks []LogSinkConfig `json:"sinks"`
+}
+
+// LogSinkConfig configures one log destination
+type LogSinkConfig struct {
+	// Type is file, stdout or discord
+	Type string `json:"type"`
+
+	// Level is the lowest level written; everything the bot logs when
+	// empty, warn for discord
+	Level string `json:"level"`
+
+	// Format is text or json (one object per line); logging.format when
+	// empty
+	Format string `json:"format"`
+
+	// Path is the file to write; the module's file when empty
+	Path string `json:"path"`
+	RotationPolicy
+
+	// ChannelID is the channel discord sinks post to
+	ChannelID string `json:"channel_id"`
+
+	// Interval is how often a discord sink posts what it collected
+	Interval core.Duration `json:"interval"`
+}
+
+// LoggingModule writes the bot's logs to files, stdout or a Discord channel
+// and logs the messages the bot sees
+type LoggingModule struct {
+	bot     *core.Bot
+	log     *slog.Logger
+	logFile string
+	version string
+
+	mu       sync.Mutex
+	settings LoggingSettings
+	sinks    []*logSinkHandle
+}
+
+// logSinkHandle is an open sink and how to detach it from the bot
+type logSinkHandle struct {
+	remove func()
+	closer io.Closer
+}
+
+// NewLoggingModule creates a new logging module writing to logFile unless
+// its settings name other sinks. An empty logFile uses logging.file.
+func NewLoggingModule(logFile string) *LoggingModule {
+	return &LoggingModule{
+		logFile: logFile,
+		version: "1.0.0",
+	}
+}
+
+func (l *LoggingModule) Name() string {
+	return "Logging"
+}
+
+func (l *LoggingModule) Version() string {
+	return l.version
+}
+
+// RequiredIntents declares the message events the module logs
+func (l *LoggingModule) RequiredIntents() discordgo.Intent {
+	return discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages
+}
+
+func (l *LoggingModule) Initialize(bot *core.Bot) error {
+	l.bot = bot
+	l.log = bot.Logger("logging")
+
+	var settings LoggingSettings
+	if err := bot.Config().DecodeModuleSettings(l.Name(), &settings); err != nil {
+		return err
+	}
+	sinks, err := l.openSinks(bot.Config(), settings)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.settings = settings
+	l.sinks = sinks
+	l.mu.Unlock()
+
+	// Add message logging handler
+	bot.AddModuleHandler(l, l.messageLogger)
+
+	l.log.Info("logging module initialized", "sinks", len(sinks))
+	return nil
+}
+
+func (l *LoggingModule) Shutdown() error {
+	l.log.Info("logging module shutting down")
+
+	l.mu.Lock()
+	sinks := l.sinks
+	l.sinks = nil
+	l.mu.Unlock()
+
+	return closeSinks(sinks)
+}
+
+// OnConfigChange reopens the sinks when their settings or the default log
+// file or format change. The old sinks stay in place if the new ones cannot
+// be opened.
+func (l *LoggingModule) OnConfigChange(old, new *core.Config) error {
+	before, _ := json.Marshal(old.Modules[l.Name()])
+	after, _ := json.Marshal(new.Modules[l.Name()])
+	if string(before) == string(after) && old.Logging.File == new.Logging.File && old.Logging.Format == new.Logging.Format {
+		return nil
+	}
+
+	var settings LoggingSettings
+	if err := new.DecodeModuleSettings(l.Name(), &settings); err != nil {
+		return err
+	}
+	sinks, err := l.openSinks(new, settings)
+	if err != nil {
+		return fmt.Errorf("error reopening log sinks: %w", err)
+	}
+
+	l.mu.Lock()
+	previous := l.sinks
+	l.settings = settings
+	l.sinks = sinks
+	l.mu.Unlock()
+
+	l.log.Info("log sinks reopened", "sinks", len(sinks))
+	return closeSinks(previous)
+}
+
+// openSinks opens and attaches every configured sink, or the default file
+func (l *LoggingModule) openSinks(config *core.Config, settings LoggingSettings) ([]*logSinkHandle, error) {
+	configs := settings.Sinks
+	if len(configs) == 0 {
+		configs = []LogSinkConfig{{Type: "file", RotationPolicy: defaultFileRotation}}
+	}
+
+	var sinks []*logSinkHandle
+	for i, sinkConfig := range configs {
+		handler, closer, err := l.newSink(config, sinkConfig)
+		if err != nil {
+			closeSinks(sinks)
+			return nil, fmt.Errorf("modules.Logging.sinks[%d]: %w", i, err)
+		}
+		sinks = append(sinks, &logSinkHandle{remove: l.bot.AddLogHandler(handler), closer: closer})
+	}
+	return sinks, nil
+}
+
+// newSink creates the handler for one sink and whatever must be closed
+// when it is removed
+func (l *LoggingModule) newSink(config *core.Config, sink LogSinkConfig) (slog.Handler, io.Closer, error) {
+	levelName := sink.Level
+	if levelName == "" {
+		levelName = "debug"
+		if sink.Type == "discord" {
+			levelName = "warn"
+		}
+	}
+	level, err := core.ParseLogLevel(levelName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("level %w", err)
+	}
+
+	format := sink.Format
+	if format == "" {
+		format = config.Logging.Format
+	}
+	newHandler := func(w io.Writer) (slog.Handler, error) {
+		options := &slog.HandlerOptions{Level: level}
+		switch strings.ToLower(format) {
+		case "json":
+			return slog.NewJSONHandler(w, options), nil
+		case "text", "":
+			return slog.NewTextHandler(w, options), nil
+		}
+		return nil, fmt.Errorf("format %q must be text or json", format)
+	}
+
+	switch sink.Type {
+	case "file":
+		path := sink.Path
+		if path == "" {
+			path = l.logFile
+		}
+		if path == "" {
+			path = config.Logging.File
+		}
+		if path == "" {
+			return nil, nil, fmt.Errorf("file sink needs a path or logging.file")
+		}
+		if _, err := newHandler(io.Discard); err != nil {
+			return nil, nil, err
+		}
+		file, err := openRotatingFile(path, sink.RotationPolicy)
+		if err != nil {
+			return nil, nil, err
+		}
+		handler, _ := newHandler(file)
+		return handler, file, nil
+
+	case "stdout":
+		handler, err := newHandler(os.Stdout)
+		return handler, nil, err
+
+	case "discord":
+		if sink.ChannelID == "" {
+			return nil, nil, fmt.Errorf("discord sink needs a channel_id")
+		}
+		interval := sink.Interval.Duration()
+		if interval <= 0 {
+			interval = discordLogInterval
+		}
+		writer := newDiscordLogWriter(l.bot.Messages, sink.ChannelID, interval)
+		handler, err := newHandler(writer)
+		if err != nil {
+			writer.Close()
+			return nil, nil, err
+		}
+		// Failures to post would be logged and posted again
+		return &skipSubsystemHandler{Handler: handler, subsystem: "messages"}, writer, nil
+	}
+	return nil, nil, fmt.Errorf("type %q must be file, stdout or discord", sink.Type)
+}
+
+// closeSinks detaches sinks from the bot and closes them
+func closeSinks(sinks []*logSinkHandle) error {
+	var firstErr error
+	for _, sink := range sinks {
+		sink.remove()
+		if sink.closer == nil {
+			continue
+		}
+		if err := sink.closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (l *LoggingModule) messageLogger(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author.Bot || !l.bot.GuildSettings(m.GuildID).ModuleEnabled(l.Name()) {
+		return
+	}
+
+	l.mu.Lock()
+	enabled := l.settings.Messages == nil || *l.settings.Messages
+	l.mu.Unlock()
+	if !enabled {
+		return
+	}
+
+	l.log.Info("message", append(core.MessageLogAttrs(m.Message),
+		"username", m.Author.Username,
+		"content", m.Content)...)
+}
+
+// Log writes a message to the module's logger
+func (l *LoggingModule) Log(message string) {
+	if l.log != nil {
+		l.log.Info(message)
+	}
+}
+
+// skipSubsystemHandler drops the records of one subsystem
+type skipSubsystemHandler struct {
+	slog.Handler
+	subsystem string
+}
+
+func (h *skipSubsystemHandler) Handle(ctx context.Context, record slog.Record) error {
+	skip := false
+	record.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == core.LogKeySubsystem {
+			skip = attr.Value.String() == h.subsystem
+			return false
+		}
+		return true
+	})
+	if skip {
+		return nil
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// discordLogWriter collects log lines and posts them to a channel as code
+// blocks, at most one message per interval unless a message fills up
+type discordLogWriter struct {
+	queue     *core.MessageQueue
+	channelID string
+
+	mu      sync.Mutex
+	pending strings.Builder
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// discordLogLimit keeps a posted block within Discord's message length
+const discordLogLimit = 1900
+
+func newDiscordLogWriter(queue *core.MessageQueue, channelID string, interval time.Duration) *discordLogWriter {
+	w := &discordLogWriter{
+		queue:     queue,
+		channelID: channelID,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go w.run(interval)
+	return w
+}
+
+func (w *discordLogWriter) Write(p []byte) (int, error) {
+	line := string(p)
+	if len(line) > discordLogLimit {
+		// Leave room for "…\n" and cut before a rune rather than inside one
+		cut := discordLogLimit - len("…\n")
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		line = line[:cut] + "…\n"
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.pending.Len()+len(line) > discordLogLimit {
+		w.flushLocked()
+	}
+	w.pending.WriteString(line)
+	return len(p), nil
+}
+
+func (w *discordLogWriter) run(interval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			w.flushLocked()
+			w.mu.Unlock()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// flushLocked posts the collected lines; w.mu must be held
+func (w *discordLogWriter) flushLocked() {
+	if w.pending.Len() == 0 {
+		return
+	}
+	// Keep log content from closing the code block early
+	text := strings.ReplaceAll(w.pending.String(), "```", "'''")
+	w.queue.EnqueueText(w.channelID, "```\n"+text+"```")
+	w.pending.Reset()
+}
+
+// Close posts what is left and stops the writer
+func (w *discordLogWriter) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+
+		w.mu.Lock()
+		w.flushLocked()
+		w.mu.Unlock()
+	})
+	return nil
+}
@@ -0,0 +1,28 @@
+package modules
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDiscordLogWriterTruncatesOnRuneBoundary(t *testing.T) {
+	// Each rune is three bytes, so the byte limit falls inside one
+	line := strings.Repeat("日", discordLogLimit) + "\n"
+	w := &discordLogWriter{}
+
+	if n, err := w.Write([]byte(line)); err != nil || n != len(line) {
+		t.Fatalf("Write = %d, %v; want %d, nil", n, err, len(line))
+	}
+
+	got := w.pending.String()
+	if !utf8.ValidString(got) {
+		t.Fatal("truncated line is not valid UTF-8")
+	}
+	if len(got) > discordLogLimit {
+		t.Errorf("truncated line is %d bytes, want at most %d", len(got), discordLogLimit)
+	}
+	if !strings.HasSuffix(got, "日…\n") {
+		t.Errorf("truncated line ends with %q, want 日…\\n", got[len(got)-10:])
+	}
+}
@@ -0,0 +1,293 @@
+package modules
+
+import (
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"discord-bot-forge/core"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// recorderTick is how often held-back events are released and the
+	// recording is flushed to disk
+	recorderTick = 250 * time.Millisecond
+
+	// recorderHold is how long an event waits for earlier sequence numbers
+	// before it is written anyway
+	recorderHold = 250 * time.Millisecond
+)
+
+// RecorderSettings configures the recorder under modules.Recorder
+type RecorderSettings struct {
+	// Path is the recording file; gzip-compressed when it ends in .gz.
+	// Defaults to a timestamped file in recordings/.
+	Path string `json:"path"`
+
+	// RedactContent replaces message text, keeping command names
+	RedactContent bool `json:"redact_content"`
+
+	// RedactIDs replaces IDs and user names with stable pseudonyms
+	RedactIDs bool `json:"redact_ids"`
+}
+
+// RecorderModule records every gateway event the bot receives to a file,
+// which forgetest.Replayer can feed back into a bot to reproduce a bug. The
+// recording starts with a snapshot of the guilds the bot already knows.
+type RecorderModule struct {
+	mu       sync.Mutex
+	log      *slog.Logger
+	settings RecorderSettings
+	writer   *core.RecordingWriter
+	redactor *core.Redactor
+	started  time.Time
+	shards   map[int]*recordedShard
+	stop     chan struct{}
+	version  string
+}
+
+// recordedShard restores gateway order for one shard. discordgo runs each
+// handler on its own goroutine, so events can reach the recorder out of
+// sequence; they are held back until their predecessors are written.
+type recordedShard struct {
+	next    int64 // 0 until the first events have been held back
+	pending []pendingEvent
+}
+
+type pendingEvent struct {
+	seq      int64
+	received time.Time
+	event    core.RecordedEvent
+}
+
+// NewRecorderModule creates a new recorder module
+func NewRecorderModule() *RecorderModule {
+	return &RecorderModule{
+		version: "1.0.0",
+	}
+}
+
+func (r *RecorderModule) Name() string {
+	return "Recorder"
+}
+
+func (r *RecorderModule) Version() string {
+	return r.version
+}
+
+func (r *RecorderModule) Initialize(bot *core.Bot) error {
+	r.log = bot.Logger("recorder")
+	settings := RecorderSettings{
+		Path: filepath.Join("recordings", time.Now().Format("20060102-150405")+".jsonl.gz"),
+	}
+	if err := bot.Config().DecodeModuleSettings(r.Name(), &settings); err != nil {
+		return err
+	}
+
+	redactor := &core.Redactor{
+		Content: settings.RedactContent,
+		Prefix:  bot.Config().Prefix,
+		IDs:     settings.RedactIDs,
+	}
+	started := time.Now()
+	writer, err := core.CreateRecording(settings.Path, core.RecordingHeader{
+		Started:    started,
+		BotVersion: bot.Version,
+		Prefix:     bot.Config().Prefix,
+		Redacted:   redactor.Redacted(),
+	})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.settings = settings
+	r.writer = writer
+	r.redactor = redactor
+	r.started = started
+	r.shards = make(map[int]*recordedShard)
+	r.stop = make(chan struct{})
+	r.snapshot(bot)
+	r.mu.Unlock()
+
+	go r.tick(r.stop)
+	bot.AddModuleHandler(r, r.record)
+
+	r.log.Info("recording gateway events", "path", settings.Path)
+	return nil
+}
+
+func (r *RecorderModule) Shutdown() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.writer == nil {
+		return nil
+	}
+	close(r.stop)
+	for _, shard := range r.shards {
+		r.release(shard, true)
+	}
+
+	err := r.writer.Close()
+	r.log.Info("recorded gateway events", "events", r.writer.Events(), "path", r.settings.Path)
+	r.writer = nil
+	return err
+}
+
+// Path returns the file being recorded to
+func (r *RecorderModule) Path() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.settings.Path
+}
+
+// snapshot records READY and GUILD_CREATE events for the state the bot
+// reached before the recorder started; r.mu must be held. Each guild is
+// read under the lock of the shard whose state holds it.
+func (r *RecorderModule) snapshot(bot *core.Bot) {
+	state := bot.Session.State
+	if state == nil {
+		return
+	}
+
+	guilds := bot.Guilds()
+	stubs := make([]map[string]interface{}, len(guilds))
+	for i, guild := range guilds {
+		stubs[i] = map[string]interface{}{"id": guild.ID, "unavailable": true}
+	}
+
+	state.RLock()
+	ready := map[string]interface{}{
+		"v":          9,
+		"user":       state.User,
+		"session_id": state.SessionID,
+		"guilds":     stubs,
+	}
+	r.writeValue(0, "READY", ready)
+	state.RUnlock()
+
+	for _, guild := range guilds {
+		session := bot.ShardForGuild(guild.ID)
+		if session == nil || session.State == nil {
+			session = bot.Session
+		}
+
+		session.State.RLock()
+		r.writeValue(session.ShardID, "GUILD_CREATE", guild)
+		session.State.RUnlock()
+	}
+}
+
+// writeValue records a synthesized event; r.mu must be held
+func (r *RecorderModule) writeValue(shard int, eventType string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		r.log.Warn("not recording event", "event", eventType, "error", err)
+		return
+	}
+	r.write(core.RecordedEvent{Offset: time.Since(r.started), Shard: shard, Type: eventType, Data: data})
+}
+
+// record queues one gateway dispatch for writing in sequence order
+func (r *RecorderModule) record(s *discordgo.Session, e *discordgo.Event) {
+	if e.Type == "" {
+		return
+	}
+	received := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.writer == nil {
+		return
+	}
+
+	shard, ok := r.shards[s.ShardID]
+	if !ok {
+		shard = &recordedShard{}
+		r.shards[s.ShardID] = shard
+	}
+	if e.Type == "READY" {
+		// A new session restarts the sequence
+		r.release(shard, true)
+		shard.next = e.Sequence
+	}
+
+	shard.pending = append(shard.pending, pendingEvent{
+		seq:      e.Sequence,
+		received: received,
+		event: core.RecordedEvent{
+			Offset: received.Sub(r.started),
+			Shard:  s.ShardID,
+			Type:   e.Type,
+			Data:   e.RawData,
+		},
+	})
+	r.release(shard, false)
+}
+
+// release writes a shard's held-back events that are next in sequence, or
+// all of them when force is set; r.mu must be held
+func (r *RecorderModule) release(shard *recordedShard, force bool) {
+	sort.Slice(shard.pending, func(i, j int) bool { return shard.pending[i].seq < shard.pending[j].seq })
+
+	for len(shard.pending) > 0 && (force || shard.pending[0].seq <= shard.next) {
+		pending := shard.pending[0]
+		shard.pending = shard.pending[1:]
+		if pending.seq >= shard.next {
+			shard.next = pending.seq + 1
+		}
+		r.write(pending.event)
+	}
+}
+
+// write redacts and appends an event; r.mu must be held
+func (r *RecorderModule) write(event core.RecordedEvent) {
+	data, err := r.redactor.Redact(event.Data)
+	if err != nil {
+		r.log.Warn("not recording event", "event", event.Type, "error", err)
+		return
+	}
+	event.Data = data
+
+	if err := r.writer.Write(event); err != nil {
+		r.log.Error("error recording event", "event", event.Type, "error", err)
+	}
+}
+
+// tick releases events whose predecessors never arrived and flushes the
+// recording so a crash loses little
+func (r *RecorderModule) tick(stop chan struct{}) {
+	ticker := time.NewTicker(recorderTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		r.mu.Lock()
+		if r.writer != nil {
+			for _, shard := range r.shards {
+				for _, pending := range shard.pending {
+					if time.Since(pending.received) > recorderHold {
+						r.release(shard, true)
+						break
+					}
+				}
+			}
+			if err := r.writer.Flush(); err != nil {
+				r.log.Error("error flushing recording", "error", err)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,118 @@
+package modules_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"discord-bot-forge/commands"
+	"discord-bot-forge/core"
+	"discord-bot-forge/forgetest"
+	"discord-bot-forge/modules"
+)
+
+func TestRecorderRoundTrip(t *testing.T) {
+	for _, redact := range []bool{false, true} {
+		name := "plain"
+		if redact {
+			name = "redacted"
+		}
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "session.jsonl.gz")
+
+			server := forgetest.NewServer(t)
+			bot := server.NewBot(func(config *core.Config) {
+				config.Modules["Recorder"] = map[string]interface{}{
+					"path":       path,
+					"redact_ids": redact,
+				}
+			})
+			bot.RegisterModule(modules.NewRecorderModule())
+			bot.RegisterCommand(commands.NewHelpCommand(bot))
+			if err := bot.Open(); err != nil {
+				t.Fatal(err)
+			}
+			<-bot.Ready()
+
+			server.Send("!help")
+			server.WaitForMessages(1)
+			if err := bot.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			recording, err := core.LoadRecording(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if redact && (len(recording.Header.Redacted) != 1 || recording.Header.Redacted[0] != "ids") {
+				t.Errorf("header redacted = %v, want [ids]", recording.Header.Redacted)
+			}
+
+			// Replaying the recording makes the same reply
+			h := forgetest.New(t)
+			h.Bot.RegisterCommand(commands.NewHelpCommand(h.Bot))
+			calls := forgetest.FormatCalls(h.Replay(path))
+			if !strings.Contains(calls, "POST channels/") {
+				t.Errorf("replay made no message calls:\n%s", calls)
+			}
+			if redact == strings.Contains(calls, forgetest.ChannelID) {
+				t.Errorf("replay with redact_ids %v replied in:\n%s", redact, calls)
+			}
+			h.AssertEmbed("🔥 DiscordBotForge Commands")
+		})
+	}
+}
+
+func TestRecorderSnapshotsGuildsFromEveryShard(t *testing.T) {
+	// 210000000000000000 is on shard 1 of 2, forgetest.GuildID on shard 0
+	const otherGuild = "210000000000000000"
+
+	path := filepath.Join(t.TempDir(), "session.jsonl.gz")
+	server := forgetest.NewServer(t)
+	server.SetShardCount(2)
+	server.AddGuild(otherGuild, "Other Server", forgetest.OwnerID)
+
+	bot := server.NewBot(func(config *core.Config) {
+		config.Modules["Recorder"] = map[string]interface{}{"path": path}
+	})
+	if err := bot.Open(); err != nil {
+		t.Fatal(err)
+	}
+	<-bot.Ready()
+
+	// Started late, the recorder has to snapshot the guilds both shards hold
+	bot.RegisterModule(modules.NewRecorderModule())
+	if err := bot.StartModule("Recorder"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	recording, err := core.LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shards := make(map[string]int)
+	for _, event := range recording.Events {
+		if event.Type != "GUILD_CREATE" {
+			continue
+		}
+		var guild struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(event.Data, &guild); err != nil {
+			t.Fatal(err)
+		}
+		shards[guild.ID] = event.Shard
+	}
+
+	want := map[string]int{forgetest.GuildID: 0, otherGuild: 1}
+	for id, shard := range want {
+		if got, ok := shards[id]; !ok || got != shard {
+			t.Errorf("guild %s recorded on shard %d (found %v), want %d", id, got, ok, shard)
+		}
+	}
+}
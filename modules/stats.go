@@ -1,92 +1,105 @@
-package modules
-
-import (
-	"fmt"
-	"log"
-	"sync"
-	"time"
-
-	"discord-bot-forge/core"
-	"github.com/bwmarrin/discordgo"
-)
-
-// StatsModule tracks DiscordBotForge statistics
-type StatsModule struct {
-	mu           sync.RWMutex
-	startTime    time.Time
-	messageCount int64
-	commandCount int64
-	userCount    int64
-	version      string
-}
-
-// NewStatsModule creates a new stats module
-func NewStatsModule() *StatsModule {
-	return &StatsModule{
-		startTime: time.Now(),
-		version:   "1.0.0",
-	}
-}
-
-func (s *StatsModule) Name() string {
-	return "Statistics"
-}
-
-func (s *StatsModule) Version() string {
-	return s.version
-}
-
-func (s *StatsModule) Initialize(bot *core.Bot) error {
-	// Add message handler to track messages
-	bot.Session.AddHandler(s.messageHandler)
-	
-	// Add ready handler to track users
-	bot.Session.AddHandler(s.readyHandler)
-	
-	log.Println("Statistics module initialized")
-	return nil
-}
-
-func (s *StatsModule) Shutdown() error {
-	log.Println("Statistics module shutdown")
-	return nil
-}
-
-func (s *StatsModule) messageHandler(session *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.Author.Bot {
-		return
-	}
-	
-	s.mu.Lock()
-	s.messageCount++
-	s.mu.Unlock()
-}
-
-func (s *StatsModule) readyHandler(session *discordgo.Session, r *discordgo.Ready) {
-	s.mu.Lock()
-	s.userCount = int64(len(r.Guilds))
-	s.mu.Unlock()
-}
-
-// GetStats returns current statistics
-func (s *StatsModule) GetStats() map[string]interface{} {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
-	uptime := time.Since(s.startTime)
-	
-	return map[string]interface{}{
-		"uptime":        uptime.String(),
-		"messages":      s.messageCount,
-		"commands":      s.commandCount,
-		"servers":       s.userCount,
-		"start_time":    s.startTime.Format("2006-01-02 15:04:05"),
-	}
-}
-
-// IncrementCommandCount increments the command counter
-func (s *StatsModule) IncrementCommandCount() {
-	s.mu.Lock()
-	s.commandCount++
-	s.mu.Unlock()
-}
+package modules
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"discord-bot-forge/core"
+	"github.com/bwmarrin/discordgo"
+)
+
+// StatsModule tracks DiscordBotForge statistics
+type StatsModule struct {
+	log          *slog.Logger
+	mu           sync.RWMutex
+	startTime    time.Time
+	messageCount int64
+	commandCount int64
+	userCount    int64
+	version      string
+}
+
+// NewStatsModule creates a new stats module
+func NewStatsModule() *StatsModule {
+	return &StatsModule{
+		startTime: time.Now(),
+		version:   "1.0.0",
+	}
+}
+
+func (s *StatsModule) Name() string {
+	return "Statistics"
+}
+
+func (s *StatsModule) Version() string {
+	return s.version
+}
+
+// RequiredIntents declares the message events the module counts
+func (s *StatsModule) RequiredIntents() discordgo.Intent {
+	return discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages
+}
+
+func (s *StatsModule) Initialize(bot *core.Bot) error {
+	s.log = bot.Logger("statistics")
+
+	// Add message handler to track messages
+	bot.AddModuleHandler(s, s.messageHandler)
+	
+	// Add ready handler to track users
+	bot.AddModuleHandler(s, s.readyHandler)
+
+	// Count commands from the event bus
+	sub := core.On(bot.Events, func(core.CommandExecutedEvent) {
+		s.IncrementCommandCount()
+	})
+	bot.OnModuleStop(s, sub.Unsubscribe)
+	
+	s.log.Info("statistics module initialized")
+	return nil
+}
+
+func (s *StatsModule) Shutdown() error {
+	s.log.Info("statistics module shut down")
+	return nil
+}
+
+func (s *StatsModule) messageHandler(session *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author.Bot {
+		return
+	}
+	
+	s.mu.Lock()
+	s.messageCount++
+	s.mu.Unlock()
+}
+
+func (s *StatsModule) readyHandler(session *discordgo.Session, r *discordgo.Ready) {
+	s.mu.Lock()
+	s.userCount = int64(len(r.Guilds))
+	s.mu.Unlock()
+}
+
+// GetStats returns current statistics
+func (s *StatsModule) GetStats() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	uptime := time.Since(s.startTime)
+	
+	return map[string]interface{}{
+		"uptime":        uptime.String(),
+		"messages":      s.messageCount,
+		"commands":      s.commandCount,
+		"servers":       s.userCount,
+		"start_time":    s.startTime.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// IncrementCommandCount increments the command counter
+func (s *StatsModule) IncrementCommandCount() {
+	s.mu.Lock()
+	s.commandCount++
+	s.mu.Unlock()
+}
@@ -0,0 +1,249 @@
+// Package plugin is the SDK for writing DiscordBotForge plugins in Go.
+//
+// A plugin is a separate executable started by the bot. It declares its
+// commands and event subscriptions, then serves requests over stdin/stdout:
+//
+//	func main() {
+//		p := plugin.New("dice", "1.0.0")
+//		p.Command(plugin.CommandSpec{Name: "roll", Description: "Roll a die"}, func(ctx *plugin.Context) error {
+//			ctx.Reply(fmt.Sprintf("🎲 %d", rand.Intn(6)+1))
+//			return nil
+//		})
+//		if err := p.Run(); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//
+// Stdout carries the protocol, so plugins must log to stderr (the default
+// for the log package) or through Logf.
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"discord-bot-forge/plugin/protocol"
+)
+
+// CommandSpec describes a command the plugin implements
+type CommandSpec = protocol.CommandSpec
+
+// CommandHandler runs a plugin command
+type CommandHandler func(ctx *Context) error
+
+// EventHandler receives a bot event the plugin subscribed to
+type EventHandler func(event Event)
+
+// Plugin declares a plugin's commands and event handlers and serves them
+type Plugin struct {
+	name    string
+	version string
+
+	commands map[string]CommandHandler
+	specs    []CommandSpec
+	events   map[string][]EventHandler
+
+	mu         sync.Mutex
+	conn       *protocol.Conn
+	handshake  protocol.Handshake
+	onShutdown func()
+}
+
+// New creates a plugin with the given name and version
+func New(name, version string) *Plugin {
+	return &Plugin{
+		name:     name,
+		version:  version,
+		commands: make(map[string]CommandHandler),
+		events:   make(map[string][]EventHandler),
+	}
+}
+
+// Command registers a command. It must be called before Run.
+func (p *Plugin) Command(spec CommandSpec, handler CommandHandler) {
+	if _, exists := p.commands[spec.Name]; !exists {
+		p.specs = append(p.specs, spec)
+	}
+	p.commands[spec.Name] = handler
+}
+
+// On subscribes to bot events by name, e.g. "command.executed". It must be
+// called before Run.
+func (p *Plugin) On(event string, handler EventHandler) {
+	p.events[event] = append(p.events[event], handler)
+}
+
+// OnShutdown registers a function to run when the bot asks the plugin to stop
+func (p *Plugin) OnShutdown(fn func()) {
+	p.onShutdown = fn
+}
+
+// Prefix returns the bot's default command prefix, known after the handshake
+func (p *Plugin) Prefix() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.handshake.Prefix
+}
+
+// Run serves the plugin on stdin/stdout until the bot closes stdin
+func (p *Plugin) Run() error {
+	return p.Serve(os.Stdin, os.Stdout)
+}
+
+// Serve serves the plugin over r and w until r is exhausted
+func (p *Plugin) Serve(r io.Reader, w io.Writer) error {
+	conn := protocol.NewConn(r, w)
+
+	p.mu.Lock()
+	p.conn = conn
+	p.mu.Unlock()
+
+	return conn.Serve(func(msg *protocol.Message) { p.handle(conn, msg) })
+}
+
+// SendMessage sends a message to a channel through the bot
+func (p *Plugin) SendMessage(channelID, content string) (string, error) {
+	p.mu.Lock()
+	conn := p.conn
+	p.mu.Unlock()
+	if conn == nil {
+		return "", protocol.ErrConnClosed
+	}
+
+	var result protocol.SendMessageResult
+	params := protocol.SendMessageParams{ChannelID: channelID, Content: content}
+	if err := conn.Call(context.Background(), protocol.MethodSendMessage, params, &result); err != nil {
+		return "", err
+	}
+	return result.MessageID, nil
+}
+
+// Logf writes a line to the bot's log
+func (p *Plugin) Logf(format string, args ...interface{}) {
+	p.mu.Lock()
+	conn := p.conn
+	p.mu.Unlock()
+
+	message := fmt.Sprintf(format, args...)
+	if conn == nil || conn.Notify(protocol.MethodLog, protocol.LogParams{Message: message}) != nil {
+		log.Print(message)
+	}
+}
+
+func (p *Plugin) handle(conn *protocol.Conn, msg *protocol.Message) {
+	switch msg.Method {
+	case protocol.MethodHandshake:
+		var params protocol.Handshake
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			conn.Reply(msg.ID, nil, fmt.Errorf("invalid params: %w", err))
+			return
+		}
+		p.mu.Lock()
+		p.handshake = params
+		p.mu.Unlock()
+
+		manifest := protocol.Manifest{
+			Name:            p.name,
+			Version:         p.version,
+			ProtocolVersion: protocol.Version,
+			Commands:        p.specs,
+		}
+		for name := range p.events {
+			manifest.Events = append(manifest.Events, name)
+		}
+		conn.Reply(msg.ID, manifest, nil)
+
+	case protocol.MethodExecute:
+		var params protocol.ExecuteParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			conn.Reply(msg.ID, nil, fmt.Errorf("invalid params: %w", err))
+			return
+		}
+		result, err := p.execute(params)
+		conn.Reply(msg.ID, result, err)
+
+	case protocol.MethodEvent:
+		var params protocol.EventParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return
+		}
+		for _, handler := range p.events[params.Name] {
+			p.safely(func() error { handler(Event(params)); return nil })
+		}
+
+	case protocol.MethodShutdown:
+		if p.onShutdown != nil {
+			p.onShutdown()
+		}
+
+	default:
+		if msg.ID != 0 {
+			conn.Reply(msg.ID, nil, fmt.Errorf("unknown method %q", msg.Method))
+		}
+	}
+}
+
+// execute runs a command handler and collects its replies
+func (p *Plugin) execute(params protocol.ExecuteParams) (protocol.ExecuteResult, error) {
+	handler, exists := p.commands[params.Command]
+	if !exists {
+		return protocol.ExecuteResult{}, fmt.Errorf("unknown command %q", params.Command)
+	}
+
+	ctx := &Context{
+		Command: params.Command,
+		Args:    params.Args,
+		Message: params.Message,
+		plugin:  p,
+	}
+	if err := p.safely(func() error { return handler(ctx) }); err != nil {
+		return protocol.ExecuteResult{}, err
+	}
+	return protocol.ExecuteResult{Reply: strings.Join(ctx.replies, "\n")}, nil
+}
+
+// safely runs fn, converting a panic into an error
+func (p *Plugin) safely(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			log.Printf("Plugin %s: %v", p.name, err)
+		}
+	}()
+	return fn()
+}
+
+// Context describes one command invocation
+type Context struct {
+	Command string
+	Args    []string
+	Message protocol.MessageContext
+
+	plugin  *Plugin
+	replies []string
+}
+
+// Reply queues a message for the invoking channel; replies are sent together
+// when the handler returns
+func (c *Context) Reply(content string) {
+	c.replies = append(c.replies, content)
+}
+
+// Send sends a message to the invoking channel immediately
+func (c *Context) Send(content string) (string, error) {
+	return c.plugin.SendMessage(c.Message.ChannelID, content)
+}
+
+// Event is a bot event delivered to a plugin
+type Event protocol.EventParams
+
+// Decode decodes the event payload into v
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
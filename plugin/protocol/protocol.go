@@ -0,0 +1,284 @@
+// Package protocol defines the messages exchanged between the bot and its
+// plugins and the connection both sides use to exchange them
+package protocol
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// Version is the version of the plugin RPC protocol. The host
+// refuses plugins that report a different version in their handshake.
+const Version = 1
+
+// maxMessageSize bounds a single protocol line
+const maxMessageSize = 1 << 20
+
+// Plugin RPC methods. Host methods are called by the bot on the plugin,
+// plugin methods are called by the plugin on the bot.
+const (
+	MethodHandshake   = "handshake"    // host -> plugin, returns Manifest
+	MethodExecute     = "execute"      // host -> plugin, returns ExecuteResult
+	MethodEvent       = "event"        // host -> plugin notification
+	MethodShutdown    = "shutdown"     // host -> plugin notification
+	MethodSendMessage = "send_message" // plugin -> host, returns SendMessageResult
+	MethodLog         = "log"          // plugin -> host notification
+)
+
+// ErrConnClosed is returned by calls on a closed plugin connection
+var ErrConnClosed = errors.New("plugin connection closed")
+
+// Message is one line of the plugin protocol. Requests carry an ID and
+// a Method, notifications only a Method, and responses the ID of the request
+// they answer together with a Result or an Error.
+type Message struct {
+	ID     uint64          `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Handshake is sent by the host when a plugin starts
+type Handshake struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	BotVersion      string `json:"bot_version"`
+	Prefix          string `json:"prefix"`
+}
+
+// Manifest is a plugin's answer to the handshake
+type Manifest struct {
+	Name            string        `json:"name"`
+	Version         string        `json:"version"`
+	ProtocolVersion int           `json:"protocol_version"`
+	Commands        []CommandSpec `json:"commands"`
+	Events          []string      `json:"events"`
+}
+
+// CommandSpec describes a command implemented by a plugin
+type CommandSpec struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Usage       string   `json:"usage"`
+	Category    string   `json:"category"`
+	Permissions []string `json:"permissions,omitempty"`
+	Cooldown    int      `json:"cooldown,omitempty"`
+}
+
+// MessageContext describes the Discord message that invoked a command
+type MessageContext struct {
+	ID         string `json:"id"`
+	ChannelID  string `json:"channel_id"`
+	GuildID    string `json:"guild_id"`
+	AuthorID   string `json:"author_id"`
+	AuthorName string `json:"author_name"`
+	Content    string `json:"content"`
+}
+
+// ExecuteParams asks a plugin to run one of its commands
+type ExecuteParams struct {
+	Command string         `json:"command"`
+	Args    []string       `json:"args"`
+	Message MessageContext `json:"message"`
+}
+
+// ExecuteResult is returned by a plugin after running a command. A
+// non-empty Reply is sent to the invoking channel.
+type ExecuteResult struct {
+	Reply string `json:"reply,omitempty"`
+}
+
+// EventParams delivers a bus event the plugin subscribed to
+type EventParams struct {
+	Name string          `json:"name"`
+	Data json.RawMessage `json:"data"`
+}
+
+// SendMessageParams asks the host to send a message to a channel
+type SendMessageParams struct {
+	ChannelID string `json:"channel_id"`
+	Content   string `json:"content"`
+}
+
+// SendMessageResult identifies a message sent for a plugin
+type SendMessageResult struct {
+	MessageID string `json:"message_id"`
+}
+
+// LogParams is a log line from a plugin
+type LogParams struct {
+	Message string `json:"message"`
+}
+
+// Conn speaks the plugin protocol, newline-delimited JSON messages,
+// over a reader and a writer. Both the bot and plugins use it.
+type Conn struct {
+	r io.Reader
+	w io.Writer
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *Message
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewConn creates a connection reading messages from r and writing
+// them to w
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{
+		r:       r,
+		w:       w,
+		pending: make(map[uint64]chan *Message),
+		done:    make(chan struct{}),
+	}
+}
+
+// Serve reads messages until the reader is exhausted or the connection is
+// closed. Responses complete pending calls; requests and notifications are
+// passed to handler on their own goroutine, so handlers may make calls.
+// Requests must be answered with Reply.
+func (c *Conn) Serve(handler func(msg *Message)) error {
+	defer c.Close()
+
+	scanner := bufio.NewScanner(c.r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		msg := &Message{}
+		if err := json.Unmarshal(line, msg); err != nil {
+			slog.Warn("ignoring invalid plugin message", "message", string(line))
+			continue
+		}
+
+		if msg.Method == "" {
+			c.mu.Lock()
+			ch, exists := c.pending[msg.ID]
+			delete(c.pending, msg.ID)
+			c.mu.Unlock()
+			if exists {
+				ch <- msg
+			}
+			continue
+		}
+
+		go handler(msg)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("plugin message longer than %d bytes: %w", maxMessageSize, err)
+		}
+		return fmt.Errorf("error reading plugin messages: %w", err)
+	}
+	return nil
+}
+
+// Call sends a request and decodes the response into result, which may be
+// nil when the result is not needed
+func (c *Conn) Call(ctx context.Context, method string, params, result interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("error encoding %s params: %w", method, err)
+	}
+
+	ch := make(chan *Message, 1)
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	forget := func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}
+
+	if err := c.send(&Message{ID: id, Method: method, Params: raw}); err != nil {
+		forget()
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		if result != nil && len(resp.Result) > 0 {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return fmt.Errorf("error decoding %s result: %w", method, err)
+			}
+		}
+		return nil
+	case <-ctx.Done():
+		forget()
+		return ctx.Err()
+	case <-c.done:
+		forget()
+		return ErrConnClosed
+	}
+}
+
+// Notify sends a message that expects no response
+func (c *Conn) Notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("error encoding %s params: %w", method, err)
+	}
+	return c.send(&Message{Method: method, Params: raw})
+}
+
+// Reply answers the request with the given ID. A non-nil err is sent as the
+// error instead of result.
+func (c *Conn) Reply(id uint64, result interface{}, err error) error {
+	if err != nil {
+		return c.send(&Message{ID: id, Error: err.Error()})
+	}
+
+	raw, encodeErr := json.Marshal(result)
+	if encodeErr != nil {
+		return c.send(&Message{ID: id, Error: fmt.Sprintf("error encoding result: %v", encodeErr)})
+	}
+	return c.send(&Message{ID: id, Result: raw})
+}
+
+// Close fails pending and future calls. It does not close the underlying
+// reader or writer.
+func (c *Conn) Close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+func (c *Conn) send(msg *Message) error {
+	select {
+	case <-c.done:
+		return ErrConnClosed
+	default:
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error encoding plugin message: %w", err)
+	}
+	data = append(data, '\n')
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if _, err := c.w.Write(data); err != nil {
+		return fmt.Errorf("error writing plugin message: %w", err)
+	}
+	return nil
+}
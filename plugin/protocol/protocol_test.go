@@ -0,0 +1,46 @@
+package protocol
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPluginConnRejectsOversizedLines(t *testing.T) {
+	r, w := io.Pipe()
+	conn := NewConn(r, io.Discard)
+
+	served := make(chan error, 1)
+	go func() { served <- conn.Serve(func(*Message) {}) }()
+
+	called := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		called <- conn.Call(ctx, MethodExecute, struct{}{}, nil)
+	}()
+
+	go w.Write([]byte(strings.Repeat("x", maxMessageSize+1) + "\n"))
+
+	select {
+	case err := <-served:
+		if !errors.Is(err, bufio.ErrTooLong) {
+			t.Fatalf("Serve error = %v, want %v", err, bufio.ErrTooLong)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return for an oversized line")
+	}
+
+	select {
+	case err := <-called:
+		if !errors.Is(err, ErrConnClosed) {
+			t.Errorf("pending Call error = %v, want %v", err, ErrConnClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending Call did not fail after the connection broke")
+	}
+}
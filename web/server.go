@@ -1,309 +1,684 @@
-package web
-
-import (
-	"encoding/json"
-	"fmt"
-	"html/template"
-	"log"
-	"net/http"
-	"sync"
-	"time"
-
-	"discord-bot-forge/core"
-	"github.com/gorilla/mux"
-	"github.com/gorilla/websocket"
-)
-
-// WebServer handles the web interface for DiscordBotForge
-type WebServer struct {
-	bot        *core.Bot
-	server     *http.Server
-	router     *mux.Router
-	upgrader   websocket.Upgrader
-	clients    map[*websocket.Conn]bool
-	clientsMux sync.RWMutex
-	templates  *template.Template
-}
-
-// BotStatus represents the current status of the bot
-type BotStatus struct {
-	Running     bool                   `json:"running"`
-	Version     string                 `json:"version"`
-	Uptime      string                 `json:"uptime"`
-	Commands    int                    `json:"commands"`
-	Modules     int                    `json:"modules"`
-	Middleware  int                    `json:"middleware"`
-	Stats       map[string]interface{} `json:"stats"`
-	LastUpdate  time.Time              `json:"last_update"`
-}
-
-// CommandInfo represents command information for the web interface
-type CommandInfo struct {
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	Usage       string   `json:"usage"`
-	Category    string   `json:"category"`
-	Cooldown    int      `json:"cooldown"`
-	Permissions []string `json:"permissions"`
-}
-
-// ModuleInfo represents module information for the web interface
-type ModuleInfo struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
-	Status  string `json:"status"`
-}
-
-// NewWebServer creates a new web server instance
-func NewWebServer(bot *core.Bot, port string) *WebServer {
-	router := mux.NewRouter()
-	
-	ws := &WebServer{
-		bot:      bot,
-		router:   router,
-		clients:  make(map[*websocket.Conn]bool),
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true // Allow all origins for development
-			},
-		},
-	}
-	
-	// Load templates
-	ws.loadTemplates()
-	
-	// Setup routes
-	ws.setupRoutes()
-	
-	ws.server = &http.Server{
-		Addr:    ":" + port,
-		Handler: router,
-	}
-	
-	return ws
-}
-
-// Start starts the web server
-func (ws *WebServer) Start() error {
-	log.Printf("🌐 Starting DiscordBotForge web interface on port %s", ws.server.Addr[1:])
-	return ws.server.ListenAndServe()
-}
-
-// loadTemplates loads HTML templates
-func (ws *WebServer) loadTemplates() {
-	tmpl := template.New("")
-	
-	// Parse all templates
-	tmpl = template.Must(tmpl.ParseGlob("web/templates/*.html"))
-	
-	ws.templates = tmpl
-}
-
-// setupRoutes configures all HTTP routes
-func (ws *WebServer) setupRoutes() {
-	// Static files
-	ws.router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("web/static/"))))
-	
-	// Web pages
-	ws.router.HandleFunc("/", ws.handleDashboard).Methods("GET")
-	ws.router.HandleFunc("/commands", ws.handleCommands).Methods("GET")
-	ws.router.HandleFunc("/modules", ws.handleModules).Methods("GET")
-	ws.router.HandleFunc("/logs", ws.handleLogs).Methods("GET")
-	ws.router.HandleFunc("/settings", ws.handleSettings).Methods("GET")
-	
-	// API endpoints
-	api := ws.router.PathPrefix("/api").Subrouter()
-	api.HandleFunc("/status", ws.handleAPIStatus).Methods("GET")
-	api.HandleFunc("/commands", ws.handleAPICommands).Methods("GET")
-	api.HandleFunc("/modules", ws.handleAPIModules).Methods("GET")
-	api.HandleFunc("/logs", ws.handleAPILogs).Methods("GET")
-	api.HandleFunc("/restart", ws.handleAPIRestart).Methods("POST")
-	api.HandleFunc("/stop", ws.handleAPIStop).Methods("POST")
-	
-	// WebSocket endpoint
-	ws.router.HandleFunc("/ws", ws.handleWebSocket)
-}
-
-// handleDashboard serves the main dashboard page
-func (ws *WebServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
-	status := ws.getBotStatus()
-	
-	data := map[string]interface{}{
-		"Title": "DiscordBotForge Dashboard",
-		"Bot":   status,
-	}
-	
-	ws.templates.ExecuteTemplate(w, "dashboard.html", data)
-}
-
-// handleCommands serves the commands management page
-func (ws *WebServer) handleCommands(w http.ResponseWriter, r *http.Request) {
-	commands := ws.getCommandsInfo()
-	
-	data := map[string]interface{}{
-		"Title":    "Commands Management",
-		"Commands": commands,
-	}
-	
-	ws.templates.ExecuteTemplate(w, "commands.html", data)
-}
-
-// handleModules serves the modules management page
-func (ws *WebServer) handleModules(w http.ResponseWriter, r *http.Request) {
-	modules := ws.getModulesInfo()
-	
-	data := map[string]interface{}{
-		"Title":   "Modules Management",
-		"Modules": modules,
-	}
-	
-	ws.templates.ExecuteTemplate(w, "modules.html", data)
-}
-
-// handleLogs serves the logs viewing page
-func (ws *WebServer) handleLogs(w http.ResponseWriter, r *http.Request) {
-	data := map[string]interface{}{
-		"Title": "Bot Logs",
-	}
-	
-	ws.templates.ExecuteTemplate(w, "logs.html", data)
-}
-
-// handleSettings serves the settings page
-func (ws *WebServer) handleSettings(w http.ResponseWriter, r *http.Request) {
-	data := map[string]interface{}{
-		"Title": "Bot Settings",
-		"Config": ws.bot.Config,
-	}
-	
-	ws.templates.ExecuteTemplate(w, "settings.html", data)
-}
-
-// API handlers
-func (ws *WebServer) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
-	status := ws.getBotStatus()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(status)
-}
-
-func (ws *WebServer) handleAPICommands(w http.ResponseWriter, r *http.Request) {
-	commands := ws.getCommandsInfo()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(commands)
-}
-
-func (ws *WebServer) handleAPIModules(w http.ResponseWriter, r *http.Request) {
-	modules := ws.getModulesInfo()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(modules)
-}
-
-func (ws *WebServer) handleAPILogs(w http.ResponseWriter, r *http.Request) {
-	// For now, return empty logs - in a real implementation, you'd read from log files
-	logs := []string{"Bot started", "Command executed: ping", "Module loaded: logging"}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(logs)
-}
-
-func (ws *WebServer) handleAPIRestart(w http.ResponseWriter, r *http.Request) {
-	// In a real implementation, you'd restart the bot
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "restarting"})
-}
-
-func (ws *WebServer) handleAPIStop(w http.ResponseWriter, r *http.Request) {
-	// In a real implementation, you'd stop the bot
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "stopping"})
-}
-
-// WebSocket handler
-func (ws *WebServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := ws.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
-		return
-	}
-	defer conn.Close()
-	
-	// Add client
-	ws.clientsMux.Lock()
-	ws.clients[conn] = true
-	ws.clientsMux.Unlock()
-	
-	log.Printf("WebSocket client connected")
-	
-	// Send initial status
-	status := ws.getBotStatus()
-	conn.WriteJSON(status)
-	
-	// Keep connection alive and send updates
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-ticker.C:
-			status := ws.getBotStatus()
-			if err := conn.WriteJSON(status); err != nil {
-				log.Printf("WebSocket write error: %v", err)
-				return
-			}
-		}
-	}
-}
-
-// Helper methods
-func (ws *WebServer) getBotStatus() BotStatus {
-	return BotStatus{
-		Running:    ws.bot.Session != nil,
-		Version:    ws.bot.Version,
-		Uptime:     "2h 15m", // This would be calculated from start time
-		Commands:   len(ws.bot.Commands),
-		Modules:    len(ws.bot.Modules),
-		Middleware: len(ws.bot.Middleware),
-		Stats:      map[string]interface{}{"messages": 150, "commands_executed": 25},
-		LastUpdate: time.Now(),
-	}
-}
-
-func (ws *WebServer) getCommandsInfo() []CommandInfo {
-	var commands []CommandInfo
-	for _, cmd := range ws.bot.Commands {
-		commands = append(commands, CommandInfo{
-			Name:        cmd.Name(),
-			Description: cmd.Description(),
-			Usage:       cmd.Usage(),
-			Category:    cmd.Category(),
-			Cooldown:    cmd.Cooldown(),
-			Permissions: cmd.Permissions(),
-		})
-	}
-	return commands
-}
-
-func (ws *WebServer) getModulesInfo() []ModuleInfo {
-	var modules []ModuleInfo
-	for _, mod := range ws.bot.Modules {
-		modules = append(modules, ModuleInfo{
-			Name:    mod.Name(),
-			Version: mod.Version(),
-			Status:  "Running",
-		})
-	}
-	return modules
-}
-
-// BroadcastToClients sends data to all connected WebSocket clients
-func (ws *WebServer) BroadcastToClients(data interface{}) {
-	ws.clientsMux.RLock()
-	defer ws.clientsMux.RUnlock()
-	
-	for client := range ws.clients {
-		if err := client.WriteJSON(data); err != nil {
-			log.Printf("Error broadcasting to client: %v", err)
-			delete(ws.clients, client)
-			client.Close()
-		}
-	}
-}
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"discord-bot-forge/core"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// WebServer handles the web interface for DiscordBotForge
+type WebServer struct {
+	bot        *core.Bot
+	log        *slog.Logger
+	server     *http.Server
+	router     *mux.Router
+	upgrader   websocket.Upgrader
+	clients    map[*websocket.Conn]bool
+	clientsMux sync.RWMutex
+	templates  map[string]*template.Template
+}
+
+// BotStatus represents the current status of the bot
+type BotStatus struct {
+	Running     bool                   `json:"running"`
+	Version     string                 `json:"version"`
+	Uptime      string                 `json:"uptime"`
+	Commands    int                    `json:"commands"`
+	Modules     int                    `json:"modules"`
+	Middleware  int                    `json:"middleware"`
+	Stats       map[string]interface{} `json:"stats"`
+	Intents     []string               `json:"intents"`
+	ShardCount  int                    `json:"shard_count"`
+	Shards      []core.ShardStatus     `json:"shards"`
+	Queue       core.MessageStats      `json:"message_queue"`
+	LastUpdate  time.Time              `json:"last_update"`
+}
+
+// CommandInfo represents command information for the web interface
+type CommandInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Usage       string   `json:"usage"`
+	Category    string   `json:"category"`
+	Cooldown    int      `json:"cooldown"`
+	Permissions []string `json:"permissions"`
+}
+
+// GuildInfo represents a guild the bot is connected to
+type GuildInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// GuildSettingInfo represents one setting and its value in a guild
+type GuildSettingInfo struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	Default     string `json:"default"`
+	Value       string `json:"value"`
+	IsSet       bool   `json:"is_set"`
+}
+
+// ModuleInfo represents module information for the web interface
+type ModuleInfo struct {
+	Name     string    `json:"name"`
+	Version  string    `json:"version"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Since    time.Time `json:"since"`
+	Restarts int       `json:"restarts"`
+}
+
+// NewWebServer creates a new web server instance listening on all interfaces
+func NewWebServer(bot *core.Bot, port string) *WebServer {
+	return NewWebServerWithAddr(bot, ":"+port)
+}
+
+// NewWebServerWithAddr creates a new web server instance listening on addr
+func NewWebServerWithAddr(bot *core.Bot, addr string) *WebServer {
+	router := mux.NewRouter()
+	
+	ws := &WebServer{
+		bot:      bot,
+		log:      bot.Logger("web"),
+		router:   router,
+		clients:  make(map[*websocket.Conn]bool),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return true // Allow all origins for development
+			},
+		},
+	}
+	
+	// Load templates
+	ws.loadTemplates()
+	
+	// Setup routes
+	ws.setupRoutes()
+	
+	ws.server = &http.Server{
+		Addr:    addr,
+		Handler: router,
+	}
+	
+	return ws
+}
+
+// Start starts the web server
+func (ws *WebServer) Start() error {
+	ws.log.Info("starting web interface", "addr", ws.server.Addr)
+	return ws.server.ListenAndServe()
+}
+
+// Shutdown stops accepting requests, waits for active ones until ctx is done
+// and disconnects WebSocket clients. It can be registered with
+// Bot.AddShutdownHook.
+func (ws *WebServer) Shutdown(ctx context.Context) error {
+	// Hijacked WebSocket connections are not tracked by http.Server
+	ws.clientsMux.Lock()
+	for client := range ws.clients {
+		client.Close()
+		delete(ws.clients, client)
+	}
+	ws.clientsMux.Unlock()
+
+	return ws.server.Shutdown(ctx)
+}
+
+// loadTemplates loads HTML templates
+func (ws *WebServer) loadTemplates() {
+	// Every page defines "content", so each one gets its own set with the base layout
+	pages, err := filepath.Glob("web/templates/*.html")
+	if err != nil {
+		log.Fatalf("Error loading templates: %v", err)
+	}
+
+	ws.templates = make(map[string]*template.Template)
+	for _, page := range pages {
+		name := filepath.Base(page)
+		if name == "base.html" {
+			continue
+		}
+		ws.templates[name] = template.Must(template.ParseFiles("web/templates/base.html", page))
+	}
+}
+
+// renderPage executes a page template inside the base layout
+func (ws *WebServer) renderPage(w http.ResponseWriter, name string, data interface{}) {
+	tmpl, ok := ws.templates[name]
+	if !ok {
+		http.Error(w, "Page not found", http.StatusNotFound)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "base.html", data); err != nil {
+		ws.log.Error("error rendering page", "page", name, "error", err)
+	}
+}
+
+// setupRoutes configures all HTTP routes
+func (ws *WebServer) setupRoutes() {
+	ws.router.Use(ws.logRequests)
+
+	// Static files
+	ws.router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("web/static/"))))
+	
+	// Web pages
+	ws.router.HandleFunc("/", ws.handleDashboard).Methods("GET")
+	ws.router.HandleFunc("/commands", ws.handleCommands).Methods("GET")
+	ws.router.HandleFunc("/modules", ws.handleModules).Methods("GET")
+	ws.router.HandleFunc("/logs", ws.handleLogs).Methods("GET")
+	ws.router.HandleFunc("/settings", ws.handleSettings).Methods("GET")
+	
+	// API endpoints
+	api := ws.router.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/status", ws.handleAPIStatus).Methods("GET")
+	api.HandleFunc("/commands", ws.handleAPICommands).Methods("GET")
+	api.HandleFunc("/commands", ws.requireToken(ws.handleAPICreateCommand)).Methods("POST")
+	api.HandleFunc("/modules", ws.handleAPIModules).Methods("GET")
+	api.HandleFunc("/modules/{name}", ws.handleAPIModule).Methods("GET")
+	api.HandleFunc("/modules/{name}/{action:start|stop|restart}", ws.requireToken(ws.handleAPIModuleAction)).Methods("POST")
+	api.HandleFunc("/logs", ws.handleAPILogs).Methods("GET")
+	api.HandleFunc("/connection", ws.handleAPIConnection).Methods("GET")
+	api.HandleFunc("/jobs", ws.handleAPIJobs).Methods("GET")
+	api.HandleFunc("/jobs/{id}", ws.requireToken(ws.handleAPICancelJob)).Methods("DELETE")
+	api.HandleFunc("/guilds", ws.handleAPIGuilds).Methods("GET")
+	api.HandleFunc("/guilds/{guild}/settings", ws.handleAPIGuildSettings).Methods("GET")
+	api.HandleFunc("/guilds/{guild}/settings/{key}", ws.requireToken(ws.handleAPISetGuildSetting)).Methods("PUT")
+	api.HandleFunc("/guilds/{guild}/settings/{key}", ws.requireToken(ws.handleAPIResetGuildSetting)).Methods("DELETE")
+	api.HandleFunc("/guilds/{guild}/commands", ws.handleAPICustomCommands).Methods("GET")
+	api.HandleFunc("/guilds/{guild}/commands/{name}", ws.requireToken(ws.handleAPIDeleteCustomCommand)).Methods("DELETE")
+	api.HandleFunc("/config/reload", ws.requireToken(ws.handleAPIReloadConfig)).Methods("POST")
+	api.HandleFunc("/restart", ws.handleAPIRestart).Methods("POST")
+	api.HandleFunc("/stop", ws.handleAPIStop).Methods("POST")
+	
+	// WebSocket endpoint
+	ws.router.HandleFunc("/ws", ws.handleWebSocket)
+}
+
+// handleDashboard serves the main dashboard page
+func (ws *WebServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	status := ws.getBotStatus()
+	
+	data := map[string]interface{}{
+		"Title": "DiscordBotForge Dashboard",
+		"Bot":   status,
+	}
+	
+	ws.renderPage(w, "dashboard.html", data)
+}
+
+// handleCommands serves the commands management page
+func (ws *WebServer) handleCommands(w http.ResponseWriter, r *http.Request) {
+	commands := ws.getCommandsInfo()
+	
+	data := map[string]interface{}{
+		"Title":    "Commands Management",
+		"Commands": commands,
+	}
+	
+	ws.renderPage(w, "commands.html", data)
+}
+
+// handleModules serves the modules management page
+func (ws *WebServer) handleModules(w http.ResponseWriter, r *http.Request) {
+	modules := ws.getModulesInfo()
+	
+	data := map[string]interface{}{
+		"Title":   "Modules Management",
+		"Modules": modules,
+	}
+	
+	ws.renderPage(w, "modules.html", data)
+}
+
+// handleLogs serves the logs viewing page
+func (ws *WebServer) handleLogs(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"Title": "Bot Logs",
+	}
+	
+	ws.renderPage(w, "logs.html", data)
+}
+
+// handleSettings serves the settings page
+func (ws *WebServer) handleSettings(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"Title": "Bot Settings",
+		"Config": ws.bot.Config(),
+	}
+	
+	ws.renderPage(w, "settings.html", data)
+}
+
+// API handlers
+func (ws *WebServer) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
+	status := ws.getBotStatus()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (ws *WebServer) handleAPICommands(w http.ResponseWriter, r *http.Request) {
+	commands := ws.getCommandsInfo()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(commands)
+}
+
+// requireToken only lets requests carrying web.token as a bearer token
+// through. Without a configured token the route is disabled.
+func (ws *WebServer) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := ws.bot.Config().Web.Token
+		if token == "" {
+			writeJSONError(w, http.StatusForbidden, "set web.token to make changes from the dashboard")
+			return
+		}
+
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, "invalid or missing dashboard token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleAPICreateCommand creates a custom command for a guild
+func (ws *WebServer) handleAPICreateCommand(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		GuildID string `json:"guild_id"`
+		core.CustomCommand
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if body.GuildID == "" {
+		writeJSONError(w, http.StatusBadRequest, "guild_id is required")
+		return
+	}
+
+	body.CreatedBy = "web"
+	if err := ws.bot.CustomCommands.Create(body.GuildID, body.CustomCommand); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, core.ErrCustomCommandExists) {
+			status = http.StatusConflict
+		}
+		writeJSONError(w, status, err.Error())
+		return
+	}
+
+	created, err := ws.bot.CustomCommands.Get(body.GuildID, strings.ToLower(strings.TrimSpace(body.Name)))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (ws *WebServer) handleAPICustomCommands(w http.ResponseWriter, r *http.Request) {
+	commands, err := ws.bot.CustomCommands.List(mux.Vars(r)["guild"])
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(commands)
+}
+
+func (ws *WebServer) handleAPIDeleteCustomCommand(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := ws.bot.CustomCommands.Delete(vars["guild"], vars["name"]); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, core.ErrCustomCommandNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSONError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+func (ws *WebServer) handleAPIModules(w http.ResponseWriter, r *http.Request) {
+	modules := ws.getModulesInfo()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(modules)
+}
+
+func (ws *WebServer) handleAPIModule(w http.ResponseWriter, r *http.Request) {
+	status, exists := ws.bot.ModuleStatus(mux.Vars(r)["name"])
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "module not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(moduleInfo(status))
+}
+
+func (ws *WebServer) handleAPIModuleAction(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	var err error
+	switch vars["action"] {
+	case "start":
+		err = ws.bot.StartModule(name)
+	case "stop":
+		err = ws.bot.StopModule(name)
+	case "restart":
+		err = ws.bot.RestartModule(name)
+	}
+
+	if errors.Is(err, core.ErrUnknownModule) {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// The module status carries the failure details, so report it either way
+	status, _ := ws.bot.ModuleStatus(name)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "module": moduleInfo(status)})
+		return
+	}
+	json.NewEncoder(w).Encode(moduleInfo(status))
+}
+
+// logRequests logs every request at debug level
+func (ws *WebServer) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		next.ServeHTTP(w, r)
+		ws.log.Debug("request", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr, "duration", time.Since(started))
+	})
+}
+
+// handleAPILogs returns the bot's most recent log entries, oldest first.
+// The level query parameter drops entries below a level.
+func (ws *WebServer) handleAPILogs(w http.ResponseWriter, r *http.Request) {
+	entries := ws.bot.RecentLogs()
+	if name := r.URL.Query().Get("level"); name != "" {
+		min, err := core.ParseLogLevel(name)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "level "+err.Error())
+			return
+		}
+		filtered := entries[:0]
+		for _, entry := range entries {
+			var level slog.Level
+			if level.UnmarshalText([]byte(entry.Level)) == nil && level >= min {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (ws *WebServer) handleAPIConnection(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state":       ws.bot.ConnectionState(),
+		"shard_count": ws.bot.ShardCount(),
+		"shards":      ws.bot.Shards(),
+		"history":     ws.bot.ConnectionHistory(),
+	})
+}
+
+func (ws *WebServer) handleAPIJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ws.bot.Scheduler.Jobs())
+}
+
+func (ws *WebServer) handleAPICancelJob(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := ws.bot.Scheduler.Cancel(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, core.ErrJobNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSONError(w, status, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "cancelled"})
+}
+
+func (ws *WebServer) handleAPIGuilds(w http.ResponseWriter, r *http.Request) {
+	guilds := make([]GuildInfo, 0)
+	for _, guild := range ws.bot.Guilds() {
+		guilds = append(guilds, GuildInfo{ID: guild.ID, Name: guild.Name})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(guilds)
+}
+
+func (ws *WebServer) handleAPIGuildSettings(w http.ResponseWriter, r *http.Request) {
+	settings := ws.getGuildSettingsInfo(mux.Vars(r)["guild"])
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+func (ws *WebServer) handleAPISetGuildSetting(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var body struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := ws.bot.GuildSettings(vars["guild"]).Set(vars["key"], body.Value); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, core.ErrUnknownSetting) {
+			status = http.StatusNotFound
+		}
+		writeJSONError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+}
+
+func (ws *WebServer) handleAPIResetGuildSetting(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := ws.bot.GuildSettings(vars["guild"]).Reset(vars["key"]); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, core.ErrUnknownSetting) {
+			status = http.StatusNotFound
+		}
+		writeJSONError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "reset"})
+}
+
+func (ws *WebServer) handleAPIReloadConfig(w http.ResponseWriter, r *http.Request) {
+	report, err := ws.bot.ReloadConfig()
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (ws *WebServer) handleAPIRestart(w http.ResponseWriter, r *http.Request) {
+	// In a real implementation, you'd restart the bot
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "restarting"})
+}
+
+func (ws *WebServer) handleAPIStop(w http.ResponseWriter, r *http.Request) {
+	// In a real implementation, you'd stop the bot
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "stopping"})
+}
+
+// WebSocket handler
+func (ws *WebServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := ws.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		ws.log.Warn("WebSocket upgrade failed", "remote", r.RemoteAddr, "error", err)
+		return
+	}
+	defer conn.Close()
+	
+	// Add client
+	ws.clientsMux.Lock()
+	ws.clients[conn] = true
+	ws.clientsMux.Unlock()
+	
+	ws.log.Debug("WebSocket client connected", "remote", r.RemoteAddr)
+	
+	// Send initial status
+	status := ws.getBotStatus()
+	conn.WriteJSON(status)
+	
+	// Keep connection alive and send updates
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ticker.C:
+			status := ws.getBotStatus()
+			if err := conn.WriteJSON(status); err != nil {
+				ws.log.Debug("WebSocket write failed", "remote", r.RemoteAddr, "error", err)
+				return
+			}
+		}
+	}
+}
+
+// Helper methods
+func (ws *WebServer) getBotStatus() BotStatus {
+	status := BotStatus{
+		Running:    ws.bot.Session != nil,
+		Version:    ws.bot.Version,
+		Uptime:     "unknown",
+		Commands:   len(ws.bot.CommandList()),
+		Modules:    len(ws.bot.Modules),
+		Middleware: len(ws.bot.Middleware),
+		Stats:      map[string]interface{}{"messages": 0, "commands_executed": 0},
+		Intents:    core.IntentNames(ws.bot.Intents()),
+		ShardCount: ws.bot.ShardCount(),
+		Shards:     ws.bot.Shards(),
+		Queue:      ws.bot.Messages.Stats(),
+		LastUpdate: time.Now(),
+	}
+
+	// Use live numbers when the statistics module is running
+	if stats, ok := core.Service[interface{ GetStats() map[string]interface{} }](ws.bot); ok {
+		values := stats.GetStats()
+		status.Uptime = fmt.Sprint(values["uptime"])
+		status.Stats = map[string]interface{}{
+			"messages":          values["messages"],
+			"commands_executed": values["commands"],
+		}
+	}
+
+	return status
+}
+
+func (ws *WebServer) getCommandsInfo() []CommandInfo {
+	var commands []CommandInfo
+	for _, cmd := range ws.bot.CommandList() {
+		commands = append(commands, CommandInfo{
+			Name:        cmd.Name(),
+			Description: cmd.Description(),
+			Usage:       cmd.Usage(),
+			Category:    cmd.Category(),
+			Cooldown:    cmd.Cooldown(),
+			Permissions: cmd.Permissions(),
+		})
+	}
+	return commands
+}
+
+func (ws *WebServer) getGuildSettingsInfo(guildID string) []GuildSettingInfo {
+	guildSettings := ws.bot.GuildSettings(guildID)
+
+	var settings []GuildSettingInfo
+	for _, setting := range ws.bot.SettingDefinitions() {
+		settings = append(settings, GuildSettingInfo{
+			Key:         setting.Key,
+			Description: setting.Description,
+			Type:        setting.Type.String(),
+			Default:     setting.Format(setting.Default),
+			Value:       setting.Format(guildSettings.Get(setting.Key)),
+			IsSet:       guildSettings.IsSet(setting.Key),
+		})
+	}
+	return settings
+}
+
+func (ws *WebServer) getModulesInfo() []ModuleInfo {
+	var modules []ModuleInfo
+	for _, status := range ws.bot.ModuleStatuses() {
+		modules = append(modules, moduleInfo(status))
+	}
+	return modules
+}
+
+func moduleInfo(status core.ModuleStatus) ModuleInfo {
+	return ModuleInfo{
+		Name:     status.Name,
+		Version:  status.Version,
+		Status:   string(status.State),
+		Error:    status.Error,
+		Since:    status.Since,
+		Restarts: status.Restarts,
+	}
+}
+
+// writeJSONError writes an error response in the API's JSON format
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// BroadcastToClients sends data to all connected WebSocket clients
+func (ws *WebServer) BroadcastToClients(data interface{}) {
+	ws.clientsMux.RLock()
+	defer ws.clientsMux.RUnlock()
+	
+	for client := range ws.clients {
+		if err := client.WriteJSON(data); err != nil {
+			ws.log.Warn("error broadcasting to WebSocket client", "error", err)
+			delete(ws.clients, client)
+			client.Close()
+		}
+	}
+}
@@ -1,407 +1,908 @@
-// DiscordBotForge Web Interface JavaScript
-
-class DiscordBotForge {
-    constructor() {
-        this.ws = null;
-        this.reconnectAttempts = 0;
-        this.maxReconnectAttempts = 5;
-        this.init();
-    }
-
-    init() {
-        this.connectWebSocket();
-        this.setupEventListeners();
-        this.startStatusUpdates();
-    }
-
-    connectWebSocket() {
-        const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const wsUrl = `${protocol}//${window.location.host}/ws`;
-        
-        try {
-            this.ws = new WebSocket(wsUrl);
-            
-            this.ws.onopen = () => {
-                console.log('WebSocket connected');
-                this.reconnectAttempts = 0;
-                this.updateConnectionStatus(true);
-            };
-            
-            this.ws.onmessage = (event) => {
-                const data = JSON.parse(event.data);
-                this.handleWebSocketMessage(data);
-            };
-            
-            this.ws.onclose = () => {
-                console.log('WebSocket disconnected');
-                this.updateConnectionStatus(false);
-                this.attemptReconnect();
-            };
-            
-            this.ws.onerror = (error) => {
-                console.error('WebSocket error:', error);
-                this.updateConnectionStatus(false);
-            };
-        } catch (error) {
-            console.error('Failed to connect WebSocket:', error);
-            this.updateConnectionStatus(false);
-        }
-    }
-
-    attemptReconnect() {
-        if (this.reconnectAttempts < this.maxReconnectAttempts) {
-            this.reconnectAttempts++;
-            console.log(`Attempting to reconnect... (${this.reconnectAttempts}/${this.maxReconnectAttempts})`);
-            setTimeout(() => {
-                this.connectWebSocket();
-            }, 2000 * this.reconnectAttempts);
-        }
-    }
-
-    updateConnectionStatus(connected) {
-        const statusElement = document.getElementById('connection-status');
-        if (statusElement) {
-            if (connected) {
-                statusElement.className = 'badge bg-success';
-                statusElement.innerHTML = '<i class="fas fa-circle"></i> Connected';
-            } else {
-                statusElement.className = 'badge bg-danger';
-                statusElement.innerHTML = '<i class="fas fa-circle"></i> Disconnected';
-            }
-        }
-    }
-
-    handleWebSocketMessage(data) {
-        // Update bot status
-        if (data.running !== undefined) {
-            this.updateBotStatus(data);
-        }
-        
-        // Update activity log
-        if (data.activity) {
-            this.addActivityLog(data.activity);
-        }
-        
-        // Update logs
-        if (data.logs) {
-            this.addLogEntry(data.logs);
-        }
-    }
-
-    updateBotStatus(data) {
-        const elements = {
-            'bot-status': data.running ? 'Running' : 'Stopped',
-            'bot-uptime': data.uptime || 'Unknown',
-            'last-update': new Date().toLocaleTimeString(),
-            'messages-count': data.stats?.messages || 0,
-            'commands-count': data.stats?.commands_executed || 0
-        };
-
-        Object.entries(elements).forEach(([id, value]) => {
-            const element = document.getElementById(id);
-            if (element) {
-                element.textContent = value;
-            }
-        });
-    }
-
-    addActivityLog(activity) {
-        const container = document.getElementById('activity-log');
-        if (!container) return;
-
-        const logEntry = document.createElement('div');
-        logEntry.className = 'activity-item';
-        logEntry.innerHTML = `
-            <i class="fas fa-info-circle text-info"></i>
-            <span class="ms-2">${activity.message}</span>
-            <small class="text-muted ms-2">${new Date().toLocaleTimeString()}</small>
-        `;
-
-        container.insertBefore(logEntry, container.firstChild);
-        
-        // Keep only last 10 entries
-        while (container.children.length > 10) {
-            container.removeChild(container.lastChild);
-        }
-    }
-
-    addLogEntry(logData) {
-        const container = document.getElementById('logs-container');
-        if (!container) return;
-
-        const logEntry = document.createElement('div');
-        logEntry.className = 'log-entry';
-        logEntry.innerHTML = `
-            <span class="log-time">[${new Date().toLocaleTimeString()}]</span>
-            <span class="log-level ${logData.level}">[${logData.level.toUpperCase()}]</span>
-            <span class="log-message">${logData.message}</span>
-        `;
-
-        container.appendChild(logEntry);
-        
-        // Auto-scroll if enabled
-        const autoScroll = document.getElementById('auto-scroll');
-        if (autoScroll && autoScroll.checked) {
-            container.scrollTop = container.scrollHeight;
-        }
-    }
-
-    setupEventListeners() {
-        // Settings form
-        const settingsForm = document.getElementById('settings-form');
-        if (settingsForm) {
-            settingsForm.addEventListener('submit', (e) => {
-                e.preventDefault();
-                this.saveSettings();
-            });
-        }
-
-        // Add command form
-        const addCommandForm = document.getElementById('add-command-form');
-        if (addCommandForm) {
-            addCommandForm.addEventListener('submit', (e) => {
-                e.preventDefault();
-                this.addCommand();
-            });
-        }
-
-        // Log filters
-        const logSearch = document.getElementById('log-search');
-        if (logSearch) {
-            logSearch.addEventListener('input', () => {
-                this.filterLogs();
-            });
-        }
-    }
-
-    startStatusUpdates() {
-        // Update status every 30 seconds
-        setInterval(() => {
-            this.fetchBotStatus();
-        }, 30000);
-    }
-
-    async fetchBotStatus() {
-        try {
-            const response = await fetch('/api/status');
-            const data = await response.json();
-            this.updateBotStatus(data);
-        } catch (error) {
-            console.error('Failed to fetch bot status:', error);
-        }
-    }
-
-    async saveSettings() {
-        const formData = new FormData(document.getElementById('settings-form'));
-        const settings = Object.fromEntries(formData);
-        
-        try {
-            const response = await fetch('/api/settings', {
-                method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json',
-                },
-                body: JSON.stringify(settings)
-            });
-            
-            if (response.ok) {
-                this.showAlert('Settings saved successfully!', 'success');
-            } else {
-                this.showAlert('Failed to save settings', 'danger');
-            }
-        } catch (error) {
-            console.error('Error saving settings:', error);
-            this.showAlert('Error saving settings', 'danger');
-        }
-    }
-
-    async addCommand() {
-        const formData = new FormData(document.getElementById('add-command-form'));
-        const command = Object.fromEntries(formData);
-        
-        try {
-            const response = await fetch('/api/commands', {
-                method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json',
-                },
-                body: JSON.stringify(command)
-            });
-            
-            if (response.ok) {
-                this.showAlert('Command added successfully!', 'success');
-                document.getElementById('add-command-form').reset();
-                this.refreshCommands();
-            } else {
-                this.showAlert('Failed to add command', 'danger');
-            }
-        } catch (error) {
-            console.error('Error adding command:', error);
-            this.showAlert('Error adding command', 'danger');
-        }
-    }
-
-    async restartBot() {
-        try {
-            const response = await fetch('/api/restart', {
-                method: 'POST'
-            });
-            
-            if (response.ok) {
-                this.showAlert('Bot restart initiated', 'info');
-            } else {
-                this.showAlert('Failed to restart bot', 'danger');
-            }
-        } catch (error) {
-            console.error('Error restarting bot:', error);
-            this.showAlert('Error restarting bot', 'danger');
-        }
-    }
-
-    async stopBot() {
-        if (confirm('Are you sure you want to stop the bot?')) {
-            try {
-                const response = await fetch('/api/stop', {
-                    method: 'POST'
-                });
-                
-                if (response.ok) {
-                    this.showAlert('Bot stop initiated', 'warning');
-                } else {
-                    this.showAlert('Failed to stop bot', 'danger');
-                }
-            } catch (error) {
-                console.error('Error stopping bot:', error);
-                this.showAlert('Error stopping bot', 'danger');
-            }
-        }
-    }
-
-    refreshStatus() {
-        this.fetchBotStatus();
-        this.showAlert('Status refreshed', 'info');
-    }
-
-    clearLogs() {
-        const container = document.getElementById('logs-container');
-        if (container) {
-            container.innerHTML = '';
-        }
-        this.showAlert('Logs cleared', 'info');
-    }
-
-    downloadLogs() {
-        // In a real implementation, this would download the log file
-        this.showAlert('Log download started', 'info');
-    }
-
-    filterLogs() {
-        const searchTerm = document.getElementById('log-search').value.toLowerCase();
-        const logLevel = document.getElementById('log-level').value;
-        const entries = document.querySelectorAll('.log-entry');
-        
-        entries.forEach(entry => {
-            const message = entry.textContent.toLowerCase();
-            const level = entry.querySelector('.log-level').textContent.toLowerCase();
-            
-            const matchesSearch = !searchTerm || message.includes(searchTerm);
-            const matchesLevel = logLevel === 'all' || level.includes(logLevel);
-            
-            entry.style.display = matchesSearch && matchesLevel ? 'block' : 'none';
-        });
-    }
-
-    applyFilters() {
-        this.filterLogs();
-        this.showAlert('Filters applied', 'info');
-    }
-
-    showCommandDetails(commandName) {
-        // In a real implementation, this would fetch and display command details
-        this.showAlert(`Showing details for command: ${commandName}`, 'info');
-    }
-
-    showModuleDetails(moduleName) {
-        // In a real implementation, this would fetch and display module details
-        this.showAlert(`Showing details for module: ${moduleName}`, 'info');
-    }
-
-    restartModule(moduleName) {
-        if (confirm(`Are you sure you want to restart the ${moduleName} module?`)) {
-            this.showAlert(`Restarting module: ${moduleName}`, 'info');
-        }
-    }
-
-    refreshCommands() {
-        // In a real implementation, this would refresh the commands table
-        this.showAlert('Commands refreshed', 'info');
-    }
-
-    showAlert(message, type) {
-        const alertDiv = document.createElement('div');
-        alertDiv.className = `alert alert-${type} alert-dismissible fade show`;
-        alertDiv.innerHTML = `
-            ${message}
-            <button type="button" class="btn-close" data-bs-dismiss="alert"></button>
-        `;
-        
-        // Insert at the top of the container
-        const container = document.querySelector('.container-fluid');
-        if (container) {
-            container.insertBefore(alertDiv, container.firstChild);
-            
-            // Auto-dismiss after 5 seconds
-            setTimeout(() => {
-                if (alertDiv.parentNode) {
-                    alertDiv.remove();
-                }
-            }, 5000);
-        }
-    }
-}
-
-// Global functions for HTML onclick handlers
-function restartBot() {
-    window.discordBotForge.restartBot();
-}
-
-function stopBot() {
-    window.discordBotForge.stopBot();
-}
-
-function refreshStatus() {
-    window.discordBotForge.refreshStatus();
-}
-
-function clearLogs() {
-    window.discordBotForge.clearLogs();
-}
-
-function downloadLogs() {
-    window.discordBotForge.downloadLogs();
-}
-
-function applyFilters() {
-    window.discordBotForge.applyFilters();
-}
-
-function showCommandDetails(commandName) {
-    window.discordBotForge.showCommandDetails(commandName);
-}
-
-function showModuleDetails(moduleName) {
-    window.discordBotForge.showModuleDetails(moduleName);
-}
-
-function restartModule(moduleName) {
-    window.discordBotForge.restartModule(moduleName);
-}
-
-function refreshCommands() {
-    window.discordBotForge.refreshCommands();
-}
-
-// Initialize the application when DOM is loaded
-document.addEventListener('DOMContentLoaded', () => {
-    window.discordBotForge = new DiscordBotForge();
-});
+// DiscordBotForge Web Interface JavaScript
+
+class DiscordBotForge {
+    constructor() {
+        this.ws = null;
+        this.reconnectAttempts = 0;
+        this.maxReconnectAttempts = 5;
+        this.init();
+    }
+
+    init() {
+        this.connectWebSocket();
+        this.setupEventListeners();
+        this.startStatusUpdates();
+    }
+
+    connectWebSocket() {
+        const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+        const wsUrl = `${protocol}//${window.location.host}/ws`;
+        
+        try {
+            this.ws = new WebSocket(wsUrl);
+            
+            this.ws.onopen = () => {
+                console.log('WebSocket connected');
+                this.reconnectAttempts = 0;
+                this.updateConnectionStatus(true);
+            };
+            
+            this.ws.onmessage = (event) => {
+                const data = JSON.parse(event.data);
+                this.handleWebSocketMessage(data);
+            };
+            
+            this.ws.onclose = () => {
+                console.log('WebSocket disconnected');
+                this.updateConnectionStatus(false);
+                this.attemptReconnect();
+            };
+            
+            this.ws.onerror = (error) => {
+                console.error('WebSocket error:', error);
+                this.updateConnectionStatus(false);
+            };
+        } catch (error) {
+            console.error('Failed to connect WebSocket:', error);
+            this.updateConnectionStatus(false);
+        }
+    }
+
+    attemptReconnect() {
+        if (this.reconnectAttempts < this.maxReconnectAttempts) {
+            this.reconnectAttempts++;
+            console.log(`Attempting to reconnect... (${this.reconnectAttempts}/${this.maxReconnectAttempts})`);
+            setTimeout(() => {
+                this.connectWebSocket();
+            }, 2000 * this.reconnectAttempts);
+        }
+    }
+
+    updateConnectionStatus(connected) {
+        const statusElement = document.getElementById('connection-status');
+        if (statusElement) {
+            if (connected) {
+                statusElement.className = 'badge bg-success';
+                statusElement.innerHTML = '<i class="fas fa-circle"></i> Connected';
+            } else {
+                statusElement.className = 'badge bg-danger';
+                statusElement.innerHTML = '<i class="fas fa-circle"></i> Disconnected';
+            }
+        }
+    }
+
+    handleWebSocketMessage(data) {
+        // Update bot status
+        if (data.running !== undefined) {
+            this.updateBotStatus(data);
+        }
+        
+        // Update activity log
+        if (data.activity) {
+            this.addActivityLog(data.activity);
+        }
+        
+        // Update logs
+        if (data.logs) {
+            this.addLogEntry(data.logs);
+        }
+    }
+
+    updateBotStatus(data) {
+        const elements = {
+            'bot-status': data.running ? 'Running' : 'Stopped',
+            'bot-uptime': data.uptime || 'Unknown',
+            'last-update': new Date().toLocaleTimeString(),
+            'messages-count': data.stats?.messages || 0,
+            'commands-count': data.stats?.commands_executed || 0
+        };
+
+        Object.entries(elements).forEach(([id, value]) => {
+            const element = document.getElementById(id);
+            if (element) {
+                element.textContent = value;
+            }
+        });
+
+        if (data.shards) {
+            this.updateShards(data.shards);
+        }
+    }
+
+    updateShards(shards) {
+        const table = document.getElementById('shards-table');
+        if (!table) return;
+
+        const badges = {
+            ready: 'bg-success',
+            connected: 'bg-info',
+            connecting: 'bg-warning',
+            disconnected: 'bg-danger'
+        };
+
+        table.innerHTML = '';
+        shards.forEach(shard => {
+            const row = document.createElement('tr');
+            row.innerHTML = `
+                <td>#${shard.id}</td>
+                <td><span class="badge ${badges[shard.state] || 'bg-secondary'}">${shard.state}</span></td>
+                <td>${Math.round(shard.latency / 1e6)} ms</td>
+                <td>${shard.guilds}</td>
+                <td>${shard.reconnects}</td>
+                <td>${shard.resumes}</td>
+                <td>${new Date(shard.since).toLocaleTimeString()}</td>
+            `;
+            table.appendChild(row);
+        });
+    }
+
+    addActivityLog(activity) {
+        const container = document.getElementById('activity-log');
+        if (!container) return;
+
+        const logEntry = document.createElement('div');
+        logEntry.className = 'activity-item';
+        logEntry.innerHTML = `
+            <i class="fas fa-info-circle text-info"></i>
+            <span class="ms-2">${activity.message}</span>
+            <small class="text-muted ms-2">${new Date().toLocaleTimeString()}</small>
+        `;
+
+        container.insertBefore(logEntry, container.firstChild);
+        
+        // Keep only last 10 entries
+        while (container.children.length > 10) {
+            container.removeChild(container.lastChild);
+        }
+    }
+
+    addLogEntry(logData) {
+        const container = document.getElementById('logs-container');
+        if (!container) return;
+
+        const logEntry = document.createElement('div');
+        logEntry.className = 'log-entry';
+        logEntry.innerHTML = `
+            <span class="log-time">[${new Date().toLocaleTimeString()}]</span>
+            <span class="log-level ${logData.level}">[${logData.level.toUpperCase()}]</span>
+            <span class="log-message">${logData.message}</span>
+        `;
+
+        container.appendChild(logEntry);
+        
+        // Auto-scroll if enabled
+        const autoScroll = document.getElementById('auto-scroll');
+        if (autoScroll && autoScroll.checked) {
+            container.scrollTop = container.scrollHeight;
+        }
+    }
+
+    setupEventListeners() {
+        // Settings form
+        const settingsForm = document.getElementById('settings-form');
+        if (settingsForm) {
+            settingsForm.addEventListener('submit', (e) => {
+                e.preventDefault();
+                this.saveSettings();
+            });
+        }
+
+        // Add command form
+        const addCommandForm = document.getElementById('add-command-form');
+        if (addCommandForm) {
+            addCommandForm.addEventListener('submit', (e) => {
+                e.preventDefault();
+                this.addCommand();
+            });
+        }
+
+        // Custom commands for the selected server
+        const commandGuild = document.getElementById('command-guild');
+        if (commandGuild) {
+            this.loadGuilds('command-guild');
+            commandGuild.addEventListener('change', () => {
+                this.loadCustomCommands(commandGuild.value);
+            });
+        }
+
+        // Shard states on the dashboard
+        if (document.getElementById('shards-table')) {
+            this.fetchBotStatus();
+        }
+
+        // Scheduled jobs on the dashboard
+        if (document.getElementById('jobs-table')) {
+            this.loadJobs();
+        }
+
+        // Guild settings editor
+        const guildSelect = document.getElementById('guild-select');
+        if (guildSelect) {
+            this.loadGuilds('guild-select');
+            guildSelect.addEventListener('change', () => {
+                this.loadGuildSettings(guildSelect.value);
+            });
+        }
+
+        // Recent log entries
+        if (document.getElementById('logs-container')) {
+            this.loadLogs();
+            setInterval(() => this.loadLogs(), 5000);
+        }
+
+        // Log filters
+        const logSearch = document.getElementById('log-search');
+        if (logSearch) {
+            logSearch.addEventListener('input', () => {
+                this.filterLogs();
+            });
+        }
+    }
+
+    startStatusUpdates() {
+        // Update status every 30 seconds
+        setInterval(() => {
+            this.fetchBotStatus();
+            if (document.getElementById('jobs-table')) {
+                this.loadJobs();
+            }
+        }, 30000);
+    }
+
+    async fetchBotStatus() {
+        try {
+            const response = await fetch('/api/status');
+            const data = await response.json();
+            this.updateBotStatus(data);
+        } catch (error) {
+            console.error('Failed to fetch bot status:', error);
+        }
+    }
+
+    async saveSettings() {
+        const formData = new FormData(document.getElementById('settings-form'));
+        const settings = Object.fromEntries(formData);
+        
+        try {
+            const response = await fetch('/api/settings', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify(settings)
+            });
+            
+            if (response.ok) {
+                this.showAlert('Settings saved successfully!', 'success');
+            } else {
+                this.showAlert('Failed to save settings', 'danger');
+            }
+        } catch (error) {
+            console.error('Error saving settings:', error);
+            this.showAlert('Error saving settings', 'danger');
+        }
+    }
+
+    async loadGuilds(selectId) {
+        try {
+            const response = await fetch('/api/guilds');
+            const guilds = await response.json();
+            const select = document.getElementById(selectId);
+
+            guilds.forEach(guild => {
+                const option = document.createElement('option');
+                option.value = guild.id;
+                option.textContent = guild.name || guild.id;
+                select.appendChild(option);
+            });
+        } catch (error) {
+            console.error('Failed to load servers:', error);
+        }
+    }
+
+    async loadGuildSettings(guildId) {
+        const table = document.getElementById('guild-settings-table');
+        if (!table) return;
+
+        if (!guildId) {
+            table.innerHTML = '<tr><td colspan="4" class="text-center text-muted">Select a server to edit its settings</td></tr>';
+            return;
+        }
+
+        try {
+            const response = await fetch(`/api/guilds/${guildId}/settings`);
+            const settings = await response.json();
+
+            table.innerHTML = '';
+            settings.forEach(setting => {
+                const row = document.createElement('tr');
+                row.innerHTML = `
+                    <td>
+                        <strong>${setting.key}</strong>
+                        <br>
+                        <small class="text-muted">${setting.description}</small>
+                    </td>
+                    <td><span class="badge bg-secondary">${setting.type}</span></td>
+                    <td>
+                        <input type="text" class="form-control form-control-sm" placeholder="${setting.default}">
+                        ${setting.is_set ? '' : '<small class="text-muted">default</small>'}
+                    </td>
+                    <td>
+                        <button class="btn btn-sm btn-outline-primary" data-action="save">
+                            <i class="fas fa-save"></i> Save
+                        </button>
+                        <button class="btn btn-sm btn-outline-warning" data-action="reset">
+                            <i class="fas fa-undo"></i> Reset
+                        </button>
+                    </td>
+                `;
+
+                const input = row.querySelector('input');
+                input.value = setting.is_set ? setting.value : '';
+                row.querySelector('[data-action="save"]').addEventListener('click', () => {
+                    this.saveGuildSetting(guildId, setting.key, input.value);
+                });
+                row.querySelector('[data-action="reset"]').addEventListener('click', () => {
+                    this.resetGuildSetting(guildId, setting.key);
+                });
+
+                table.appendChild(row);
+            });
+        } catch (error) {
+            console.error('Failed to load server settings:', error);
+            this.showAlert('Error loading server settings', 'danger');
+        }
+    }
+
+    async saveGuildSetting(guildId, key, value) {
+        try {
+            const response = await this.fetchWithToken(`/api/guilds/${guildId}/settings/${key}`, {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({ value })
+            });
+
+            if (response.ok) {
+                this.showAlert(`Setting ${key} saved`, 'success');
+                this.loadGuildSettings(guildId);
+            } else {
+                const data = await response.json();
+                this.showAlert(data.error || `Failed to save ${key}`, 'danger');
+            }
+        } catch (error) {
+            console.error('Error saving server setting:', error);
+            this.showAlert('Error saving server setting', 'danger');
+        }
+    }
+
+    async resetGuildSetting(guildId, key) {
+        try {
+            const response = await this.fetchWithToken(`/api/guilds/${guildId}/settings/${key}`, {
+                method: 'DELETE'
+            });
+
+            if (response.ok) {
+                this.showAlert(`Setting ${key} reset to default`, 'info');
+                this.loadGuildSettings(guildId);
+            } else {
+                const data = await response.json();
+                this.showAlert(data.error || `Failed to reset ${key}`, 'danger');
+            }
+        } catch (error) {
+            console.error('Error resetting server setting:', error);
+            this.showAlert('Error resetting server setting', 'danger');
+        }
+    }
+
+    // fetchWithToken sends the dashboard token, asking for it when the
+    // server rejects the request
+    async fetchWithToken(url, options = {}) {
+        const send = () => fetch(url, {
+            ...options,
+            headers: {
+                ...options.headers,
+                'Authorization': `Bearer ${sessionStorage.getItem('forgeToken') || ''}`,
+            },
+        });
+
+        let response = await send();
+        if (response.status === 401) {
+            const token = prompt('Dashboard token (web.token):');
+            if (token) {
+                sessionStorage.setItem('forgeToken', token);
+                response = await send();
+            }
+        }
+        return response;
+    }
+
+    async addCommand() {
+        const form = document.getElementById('add-command-form');
+        const command = Object.fromEntries(new FormData(form));
+        command.cooldown = parseInt(command.cooldown, 10) || 0;
+        
+        try {
+            const response = await this.fetchWithToken('/api/commands', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify(command)
+            });
+            
+            if (response.ok) {
+                this.showAlert(`Command ${command.name} added successfully!`, 'success');
+                form.reset();
+                form.elements.guild_id.value = command.guild_id;
+                this.refreshCommands();
+            } else {
+                const data = await response.json();
+                this.showAlert(data.error || 'Failed to add command', 'danger');
+            }
+        } catch (error) {
+            console.error('Error adding command:', error);
+            this.showAlert('Error adding command', 'danger');
+        }
+    }
+
+    async loadCustomCommands(guildId) {
+        const table = document.getElementById('custom-commands-table');
+        if (!table) return;
+
+        if (!guildId) {
+            table.innerHTML = '<tr><td colspan="3" class="text-center text-muted">Select a server to see its custom commands</td></tr>';
+            return;
+        }
+
+        try {
+            const response = await fetch(`/api/guilds/${guildId}/commands`);
+            const commands = await response.json();
+
+            table.innerHTML = '';
+            if (commands.length === 0) {
+                table.innerHTML = '<tr><td colspan="3" class="text-center text-muted">No custom commands yet</td></tr>';
+                return;
+            }
+
+            commands.forEach(command => {
+                const row = document.createElement('tr');
+                row.innerHTML = `
+                    <td><strong></strong><br><small class="text-muted"></small></td>
+                    <td><code></code></td>
+                    <td>
+                        <button type="button" class="btn btn-sm btn-outline-danger">
+                            <i class="fas fa-trash"></i> Delete
+                        </button>
+                    </td>
+                `;
+
+                // Templates are user input, so set them as text
+                row.querySelector('strong').textContent = command.name;
+                row.querySelector('small').textContent = command.description;
+                row.querySelector('code').textContent = command.template;
+                row.querySelector('button').addEventListener('click', () => {
+                    this.deleteCustomCommand(guildId, command.name);
+                });
+
+                table.appendChild(row);
+            });
+        } catch (error) {
+            console.error('Failed to load custom commands:', error);
+            this.showAlert('Error loading custom commands', 'danger');
+        }
+    }
+
+    async deleteCustomCommand(guildId, name) {
+        if (!confirm(`Are you sure you want to delete the ${name} command?`)) {
+            return;
+        }
+
+        try {
+            const response = await this.fetchWithToken(`/api/guilds/${guildId}/commands/${encodeURIComponent(name)}`, {
+                method: 'DELETE'
+            });
+
+            if (response.ok) {
+                this.showAlert(`Command ${name} deleted`, 'success');
+                this.loadCustomCommands(guildId);
+            } else {
+                const data = await response.json();
+                this.showAlert(data.error || `Failed to delete ${name}`, 'danger');
+            }
+        } catch (error) {
+            console.error('Error deleting custom command:', error);
+            this.showAlert('Error deleting custom command', 'danger');
+        }
+    }
+
+    async loadJobs() {
+        const table = document.getElementById('jobs-table');
+        if (!table) return;
+
+        try {
+            const response = await fetch('/api/jobs');
+            const jobs = await response.json();
+
+            table.innerHTML = '';
+            if (jobs.length === 0) {
+                table.innerHTML = '<tr><td colspan="6" class="text-center text-muted">No scheduled jobs</td></tr>';
+                return;
+            }
+
+            const formatTime = (value) => value ? new Date(value).toLocaleString() : '-';
+            jobs.forEach(job => {
+                let status = '<span class="badge bg-secondary">Waiting</span>';
+                if (job.running) {
+                    status = '<span class="badge bg-info">Running</span>';
+                } else if (job.last_error) {
+                    status = '<span class="badge bg-danger">Failed</span>';
+                } else if (job.done) {
+                    status = '<span class="badge bg-success">Done</span>';
+                } else if (job.runs > 0) {
+                    status = '<span class="badge bg-success">OK</span>';
+                }
+
+                const row = document.createElement('tr');
+                row.innerHTML = `
+                    <td><strong></strong><br><small class="text-muted"></small></td>
+                    <td><code></code></td>
+                    <td>${formatTime(job.next_run)}</td>
+                    <td>${formatTime(job.last_run)}</td>
+                    <td>${status}</td>
+                    <td></td>
+                `;
+
+                row.querySelector('strong').textContent = job.name;
+                row.querySelector('small').textContent = job.module || 'bot';
+                row.querySelector('code').textContent = job.schedule;
+                if (job.last_error) {
+                    row.cells[4].title = job.last_error;
+                }
+                if (!job.done) {
+                    const button = document.createElement('button');
+                    button.className = 'btn btn-sm btn-outline-danger';
+                    button.innerHTML = '<i class="fas fa-times"></i> Cancel';
+                    button.addEventListener('click', () => this.cancelJob(job.id, job.name));
+                    row.cells[5].appendChild(button);
+                }
+
+                table.appendChild(row);
+            });
+        } catch (error) {
+            console.error('Failed to load scheduled jobs:', error);
+        }
+    }
+
+    async cancelJob(id, name) {
+        if (!confirm(`Are you sure you want to cancel the ${name} job?`)) {
+            return;
+        }
+
+        try {
+            const response = await this.fetchWithToken(`/api/jobs/${encodeURIComponent(id)}`, {
+                method: 'DELETE'
+            });
+
+            if (response.ok) {
+                this.showAlert(`Job ${name} cancelled`, 'info');
+                this.loadJobs();
+            } else {
+                const data = await response.json();
+                this.showAlert(data.error || `Failed to cancel ${name}`, 'danger');
+            }
+        } catch (error) {
+            console.error('Error cancelling job:', error);
+            this.showAlert('Error cancelling job', 'danger');
+        }
+    }
+
+    async restartBot() {
+        try {
+            const response = await fetch('/api/restart', {
+                method: 'POST'
+            });
+            
+            if (response.ok) {
+                this.showAlert('Bot restart initiated', 'info');
+            } else {
+                this.showAlert('Failed to restart bot', 'danger');
+            }
+        } catch (error) {
+            console.error('Error restarting bot:', error);
+            this.showAlert('Error restarting bot', 'danger');
+        }
+    }
+
+    async stopBot() {
+        if (confirm('Are you sure you want to stop the bot?')) {
+            try {
+                const response = await fetch('/api/stop', {
+                    method: 'POST'
+                });
+                
+                if (response.ok) {
+                    this.showAlert('Bot stop initiated', 'warning');
+                } else {
+                    this.showAlert('Failed to stop bot', 'danger');
+                }
+            } catch (error) {
+                console.error('Error stopping bot:', error);
+                this.showAlert('Error stopping bot', 'danger');
+            }
+        }
+    }
+
+    refreshStatus() {
+        this.fetchBotStatus();
+        this.showAlert('Status refreshed', 'info');
+    }
+
+    async loadLogs() {
+        const container = document.getElementById('logs-container');
+        if (!container) return;
+
+        try {
+            const response = await fetch('/api/logs');
+            const entries = await response.json();
+            if (!response.ok) return;
+
+            // Only append entries newer than the last one shown
+            const fresh = entries.filter(entry => !this.lastLogTime || entry.time > this.lastLogTime);
+            if (fresh.length === 0) return;
+            this.lastLogTime = fresh[fresh.length - 1].time;
+
+            fresh.forEach(entry => this.renderLogEntry(container, entry));
+            this.updateLogCounts();
+            this.filterLogs();
+
+            const autoScroll = document.getElementById('auto-scroll');
+            if (autoScroll && autoScroll.checked) {
+                container.scrollTop = container.scrollHeight;
+            }
+        } catch (error) {
+            console.error('Error loading logs:', error);
+        }
+    }
+
+    renderLogEntry(container, entry) {
+        const level = entry.level.toLowerCase();
+        const row = document.createElement('div');
+        row.className = 'log-entry';
+        row.dataset.level = level;
+
+        const fields = Object.entries(entry.fields || {})
+            .map(([key, value]) => `${key}=${typeof value === 'string' ? value : JSON.stringify(value)}`)
+            .join(' ');
+        const parts = [
+            ['log-time', `[${new Date(entry.time).toLocaleTimeString()}]`],
+            ['log-level ' + (level === 'warn' ? 'warning' : level), `[${entry.level}]`],
+            ['log-subsystem text-muted', entry.subsystem ? `${entry.subsystem}:` : ''],
+            ['log-message', entry.msg],
+            ['log-fields text-muted', fields]
+        ];
+        // Log messages can contain user input, so they are set as text
+        parts.forEach(([className, text]) => {
+            const span = document.createElement('span');
+            span.className = className;
+            span.textContent = text;
+            row.appendChild(span);
+            row.appendChild(document.createTextNode(' '));
+        });
+        container.appendChild(row);
+    }
+
+    updateLogCounts() {
+        const counts = { info: 0, warn: 0, error: 0 };
+        const entries = document.querySelectorAll('.log-entry');
+        entries.forEach(entry => {
+            if (entry.dataset.level in counts) {
+                counts[entry.dataset.level]++;
+            }
+        });
+
+        const set = (id, value) => {
+            const element = document.getElementById(id);
+            if (element) element.textContent = value;
+        };
+        set('info-count', counts.info);
+        set('warning-count', counts.warn);
+        set('error-count', counts.error);
+        set('total-count', entries.length);
+    }
+
+    clearLogs() {
+        const container = document.getElementById('logs-container');
+        if (container) {
+            container.innerHTML = '';
+        }
+        this.updateLogCounts();
+        this.showAlert('Logs cleared', 'info');
+    }
+
+    downloadLogs() {
+        // In a real implementation, this would download the log file
+        this.showAlert('Log download started', 'info');
+    }
+
+    filterLogs() {
+        const search = document.getElementById('log-search');
+        const levelSelect = document.getElementById('log-level');
+        if (!search || !levelSelect) return;
+
+        const searchTerm = search.value.toLowerCase();
+        const logLevel = levelSelect.value;
+        const entries = document.querySelectorAll('.log-entry');
+
+        // Each option shows its level and everything more severe
+        const severity = { debug: 0, info: 1, warn: 2, warning: 2, error: 3 };
+
+        entries.forEach(entry => {
+            const message = entry.textContent.toLowerCase();
+            const level = entry.dataset.level || '';
+
+            const matchesSearch = !searchTerm || message.includes(searchTerm);
+            const matchesLevel = logLevel === 'all' || (severity[level] ?? 0) >= severity[logLevel];
+
+            entry.style.display = matchesSearch && matchesLevel ? 'block' : 'none';
+        });
+    }
+
+    applyFilters() {
+        this.filterLogs();
+        this.showAlert('Filters applied', 'info');
+    }
+
+    showCommandDetails(commandName) {
+        // In a real implementation, this would fetch and display command details
+        this.showAlert(`Showing details for command: ${commandName}`, 'info');
+    }
+
+    async showModuleDetails(moduleName) {
+        const container = document.getElementById('module-details');
+        if (!container) return;
+
+        try {
+            const response = await fetch(`/api/modules/${encodeURIComponent(moduleName)}`);
+            const module = await response.json();
+            if (!response.ok) {
+                this.showAlert(module.error || `Module ${moduleName} not found`, 'danger');
+                return;
+            }
+
+            container.innerHTML = `
+                <h5>${module.name} <span class="badge bg-info">${module.version}</span></h5>
+                <p class="mb-1"><strong>Status:</strong> ${module.status}</p>
+                <p class="mb-1"><strong>Since:</strong> ${new Date(module.since).toLocaleString()}</p>
+                <p class="mb-1"><strong>Restarts:</strong> ${module.restarts}</p>
+                ${module.error ? `<div class="alert alert-danger mt-2 mb-0">${module.error}</div>` : ''}
+            `;
+        } catch (error) {
+            console.error('Error loading module details:', error);
+            this.showAlert('Error loading module details', 'danger');
+        }
+    }
+
+    async moduleAction(moduleName, action) {
+        try {
+            const response = await this.fetchWithToken(`/api/modules/${encodeURIComponent(moduleName)}/${action}`, {
+                method: 'POST'
+            });
+            const data = await response.json();
+
+            if (response.ok) {
+                this.showAlert(`Module ${moduleName}: ${data.status}`, 'success');
+                setTimeout(() => window.location.reload(), 1000);
+            } else {
+                this.showAlert(data.error || `Failed to ${action} module ${moduleName}`, 'danger');
+            }
+        } catch (error) {
+            console.error(`Error during module ${action}:`, error);
+            this.showAlert(`Error during module ${action}`, 'danger');
+        }
+    }
+
+    restartModule(moduleName) {
+        if (confirm(`Are you sure you want to restart the ${moduleName} module?`)) {
+            this.moduleAction(moduleName, 'restart');
+        }
+    }
+
+    stopModule(moduleName) {
+        if (confirm(`Are you sure you want to stop the ${moduleName} module?`)) {
+            this.moduleAction(moduleName, 'stop');
+        }
+    }
+
+    startModule(moduleName) {
+        this.moduleAction(moduleName, 'start');
+    }
+
+    refreshCommands() {
+        const commandGuild = document.getElementById('command-guild');
+        if (commandGuild) {
+            this.loadCustomCommands(commandGuild.value);
+        }
+    }
+
+    showAlert(message, type) {
+        const alertDiv = document.createElement('div');
+        alertDiv.className = `alert alert-${type} alert-dismissible fade show`;
+        alertDiv.innerHTML = `
+            ${message}
+            <button type="button" class="btn-close" data-bs-dismiss="alert"></button>
+        `;
+        
+        // Insert at the top of the container
+        const container = document.querySelector('.container-fluid');
+        if (container) {
+            container.insertBefore(alertDiv, container.firstChild);
+            
+            // Auto-dismiss after 5 seconds
+            setTimeout(() => {
+                if (alertDiv.parentNode) {
+                    alertDiv.remove();
+                }
+            }, 5000);
+        }
+    }
+}
+
+// Global functions for HTML onclick handlers
+function restartBot() {
+    window.discordBotForge.restartBot();
+}
+
+function stopBot() {
+    window.discordBotForge.stopBot();
+}
+
+function refreshStatus() {
+    window.discordBotForge.refreshStatus();
+}
+
+function clearLogs() {
+    window.discordBotForge.clearLogs();
+}
+
+function downloadLogs() {
+    window.discordBotForge.downloadLogs();
+}
+
+function applyFilters() {
+    window.discordBotForge.applyFilters();
+}
+
+function showCommandDetails(commandName) {
+    window.discordBotForge.showCommandDetails(commandName);
+}
+
+function showModuleDetails(moduleName) {
+    window.discordBotForge.showModuleDetails(moduleName);
+}
+
+function restartModule(moduleName) {
+    window.discordBotForge.restartModule(moduleName);
+}
+
+function stopModule(moduleName) {
+    window.discordBotForge.stopModule(moduleName);
+}
+
+function startModule(moduleName) {
+    window.discordBotForge.startModule(moduleName);
+}
+
+function refreshCommands() {
+    window.discordBotForge.refreshCommands();
+}
+
+function loadJobs() {
+    window.discordBotForge.loadJobs();
+}
+
+// Initialize the application when DOM is loaded
+document.addEventListener('DOMContentLoaded', () => {
+    window.discordBotForge = new DiscordBotForge();
+});